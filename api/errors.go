@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when the object a script works on does not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrAccessDenied is returned when the remote user is not allowed to perform an operation
+	ErrAccessDenied = errors.New("access denied")
+
+	// ErrInUse is returned when an object is locked by another process or virtual machine
+	ErrInUse = errors.New("in use")
+)
+
+// hResults of the win32 errors that mean a file is in use
+const (
+	hResultSharingViolation = -2147024864 // 0x80070020
+	hResultLockViolation    = -2147024863 // 0x80070021
+	hResultAccessDenied     = -2147024891 // 0x80070005
+)
+
+// Error is a failure reported by a PowerShell ErrorRecord on the Hyper-V host. Use errors.Is with ErrNotFound,
+// ErrAccessDenied or ErrInUse to find out why it failed.
+type Error struct {
+	Kind                  error
+	Category              string
+	FullyQualifiedErrorId string
+	TargetName            string
+	Message               string
+	Err                   error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+
+	return []error{e.Kind, e.Err}
+}
+
+// ToErrorKind works out which of ErrNotFound, ErrAccessDenied or ErrInUse an ErrorRecord represents. It returns nil
+// when the ErrorRecord doesn't match any of them.
+func ToErrorKind(category string, fullyQualifiedErrorId string, exceptionType string, hResult int) error {
+	errorId := strings.ToLower(strings.SplitN(fullyQualifiedErrorId, ",", 2)[0])
+	exceptionType = exceptionType[strings.LastIndex(exceptionType, ".")+1:]
+
+	switch {
+	case category == "ObjectNotFound",
+		strings.HasSuffix(errorId, "notfound"),
+		exceptionType == "ItemNotFoundException",
+		exceptionType == "FileNotFoundException",
+		exceptionType == "DirectoryNotFoundException":
+		return ErrNotFound
+	case category == "PermissionDenied",
+		category == "SecurityError",
+		errorId == "unauthorizedaccess",
+		strings.HasSuffix(errorId, "accessdenied"),
+		exceptionType == "UnauthorizedAccessException",
+		hResult == hResultAccessDenied:
+		return ErrAccessDenied
+	case category == "ResourceBusy",
+		strings.HasSuffix(errorId, "inuse"),
+		hResult == hResultSharingViolation,
+		hResult == hResultLockViolation:
+		return ErrInUse
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestToErrorKind(t *testing.T) {
+	testCases := []struct {
+		category              string
+		fullyQualifiedErrorId string
+		exceptionType         string
+		hResult               int
+		expected              error
+	}{
+		{"ObjectNotFound", "VmNotFound", "Microsoft.PowerShell.Commands.WriteErrorException", 0, ErrNotFound},
+		{"InvalidArgument", "InvalidParameter,Microsoft.HyperV.PowerShell.Commands.GetVM", "", 0, nil},
+		{"ObjectNotFound", "PathNotFound,Microsoft.PowerShell.Commands.GetItemCommand", "System.Management.Automation.ItemNotFoundException", 0, ErrNotFound},
+		{"NotSpecified", "System.IO.FileNotFoundException", "System.IO.FileNotFoundException", -2147024894, ErrNotFound},
+		{"PermissionDenied", "AccessDenied,Microsoft.HyperV.PowerShell.Commands.StartVM", "", 0, ErrAccessDenied},
+		{"NotSpecified", "UnauthorizedAccess", "System.UnauthorizedAccessException", hResultAccessDenied, ErrAccessDenied},
+		{"ResourceBusy", "ObjectInUse,Microsoft.Vhd.PowerShell.Cmdlets.ResizeVhd", "", 0, ErrInUse},
+		{"WriteError", "RemoveFileSystemItemIOError,Microsoft.PowerShell.Commands.RemoveItemCommand", "System.IO.IOException", hResultSharingViolation, ErrInUse},
+	}
+
+	for _, testCase := range testCases {
+		kind := ToErrorKind(testCase.category, testCase.fullyQualifiedErrorId, testCase.exceptionType, testCase.hResult)
+		if kind != testCase.expected {
+			t.Errorf("Expected %s to be %v but was %v", testCase.fullyQualifiedErrorId, testCase.expected, kind)
+		}
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	scriptError := errors.New("run command operation returned code=1")
+	err := error(&Error{Kind: ErrNotFound, Category: "ObjectNotFound", FullyQualifiedErrorId: "VmNotFound", Err: scriptError})
+
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, scriptError) {
+		t.Errorf("Expected error to match its kind and the script error: %v", err)
+	}
+
+	if errors.Is(err, ErrAccessDenied) || errors.Is(err, ErrInUse) {
+		t.Errorf("Expected error to only match its own kind: %v", err)
+	}
+
+	if err.Error() != scriptError.Error() {
+		t.Errorf("Expected the script error message: %s", err.Error())
+	}
+}
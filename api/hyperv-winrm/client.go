@@ -1,6 +1,10 @@
 package hyperv_winrm
 
 import (
+	"context"
+	"log"
+	"sync"
+
 	"github.com/taliesins/terraform-provider-hyperv/api"
 	winrm_helper "github.com/taliesins/terraform-provider-hyperv/api/winrm-helper"
 )
@@ -13,4 +17,47 @@ func New(clientConfig *ClientConfig) (*api.Provider, error) {
 
 type ClientConfig struct {
 	WinRmClient winrm_helper.Client
+
+	// MaxHeavyOperations limits how many heavy operations, like downloading or converting a vhd and building an iso,
+	// run against the host at once so that lighter calls keep flowing. 0 doesn't limit them.
+	MaxHeavyOperations int
+
+	// Host is the Hyper-V host the client manages
+	Host string
+	// NewHostClient creates a client for another Hyper-V host, it is used to manage vms that are placed or migrated
+	// to a host other than the provider host. Nil doesn't allow other hosts.
+	NewHostClient func(host string) (api.Client, error)
+
+	heavyOperationsOnce sync.Once
+	heavyOperations     chan struct{}
+
+	hostClientsMutex sync.Mutex
+	hostClients      map[string]api.Client
+}
+
+// acquireHeavyOperation waits for a heavy operation slot. The returned func must be called to release the slot.
+func (c *ClientConfig) acquireHeavyOperation(ctx context.Context, operation string) (release func(), err error) {
+	if c.MaxHeavyOperations <= 0 {
+		return func() {}, nil
+	}
+
+	c.heavyOperationsOnce.Do(func() {
+		c.heavyOperations = make(chan struct{}, c.MaxHeavyOperations)
+	})
+
+	select {
+	case c.heavyOperations <- struct{}{}:
+	default:
+		log.Printf("[DEBUG] Waiting for one of %d heavy operations to finish before running %s", c.MaxHeavyOperations, operation)
+
+		select {
+		case c.heavyOperations <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return func() {
+		<-c.heavyOperations
+	}, nil
 }
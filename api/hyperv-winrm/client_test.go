@@ -0,0 +1,44 @@
+package hyperv_winrm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHeavyOperationsAreLimited(t *testing.T) {
+	c := &ClientConfig{MaxHeavyOperations: 1}
+
+	release, err := c.acquireHeavyOperation(context.Background(), "CreateOrUpdateVhd")
+	if err != nil {
+		t.Fatalf("Unable to acquire heavy operation: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = c.acquireHeavyOperation(ctx, "CreateOrUpdateIsoImage")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected second heavy operation to wait: %v", err)
+	}
+
+	release()
+
+	release, err = c.acquireHeavyOperation(context.Background(), "CreateOrUpdateIsoImage")
+	if err != nil {
+		t.Errorf("Expected released heavy operation slot to be reused: %v", err)
+	}
+	release()
+}
+
+func TestHeavyOperationsAreUnlimitedByDefault(t *testing.T) {
+	c := &ClientConfig{}
+
+	for i := 0; i < 10; i++ {
+		_, err := c.acquireHeavyOperation(context.Background(), "CreateOrUpdateVhd")
+		if err != nil {
+			t.Fatalf("Unable to acquire heavy operation: %s", err.Error())
+		}
+	}
+}
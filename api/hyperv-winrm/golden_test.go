@@ -0,0 +1,802 @@
+package hyperv_winrm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+	winrm_helper "github.com/taliesins/terraform-provider-hyperv/api/winrm-helper"
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+var boundArgumentsRegex = regexp.MustCompile(`^\$arguments = .*FromBase64String\('([A-Za-z0-9+/=]*)'\).*\n`)
+
+type goldenTestCase struct {
+	name      string
+	responses map[string]string
+	call      func(ctx context.Context, c *ClientConfig) (interface{}, error)
+}
+
+func goldenTestCases() []goldenTestCase {
+	vmNetworkAdapter := api.VmNetworkAdapter{
+		Name:               "wan",
+		SwitchName:         "external",
+		DynamicMacAddress:  true,
+		MacAddressSpoofing: api.OnOffState_Off,
+		DhcpGuard:          api.OnOffState_Off,
+		RouterGuard:        api.OnOffState_Off,
+		PortMirroring:      api.PortMirroring_None,
+		IeeePriorityTag:    api.OnOffState_Off,
+		VmqWeight:          100,
+		AllowTeaming:       api.OnOffState_Off,
+		DeviceNaming:       api.OnOffState_Off,
+		FixSpeed10G:        api.OnOffState_Off,
+		MandatoryFeatureId: []string{},
+		VlanAccess:         true,
+		VlanId:             42,
+	}
+
+	vmHardDiskDrive := api.VmHardDiskDrive{
+		ControllerType:          api.ControllerType_Scsi,
+		ControllerNumber:        0,
+		ControllerLocation:      1,
+		Path:                    `C:\vhd\web.vhdx`,
+		OverrideCacheAttributes: api.CacheAttributes_Default,
+	}
+
+	vmDvdDrive := api.VmDvdDrive{
+		ControllerNumber:   0,
+		ControllerLocation: 2,
+		Path:               `C:\iso\cloud-init.iso`,
+	}
+
+	return []goldenTestCase{
+		{
+			name:      "VhdExists",
+			responses: map[string]string{"ExistsVhd": `{"Exists":true}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.VhdExists(ctx, `C:\vhd\web.vhdx`)
+			},
+		},
+		{
+			name: "CreateOrUpdateVhd",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVhd(ctx, `C:\vhd\web.vhdx`, "", "", 0, api.VhdType_Dynamic, "", 10737418240, 0, 0, 0)
+			},
+		},
+		{
+			name: "ResizeVhd",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.ResizeVhd(ctx, `C:\vhd\web.vhdx`, 21474836480)
+			},
+		},
+		{
+			name:      "GetVhd",
+			responses: map[string]string{"GetVhd": `{"Path":"C:\\vhd\\web.vhdx","VhdType":3,"Size":10737418240,"BlockSize":33554432,"LogicalSectorSize":512,"PhysicalSectorSize":4096}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVhd(ctx, `C:\vhd\web.vhdx`)
+			},
+		},
+		{
+			name: "DeleteVhd",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.DeleteVhd(ctx, `C:\vhd\web.vhdx`)
+			},
+		},
+		{
+			name:      "VmExists",
+			responses: map[string]string{"ExistsVm": `{"Exists":false}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.VmExists(ctx, "web")
+			},
+		},
+		{
+			name: "CreateVm",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateVm(ctx, "web", `C:\vm`, 2, api.CriticalErrorAction_Pause, 30, api.StartAction_StartIfRunning, 0, api.StopAction_Save, api.CheckpointType_Production, true, false, 536870912, api.OnOffState_Off, 134217728, 4294967296, 536870912, 1073741824, "web server", 2, "", "", false)
+			},
+		},
+		{
+			name:      "GetVm",
+			responses: map[string]string{"GetVm": `{"Name":"web","Path":"C:\\vm","Generation":2,"AutomaticCriticalErrorAction":1,"AutomaticStartAction":3,"AutomaticStopAction":3,"CheckpointType":3,"DynamicMemory":true,"MemoryStartupBytes":1073741824,"ProcessorCount":2}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVm(ctx, "web")
+			},
+		},
+		{
+			name: "UpdateVm",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.UpdateVm(ctx, "web", api.CriticalErrorAction_Pause, 30, api.StartAction_StartIfRunning, 0, api.StopAction_Save, api.CheckpointType_Production, false, false, 536870912, api.OnOffState_Off, 134217728, 1073741824, 1073741824, 1073741824, "web server", 4, "", "", true)
+			},
+		},
+		{
+			name: "DeleteVm",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.DeleteVm(ctx, "web")
+			},
+		},
+		{
+			name: "CreateVmDvdDrive",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateVmDvdDrive(ctx, "web", 0, 2, `C:\iso\cloud-init.iso`, "")
+			},
+		},
+		{
+			name:      "GetVmDvdDrives",
+			responses: map[string]string{"GetVmDvdDrives": `[{"ControllerNumber":0,"ControllerLocation":2,"Path":"C:\\iso\\cloud-init.iso"}]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmDvdDrives(ctx, "web")
+			},
+		},
+		{
+			name: "UpdateVmDvdDrive",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.UpdateVmDvdDrive(ctx, "web", 0, 2, 0, 3, `C:\iso\cloud-init.iso`, "")
+			},
+		},
+		{
+			name: "DeleteVmDvdDrive",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.DeleteVmDvdDrive(ctx, "web", 0, 2)
+			},
+		},
+		{
+			name: "CreateOrUpdateVmDvdDrives",
+			responses: map[string]string{
+				"GetVmDvdDrives":       `[{"ControllerNumber":0,"ControllerLocation":2,"Path":""},{"ControllerNumber":0,"ControllerLocation":3,"Path":""}]`,
+				"GetVm":                `{"Name":"web","Generation":2}`,
+				"GetVmScsiControllers": `[{"ControllerNumber":0,"DriveCount":2}]`,
+			},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmDvdDrives(ctx, "web", []api.VmDvdDrive{vmDvdDrive})
+			},
+		},
+		{
+			name: "CreateOrUpdateVmBios",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmBios(ctx, "appliance", []api.Gen1BootType{
+					api.Gen1BootType_IDE,
+					api.Gen1BootType_CD,
+					api.Gen1BootType_LegacyNetworkAdapter,
+					api.Gen1BootType_Floppy,
+				}, true)
+			},
+		},
+		{
+			name:      "GetVmBios",
+			responses: map[string]string{"GetVmBios": `{"StartupOrder":["IDE","CD","LegacyNetworkAdapter","Floppy"],"NumLockEnabled":true}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmBios(ctx, "appliance")
+			},
+		},
+		{
+			name: "UpdateVmComPort",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.UpdateVmComPort(ctx, "appliance", 1, `\\.\pipe\appliance-com1`, api.OnOffState_On)
+			},
+		},
+		{
+			name:      "GetVmComPorts",
+			responses: map[string]string{"GetVmComPorts": `[{"Number":1,"Path":"\\\\.\\pipe\\appliance-com1","DebuggerMode":0},{"Number":2,"Path":"","DebuggerMode":1}]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmComPorts(ctx, "appliance")
+			},
+		},
+		{
+			name:      "CreateOrUpdateVmComPorts",
+			responses: map[string]string{"GetVmComPorts": `[{"Number":1,"Path":"","DebuggerMode":1},{"Number":2,"Path":"\\\\.\\pipe\\old-com2","DebuggerMode":1}]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmComPorts(ctx, "appliance", []api.VmComPort{
+					{Number: 1, Path: `\\.\pipe\appliance-com1`, DebuggerMode: api.OnOffState_Off},
+				})
+			},
+		},
+		{
+			name: "CreateOrUpdateVmFirmware",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmFirmware(ctx, "web", []api.Gen2BootOrder{
+					{Type: api.Gen2BootType_HardDiskDrive, Path: `C:\vhd\web.vhdx`, ControllerNumber: 0, ControllerLocation: 1},
+					{Type: api.Gen2BootType_NetworkAdapter, NetworkAdapterName: "wan", SwitchName: "external"},
+				}, api.OnOffState_On, "MicrosoftUEFICertificateAuthority", api.IPProtocolPreference_IPv4, api.ConsoleModeType_Default, api.OnOffState_On, true, api.VmKeyProtectorType_HgsGuardian, "web-guardian", true, false, false)
+			},
+		},
+		{
+			name:      "GetVmFirmware",
+			responses: map[string]string{"GetVmFirmware": `{"BootOrders":[{"Type":"HardDiskDrive","Path":"C:\\vhd\\web.vhdx","ControllerNumber":0,"ControllerLocation":1}],"EnableSecureBoot":0,"SecureBootTemplate":"MicrosoftUEFICertificateAuthority","PreferredNetworkBootProtocol":0,"ConsoleMode":0,"PauseAfterBootFailure":1,"TpmEnabled":true,"KeyProtector":"HgsGuardian","HgsGuardianName":"web-guardian","EncryptStateAndVmMigrationTraffic":true,"Shielded":false,"VirtualizationBasedSecurityOptOut":false}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmFirmware(ctx, "web")
+			},
+		},
+		{
+			name: "CreateVmHardDiskDrive",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateVmHardDiskDrive(ctx, "web", api.ControllerType_Scsi, 0, 1, `C:\vhd\web.vhdx`, 0, "", false, 0, 0, "", api.CacheAttributes_Default)
+			},
+		},
+		{
+			name:      "GetVmHardDiskDrives",
+			responses: map[string]string{"GetVmHardDiskDrives": `[{"ControllerType":1,"ControllerNumber":0,"ControllerLocation":1,"Path":"C:\\vhd\\web.vhdx"}]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmHardDiskDrives(ctx, "web")
+			},
+		},
+		{
+			name: "UpdateVmHardDiskDrive",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.UpdateVmHardDiskDrive(ctx, "web", 0, 1, api.ControllerType_Scsi, 0, 2, `C:\vhd\web.vhdx`, 0, "", false, 1000, 100, "", api.CacheAttributes_WriteCacheEnabled)
+			},
+		},
+		{
+			name: "DeleteVmHardDiskDrive",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.DeleteVmHardDiskDrive(ctx, "web", 0, 1)
+			},
+		},
+		{
+			name: "CreateOrUpdateVmHardDiskDrives",
+			responses: map[string]string{
+				"GetVmHardDiskDrives":  `[]`,
+				"GetVmScsiControllers": `[{"ControllerNumber":0,"DriveCount":0}]`,
+			},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmHardDiskDrives(ctx, "web", []api.VmHardDiskDrive{vmHardDiskDrive})
+			},
+		},
+		{
+			name:      "CreateOrUpdateVmScsiControllersAddsControllers",
+			responses: map[string]string{"GetVmScsiControllers": `[{"ControllerNumber":0,"DriveCount":1}]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmScsiControllers(ctx, "web", []int{0, 2})
+			},
+		},
+		{
+			name:      "CreateOrUpdateVmScsiControllersRemovesTrailingEmptyControllers",
+			responses: map[string]string{"GetVmScsiControllers": `[{"ControllerNumber":0,"DriveCount":1},{"ControllerNumber":1,"DriveCount":0},{"ControllerNumber":2,"DriveCount":0},{"ControllerNumber":3,"DriveCount":0}]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmScsiControllers(ctx, "web", []int{0, 1})
+			},
+		},
+		{
+			name:      "GetVmIntegrationServices",
+			responses: map[string]string{"GetVmIntegrationServices": `[{"Name":"Guest Service Interface","Enabled":false},{"Name":"Heartbeat","Enabled":true}]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmIntegrationServices(ctx, "web")
+			},
+		},
+		{
+			name: "EnableVmIntegrationService",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.EnableVmIntegrationService(ctx, "web", "Guest Service Interface")
+			},
+		},
+		{
+			name: "DisableVmIntegrationService",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.DisableVmIntegrationService(ctx, "web", "Heartbeat")
+			},
+		},
+		{
+			name: "CreateOrUpdateVmKvpItem",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmKvpItem(ctx, "web", "Role", "frontend")
+			},
+		},
+		{
+			name:      "RunVmGuestScript",
+			responses: map[string]string{"RunVmGuestScript": `{"ExitCode":0,"Stdout":"web\r\n","Stderr":""}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.RunVmGuestScript(ctx, "web", `web\Administrator`, "P@ssw0rd", api.JoinVmGuestScriptInline([]string{"hostname", "exit 0"}))
+			},
+		},
+		{
+			name:      "GetVmKvpItems",
+			responses: map[string]string{"GetVmKvpItems": `[{"Name":"Role","Data":"frontend"}]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmKvpItems(ctx, "web")
+			},
+		},
+		{
+			name: "DeleteVmKvpItem",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.DeleteVmKvpItem(ctx, "web", "Role")
+			},
+		},
+		{
+			name:      "GetVmGuestKvpItems",
+			responses: map[string]string{"GetVmGuestKvpItems": `{"IntrinsicItems":{"FullyQualifiedDomainName":"web.example.com","OSName":"Windows Server 2022 Datacenter","OSVersion":"10.0.20348","NetworkAddressIPv4":"10.0.0.5;192.168.1.10"},"Items":{"Role":"frontend"}}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmGuestKvpItems(ctx, "web")
+			},
+		},
+		{
+			name: "CreateOrUpdateVmIntegrationServices",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmIntegrationServices(ctx, "web", []api.VmIntegrationService{
+					{Name: "Guest Service Interface", Enabled: true},
+					{Name: "Heartbeat", Enabled: false},
+				})
+			},
+		},
+		{
+			name:      "CreateVmNetworkAdapter",
+			responses: map[string]string{"GetVmNetworkAdapters": `[]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmNetworkAdapters(ctx, "web", []api.VmNetworkAdapter{vmNetworkAdapter})
+			},
+		},
+		{
+			name:      "GetVmNetworkAdapters",
+			responses: map[string]string{"GetVmNetworkAdapters": `[{"Index":0,"Name":"wan","SwitchName":"external","DynamicMacAddress":true,"VlanAccess":true,"VlanId":42,"IpAddresses":["10.0.0.5"]}]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmNetworkAdapters(ctx, "web", []api.VmNetworkAdapterWaitForIp{{Name: "wan", WaitForIps: true}})
+			},
+		},
+		{
+			name: "WaitForVmNetworkAdaptersIps",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.WaitForVmNetworkAdaptersIps(ctx, "web", 300, 2, []api.VmNetworkAdapterWaitForIp{{Name: "wan", WaitForIps: true}})
+			},
+		},
+		{
+			name:      "UpdateVmNetworkAdapter",
+			responses: map[string]string{"GetVmNetworkAdapters": `[{"Index":0,"Name":"lan"}]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmNetworkAdapters(ctx, "web", []api.VmNetworkAdapter{vmNetworkAdapter})
+			},
+		},
+		{
+			name: "DeleteVmNetworkAdapter",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.DeleteVmNetworkAdapter(ctx, "web", 1)
+			},
+		},
+		{
+			name:      "CreateOrUpdateVmNetworkAdapters",
+			responses: map[string]string{"GetVmNetworkAdapters": `[{"Index":0,"Name":"wan"},{"Index":1,"Name":"lan"}]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmNetworkAdapters(ctx, "web", []api.VmNetworkAdapter{vmNetworkAdapter})
+			},
+		},
+		{
+			name: "CreateOrUpdateVmProcessor",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmProcessor(ctx, "web", false, false, 0, 100, 0, 100, 0, 0, false, true)
+			},
+		},
+		{
+			name:      "GetVmProcessor",
+			responses: map[string]string{"GetVmProcessor": `{"Maximum":100,"RelativeWeight":100,"ExposeVirtualizationExtensions":true}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmProcessor(ctx, "web")
+			},
+		},
+		{
+			name: "CreateOrUpdateVmMemory",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateVmMemory(ctx, "web", 20, 80, 4294967296)
+			},
+		},
+		{
+			name:      "GetVmMemory",
+			responses: map[string]string{"GetVmMemory": `{"Buffer":20,"Priority":80,"MaximumAmountPerNumaNodeBytes":4294967296,"HostNumaSpanning":"Off"}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmMemory(ctx, "web")
+			},
+		},
+		{
+			name:      "GetVmStatus",
+			responses: map[string]string{"GetVmStatus": `{"State":2}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmStatus(ctx, "web")
+			},
+		},
+		{
+			name: "UpdateVmStatus",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.UpdateVmStatus(ctx, "web", 300, 2, api.VmState_Running)
+			},
+		},
+		{
+			name:      "VMSwitchExists",
+			responses: map[string]string{"ExistsVMSwitch": `{"Exists":true}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.VMSwitchExists(ctx, "external")
+			},
+		},
+		{
+			name: "CreateVMSwitch",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateVMSwitch(ctx, "external", "uplink", true, false, false, false, api.VMSwitchBandwidthMode_Weight, api.VMSwitchType_External, []string{"Ethernet"}, 0, 10, false, 16, false)
+			},
+		},
+		{
+			name:      "GetVMSwitch",
+			responses: map[string]string{"GetVMSwitch": `{"Name":"external","Notes":"uplink","AllowManagementOS":true,"BandwidthReservationMode":1,"SwitchType":2,"NetAdapterNames":["Ethernet"]}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVMSwitch(ctx, "external")
+			},
+		},
+		{
+			name: "UpdateVMSwitch",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.UpdateVMSwitch(ctx, "external", "uplink", "renamed", true, api.VMSwitchType_External, []string{"Ethernet"}, 0, 10, false, 16, false)
+			},
+		},
+		{
+			name: "DeleteVMSwitch",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.DeleteVMSwitch(ctx, "external")
+			},
+		},
+		{
+			name: "CreateOrUpdateIsoImage",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateOrUpdateIsoImage(ctx, "", "", "bootstrap.zip", "abc123", "", "", "", `$env:TEMP\bootstrap.zip`, "", api.IsoMediaType_DVDPLUSRW_DUALLAYER, api.IsoFileSystemType_Joliet, "BOOTSTRAP", `C:\iso\bootstrap.iso`, `$env:TEMP\bootstrap.zip`, "", "", nil)
+			},
+		},
+		{
+			name: "CreateOrUpdateIsoImageWithFiles",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				files, err := api.IsoImageFiles("#cloud-config\nhostname: web\n", "instance-id: web\n", "", nil)
+				if err != nil {
+					return nil, err
+				}
+
+				return nil, c.CreateOrUpdateIsoImage(ctx, "", "", "", "", "", "", "", "", "", api.IsoMediaType_DVDPLUSRW_DUALLAYER, api.IsoFileSystemType_Unknown, api.IsoImageCloudInitVolumeName, `C:\iso\cloud-init.iso`, "", "", api.IsoImageFilesHash(files), files)
+			},
+		},
+		{
+			name:      "GetIsoImage",
+			responses: map[string]string{"GetIsoImage": `{"SourceZipFilePath":"bootstrap.zip","SourceZipFilePathHash":"abc123","Media":"dvdplusrw_duallayer","FileSystem":"Joliet","VolumeName":"BOOTSTRAP","ResolveDestinationIsoFilePath":"C:\\iso\\bootstrap.iso"}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetIsoImage(ctx, `C:\iso\bootstrap.iso`)
+			},
+		},
+		{
+			name:      "RemoveTemporaryItems",
+			responses: map[string]string{"RemoveTemporaryItems": `["C:\\Temp\\shell-terraform-hyperv-2CGEKPCIDjXFIZVw3Ghq6O2OJf5.ps1","Task Scheduler Library\\terraform-hyperv-2CGEKPCIDjXFIZVw3Ghq6O2OJf5"]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.RemoveTemporaryItems(ctx, 24*time.Hour, "2CGEKQ6uYq0jr0RZ8SnyYQ7Wt7N")
+			},
+		},
+		{
+			name: "CreateVmCheckpoint",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.CreateVmCheckpoint(ctx, "web", "baseline")
+			},
+		},
+		{
+			name:      "GetVmCheckpoint",
+			responses: map[string]string{"GetVmCheckpoint": `{"VmName":"web","Name":"baseline","Id":"5c0cbd8e-7a4d-4a8c-9f0e-2f4b8f4a1d3e","ParentCheckpointName":"","CreationTime":"2023-06-01T10:00:00.0000000Z","CheckpointType":"Standard"}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmCheckpoint(ctx, "web", "baseline")
+			},
+		},
+		{
+			name: "RenameVmCheckpoint",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.RenameVmCheckpoint(ctx, "web", "baseline", "clean")
+			},
+		},
+		{
+			name: "RestoreVmCheckpoint",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.RestoreVmCheckpoint(ctx, "web", "baseline")
+			},
+		},
+		{
+			name: "DeleteVmCheckpoint",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.DeleteVmCheckpoint(ctx, "web", "baseline", false)
+			},
+		},
+		{
+			name: "ImportVm",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.ImportVm(ctx, "web", `D:\exports\golden`, api.VmImportMode_GenerateNewId, 2, `D:\vms`, `D:\vhd\web`, "", "")
+			},
+		},
+		{
+			name: "ExportVm",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.ExportVm(ctx, "golden", `D:\exports`, "baseline")
+			},
+		},
+		{
+			name:      "GetVmExport",
+			responses: map[string]string{"GetVmExport": `{"VmName":"golden","Path":"D:\\exports","ExportPath":"D:\\exports\\golden","VmcxPath":"D:\\exports\\golden\\Virtual Machines\\5C0CBD8E-7A4D-4A8C-9F0E-2F4B8F4A1D3E.vmcx"}`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmExport(ctx, "golden", `D:\exports`)
+			},
+		},
+		{
+			name: "DeleteVmExport",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.DeleteVmExport(ctx, "golden", `D:\exports`)
+			},
+		},
+		{
+			name: "MoveVmStorage",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.MoveVmStorage(ctx, "web", 1800, 2, api.VmStorageMove{
+					VirtualMachinePath:  `E:\vms\web`,
+					SnapshotFilePath:    `E:\snapshots`,
+					SmartPagingFilePath: `E:\paging`,
+					Vhds: []api.VhdMove{
+						{SourceFilePath: `C:\vhd\web.vhdx`, DestinationFilePath: `E:\vhd\web.vhdx`},
+					},
+				})
+			},
+		},
+		{
+			name:      "GetVmMigrationIncompatibilities",
+			responses: map[string]string{"GetVmMigrationIncompatibilities": `[{"MessageId":21014,"Message":"The virtual machine is using processor-specific features not supported on host 'HV02'."}]`},
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return c.GetVmMigrationIncompatibilities(ctx, "web", "HV02", true, api.VmStorageMove{
+					VirtualMachinePath: `E:\vms\web`,
+				})
+			},
+		},
+		{
+			name: "MoveVm",
+			call: func(ctx context.Context, c *ClientConfig) (interface{}, error) {
+				return nil, c.MoveVm(ctx, "web", "HV02", true, 3600, 2, api.VmStorageMove{
+					Vhds: []api.VhdMove{
+						{SourceFilePath: `C:\vhd\web.vhdx`, DestinationFilePath: `E:\vhd\web.vhdx`},
+					},
+				})
+			},
+		},
+	}
+}
+
+// formatArguments renders the arguments bound into a script as indented json. Json encoded arguments are expanded so
+// that changes to them show up as readable diffs.
+func formatArguments(t *testing.T, argumentsJson []byte) string {
+	arguments := map[string]interface{}{}
+	err := json.Unmarshal(argumentsJson, &arguments)
+	if err != nil {
+		t.Fatalf("Unable to deserialize arguments: %s", err.Error())
+	}
+
+	for name, value := range arguments {
+		if stringValue, ok := value.(string); ok && strings.HasSuffix(name, "Json") {
+			var expandedValue interface{}
+			if json.Unmarshal([]byte(stringValue), &expandedValue) == nil {
+				arguments[name] = expandedValue
+			}
+		}
+	}
+
+	formattedArguments, err := json.MarshalIndent(arguments, "", "  ")
+	if err != nil {
+		t.Fatalf("Unable to serialize arguments: %s", err.Error())
+	}
+
+	return string(formattedArguments)
+}
+
+func formatGolden(t *testing.T, scripts []winrm_helper.FakeScript, result interface{}) string {
+	var golden bytes.Buffer
+
+	for _, script := range scripts {
+		match := boundArgumentsRegex.FindStringSubmatch(script.Script)
+		if match == nil {
+			t.Fatalf("Script %s does not bind $arguments: %s", script.Name, script.Script)
+		}
+
+		argumentsJson, err := base64.StdEncoding.DecodeString(match[1])
+		if err != nil {
+			t.Fatalf("Unable to decode arguments for %s: %s", script.Name, err.Error())
+		}
+
+		fmt.Fprintf(&golden, "=== %s\n--- arguments\n%s\n--- script%s\n", script.Name, formatArguments(t, argumentsJson), strings.TrimPrefix(script.Script, match[0]+powershell.ErrorRecordTrap))
+	}
+
+	if result != nil {
+		formattedResult, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			t.Fatalf("Unable to serialize result: %s", err.Error())
+		}
+
+		fmt.Fprintf(&golden, "=== result\n%s\n", formattedResult)
+	}
+
+	return golden.String()
+}
+
+func TestClientConfigGolden(t *testing.T) {
+	for _, testCase := range goldenTestCases() {
+		t.Run(testCase.name, func(t *testing.T) {
+			fakeClient := winrm_helper.NewFakeClient()
+			for name, response := range testCase.responses {
+				fakeClient.Respond(name, response, nil)
+			}
+
+			result, err := testCase.call(context.Background(), &ClientConfig{WinRmClient: fakeClient})
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+
+			actual := formatGolden(t, fakeClient.Scripts(), result)
+			goldenPath := filepath.Join("testdata", testCase.name+".golden")
+
+			if *updateGolden {
+				err = os.WriteFile(goldenPath, []byte(actual), 0644)
+				if err != nil {
+					t.Fatalf("Unable to write golden file: %s", err.Error())
+				}
+			}
+
+			expected, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("Unable to read golden file, run go test with -update to create it: %s", err.Error())
+			}
+
+			if actual != string(expected) {
+				t.Errorf("Script for %s does not match %s, run go test with -update and review the diff:\n%s", testCase.name, goldenPath, actual)
+			}
+		})
+	}
+}
+
+func TestClientConfigPropagatesScriptErrors(t *testing.T) {
+	fakeClient := winrm_helper.NewFakeClient()
+	fakeClient.Respond("GetVmHardDiskDrives", "", errors.New("VM not found"))
+	c := &ClientConfig{WinRmClient: fakeClient}
+
+	err := c.CreateOrUpdateVmHardDiskDrives(context.Background(), "web", []api.VmHardDiskDrive{{Path: `C:\vhd\web.vhdx`}})
+	if err == nil || err.Error() != "VM not found" {
+		t.Errorf("Expected script error to be returned: %v", err)
+	}
+
+	if scripts := fakeClient.Scripts(); len(scripts) != 1 {
+		t.Errorf("Expected no scripts to run after a failure: %#v", scripts)
+	}
+}
+
+func TestClientConfigReturnsNotFound(t *testing.T) {
+	fakeClient := winrm_helper.NewFakeClient()
+	fakeClient.Respond("GetVm", "", &api.Error{Kind: api.ErrNotFound, Category: "ObjectNotFound", FullyQualifiedErrorId: "VmNotFound", Err: errors.New("VM does not exist - web")})
+	c := &ClientConfig{WinRmClient: fakeClient}
+
+	_, err := c.GetVm(context.Background(), "web")
+	if !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("Expected not found error to be returned: %v", err)
+	}
+}
+
+func TestClientConfigRejectsInvalidResult(t *testing.T) {
+	fakeClient := winrm_helper.NewFakeClient()
+	fakeClient.Respond("GetVm", "WARNING: not json", nil)
+	c := &ClientConfig{WinRmClient: fakeClient}
+
+	_, err := c.GetVm(context.Background(), "web")
+	if err == nil {
+		t.Errorf("Expected invalid result to be rejected")
+	}
+}
+
+func TestClientConfigRemoteFiles(t *testing.T) {
+	fakeClient := winrm_helper.NewFakeClient()
+	c := &ClientConfig{WinRmClient: fakeClient}
+	ctx := context.Background()
+
+	localFilePath := filepath.Join(t.TempDir(), "bootstrap.zip")
+	err := os.WriteFile(localFilePath, []byte("zip"), 0600)
+	if err != nil {
+		t.Fatalf("Unable to write local file: %s", err.Error())
+	}
+
+	err = c.RemoteFileUpload(ctx, localFilePath, `$env:TEMP\bootstrap.zip`)
+	if err != nil {
+		t.Fatalf("Unable to upload file: %s", err.Error())
+	}
+
+	exists, err := c.RemoteFileExists(ctx, `$env:TEMP\bootstrap.zip`)
+	if err != nil || !exists {
+		t.Errorf("Expected remote file to exist: %v", err)
+	}
+
+	err = c.RemoteFileDelete(ctx, `$env:TEMP\bootstrap.zip`)
+	if err != nil {
+		t.Fatalf("Unable to delete file: %s", err.Error())
+	}
+
+	exists, err = c.RemoteFileExists(ctx, `$env:TEMP\bootstrap.zip`)
+	if err != nil || exists {
+		t.Errorf("Expected remote file to be deleted: %v", err)
+	}
+}
+
+func TestClientConfigCopyVmGuestFile(t *testing.T) {
+	fakeClient := winrm_helper.NewFakeClient()
+	fakeClient.Respond("GetVmIntegrationServices", `[{"Name":"Heartbeat","Enabled":true},{"Name":"Guest Service Interface","Enabled":false}]`, nil)
+	c := &ClientConfig{WinRmClient: fakeClient}
+
+	localFilePath := filepath.Join(t.TempDir(), "app.json")
+	err := os.WriteFile(localFilePath, []byte("{}"), 0600)
+	if err != nil {
+		t.Fatalf("Unable to write local file: %s", err.Error())
+	}
+
+	err = c.CopyVmGuestFile(context.Background(), "web", localFilePath, `C:\config\app.json`, true)
+	if err != nil {
+		t.Fatalf("Unable to copy guest file: %s", err.Error())
+	}
+
+	scripts := fakeClient.Scripts()
+	names := make([]string, 0, len(scripts))
+	for _, script := range scripts {
+		names = append(names, script.Name)
+	}
+	if strings.Join(names, ",") != "GetVmIntegrationServices,EnableVmIntegrationService,CopyVmGuestFile" {
+		t.Fatalf("Unexpected scripts: %#v", names)
+	}
+
+	if args := scripts[1].Args.(enableVmIntegrationServiceArgs); args.Name != api.VmGuestFileIntegrationService {
+		t.Errorf("Expected %s to be enabled: %#v", api.VmGuestFileIntegrationService, args)
+	}
+
+	var vmGuestFile api.VmGuestFile
+	err = json.Unmarshal([]byte(scripts[2].Args.(copyVmGuestFileArgs).VmGuestFileJson), &vmGuestFile)
+	if err != nil {
+		t.Fatalf("Unable to read guest file arguments: %s", err.Error())
+	}
+	if !strings.HasPrefix(vmGuestFile.SourceFilePath, `$env:TEMP\`+powershell.TemporaryItemPrefix+powershell.SessionId) || vmGuestFile.DestinationFilePath != `C:\config\app.json` || !vmGuestFile.CreateFullPath {
+		t.Errorf("Unexpected guest file arguments: %#v", vmGuestFile)
+	}
+
+	if files := fakeClient.Files(); len(files) != 0 {
+		t.Errorf("Expected staged file to be deleted: %#v", files)
+	}
+}
+
+func TestClientConfigCreateOrUpdateIsoImageRedactsFiles(t *testing.T) {
+	fakeClient := winrm_helper.NewFakeClient()
+	c := &ClientConfig{WinRmClient: fakeClient}
+
+	userData := "#cloud-config\npassword: hunter2\n"
+	powershell.RegisterSecret(userData)
+
+	files, err := api.IsoImageFiles(userData, "instance-id: web\n", "", nil)
+	if err != nil {
+		t.Fatalf("Unable to generate iso files: %s", err.Error())
+	}
+
+	err = c.CreateOrUpdateIsoImage(context.Background(), "", "", "", "", "", "", "", "", "", api.IsoMediaType_DVDPLUSRW_DUALLAYER, api.IsoFileSystemType_Unknown, api.IsoImageCloudInitVolumeName, `C:\iso\cloud-init.iso`, "", "", api.IsoImageFilesHash(files), files)
+	if err != nil {
+		t.Fatalf("Unable to create iso image: %s", err.Error())
+	}
+
+	scripts := fakeClient.Scripts()
+	if len(scripts) != 1 {
+		t.Fatalf("Unexpected scripts: %#v", scripts)
+	}
+
+	rendered := powershell.RenderRedactedScript(createOrUpdateIsoImageTemplate, scripts[0].Args)
+	if strings.Contains(rendered, "hunter2") {
+		t.Errorf("Expected user-data to be masked in the logged script:\n%s", rendered)
+	}
+	if !strings.Contains(rendered, `cloud-init.iso`) {
+		t.Errorf("Expected the logged script to show the iso arguments:\n%s", rendered)
+	}
+
+	redacted := powershell.Redact(fmt.Sprintf("%#v", scripts[0].Args))
+	if strings.Contains(redacted, "hunter2") {
+		t.Errorf("Expected user-data to be masked in the arguments: %s", redacted)
+	}
+}
@@ -10,6 +10,12 @@ import (
 )
 
 func (c *ClientConfig) RemoteFileUpload(ctx context.Context, filePath string, remoteFilePath string) (err error) {
+	release, err := c.acquireHeavyOperation(ctx, "RemoteFileUpload")
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	_, err = c.WinRmClient.UploadFile(ctx, filePath, remoteFilePath)
 	return err
 }
@@ -26,14 +32,18 @@ func (c *ClientConfig) RemoteFileExists(ctx context.Context, remoteFilePath stri
 
 type createOrUpdateIsoImageArgs struct {
 	IsoImageJson string
+	// FilesJson carries the files apart from IsoImageJson as they may hold secrets such as cloud-init user-data
+	FilesJson string `sensitive:"true"`
 }
 
 var createOrUpdateIsoImageTemplate = template.Must(template.New("CreateOrUpdateIsoImage").Parse(`
 $ErrorActionPreference = 'Stop'
-$isoImageJson = @'
-{{.IsoImageJson}}
-'@ 
+$isoImageJson = $arguments.IsoImageJson
 $isoImage = $isoImageJson | ConvertFrom-Json
+$isoImageFiles = $null
+if ($arguments.FilesJson) {
+	$isoImageFiles = $arguments.FilesJson | ConvertFrom-Json
+}
 
 $mediaType = @{}
 
@@ -64,6 +74,8 @@ function Save-IsoImage {
         [string]$SourceBootFilePath = "",
         [parameter(Mandatory = $false, ValueFromPipeline = $false)]
         [string]$SourceBootFilePathHash = "",
+        [parameter(Mandatory = $false, ValueFromPipeline = $false)]
+        [string]$SourceFilesHash = "",
         [parameter(Mandatory = $true, ValueFromPipeline = $false)]
         [string]$DestinationIsoFilePath,
         [parameter(Mandatory = $false, ValueFromPipeline = $false)]
@@ -85,6 +97,8 @@ function Save-IsoImage {
         [parameter(Mandatory = $false, ValueFromPipeline = $false)]
         [string]$ResolveDestinationBootFilePath = "",
         [Parameter(Mandatory = $false, ValueFromPipeline = $false)]
+        [object]$Files = $null,
+        [Parameter(Mandatory = $false, ValueFromPipeline = $false)]
         [switch]$Force
     )
     $typeDefinition = @'
@@ -141,11 +155,11 @@ function Save-IsoImage {
 	$expandedResolveDestinationBootFilePath = $ExecutionContext.InvokeCommand.ExpandString($ResolveDestinationBootFilePath)
 
 	if (!(Test-Path -Path $expandedResolveDestinationIsoFilePath) -and !$SourceIsoFilePath) {
-        if (!$expandedResolveDestinationZipFilePath) {
-            throw ("must specify a value for ResolveDestinationZipFilePath if no SourceIsoFilePath is provided")
+        if (!$expandedResolveDestinationZipFilePath -and !$Files) {
+            throw ("must specify a value for ResolveDestinationZipFilePath if no SourceIsoFilePath or Files are provided")
         }
 
-		if (!(Test-Path -Path $expandedResolveDestinationZipFilePath)) {
+		if ($expandedResolveDestinationZipFilePath -and !(Test-Path -Path $expandedResolveDestinationZipFilePath)) {
 			throw ("Could not find $($expandedResolveDestinationZipFilePath) for specified SourceZipFilePath=$($SourceZipFilePath)")
 		} 
 
@@ -162,7 +176,18 @@ function Save-IsoImage {
 		$expandedResolveDestinationUnzipDirectoryPath = New-TemporaryDirectory
 		try
 		{
-			Expand-Archive -Path $expandedResolveDestinationZipFilePath -DestinationPath $expandedResolveDestinationUnzipDirectoryPath
+			if ($expandedResolveDestinationZipFilePath) {
+				Expand-Archive -Path $expandedResolveDestinationZipFilePath -DestinationPath $expandedResolveDestinationUnzipDirectoryPath
+			}
+
+			if ($Files) {
+				foreach ($file in $Files.PSObject.Properties) {
+					$filePath = Join-Path $expandedResolveDestinationUnzipDirectoryPath ($file.Name -replace '/', '\')
+					New-Item -Path (Split-Path -Path $filePath -Parent) -ItemType Directory -Force | Out-Null
+					#cloud-init does not expect a byte order mark
+					[System.IO.File]::WriteAllText($filePath, $file.Value, (New-Object System.Text.UTF8Encoding $false))
+				}
+			}
 	
 			if ($SourceBootFilePath) {
 				try {
@@ -230,7 +255,7 @@ function Save-IsoImage {
 		}
 	}
 
-	Save-IsoImageMetaData -SourceIsoFilePath $SourceIsoFilePath -SourceIsoFilePathHash $SourceIsoFilePathHash -SourceZipFilePath $SourceZipFilePath -SourceZipFilePathHash $SourceZipFilePathHash -SourceBootFilePath $SourceBootFilePath -SourceBootFilePathHash $SourceBootFilePathHash -DestinationIsoFilePath $DestinationIsoFilePath -DestinationZipFilePath $DestinationZipFilePath -DestinationBootFilePath $DestinationBootFilePath -Media $Media -FileSystem $FileSystem -VolumeName $VolumeName -ResolveDestinationIsoFilePath $ResolveDestinationIsoFilePath -ResolveDestinationZipFilePath $ResolveDestinationZipFilePath -ResolveDestinationBootFilePath $ResolveDestinationBootFilePath -Force:$true
+	Save-IsoImageMetaData -SourceIsoFilePath $SourceIsoFilePath -SourceIsoFilePathHash $SourceIsoFilePathHash -SourceZipFilePath $SourceZipFilePath -SourceZipFilePathHash $SourceZipFilePathHash -SourceBootFilePath $SourceBootFilePath -SourceBootFilePathHash $SourceBootFilePathHash -SourceFilesHash $SourceFilesHash -DestinationIsoFilePath $DestinationIsoFilePath -DestinationZipFilePath $DestinationZipFilePath -DestinationBootFilePath $DestinationBootFilePath -Media $Media -FileSystem $FileSystem -VolumeName $VolumeName -ResolveDestinationIsoFilePath $ResolveDestinationIsoFilePath -ResolveDestinationZipFilePath $ResolveDestinationZipFilePath -ResolveDestinationBootFilePath $ResolveDestinationBootFilePath -Force:$true
 }
 
 function Save-IsoImageMetaData {
@@ -249,6 +274,8 @@ function Save-IsoImageMetaData {
         [string]$SourceBootFilePath = "",
         [parameter(Mandatory = $false, ValueFromPipeline = $false)]
         [string]$SourceBootFilePathHash = "",
+        [parameter(Mandatory = $false, ValueFromPipeline = $false)]
+        [string]$SourceFilesHash = "",
         [parameter(Mandatory = $true, ValueFromPipeline = $false)]
         [string]$DestinationIsoFilePath,
         [parameter(Mandatory = $false, ValueFromPipeline = $false)]
@@ -263,9 +290,9 @@ function Save-IsoImageMetaData {
         [int]$FileSystem = 0x40000000,
         [Parameter(Mandatory = $false, ValueFromPipeline = $false)]
         [string]$VolumeName = "UNTITLED",
-        [parameter(Mandatory = $false, ValueFromPipeline = $false)]
-        [string]$ResolveDestinationIsoFilePath = "",
         [parameter(Mandatory = $true, ValueFromPipeline = $false)]
+        [string]$ResolveDestinationIsoFilePath = "",
+        [parameter(Mandatory = $false, ValueFromPipeline = $false)]
         [string]$ResolveDestinationZipFilePath = "",
         [parameter(Mandatory = $false, ValueFromPipeline = $false)]
         [string]$ResolveDestinationBootFilePath = "",
@@ -282,6 +309,7 @@ function Save-IsoImageMetaData {
 	$IsoImageMetadata.SourceZipFilePathHash=$SourceZipFilePathHash
 	$IsoImageMetadata.SourceBootFilePath=$SourceBootFilePath
 	$IsoImageMetadata.SourceBootFilePathHash=$SourceBootFilePathHash
+	$IsoImageMetadata.SourceFilesHash=$SourceFilesHash
 	$IsoImageMetadata.DestinationIsoFilePath=$DestinationIsoFilePath
 	$IsoImageMetadata.DestinationZipFilePath=$DestinationZipFilePath
 	$IsoImageMetadata.DestinationBootFilePath=$DestinationBootFilePath
@@ -302,6 +330,7 @@ $SaveIsoImageArgs.SourceZipFilePath=$isoImage.SourceZipFilePath
 $SaveIsoImageArgs.SourceZipFilePathHash=$isoImage.SourceZipFilePathHash
 $SaveIsoImageArgs.SourceBootFilePath=$isoImage.SourceBootFilePath
 $SaveIsoImageArgs.SourceBootFilePathHash=$isoImage.SourceBootFilePathHash
+$SaveIsoImageArgs.SourceFilesHash=$isoImage.SourceFilesHash
 $SaveIsoImageArgs.DestinationIsoFilePath=$isoImage.DestinationIsoFilePath
 $SaveIsoImageArgs.DestinationZipFilePath=$isoImage.DestinationZipFilePath
 $SaveIsoImageArgs.DestinationBootFilePath=$isoImage.DestinationBootFilePath
@@ -311,12 +340,13 @@ $SaveIsoImageArgs.VolumeName=$isoImage.VolumeName
 $SaveIsoImageArgs.ResolveDestinationIsoFilePath=$isoImage.ResolveDestinationIsoFilePath
 $SaveIsoImageArgs.ResolveDestinationZipFilePath=$isoImage.ResolveDestinationZipFilePath
 $SaveIsoImageArgs.ResolveDestinationBootFilePath=$isoImage.ResolveDestinationBootFilePath
+$SaveIsoImageArgs.Files=$isoImageFiles
 $SaveIsoImageArgs.Force=$true
 
 Save-IsoImage @SaveIsoImageArgs
 `))
 
-func (c *ClientConfig) CreateOrUpdateIsoImage(ctx context.Context, sourceIsoFilePath string, sourceIsoFilePathHash string, sourceZipFilePath string, sourceZipFilePathHash string, sourceBootFilePath string, sourceBootFilePathHash string, destinationIsoFilePath string, destinationZipFilePath string, destinationBootFilePath string, media api.IsoMediaType, fileSystem api.IsoFileSystemType, volumeName string, resolveDestinationIsoFilePath string, resolveDestinationZipFilePath string, resolveDestinationBootFilePath string) (err error) {
+func (c *ClientConfig) CreateOrUpdateIsoImage(ctx context.Context, sourceIsoFilePath string, sourceIsoFilePathHash string, sourceZipFilePath string, sourceZipFilePathHash string, sourceBootFilePath string, sourceBootFilePathHash string, destinationIsoFilePath string, destinationZipFilePath string, destinationBootFilePath string, media api.IsoMediaType, fileSystem api.IsoFileSystemType, volumeName string, resolveDestinationIsoFilePath string, resolveDestinationZipFilePath string, resolveDestinationBootFilePath string, sourceFilesHash string, files map[string]string) (err error) {
 	isoImageJson, err := json.Marshal(api.IsoImage{
 		SourceIsoFilePath:              sourceIsoFilePath,
 		SourceIsoFilePathHash:          sourceIsoFilePathHash,
@@ -324,6 +354,7 @@ func (c *ClientConfig) CreateOrUpdateIsoImage(ctx context.Context, sourceIsoFile
 		SourceZipFilePathHash:          sourceZipFilePathHash,
 		SourceBootFilePath:             sourceBootFilePath,
 		SourceBootFilePathHash:         sourceBootFilePathHash,
+		SourceFilesHash:                sourceFilesHash,
 		DestinationIsoFilePath:         destinationIsoFilePath,
 		DestinationZipFilePath:         destinationZipFilePath,
 		DestinationBootFilePath:        destinationBootFilePath,
@@ -339,8 +370,23 @@ func (c *ClientConfig) CreateOrUpdateIsoImage(ctx context.Context, sourceIsoFile
 		return fmt.Errorf("error converting object to json: %s", err)
 	}
 
+	var filesJson []byte
+	if len(files) > 0 {
+		filesJson, err = json.Marshal(files)
+		if err != nil {
+			return fmt.Errorf("error converting object to json: %s", err)
+		}
+	}
+
+	release, err := c.acquireHeavyOperation(ctx, "CreateOrUpdateIsoImage")
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	err = c.WinRmClient.RunFireAndForgetScript(ctx, createOrUpdateIsoImageTemplate, createOrUpdateIsoImageArgs{
 		IsoImageJson: string(isoImageJson),
+		FilesJson:    string(filesJson),
 	})
 
 	if err != nil {
@@ -356,7 +402,7 @@ type getIsoImageArgs struct {
 
 var getIsoImageTemplate = template.Must(template.New("GetIsoImage").Parse(`
 $ErrorActionPreference = 'Stop'
-$ResolveDestinationIsoFilePath='{{.ResolveDestinationIsoFilePath}}'
+$ResolveDestinationIsoFilePath=$arguments.ResolveDestinationIsoFilePath
 $isoImageObject = $null
 
 $expandedResolveDestinationIsoFilePath = $ExecutionContext.InvokeCommand.ExpandString($ResolveDestinationIsoFilePath)
@@ -373,6 +419,7 @@ if (Test-Path $metadataPath) {
 	$isoImageObject.SourceZipFilePathHash=$metadata.SourceZipFilePathHash
 	$isoImageObject.SourceBootFilePath=$metadata.SourceBootFilePath
 	$isoImageObject.SourceBootFilePathHash=$metadata.SourceBootFilePathHash
+	$isoImageObject.SourceFilesHash=$metadata.SourceFilesHash
 	$isoImageObject.DestinationIsoFilePath=$metadata.DestinationIsoFilePath
 	$isoImageObject.DestinationZipFilePath=$metadata.DestinationZipFilePath
 	$isoImageObject.DestinationBootFilePath=$metadata.DestinationBootFilePath
@@ -388,7 +435,7 @@ if ($isoImageObject){
 	$isoImage = ConvertTo-Json -InputObject $isoImageObject
 	$isoImage
 } else {
-	"{}"
+	Write-Error -Message "Iso image does not exist - $($expandedResolveDestinationIsoFilePath)" -Category ObjectNotFound -ErrorId 'IsoImageNotFound' -TargetObject $expandedResolveDestinationIsoFilePath
 }
 `))
 
@@ -0,0 +1,207 @@
+package hyperv_winrm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
+)
+
+const hostileInput = `x'; Remove-Item -Recurse -Force C:\ ; $(Stop-Computer) "` + "`" + `" @' '@ {{.Name}}` + "\r\n#"
+
+type scriptTemplateTestCase struct {
+	template *template.Template
+	args     interface{}
+}
+
+func scriptTemplateTestCases() []scriptTemplateTestCase {
+	return []scriptTemplateTestCase{
+		{createOrUpdateIsoImageTemplate, createOrUpdateIsoImageArgs{IsoImageJson: hostileInput, FilesJson: hostileInput}},
+		{getIsoImageTemplate, getIsoImageArgs{ResolveDestinationIsoFilePath: hostileInput}},
+		{existsVhdTemplate, existsVhdArgs{Path: hostileInput}},
+		{createOrUpdateVhdTemplate, createOrUpdateVhdArgs{Source: hostileInput, SourceVm: hostileInput, SourceDisk: 1, VhdJson: hostileInput}},
+		{resizeVhdTemplate, resizeVhdArgs{Path: hostileInput, Size: 1}},
+		{getVhdTemplate, getVhdArgs{Path: hostileInput}},
+		{deleteVhdTemplate, deleteVhdArgs{Path: hostileInput}},
+		{existsVmTemplate, existsVmArgs{Name: hostileInput}},
+		{createVmTemplate, createVmArgs{VmJson: hostileInput}},
+		{getVmTemplate, getVmArgs{Name: hostileInput}},
+		{updateVmTemplate, updateVmArgs{VmJson: hostileInput}},
+		{deleteVmTemplate, deleteVmArgs{Name: hostileInput}},
+		{createVmDvdDriveTemplate, createVmDvdDriveArgs{VmDvdDriveJson: hostileInput}},
+		{getVmDvdDrivesTemplate, getVmDvdDrivesArgs{VmName: hostileInput}},
+		{updateVmDvdDriveTemplate, updateVmDvdDriveArgs{VmName: hostileInput, ControllerNumber: 1, ControllerLocation: 1, VmDvdDriveJson: hostileInput}},
+		{deleteVmDvdDriveTemplate, deleteVmDvdDriveArgs{VmName: hostileInput, ControllerNumber: 1, ControllerLocation: 1}},
+		{createOrUpdateVmBiosTemplate, createOrUpdateVmBiosArgs{VmBiosJson: hostileInput}},
+		{getVmBiosTemplate, getVmBiosArgs{VmName: hostileInput}},
+		{updateVmComPortTemplate, updateVmComPortArgs{VmComPortJson: hostileInput}},
+		{getVmComPortsTemplate, getVmComPortsArgs{VmName: hostileInput}},
+		{createOrUpdateVmFirmwareTemplate, createOrUpdateVmFirmwareArgs{VmFirmwareJson: hostileInput}},
+		{getVmFirmwareTemplate, getVmFirmwareArgs{VmName: hostileInput}},
+		{copyVmGuestFileTemplate, copyVmGuestFileArgs{VmGuestFileJson: hostileInput}},
+		{runVmGuestScriptTemplate, runVmGuestScriptArgs{VmName: hostileInput, Username: hostileInput, Password: hostileInput, Script: hostileInput}},
+		{createVmHardDiskDriveTemplate, createVmHardDiskDriveArgs{VmHardDiskDriveJson: hostileInput}},
+		{getVmHardDiskDrivesTemplate, getVmHardDiskDrivesArgs{VmName: hostileInput}},
+		{updateVmHardDiskDriveTemplate, updateVmHardDiskDriveArgs{VmName: hostileInput, ControllerNumber: 1, ControllerLocation: 1, VmHardDiskDriveJson: hostileInput}},
+		{deleteVmHardDiskDriveTemplate, deleteVmHardDiskDriveArgs{VmName: hostileInput, ControllerNumber: 1, ControllerLocation: 1}},
+		{getVmIntegrationServicesTemplate, getVmIntegrationServicesArgs{VmName: hostileInput}},
+		{enableVmIntegrationServiceTemplate, enableVmIntegrationServiceArgs{VmName: hostileInput, Name: hostileInput}},
+		{disableVmIntegrationServiceTemplate, disableVmIntegrationServiceArgs{VmName: hostileInput, Name: hostileInput}},
+		{createOrUpdateVmKvpItemTemplate, createOrUpdateVmKvpItemArgs{VmKvpItemJson: hostileInput}},
+		{getVmKvpItemsTemplate, getVmKvpItemsArgs{VmName: hostileInput}},
+		{deleteVmKvpItemTemplate, deleteVmKvpItemArgs{VmName: hostileInput, Name: hostileInput}},
+		{getVmGuestKvpItemsTemplate, getVmGuestKvpItemsArgs{VmName: hostileInput}},
+		{createVmNetworkAdapterTemplate, createVmNetworkAdapterArgs{VmNetworkAdapterJson: hostileInput}},
+		{getVmNetworkAdaptersTemplate, getVmNetworkAdaptersArgs{VmName: hostileInput}},
+		{waitForVmNetworkAdaptersIpsTemplate, waitForVmNetworkAdaptersIpsArgs{VmName: hostileInput, Timeout: 1, PollPeriod: 1, VmNetworkAdaptersWaitForIpsJson: hostileInput}},
+		{updateVmNetworkAdapterTemplate, updateVmNetworkAdapterArgs{VmName: hostileInput, Index: 1, VmNetworkAdapterJson: hostileInput}},
+		{deleteVmNetworkAdapterTemplate, deleteVmNetworkAdapterArgs{VmName: hostileInput, Index: 1}},
+		{createOrUpdateVmProcessorTemplate, createOrUpdateVmProcessorArgs{VmProcessorJson: hostileInput}},
+		{getVmProcessorTemplate, getVmProcessorArgs{VmName: hostileInput}},
+		{createVmScsiControllerTemplate, createVmScsiControllerArgs{VmName: hostileInput}},
+		{getVmScsiControllersTemplate, getVmScsiControllersArgs{VmName: hostileInput}},
+		{deleteVmScsiControllerTemplate, deleteVmScsiControllerArgs{VmName: hostileInput, ControllerNumber: 1}},
+		{createOrUpdateVmMemoryTemplate, createOrUpdateVmMemoryArgs{VmMemoryJson: hostileInput}},
+		{getVmMemoryTemplate, getVmMemoryArgs{VmName: hostileInput}},
+		{getVmStatusTemplate, getVmStatusArgs{VmName: hostileInput}},
+		{updateVmStatusTemplate, updateVmStatusArgs{VmName: hostileInput, Timeout: 1, PollPeriod: 1, VmStatusJson: hostileInput}},
+		{existsVMSwitchTemplate, existsVMSwitchArgs{Name: hostileInput}},
+		{createVMSwitchTemplate, createVMSwitchArgs{VmSwitchJson: hostileInput}},
+		{getVMSwitchTemplate, getVMSwitchArgs{Name: hostileInput}},
+		{updateVMSwitchTemplate, updateVMSwitchArgs{OldName: hostileInput, VmSwitchJson: hostileInput}},
+		{deleteVMSwitchTemplate, deleteVMSwitchArgs{Name: hostileInput}},
+		{removeTemporaryItemsTemplate, removeTemporaryItemsArgs{TemporaryItemPrefix: hostileInput, SessionId: hostileInput, OlderThanSeconds: 1}},
+		{createVmCheckpointTemplate, createVmCheckpointArgs{VmName: hostileInput, Name: hostileInput}},
+		{getVmCheckpointTemplate, getVmCheckpointArgs{VmName: hostileInput, Name: hostileInput}},
+		{renameVmCheckpointTemplate, renameVmCheckpointArgs{VmName: hostileInput, OldName: hostileInput, Name: hostileInput}},
+		{restoreVmCheckpointTemplate, restoreVmCheckpointArgs{VmName: hostileInput, Name: hostileInput}},
+		{deleteVmCheckpointTemplate, deleteVmCheckpointArgs{VmName: hostileInput, Name: hostileInput, IncludeChildCheckpoints: true}},
+		{importVmTemplate, importVmArgs{VmImportJson: hostileInput}},
+		{exportVmTemplate, exportVmArgs{VmName: hostileInput, Path: hostileInput, CheckpointName: hostileInput}},
+		{getVmExportTemplate, getVmExportArgs{VmName: hostileInput, Path: hostileInput}},
+		{deleteVmExportTemplate, deleteVmExportArgs{VmName: hostileInput, Path: hostileInput}},
+		{moveVmStorageTemplate, moveVmStorageArgs{VmName: hostileInput, Timeout: 1, PollPeriod: 1, VmStorageMoveJson: hostileInput}},
+		{getVmMigrationIncompatibilitiesTemplate, getVmMigrationIncompatibilitiesArgs{VmName: hostileInput, DestinationHost: hostileInput, IncludeStorage: true, VmStorageMoveJson: hostileInput}},
+		{moveVmTemplate, moveVmArgs{VmName: hostileInput, DestinationHost: hostileInput, IncludeStorage: true, Timeout: 1, PollPeriod: 1, VmStorageMoveJson: hostileInput}},
+	}
+}
+
+// declaredScriptTemplateNames finds every template.New("...") call in the package so that new
+// templates can not be added without also being covered by scriptTemplateTestCases.
+func declaredScriptTemplateNames(t *testing.T) []string {
+	fileSet := token.NewFileSet()
+	packages, err := parser.ParseDir(fileSet, ".", nil, 0)
+	if err != nil {
+		t.Fatalf("Unable to parse package: %s", err.Error())
+	}
+
+	var names []string
+	for _, p := range packages {
+		for fileName, file := range p.Files {
+			if strings.HasSuffix(fileName, "_test.go") {
+				continue
+			}
+
+			ast.Inspect(file, func(node ast.Node) bool {
+				call, ok := node.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+
+				selector, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok || selector.Sel.Name != "New" || len(call.Args) != 1 {
+					return true
+				}
+
+				if pkg, ok := selector.X.(*ast.Ident); !ok || pkg.Name != "template" {
+					return true
+				}
+
+				if literal, ok := call.Args[0].(*ast.BasicLit); ok {
+					name, err := strconv.Unquote(literal.Value)
+					if err == nil {
+						names = append(names, name)
+					}
+				}
+
+				return true
+			})
+		}
+	}
+
+	return names
+}
+
+func TestEveryScriptTemplateIsCovered(t *testing.T) {
+	covered := map[string]bool{}
+	for _, testCase := range scriptTemplateTestCases() {
+		covered[testCase.template.Name()] = true
+	}
+
+	for _, name := range declaredScriptTemplateNames(t) {
+		if !covered[name] {
+			t.Errorf("Script template %s is not covered by scriptTemplateTestCases", name)
+		}
+	}
+}
+
+func TestScriptTemplatesDoNotInterpolate(t *testing.T) {
+	for _, testCase := range scriptTemplateTestCases() {
+		err := powershell.ValidateScriptTemplate(testCase.template)
+		if err != nil {
+			t.Errorf("Script template %s is not safe: %s", testCase.template.Name(), err.Error())
+		}
+	}
+}
+
+func TestScriptTemplatesBindHostileArguments(t *testing.T) {
+	bindArgumentsRegex := regexp.MustCompile(`^\$arguments = \[System\.Text\.Encoding\]::UTF8\.GetString\(\[System\.Convert\]::FromBase64String\('([A-Za-z0-9+/=]*)'\)\) \| ConvertFrom-Json\n`)
+
+	for _, testCase := range scriptTemplateTestCases() {
+		name := testCase.template.Name()
+
+		script, err := powershell.RenderScript(testCase.template, testCase.args)
+		if err != nil {
+			t.Errorf("Unable to render script template %s: %s", name, err.Error())
+			continue
+		}
+
+		if strings.Contains(script, hostileInput) || strings.Contains(script, "Stop-Computer") || strings.Contains(script, "Remove-Item -Recurse -Force C:") {
+			t.Errorf("Script template %s rendered hostile input into the script", name)
+		}
+
+		match := bindArgumentsRegex.FindStringSubmatch(script)
+		if match == nil {
+			t.Errorf("Script template %s does not start by binding $arguments", name)
+			continue
+		}
+
+		if script[len(match[0]):] != powershell.ErrorRecordTrap+testCase.template.Tree.Root.String() {
+			t.Errorf("Script template %s body was altered by its arguments", name)
+		}
+
+		argsJson, err := base64.StdEncoding.DecodeString(match[1])
+		if err != nil {
+			t.Errorf("Script template %s arguments are not base64: %s", name, err.Error())
+			continue
+		}
+
+		expectedArgsJson, err := json.Marshal(testCase.args)
+		if err != nil {
+			t.Errorf("Unable to serialize arguments for %s: %s", name, err.Error())
+			continue
+		}
+
+		if string(argsJson) != string(expectedArgsJson) {
+			t.Errorf("Script template %s arguments did not round trip: %s", name, string(argsJson))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package hyperv_winrm
+
+import (
+	"context"
+	"text/template"
+	"time"
+
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
+)
+
+type removeTemporaryItemsArgs struct {
+	TemporaryItemPrefix string
+	SessionId           string
+	OlderThanSeconds    int64
+}
+
+var removeTemporaryItemsTemplate = template.Must(template.New("RemoveTemporaryItems").Parse(`
+$ErrorActionPreference = 'Stop'
+$temporaryItemPrefix = $arguments.TemporaryItemPrefix
+$sessionId = $arguments.SessionId
+$cutoff = (Get-Date).AddSeconds(-$arguments.OlderThanSeconds)
+$removedItems = @()
+
+$temporaryItemPatterns = @("$($temporaryItemPrefix)*", "shell-$($temporaryItemPrefix)*", "elevated-shell-$($temporaryItemPrefix)*")
+Get-ChildItem -Path $env:TEMP -Force -ErrorAction SilentlyContinue | ?{
+	$item = $_
+	($temporaryItemPatterns | ?{ $item.Name -like $_ }) -and !$item.Name.Contains($sessionId) -and $item.LastWriteTime -lt $cutoff
+} | %{
+	Remove-Item -LiteralPath $_.FullName -Force -Recurse -ErrorAction SilentlyContinue
+	if (!(Test-Path -LiteralPath $_.FullName)) {
+		$removedItems += $_.FullName
+	}
+}
+
+$schedule = New-Object -ComObject 'Schedule.Service'
+$schedule.Connect()
+$folder = $schedule.GetFolder('\')
+$folder.GetTasks(1) | ?{
+	$_.Name -like "$($temporaryItemPrefix)*" -and !$_.Name.Contains($sessionId) -and $_.LastRunTime -lt $cutoff
+} | %{
+	$_.Stop(0) | Out-Null
+	$folder.DeleteTask($_.Name, 0) | Out-Null
+	$removedItems += "Task Scheduler Library\$($_.Name)"
+}
+[System.Runtime.Interopservices.Marshal]::ReleaseComObject($schedule) | Out-Null
+
+ConvertTo-Json -InputObject @($removedItems)
+`))
+
+func (c *ClientConfig) RemoveTemporaryItems(ctx context.Context, olderThan time.Duration, sessionId string) (removedItems []string, err error) {
+	err = c.WinRmClient.RunScriptWithResult(ctx, removeTemporaryItemsTemplate, removeTemporaryItemsArgs{
+		TemporaryItemPrefix: powershell.TemporaryItemPrefix,
+		SessionId:           sessionId,
+		OlderThanSeconds:    int64(olderThan.Seconds()),
+	}, &removedItems)
+
+	return removedItems, err
+}
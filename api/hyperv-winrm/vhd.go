@@ -14,7 +14,7 @@ type existsVhdArgs struct {
 
 var existsVhdTemplate = template.Must(template.New("ExistsVhd").Parse(`
 $ErrorActionPreference = 'Stop'
-$path='{{.Path}}'
+$path=$arguments.Path
 
 if (Test-Path $path) {
 	$exists = ConvertTo-Json -InputObject @{Exists=$true}
@@ -44,10 +44,10 @@ var createOrUpdateVhdTemplate = template.Must(template.New("CreateOrUpdateVhd").
 $ErrorActionPreference = 'Stop'
 
 Import-Module Hyper-V
-$source='{{.Source}}'
-$sourceVm='{{.SourceVm}}'
-$sourceDisk={{.SourceDisk}}
-$vhd = '{{.VhdJson}}' | ConvertFrom-Json
+$source=$arguments.Source
+$sourceVm=$arguments.SourceVm
+$sourceDisk=$arguments.SourceDisk
+$vhd = $arguments.VhdJson | ConvertFrom-Json
 $vhdType = [Microsoft.Vhd.PowerShell.VhdType]$vhd.VhdType
 
 function Get-TarPath {
@@ -302,6 +302,12 @@ func (c *ClientConfig) CreateOrUpdateVhd(ctx context.Context, path string, sourc
 		return err
 	}
 
+	release, err := c.acquireHeavyOperation(ctx, "CreateOrUpdateVhd")
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	err = c.WinRmClient.RunFireAndForgetScript(ctx, createOrUpdateVhdTemplate, createOrUpdateVhdArgs{
 		Source:     source,
 		SourceVm:   sourceVm,
@@ -319,9 +325,9 @@ type resizeVhdArgs struct {
 
 var resizeVhdTemplate = template.Must(template.New("ResizeVhd").Parse(`
 $ErrorActionPreference = 'Stop'
-$vhd = Get-VHD -Path '{{.Path}}'
-if ($vhd.Size -ne {{.Size}}){
-	Resize-VHD -Path '{{.Path}}' -SizeBytes {{.Size}}
+$vhd = Get-VHD -Path $arguments.Path
+if ($vhd.Size -ne $arguments.Size){
+	Resize-VHD -Path $arguments.Path -SizeBytes $arguments.Size
 }
 `))
 
@@ -340,7 +346,7 @@ type getVhdArgs struct {
 
 var getVhdTemplate = template.Must(template.New("GetVhd").Parse(`
 $ErrorActionPreference = 'Stop'
-$path='{{.Path}}'
+$path=$arguments.Path
 
 $vhdObject = $null
 if (Test-Path $path) {
@@ -368,7 +374,7 @@ if ($vhdObject){
 	$vhd = ConvertTo-Json -InputObject $vhdObject
 	$vhd
 } else {
-	"{}"
+	Write-Error -Message "Vhd does not exist - $($path)" -Category ObjectNotFound -ErrorId 'VhdNotFound' -TargetObject $path
 }
 `))
 
@@ -387,8 +393,8 @@ type deleteVhdArgs struct {
 var deleteVhdTemplate = template.Must(template.New("DeleteVhd").Parse(`
 $ErrorActionPreference = 'Stop'
 
-$targetDirectory = (split-path '{{.Path}}' -Parent)
-$targetName = (split-path '{{.Path}}' -Leaf)
+$targetDirectory = (split-path $arguments.Path -Parent)
+$targetName = (split-path $arguments.Path -Leaf)
 $targetName = $targetName.Substring(0,$targetName.LastIndexOf('.')).split('\')[-1]
 
 Get-ChildItem -Path $targetDirectory |?{$_.BaseName.StartsWith($targetName)} | %{
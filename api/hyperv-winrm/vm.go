@@ -14,7 +14,7 @@ type existsVmArgs struct {
 
 var existsVmTemplate = template.Must(template.New("ExistsVm").Parse(`
 $ErrorActionPreference = 'Stop'
-$vmObject = Get-VM -Name '{{.Name}}*' | ?{$_.Name -eq '{{.Name}}' }
+$vmObject = Get-VM -Name "$($arguments.Name)*" | ?{$_.Name -eq $arguments.Name }
 
 if ($vmObject){
 	$exists = ConvertTo-Json -InputObject @{Exists=$true}
@@ -40,7 +40,7 @@ type createVmArgs struct {
 var createVmTemplate = template.Must(template.New("CreateVm").Parse(`
 $ErrorActionPreference = 'Stop'
 Import-Module Hyper-V
-$vm = '{{.VmJson}}' | ConvertFrom-Json
+$vm = $arguments.VmJson | ConvertFrom-Json
 $automaticCriticalErrorAction = [Microsoft.HyperV.PowerShell.CriticalErrorAction]$vm.AutomaticCriticalErrorAction
 $automaticStartAction = [Microsoft.HyperV.PowerShell.StartAction]$vm.AutomaticStartAction
 $automaticStopAction = [Microsoft.HyperV.PowerShell.StopAction]$vm.AutomaticStopAction
@@ -181,7 +181,7 @@ type getVmArgs struct {
 
 var getVmTemplate = template.Must(template.New("GetVm").Parse(`
 $ErrorActionPreference = 'Stop'
-$vmObject = Get-VM -Name '{{.Name}}*' -ErrorAction SilentlyContinue | ?{$_.Name -eq '{{.Name}}' } | %{ @{
+$vmObject = Get-VM -Name "$($arguments.Name)*" -ErrorAction SilentlyContinue | ?{$_.Name -eq $arguments.Name } | %{ @{
 	Name=$_.Name;
 	Path=$_.Path;
 	Generation=$_.Generation;
@@ -210,7 +210,7 @@ if ($vmObject) {
 	$vm = ConvertTo-Json -InputObject $vmObject
 	$vm
 } else {
-	"{}"
+	Write-Error -Message "VM does not exist - $($arguments.Name)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $arguments.Name
 }
 `))
 
@@ -229,7 +229,7 @@ type updateVmArgs struct {
 var updateVmTemplate = template.Must(template.New("UpdateVm").Parse(`
 $ErrorActionPreference = 'Stop'
 Import-Module Hyper-V
-$vm = '{{.VmJson}}' | ConvertFrom-Json
+$vm = $arguments.VmJson | ConvertFrom-Json
 $automaticCriticalErrorAction = [Microsoft.HyperV.PowerShell.CriticalErrorAction]$vm.AutomaticCriticalErrorAction
 $automaticStartAction = [Microsoft.HyperV.PowerShell.StartAction]$vm.AutomaticStartAction
 $automaticStopAction = [Microsoft.HyperV.PowerShell.StopAction]$vm.AutomaticStopAction
@@ -239,7 +239,7 @@ $allowUnverifiedPaths = $true #Not a property set on the vm object, skips valida
 $vmObject = Get-VM -Name "$($vm.Name)*" | ?{$_.Name -eq $vm.Name}
 
 if (!$vmObject){
-	throw "VM does not exist - $($vm.Name)"
+	Write-Error -Message "VM does not exist - $($vm.Name)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vm.Name
 }
 
 #Set static and dynamic properties can't be set at the same time, but we need the values to match terraforms state
@@ -347,7 +347,7 @@ type deleteVmArgs struct {
 
 var deleteVmTemplate = template.Must(template.New("DeleteVm").Parse(`
 $ErrorActionPreference = 'Stop'
-Get-VM -Name '{{.Name}}*' | ?{$_.Name -eq '{{.Name}}'} | Remove-VM -force
+Get-VM -Name "$($arguments.Name)*" | ?{$_.Name -eq $arguments.Name} | Remove-VM -force
 `))
 
 func (c *ClientConfig) DeleteVm(ctx context.Context, name string) (err error) {
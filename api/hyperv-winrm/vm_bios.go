@@ -0,0 +1,123 @@
+package hyperv_winrm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/template"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+type createOrUpdateVmBiosArgs struct {
+	VmBiosJson string
+}
+
+var createOrUpdateVmBiosTemplate = template.Must(template.New("CreateOrUpdateVmBios").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmBios = $arguments.VmBiosJson | ConvertFrom-Json
+
+$vmObject = Get-VM -Name "$($vmBios.VmName)*" | ?{$_.Name -eq $vmBios.VmName }
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($vmBios.VmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmBios.VmName
+}
+
+$SetVMBiosArgs = @{}
+$SetVMBiosArgs.VM=$vmObject
+
+if ($vmBios.StartupOrder) {
+	$SetVMBiosArgs.StartupOrder=[Microsoft.HyperV.PowerShell.BootDevice[]]@($vmBios.StartupOrder)
+}
+
+if ($vmBios.NumLockEnabled) {
+	$SetVMBiosArgs.EnableNumLock=$true
+} else {
+	$SetVMBiosArgs.DisableNumLock=$true
+}
+
+Set-VMBios @SetVMBiosArgs
+`))
+
+func (c *ClientConfig) CreateOrUpdateVmBios(
+	ctx context.Context,
+	vmName string,
+	startupOrder []api.Gen1BootType,
+	numLockEnabled bool,
+) (err error) {
+	vmBiosJson, err := json.Marshal(api.VmBios{
+		VmName:         vmName,
+		StartupOrder:   startupOrder,
+		NumLockEnabled: numLockEnabled,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, createOrUpdateVmBiosTemplate, createOrUpdateVmBiosArgs{
+		VmBiosJson: string(vmBiosJson),
+	})
+
+	return err
+}
+
+type getVmBiosArgs struct {
+	VmName string
+}
+
+var getVmBiosTemplate = template.Must(template.New("GetVmBios").Parse(`
+$ErrorActionPreference = 'Stop'
+
+$vmBiosObject = Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMBios | %{ @{
+	StartupOrder=   @($_.StartupOrder | %{ $_.ToString() })
+	NumLockEnabled= $_.NumLockEnabled
+}}
+
+if ($vmBiosObject) {
+	$vmBios = ConvertTo-Json -InputObject $vmBiosObject
+	$vmBios
+} else {
+	"{}"
+}
+`))
+
+func (c *ClientConfig) GetVmBios(ctx context.Context, vmName string) (result api.VmBios, err error) {
+	err = c.WinRmClient.RunScriptWithResult(ctx, getVmBiosTemplate, getVmBiosArgs{
+		VmName: vmName,
+	}, &result)
+
+	return result, err
+}
+
+func (c *ClientConfig) GetNoVmBioses(ctx context.Context) (result []api.VmBios) {
+	result = make([]api.VmBios, 0)
+	return result
+}
+
+func (c *ClientConfig) GetVmBioses(ctx context.Context, vmName string) (result []api.VmBios, err error) {
+	result = make([]api.VmBios, 0)
+	vmBios, err := c.GetVmBios(ctx, vmName)
+	if err != nil {
+		return result, err
+	}
+	result = append(result, vmBios)
+	return result, err
+}
+
+func (c *ClientConfig) CreateOrUpdateVmBioses(ctx context.Context, vmName string, vmBioses []api.VmBios) (err error) {
+	if len(vmBioses) == 0 {
+		return nil
+	}
+	if len(vmBioses) > 1 {
+		return fmt.Errorf("only 1 vm bios setting allowed per a vm")
+	}
+
+	vmBios := vmBioses[0]
+
+	return c.CreateOrUpdateVmBios(ctx, vmName,
+		vmBios.StartupOrder,
+		vmBios.NumLockEnabled,
+	)
+}
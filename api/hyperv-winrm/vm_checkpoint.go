@@ -0,0 +1,200 @@
+package hyperv_winrm
+
+import (
+	"context"
+	"text/template"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+type createVmCheckpointArgs struct {
+	VmName string
+	Name   string
+}
+
+var createVmCheckpointTemplate = template.Must(template.New("CreateVmCheckpoint").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmName = $arguments.VmName
+$name = $arguments.Name
+
+$vmObject = Get-VM -Name "$($vmName)*" | ?{$_.Name -eq $vmName}
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($vmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmName
+}
+
+if (Get-VMSnapshot -VM $vmObject | ?{$_.Name -eq $name}) {
+	Write-Error -Message "VM checkpoint already exists - $($name)" -Category ResourceExists -ErrorId 'VmCheckpointExists' -TargetObject $name
+}
+
+Checkpoint-VM -VM $vmObject -SnapshotName $name
+`))
+
+func (c *ClientConfig) CreateVmCheckpoint(ctx context.Context, vmName string, name string) (err error) {
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, createVmCheckpointTemplate, createVmCheckpointArgs{
+		VmName: vmName,
+		Name:   name,
+	})
+
+	return err
+}
+
+type getVmCheckpointArgs struct {
+	VmName string
+	Name   string
+}
+
+var getVmCheckpointTemplate = template.Must(template.New("GetVmCheckpoint").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmName = $arguments.VmName
+$name = $arguments.Name
+
+$vmObject = Get-VM -Name "$($vmName)*" | ?{$_.Name -eq $vmName}
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($vmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmName
+}
+
+$vmCheckpointObjects = @(Get-VMSnapshot -VM $vmObject | ?{$_.Name -eq $name})
+
+if ($vmCheckpointObjects.Length -gt 1) {
+	throw "VM $($vmName) has $($vmCheckpointObjects.Length) checkpoints called $($name), rename them so that the name is unique"
+}
+
+$vmCheckpointObject = $vmCheckpointObjects | %{ @{
+	VmName=$_.VMName;
+	Name=$_.Name;
+	Id=$_.Id.ToString();
+	ParentCheckpointName=$(if ($_.ParentCheckpointName) { $_.ParentCheckpointName } else { '' });
+	CreationTime=$_.CreationTime.ToUniversalTime().ToString('o');
+	CheckpointType=$_.SnapshotType.ToString();
+}}
+
+if ($vmCheckpointObject) {
+	$vmCheckpoint = ConvertTo-Json -InputObject $vmCheckpointObject
+	$vmCheckpoint
+} else {
+	Write-Error -Message "VM checkpoint does not exist - $($name)" -Category ObjectNotFound -ErrorId 'VmCheckpointNotFound' -TargetObject $name
+}
+`))
+
+func (c *ClientConfig) GetVmCheckpoint(ctx context.Context, vmName string, name string) (result api.VmCheckpoint, err error) {
+	err = c.WinRmClient.RunScriptWithResult(ctx, getVmCheckpointTemplate, getVmCheckpointArgs{
+		VmName: vmName,
+		Name:   name,
+	}, &result)
+
+	return result, err
+}
+
+type renameVmCheckpointArgs struct {
+	VmName  string
+	OldName string
+	Name    string
+}
+
+var renameVmCheckpointTemplate = template.Must(template.New("RenameVmCheckpoint").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmName = $arguments.VmName
+$oldName = $arguments.OldName
+$name = $arguments.Name
+
+$vmObject = Get-VM -Name "$($vmName)*" | ?{$_.Name -eq $vmName}
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($vmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmName
+}
+
+$vmCheckpointObject = Get-VMSnapshot -VM $vmObject | ?{$_.Name -eq $oldName}
+
+if (!$vmCheckpointObject){
+	Write-Error -Message "VM checkpoint does not exist - $($oldName)" -Category ObjectNotFound -ErrorId 'VmCheckpointNotFound' -TargetObject $oldName
+}
+
+if (Get-VMSnapshot -VM $vmObject | ?{$_.Name -eq $name}) {
+	Write-Error -Message "VM checkpoint already exists - $($name)" -Category ResourceExists -ErrorId 'VmCheckpointExists' -TargetObject $name
+}
+
+$vmCheckpointObject | Rename-VMSnapshot -NewName $name
+`))
+
+func (c *ClientConfig) RenameVmCheckpoint(ctx context.Context, vmName string, oldName string, name string) (err error) {
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, renameVmCheckpointTemplate, renameVmCheckpointArgs{
+		VmName:  vmName,
+		OldName: oldName,
+		Name:    name,
+	})
+
+	return err
+}
+
+type restoreVmCheckpointArgs struct {
+	VmName string
+	Name   string
+}
+
+var restoreVmCheckpointTemplate = template.Must(template.New("RestoreVmCheckpoint").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmName = $arguments.VmName
+$name = $arguments.Name
+
+$vmObject = Get-VM -Name "$($vmName)*" | ?{$_.Name -eq $vmName}
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($vmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmName
+}
+
+$vmCheckpointObject = Get-VMSnapshot -VM $vmObject | ?{$_.Name -eq $name}
+
+if (!$vmCheckpointObject){
+	Write-Error -Message "VM checkpoint does not exist - $($name)" -Category ObjectNotFound -ErrorId 'VmCheckpointNotFound' -TargetObject $name
+}
+
+$vmCheckpointObject | Restore-VMSnapshot -Confirm:$false
+`))
+
+func (c *ClientConfig) RestoreVmCheckpoint(ctx context.Context, vmName string, name string) (err error) {
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, restoreVmCheckpointTemplate, restoreVmCheckpointArgs{
+		VmName: vmName,
+		Name:   name,
+	})
+
+	return err
+}
+
+type deleteVmCheckpointArgs struct {
+	VmName                  string
+	Name                    string
+	IncludeChildCheckpoints bool
+}
+
+var deleteVmCheckpointTemplate = template.Must(template.New("DeleteVmCheckpoint").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmName = $arguments.VmName
+$name = $arguments.Name
+
+$vmObject = Get-VM -Name "$($vmName)*" | ?{$_.Name -eq $vmName}
+
+if ($vmObject) {
+	$vmCheckpointObject = Get-VMSnapshot -VM $vmObject | ?{$_.Name -eq $name}
+
+	if ($vmCheckpointObject) {
+		$vmCheckpointObject | Remove-VMSnapshot -IncludeAllChildSnapshots:$arguments.IncludeChildCheckpoints -Confirm:$false
+	}
+}
+`))
+
+func (c *ClientConfig) DeleteVmCheckpoint(ctx context.Context, vmName string, name string, includeChildCheckpoints bool) (err error) {
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, deleteVmCheckpointTemplate, deleteVmCheckpointArgs{
+		VmName:                  vmName,
+		Name:                    name,
+		IncludeChildCheckpoints: includeChildCheckpoints,
+	})
+
+	return err
+}
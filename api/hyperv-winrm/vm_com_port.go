@@ -0,0 +1,133 @@
+package hyperv_winrm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/template"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+type updateVmComPortArgs struct {
+	VmComPortJson string
+}
+
+var updateVmComPortTemplate = template.Must(template.New("UpdateVmComPort").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmComPort = $arguments.VmComPortJson | ConvertFrom-Json
+
+$vmObject = Get-VM -Name "$($vmComPort.VmName)*" | ?{$_.Name -eq $vmComPort.VmName }
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($vmComPort.VmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmComPort.VmName
+}
+
+#An empty path disconnects the com port from its named pipe
+$SetVmComPortArgs = @{}
+$SetVmComPortArgs.VM=$vmObject
+$SetVmComPortArgs.Number=$vmComPort.Number
+$SetVmComPortArgs.Path=$vmComPort.Path
+$SetVmComPortArgs.DebuggerMode=$vmComPort.DebuggerMode
+
+Set-VMComPort @SetVmComPortArgs
+`))
+
+func (c *ClientConfig) UpdateVmComPort(
+	ctx context.Context,
+	vmName string,
+	number int,
+	path string,
+	debuggerMode api.OnOffState,
+) (err error) {
+	vmComPortJson, err := json.Marshal(api.VmComPort{
+		VmName:       vmName,
+		Number:       number,
+		Path:         path,
+		DebuggerMode: debuggerMode,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, updateVmComPortTemplate, updateVmComPortArgs{
+		VmComPortJson: string(vmComPortJson),
+	})
+
+	return err
+}
+
+type getVmComPortsArgs struct {
+	VmName string
+}
+
+var getVmComPortsTemplate = template.Must(template.New("GetVmComPorts").Parse(`
+$ErrorActionPreference = 'Stop'
+$vmComPortsObject = @(Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMComPort | %{ @{
+	Number=$_.Number;
+	Path=if ($_.Path) { $_.Path } else { '' };
+	DebuggerMode=$_.DebuggerMode;
+}})
+
+if ($vmComPortsObject) {
+	$vmComPorts = ConvertTo-Json -InputObject $vmComPortsObject
+	$vmComPorts
+} else {
+	"[]"
+}
+`))
+
+func (c *ClientConfig) GetVmComPorts(ctx context.Context, vmName string) (result []api.VmComPort, err error) {
+	result = make([]api.VmComPort, 0)
+
+	err = c.WinRmClient.RunScriptWithResult(ctx, getVmComPortsTemplate, getVmComPortsArgs{
+		VmName: vmName,
+	}, &result)
+
+	return result, err
+}
+
+// CreateOrUpdateVmComPorts connects the declared com ports to their named pipes and disconnects the other com ports
+func (c *ClientConfig) CreateOrUpdateVmComPorts(ctx context.Context, vmName string, vmComPorts []api.VmComPort) (err error) {
+	desiredVmComPorts := make(map[int]api.VmComPort)
+	for _, vmComPort := range vmComPorts {
+		if vmComPort.Number < api.MinVmComPortNumber || vmComPort.Number > api.MaxVmComPortNumber {
+			return fmt.Errorf("com port number %d should be between %d and %d", vmComPort.Number, api.MinVmComPortNumber, api.MaxVmComPortNumber)
+		}
+
+		if _, ok := desiredVmComPorts[vmComPort.Number]; ok {
+			return fmt.Errorf("only 1 setting allowed per com port, COM%d is declared more than once", vmComPort.Number)
+		}
+
+		desiredVmComPorts[vmComPort.Number] = vmComPort
+	}
+
+	currentVmComPorts, err := c.GetVmComPorts(ctx, vmName)
+	if err != nil {
+		return err
+	}
+
+	for _, currentVmComPort := range currentVmComPorts {
+		desiredVmComPort, ok := desiredVmComPorts[currentVmComPort.Number]
+		if !ok {
+			desiredVmComPort = api.VmComPort{
+				Number:       currentVmComPort.Number,
+				Path:         "",
+				DebuggerMode: api.OnOffState_Off,
+			}
+		}
+
+		if currentVmComPort.Path == desiredVmComPort.Path && currentVmComPort.DebuggerMode == desiredVmComPort.DebuggerMode {
+			continue
+		}
+
+		err = c.UpdateVmComPort(ctx, vmName, desiredVmComPort.Number, desiredVmComPort.Path, desiredVmComPort.DebuggerMode)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -15,7 +15,7 @@ type createVmDvdDriveArgs struct {
 var createVmDvdDriveTemplate = template.Must(template.New("CreateVmDvdDrive").Parse(`
 $ErrorActionPreference = 'Stop'
 Import-Module Hyper-V
-$vmDvdDrive = '{{.VmDvdDriveJson}}' | ConvertFrom-Json
+$vmDvdDrive = $arguments.VmDvdDriveJson | ConvertFrom-Json
 if (!$vmDvdDrive.Path){
 	$vmDvdDrive.Path = $null
 }
@@ -64,7 +64,7 @@ type getVmDvdDrivesArgs struct {
 
 var getVmDvdDrivesTemplate = template.Must(template.New("GetVmDvdDrives").Parse(`
 $ErrorActionPreference = 'Stop'
-$vmDvdDrivesObject = @(Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' } | Get-VMDvdDrive | %{ @{
+$vmDvdDrivesObject = @(Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMDvdDrive | %{ @{
 	ControllerNumber=$_.ControllerNumber;
 	ControllerLocation=$_.ControllerLocation;
 	Path=$_.Path;
@@ -101,12 +101,12 @@ type updateVmDvdDriveArgs struct {
 var updateVmDvdDriveTemplate = template.Must(template.New("UpdateVmDvdDrive").Parse(`
 $ErrorActionPreference = 'Stop'
 Import-Module Hyper-V
-$vmDvdDrive = '{{.VmDvdDriveJson}}' | ConvertFrom-Json
+$vmDvdDrive = $arguments.VmDvdDriveJson | ConvertFrom-Json
 
-$vmDvdDrivesObject = @(Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' } | Get-VMDvdDrive -ControllerLocation {{.ControllerLocation}} -ControllerNumber {{.ControllerNumber}} )
+$vmDvdDrivesObject = @(Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMDvdDrive -ControllerLocation $arguments.ControllerLocation -ControllerNumber $arguments.ControllerNumber )
 
 if (!$vmDvdDrivesObject){
-	throw "VM dvd drive does not exist - {{.ControllerLocation}} {{.ControllerNumber}}"
+	Write-Error -Message "VM dvd drive does not exist - $($arguments.ControllerLocation) $($arguments.ControllerNumber)" -Category ObjectNotFound -ErrorId 'VmDvdDriveNotFound' -TargetObject $arguments.VmName
 }
 
 $SetVmDvdDriveArgs = @{}
@@ -174,7 +174,7 @@ type deleteVmDvdDriveArgs struct {
 var deleteVmDvdDriveTemplate = template.Must(template.New("DeleteVmDvdDrive").Parse(`
 $ErrorActionPreference = 'Stop'
 
-@(Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' } | Get-VMDvdDrive -ControllerNumber {{.ControllerNumber}} -ControllerLocation {{.ControllerLocation}}) | Remove-VMDvdDrive
+@(Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMDvdDrive -ControllerNumber $arguments.ControllerNumber -ControllerLocation $arguments.ControllerLocation) | Remove-VMDvdDrive
 `))
 
 func (c *ClientConfig) DeleteVmDvdDrive(ctx context.Context, vmName string, controllerNumber int, controllerLocation int) (err error) {
@@ -193,6 +193,24 @@ func (c *ClientConfig) CreateOrUpdateVmDvdDrives(ctx context.Context, vmName str
 		return err
 	}
 
+	vm, err := c.GetVm(ctx, vmName)
+	if err != nil {
+		return err
+	}
+
+	// Dvd drives are attached to the Ide controllers of generation 1 vms and to the Scsi controllers of generation 2 vms
+	scsiControllerNumbers := make([]int, 0)
+	if vm.Generation > 1 {
+		for _, dvdDrive := range dvdDrives {
+			scsiControllerNumbers = append(scsiControllerNumbers, dvdDrive.ControllerNumber)
+		}
+
+		err = c.CreateOrUpdateVmScsiControllers(ctx, vmName, scsiControllerNumbers)
+		if err != nil {
+			return err
+		}
+	}
+
 	currentDvdDrivesLength := len(currentDvdDrives)
 	desiredDvdDrivesLength := len(dvdDrives)
 
@@ -243,5 +261,10 @@ func (c *ClientConfig) CreateOrUpdateVmDvdDrives(ctx context.Context, vmName str
 		}
 	}
 
+	if vm.Generation > 1 {
+		// Remove the scsi controllers that were emptied by moving or deleting dvd drives
+		return c.CreateOrUpdateVmScsiControllers(ctx, vmName, scsiControllerNumbers)
+	}
+
 	return nil
 }
@@ -0,0 +1,244 @@
+package hyperv_winrm
+
+import (
+	"context"
+	"encoding/json"
+	"text/template"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+type importVmArgs struct {
+	VmImportJson string
+}
+
+var importVmTemplate = template.Must(template.New("ImportVm").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmImport = $arguments.VmImportJson | ConvertFrom-Json
+#Mode is 0 to register in place, 1 to copy and 2 to copy with a new id
+$mode = [int]$vmImport.Mode
+
+$vmObject = Get-VM -Name "$($vmImport.Name)*" | ?{$_.Name -eq $vmImport.Name}
+
+if ($vmObject){
+	throw "VM already exists - $($vmImport.Name)"
+}
+
+$vmcxPath = $vmImport.Path
+if (Test-Path -LiteralPath $vmcxPath -PathType Container) {
+	$vmcxFiles = @(Get-ChildItem -LiteralPath $vmcxPath -Recurse -Filter '*.vmcx' | ?{$_.Directory.Name -eq 'Virtual Machines'})
+
+	if ($vmcxFiles.Length -eq 0) {
+		Write-Error -Message "Exported VM does not exist - $($vmcxPath)" -Category ObjectNotFound -ErrorId 'VmExportNotFound' -TargetObject $vmcxPath
+	}
+
+	if ($vmcxFiles.Length -gt 1) {
+		throw "Found $($vmcxFiles.Length) exported VMs in $($vmcxPath), specify the path of the vmcx file to import"
+	}
+
+	$vmcxPath = $vmcxFiles[0].FullName
+} elseif (!(Test-Path -LiteralPath $vmcxPath -PathType Leaf)) {
+	Write-Error -Message "Exported VM does not exist - $($vmcxPath)" -Category ObjectNotFound -ErrorId 'VmExportNotFound' -TargetObject $vmcxPath
+}
+
+$CompareVmArgs = @{
+	Path=$vmcxPath
+}
+
+if ($mode -eq 0) {
+	$CompareVmArgs.Register=$true
+} else {
+	$CompareVmArgs.Copy=$true
+
+	if ($mode -eq 2) {
+		$CompareVmArgs.GenerateNewId=$true
+	}
+
+	if ($vmImport.VirtualMachinePath) {
+		$CompareVmArgs.VirtualMachinePath=$vmImport.VirtualMachinePath
+	}
+
+	if ($vmImport.VhdDestinationPath) {
+		$CompareVmArgs.VhdDestinationPath=$vmImport.VhdDestinationPath
+	}
+
+	if ($vmImport.SnapshotFilePath) {
+		$CompareVmArgs.SnapshotFilePath=$vmImport.SnapshotFilePath
+	}
+
+	if ($vmImport.SmartPagingFilePath) {
+		$CompareVmArgs.SmartPagingFilePath=$vmImport.SmartPagingFilePath
+	}
+}
+
+$compatibilityReport = Compare-VM @CompareVmArgs
+
+#Nothing has been copied or registered yet, so a generation mismatch leaves nothing behind
+if ($compatibilityReport.VM.Generation -ne $vmImport.Generation) {
+	throw "Exported VM $($vmcxPath) is generation $($compatibilityReport.VM.Generation), but generation $($vmImport.Generation) is declared"
+}
+
+#Network adapters connected to switches that don't exist on this host are disconnected, the declared network adapters are applied after the import
+foreach ($incompatibility in $compatibilityReport.Incompatibilities) {
+	if ($incompatibility.MessageId -eq 33012) {
+		$incompatibility.Source | Disconnect-VMNetworkAdapter
+	}
+}
+
+$vmObject = Import-VM -CompatibilityReport $compatibilityReport
+
+if ($vmObject.Name -ne $vmImport.Name) {
+	Rename-VM -VM $vmObject -NewName $vmImport.Name
+}
+`))
+
+func (c *ClientConfig) ImportVm(
+	ctx context.Context,
+	name string,
+	path string,
+	mode api.VmImportMode,
+	generation int,
+	virtualMachinePath string,
+	vhdDestinationPath string,
+	snapshotFilePath string,
+	smartPagingFilePath string,
+) (err error) {
+	release, err := c.acquireHeavyOperation(ctx, "ImportVm")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	vmImportJson, err := json.Marshal(api.VmImport{
+		Name:                name,
+		Path:                path,
+		Mode:                mode,
+		Generation:          generation,
+		VirtualMachinePath:  virtualMachinePath,
+		VhdDestinationPath:  vhdDestinationPath,
+		SnapshotFilePath:    snapshotFilePath,
+		SmartPagingFilePath: smartPagingFilePath,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, importVmTemplate, importVmArgs{
+		VmImportJson: string(vmImportJson),
+	})
+
+	return err
+}
+
+type exportVmArgs struct {
+	VmName         string
+	Path           string
+	CheckpointName string
+}
+
+var exportVmTemplate = template.Must(template.New("ExportVm").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmName = $arguments.VmName
+$path = $arguments.Path
+$checkpointName = $arguments.CheckpointName
+
+$vmObject = Get-VM -Name "$($vmName)*" | ?{$_.Name -eq $vmName}
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($vmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmName
+}
+
+$exportPath = Join-Path $path $vmName
+if (Test-Path -LiteralPath $exportPath) {
+	Write-Error -Message "VM export already exists - $($exportPath)" -Category ResourceExists -ErrorId 'VmExportExists' -TargetObject $exportPath
+}
+
+if ($checkpointName) {
+	$vmCheckpointObject = Get-VMSnapshot -VM $vmObject | ?{$_.Name -eq $checkpointName}
+
+	if (!$vmCheckpointObject){
+		Write-Error -Message "VM checkpoint does not exist - $($checkpointName)" -Category ObjectNotFound -ErrorId 'VmCheckpointNotFound' -TargetObject $checkpointName
+	}
+
+	$vmCheckpointObject | Export-VMSnapshot -Path $path
+} else {
+	Export-VM -VM $vmObject -Path $path
+}
+`))
+
+func (c *ClientConfig) ExportVm(ctx context.Context, vmName string, path string, checkpointName string) (err error) {
+	release, err := c.acquireHeavyOperation(ctx, "ExportVm")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, exportVmTemplate, exportVmArgs{
+		VmName:         vmName,
+		Path:           path,
+		CheckpointName: checkpointName,
+	})
+
+	return err
+}
+
+type getVmExportArgs struct {
+	VmName string
+	Path   string
+}
+
+var getVmExportTemplate = template.Must(template.New("GetVmExport").Parse(`
+$ErrorActionPreference = 'Stop'
+$vmName = $arguments.VmName
+$path = $arguments.Path
+$exportPath = Join-Path $path $vmName
+
+$vmcxFile = Get-ChildItem -LiteralPath (Join-Path $exportPath 'Virtual Machines') -Filter '*.vmcx' -ErrorAction SilentlyContinue | Select-Object -First 1
+
+if ($vmcxFile) {
+	$vmExport = ConvertTo-Json -InputObject @{
+		VmName=$vmName;
+		Path=$path;
+		ExportPath=$exportPath;
+		VmcxPath=$vmcxFile.FullName;
+	}
+	$vmExport
+} else {
+	Write-Error -Message "VM export does not exist - $($exportPath)" -Category ObjectNotFound -ErrorId 'VmExportNotFound' -TargetObject $exportPath
+}
+`))
+
+func (c *ClientConfig) GetVmExport(ctx context.Context, vmName string, path string) (result api.VmExport, err error) {
+	err = c.WinRmClient.RunScriptWithResult(ctx, getVmExportTemplate, getVmExportArgs{
+		VmName: vmName,
+		Path:   path,
+	}, &result)
+
+	return result, err
+}
+
+type deleteVmExportArgs struct {
+	VmName string
+	Path   string
+}
+
+var deleteVmExportTemplate = template.Must(template.New("DeleteVmExport").Parse(`
+$ErrorActionPreference = 'Stop'
+$exportPath = Join-Path $arguments.Path $arguments.VmName
+
+if (Test-Path -LiteralPath $exportPath) {
+	Remove-Item -LiteralPath $exportPath -Recurse -Force
+}
+`))
+
+func (c *ClientConfig) DeleteVmExport(ctx context.Context, vmName string, path string) (err error) {
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, deleteVmExportTemplate, deleteVmExportArgs{
+		VmName: vmName,
+		Path:   path,
+	})
+
+	return err
+}
@@ -16,7 +16,7 @@ type createOrUpdateVmFirmwareArgs struct {
 var createOrUpdateVmFirmwareTemplate = template.Must(template.New("CreateOrUpdateVmFirmware").Parse(`
 $ErrorActionPreference = 'Stop'
 Import-Module Hyper-V
-$vmFirmware = '{{.VmFirmwareJson}}' | ConvertFrom-Json
+$vmFirmware = $arguments.VmFirmwareJson | ConvertFrom-Json
 
 $bootOrders = @($vmFirmware.BootOrders | %{
 	$bootOrder = $_
@@ -81,6 +81,63 @@ $SetVMFirmwareArgs.ConsoleMode=$vmFirmware.ConsoleMode
 $SetVMFirmwareArgs.PauseAfterBootFailure=$vmFirmware.PauseAfterBootFailure
 
 Set-VMFirmware @SetVMFirmwareArgs
+
+$vmObject = Get-VM -Name "$($vmFirmware.VmName)*" | ?{$_.Name -eq $vmFirmware.VmName }
+$vmSecurity = Get-VMSecurity -VM $vmObject
+
+#KeyProtector is 0 for none, 1 for a new local key protector and 2 for a key protector owned by an hgs guardian
+$keyProtector = [int]$vmFirmware.KeyProtector
+#A new local key protector is owned by the UntrustedGuardian of the host
+$hgsGuardianName = if ($keyProtector -eq 1) { 'UntrustedGuardian' } elseif ($keyProtector -eq 2) { $vmFirmware.HgsGuardianName } else { '' }
+
+$currentHgsGuardianName = ''
+$rawKeyProtector = [byte[]](Get-VMKeyProtector -VM $vmObject)
+if ($rawKeyProtector -and $rawKeyProtector.Length -gt 4) {
+	#ConvertTo-HgsKeyProtector needs the HgsClient module, without it only a new local key protector can have been set
+	if (Get-Command ConvertTo-HgsKeyProtector -ErrorAction SilentlyContinue) {
+		$currentHgsGuardianName = (ConvertTo-HgsKeyProtector -Bytes $rawKeyProtector).Owner.Name
+	} else {
+		$currentHgsGuardianName = 'UntrustedGuardian'
+	}
+}
+
+if (!$vmFirmware.Shielded -and $vmSecurity.Shielded) {
+	Set-VMSecurityPolicy -VM $vmObject -Shielded $false
+}
+
+if ($currentHgsGuardianName -ne $hgsGuardianName) {
+	if ($keyProtector -eq 0) {
+		throw "Unable to remove key protector owned by $($currentHgsGuardianName) from vm $($vmFirmware.VmName)"
+	} elseif ($keyProtector -eq 1) {
+		Set-VMKeyProtector -VM $vmObject -NewLocalKeyProtector
+	} else {
+		$hgsGuardian = Get-HgsGuardian -Name $hgsGuardianName -ErrorAction SilentlyContinue
+		if (!$hgsGuardian) {
+			$hgsGuardian = New-HgsGuardian -Name $hgsGuardianName -GenerateCertificates
+		}
+
+		$hgsKeyProtector = New-HgsKeyProtector -Owner $hgsGuardian -AllowUntrustedRoot
+		Set-VMKeyProtector -VM $vmObject -KeyProtector $hgsKeyProtector.RawData
+	}
+}
+
+if ($vmFirmware.TpmEnabled -and !$vmSecurity.TpmEnabled) {
+	if (!$hgsGuardianName) {
+		throw "Unable to enable tpm of vm $($vmFirmware.VmName) without a key protector"
+	}
+
+	Enable-VMTPM -VM $vmObject
+} elseif (!$vmFirmware.TpmEnabled -and $vmSecurity.TpmEnabled) {
+	Disable-VMTPM -VM $vmObject
+}
+
+if ($vmFirmware.EncryptStateAndVmMigrationTraffic -ne $vmSecurity.EncryptStateAndVmMigrationTraffic -or $vmFirmware.VirtualizationBasedSecurityOptOut -ne $vmSecurity.VirtualizationBasedSecurityOptOut) {
+	Set-VMSecurity -VM $vmObject -EncryptStateAndVmMigrationTraffic $vmFirmware.EncryptStateAndVmMigrationTraffic -VirtualizationBasedSecurityOptOut $vmFirmware.VirtualizationBasedSecurityOptOut
+}
+
+if ($vmFirmware.Shielded -and !$vmSecurity.Shielded) {
+	Set-VMSecurityPolicy -VM $vmObject -Shielded $true
+}
 `))
 
 func (c *ClientConfig) CreateOrUpdateVmFirmware(
@@ -92,6 +149,12 @@ func (c *ClientConfig) CreateOrUpdateVmFirmware(
 	preferredNetworkBootProtocol api.IPProtocolPreference,
 	consoleMode api.ConsoleModeType,
 	pauseAfterBootFailure api.OnOffState,
+	tpmEnabled bool,
+	keyProtector api.VmKeyProtectorType,
+	hgsGuardianName string,
+	encryptStateAndVmMigrationTraffic bool,
+	shielded bool,
+	virtualizationBasedSecurityOptOut bool,
 ) (err error) {
 	vmFirmwareJson, err := json.Marshal(api.VmFirmware{
 		VmName:                       vmName,
@@ -101,6 +164,13 @@ func (c *ClientConfig) CreateOrUpdateVmFirmware(
 		PreferredNetworkBootProtocol: preferredNetworkBootProtocol,
 		ConsoleMode:                  consoleMode,
 		PauseAfterBootFailure:        pauseAfterBootFailure,
+
+		TpmEnabled:                        tpmEnabled,
+		KeyProtector:                      keyProtector,
+		HgsGuardianName:                   hgsGuardianName,
+		EncryptStateAndVmMigrationTraffic: encryptStateAndVmMigrationTraffic,
+		Shielded:                          shielded,
+		VirtualizationBasedSecurityOptOut: virtualizationBasedSecurityOptOut,
 	})
 
 	if err != nil {
@@ -121,7 +191,34 @@ type getVmFirmwareArgs struct {
 var getVmFirmwareTemplate = template.Must(template.New("GetVmFirmware").Parse(`
 $ErrorActionPreference = 'Stop'
 
-$vmFirmwareObject = Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' } | Get-VMFirmware | %{ @{
+$vmObject = Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName }
+
+$keyProtector = 'None'
+$hgsGuardianName = ''
+$vmSecurity = $null
+if ($vmObject) {
+	$vmSecurity = Get-VMSecurity -VM $vmObject
+
+	$rawKeyProtector = [byte[]](Get-VMKeyProtector -VM $vmObject)
+	if ($rawKeyProtector -and $rawKeyProtector.Length -gt 4) {
+		#ConvertTo-HgsKeyProtector needs the HgsClient module, without it only a new local key protector can have been set
+		if (Get-Command ConvertTo-HgsKeyProtector -ErrorAction SilentlyContinue) {
+			$hgsGuardianName = (ConvertTo-HgsKeyProtector -Bytes $rawKeyProtector).Owner.Name
+		} else {
+			$hgsGuardianName = 'UntrustedGuardian'
+		}
+
+		#A new local key protector is owned by the UntrustedGuardian of the host
+		if ($hgsGuardianName -eq 'UntrustedGuardian') {
+			$keyProtector = 'NewLocalKeyProtector'
+			$hgsGuardianName = ''
+		} else {
+			$keyProtector = 'HgsGuardian'
+		}
+	}
+}
+
+$vmFirmwareObject = $vmObject | Get-VMFirmware | %{ @{
 	BootOrders= @($_.BootOrder | %{
 		if ($_.BootType -eq 'Network') {
 			@{Type='NetworkAdapter';NetworkAdapterName=$_.Device.Name;SwitchName=$_.Device.SwitchName;MacAddress=$_.Device.MacAddress;Path='';ControllerNumber=-1;ControllerLocation=-1;}
@@ -134,6 +231,12 @@ $vmFirmwareObject = Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' }
 	PreferredNetworkBootProtocol= $_.PreferredNetworkBootProtocol
 	ConsoleMode=                  $_.ConsoleMode
 	PauseAfterBootFailure=        $_.PauseAfterBootFailure
+	TpmEnabled=                        $vmSecurity.TpmEnabled
+	KeyProtector=                      $keyProtector
+	HgsGuardianName=                   $hgsGuardianName
+	EncryptStateAndVmMigrationTraffic= $vmSecurity.EncryptStateAndVmMigrationTraffic
+	Shielded=                          $vmSecurity.Shielded
+	VirtualizationBasedSecurityOptOut= $vmSecurity.VirtualizationBasedSecurityOptOut
 }}
 
 if ($vmFirmwareObject) {
@@ -184,5 +287,11 @@ func (c *ClientConfig) CreateOrUpdateVmFirmwares(ctx context.Context, vmName str
 		vmFirmware.PreferredNetworkBootProtocol,
 		vmFirmware.ConsoleMode,
 		vmFirmware.PauseAfterBootFailure,
+		vmFirmware.TpmEnabled,
+		vmFirmware.KeyProtector,
+		vmFirmware.HgsGuardianName,
+		vmFirmware.EncryptStateAndVmMigrationTraffic,
+		vmFirmware.Shielded,
+		vmFirmware.VirtualizationBasedSecurityOptOut,
 	)
 }
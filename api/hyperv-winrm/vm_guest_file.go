@@ -0,0 +1,88 @@
+package hyperv_winrm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"text/template"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
+)
+
+type copyVmGuestFileArgs struct {
+	VmGuestFileJson string
+}
+
+var copyVmGuestFileTemplate = template.Must(template.New("CopyVmGuestFile").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmGuestFile = $arguments.VmGuestFileJson | ConvertFrom-Json
+
+$vmObject = Get-VM -Name "$($vmGuestFile.VmName)*" | ?{$_.Name -eq $vmGuestFile.VmName }
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($vmGuestFile.VmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmGuestFile.VmName
+}
+
+if ($vmObject.State -ne 'Running') {
+	throw "VM $($vmGuestFile.VmName) must be running to copy $($vmGuestFile.DestinationFilePath) into the guest - state is $($vmObject.State)"
+}
+
+Copy-VMFile -VM $vmObject -SourcePath $vmGuestFile.SourceFilePath -DestinationPath $vmGuestFile.DestinationFilePath -FileSource Host -CreateFullPath:$vmGuestFile.CreateFullPath -Force
+`))
+
+func (c *ClientConfig) CopyVmGuestFile(ctx context.Context, vmName string, sourceFilePath string, destinationFilePath string, createFullPath bool) (err error) {
+	integrationServices, err := c.GetVmIntegrationServices(ctx, vmName)
+	if err != nil {
+		return err
+	}
+
+	for _, integrationService := range integrationServices {
+		if integrationService.Name == api.VmGuestFileIntegrationService && !integrationService.Enabled {
+			err = c.EnableVmIntegrationService(ctx, vmName, integrationService.Name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	release, err := c.acquireHeavyOperation(ctx, "CopyVmGuestFile")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Stage the file under a temporary name so that it is cleaned up with the other temporary items if the copy is
+	// interrupted
+	stagedFilePath := fmt.Sprintf(`$env:TEMP\%s%s-%s-%s`, powershell.TemporaryItemPrefix, powershell.SessionId, powershell.TimeOrderedUUID(), filepath.Base(sourceFilePath))
+	stagedFilePath, err = c.WinRmClient.UploadFile(ctx, sourceFilePath, stagedFilePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		deleteErr := c.WinRmClient.DeleteFileOrDirectory(ctx, stagedFilePath)
+		if deleteErr != nil {
+			log.Printf("[WARN] Unable to delete staged file %s: %s", stagedFilePath, deleteErr)
+		}
+	}()
+
+	vmGuestFileJson, err := json.Marshal(api.VmGuestFile{
+		VmName:              vmName,
+		SourceFilePath:      stagedFilePath,
+		DestinationFilePath: destinationFilePath,
+		CreateFullPath:      createFullPath,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, copyVmGuestFileTemplate, copyVmGuestFileArgs{
+		VmGuestFileJson: string(vmGuestFileJson),
+	})
+
+	return err
+}
@@ -0,0 +1,75 @@
+package hyperv_winrm
+
+import (
+	"context"
+	"text/template"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+type runVmGuestScriptArgs struct {
+	VmName   string
+	Username string
+	Password string `sensitive:"true"`
+	Script   string
+}
+
+var runVmGuestScriptTemplate = template.Must(template.New("RunVmGuestScript").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+
+$vmObject = Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName }
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($arguments.VmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $arguments.VmName
+}
+
+if ($vmObject.State -ne 'Running') {
+	throw "VM $($arguments.VmName) must be running to run a script in the guest - state is $($vmObject.State)"
+}
+
+$credential = New-Object System.Management.Automation.PSCredential($arguments.Username, (ConvertTo-SecureString -String $arguments.Password -AsPlainText -Force))
+
+$vmGuestScriptResultObject = Invoke-Command -VMId $vmObject.Id -Credential $credential -ArgumentList $arguments.Script -ScriptBlock {
+	param($script)
+	$ErrorActionPreference = 'Stop'
+
+	#The script runs in its own process so that exit and the output streams of the script are captured
+	$name = "terraform-$([guid]::NewGuid())"
+	$scriptPath = Join-Path $env:TEMP "$($name).ps1"
+	$stdoutPath = Join-Path $env:TEMP "$($name).stdout"
+	$stderrPath = Join-Path $env:TEMP "$($name).stderr"
+
+	try {
+		[System.IO.File]::WriteAllText($scriptPath, $script, (New-Object System.Text.UTF8Encoding $true))
+
+		$process = Start-Process -FilePath 'powershell.exe' -ArgumentList @('-NoProfile', '-NonInteractive', '-ExecutionPolicy', 'Bypass', '-File', ('"' + $scriptPath + '"')) -RedirectStandardOutput $stdoutPath -RedirectStandardError $stderrPath -NoNewWindow -Wait -PassThru
+
+		@{
+			ExitCode=$process.ExitCode;
+			Stdout=[System.IO.File]::ReadAllText($stdoutPath);
+			Stderr=[System.IO.File]::ReadAllText($stderrPath);
+		}
+	} finally {
+		Remove-Item -LiteralPath $scriptPath, $stdoutPath, $stderrPath -Force -ErrorAction SilentlyContinue
+	}
+}
+
+$vmGuestScriptResult = ConvertTo-Json -InputObject @{
+	ExitCode=$vmGuestScriptResultObject.ExitCode;
+	Stdout=$vmGuestScriptResultObject.Stdout;
+	Stderr=$vmGuestScriptResultObject.Stderr;
+}
+$vmGuestScriptResult
+`))
+
+func (c *ClientConfig) RunVmGuestScript(ctx context.Context, vmName string, username string, password string, script string) (result api.VmGuestScriptResult, err error) {
+	err = c.WinRmClient.RunScriptWithResult(ctx, runVmGuestScriptTemplate, runVmGuestScriptArgs{
+		VmName:   vmName,
+		Username: username,
+		Password: password,
+		Script:   script,
+	}, &result)
+
+	return result, err
+}
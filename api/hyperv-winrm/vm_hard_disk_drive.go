@@ -15,7 +15,7 @@ type createVmHardDiskDriveArgs struct {
 var createVmHardDiskDriveTemplate = template.Must(template.New("CreateVmHardDiskDrive").Parse(`
 $ErrorActionPreference = 'Stop'
 Import-Module Hyper-V
-$vmHardDiskDrive = '{{.VmHardDiskDriveJson}}' | ConvertFrom-Json
+$vmHardDiskDrive = $arguments.VmHardDiskDriveJson | ConvertFrom-Json
 
 $NewVmHardDiskDriveArgs = @{
 	VmName=$vmHardDiskDrive.VmName
@@ -87,7 +87,7 @@ type getVmHardDiskDrivesArgs struct {
 
 var getVmHardDiskDrivesTemplate = template.Must(template.New("GetVmHardDiskDrives").Parse(`
 $ErrorActionPreference = 'Stop'
-$vmHardDiskDrivesObject = @(Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' } | Get-VMHardDiskDrive | %{ @{
+$vmHardDiskDrivesObject = @(Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMHardDiskDrive | %{ @{
 	ControllerType=$_.ControllerType;
 	ControllerNumber=$_.ControllerNumber;
 	ControllerLocation=$_.ControllerLocation;
@@ -129,12 +129,12 @@ type updateVmHardDiskDriveArgs struct {
 var updateVmHardDiskDriveTemplate = template.Must(template.New("UpdateVmHardDiskDrive").Parse(`
 $ErrorActionPreference = 'Stop'
 Import-Module Hyper-V
-$vmHardDiskDrive = '{{.VmHardDiskDriveJson}}' | ConvertFrom-Json
+$vmHardDiskDrive = $arguments.VmHardDiskDriveJson | ConvertFrom-Json
 
-$vmHardDiskDrivesObject = @(Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' } | Get-VMHardDiskDrive -ControllerLocation {{.ControllerLocation}} -ControllerNumber {{.ControllerNumber}} )
+$vmHardDiskDrivesObject = @(Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMHardDiskDrive -ControllerLocation $arguments.ControllerLocation -ControllerNumber $arguments.ControllerNumber )
 
 if (!$vmHardDiskDrivesObject){
-	throw "VM hard disk drive does not exist - {{.ControllerLocation}} {{.ControllerNumber}}"
+	Write-Error -Message "VM hard disk drive does not exist - $($arguments.ControllerLocation) $($arguments.ControllerNumber)" -Category ObjectNotFound -ErrorId 'VmHardDiskDriveNotFound' -TargetObject $arguments.VmName
 }
 
 $SetVmHardDiskDriveArgs = @{}
@@ -221,7 +221,7 @@ type deleteVmHardDiskDriveArgs struct {
 var deleteVmHardDiskDriveTemplate = template.Must(template.New("DeleteVmHardDiskDrive").Parse(`
 $ErrorActionPreference = 'Stop'
 
-@(Get-VMHardDiskDrive -VmName '{{.VmName}}' -ControllerNumber {{.ControllerNumber}} -ControllerLocation {{.ControllerLocation}}) | Remove-VMHardDiskDrive
+@(Get-VMHardDiskDrive -VmName $arguments.VmName -ControllerNumber $arguments.ControllerNumber -ControllerLocation $arguments.ControllerLocation) | Remove-VMHardDiskDrive
 `))
 
 func (c *ClientConfig) DeleteVmHardDiskDrive(ctx context.Context, vmname string, controllerNumber int32, controllerLocation int32) (err error) {
@@ -240,6 +240,18 @@ func (c *ClientConfig) CreateOrUpdateVmHardDiskDrives(ctx context.Context, vmNam
 		return err
 	}
 
+	scsiControllerNumbers := make([]int, 0)
+	for _, hardDiskDrive := range hardDiskDrives {
+		if hardDiskDrive.ControllerType == api.ControllerType_Scsi {
+			scsiControllerNumbers = append(scsiControllerNumbers, int(hardDiskDrive.ControllerNumber))
+		}
+	}
+
+	err = c.CreateOrUpdateVmScsiControllers(ctx, vmName, scsiControllerNumbers)
+	if err != nil {
+		return err
+	}
+
 	currentHardDiskDrivesLength := len(currentHardDiskDrives)
 	desiredHardDiskDrivesLength := len(hardDiskDrives)
 
@@ -304,5 +316,6 @@ func (c *ClientConfig) CreateOrUpdateVmHardDiskDrives(ctx context.Context, vmNam
 		}
 	}
 
-	return nil
+	// Remove the scsi controllers that were emptied by moving or deleting hard disk drives
+	return c.CreateOrUpdateVmScsiControllers(ctx, vmName, scsiControllerNumbers)
 }
@@ -13,7 +13,7 @@ type getVmIntegrationServicesArgs struct {
 
 var getVmIntegrationServicesTemplate = template.Must(template.New("GetVmIntegrationServices").Parse(`
 $ErrorActionPreference = 'Stop'
-$vmIntegrationServicesObject = @(Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' } | Get-VMIntegrationService | %{ @{
+$vmIntegrationServicesObject = @(Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMIntegrationService | %{ @{
 	Name=$_.Name;
 	Enabled=$_.Enabled;
 }})
@@ -42,7 +42,7 @@ type enableVmIntegrationServiceArgs struct {
 var enableVmIntegrationServiceTemplate = template.Must(template.New("EnableVmIntegrationService").Parse(`
 $ErrorActionPreference = 'Stop'
 
-Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' } | Enable-VMIntegrationService -Name '{{.Name}}'
+Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Enable-VMIntegrationService -Name $arguments.Name
 `))
 
 func (c *ClientConfig) EnableVmIntegrationService(ctx context.Context, vmName string, name string) (err error) {
@@ -62,7 +62,7 @@ type disableVmIntegrationServiceArgs struct {
 var disableVmIntegrationServiceTemplate = template.Must(template.New("DisableVmIntegrationService").Parse(`
 $ErrorActionPreference = 'Stop'
 
-Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' } | Disable-VMIntegrationService -Name '{{.Name}}'
+Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Disable-VMIntegrationService -Name $arguments.Name
 `))
 
 func (c *ClientConfig) DisableVmIntegrationService(ctx context.Context, vmName string, name string) (err error) {
@@ -0,0 +1,222 @@
+package hyperv_winrm
+
+import (
+	"context"
+	"encoding/json"
+	"text/template"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+type createOrUpdateVmKvpItemArgs struct {
+	VmKvpItemJson string
+}
+
+var createOrUpdateVmKvpItemTemplate = template.Must(template.New("CreateOrUpdateVmKvpItem").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmKvpItem = $arguments.VmKvpItemJson | ConvertFrom-Json
+
+$vmObject = Get-VM -Name "$($vmKvpItem.VmName)*" | ?{$_.Name -eq $vmKvpItem.VmName }
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($vmKvpItem.VmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmKvpItem.VmName
+}
+
+$virtualSystemManagementService = Get-WmiObject -Namespace root\virtualization\v2 -Class Msvm_VirtualSystemManagementService
+$computerSystem = Get-WmiObject -Namespace root\virtualization\v2 -Class Msvm_ComputerSystem -Filter "Name='$($vmObject.Id)'"
+$kvpExchangeComponentSettingData = $computerSystem.GetRelated('Msvm_VirtualSystemSettingData') | ?{ $_.VirtualSystemType -eq 'Microsoft:Hyper-V:System:Realized' } | %{ $_.GetRelated('Msvm_KvpExchangeComponentSettingData') }
+
+$exists = @($kvpExchangeComponentSettingData.HostExchangeItems | ?{ $_ } | ?{ ([xml]$_).SelectSingleNode("/INSTANCE/PROPERTY[@NAME='Name']/VALUE").InnerText -eq $vmKvpItem.Name }).Count -gt 0
+
+$kvpExchangeDataItem = ([WmiClass]"\\$($virtualSystemManagementService.__SERVER)\root\virtualization\v2:Msvm_KvpExchangeDataItem").CreateInstance()
+$kvpExchangeDataItem.Name = $vmKvpItem.Name
+$kvpExchangeDataItem.Data = $vmKvpItem.Data
+#Source 0 is a host to guest item
+$kvpExchangeDataItem.Source = 0
+
+if ($exists) {
+	$result = $virtualSystemManagementService.ModifyKvpItems($computerSystem, @($kvpExchangeDataItem.PSBase.GetText(1)))
+} else {
+	$result = $virtualSystemManagementService.AddKvpItems($computerSystem, @($kvpExchangeDataItem.PSBase.GetText(1)))
+}
+
+#4096 means the items are changed by a job
+if ($result.ReturnValue -eq 4096) {
+	$job = [WMI]$result.Job
+	while ($job.JobState -eq 3 -or $job.JobState -eq 4) {
+		Start-Sleep -Milliseconds 100
+		$job = [WMI]$result.Job
+	}
+
+	if ($job.JobState -ne 7) {
+		throw "Unable to set kvp item $($vmKvpItem.Name) of vm $($vmKvpItem.VmName) - $($job.ErrorDescription)"
+	}
+} elseif ($result.ReturnValue -ne 0) {
+	throw "Unable to set kvp item $($vmKvpItem.Name) of vm $($vmKvpItem.VmName) - return value $($result.ReturnValue)"
+}
+`))
+
+func (c *ClientConfig) CreateOrUpdateVmKvpItem(ctx context.Context, vmName string, name string, data string) (err error) {
+	vmKvpItemJson, err := json.Marshal(api.VmKvpItem{
+		VmName: vmName,
+		Name:   name,
+		Data:   data,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, createOrUpdateVmKvpItemTemplate, createOrUpdateVmKvpItemArgs{
+		VmKvpItemJson: string(vmKvpItemJson),
+	})
+
+	return err
+}
+
+type getVmKvpItemsArgs struct {
+	VmName string
+}
+
+var getVmKvpItemsTemplate = template.Must(template.New("GetVmKvpItems").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+
+$vmObject = Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName }
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($arguments.VmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $arguments.VmName
+}
+
+$computerSystem = Get-WmiObject -Namespace root\virtualization\v2 -Class Msvm_ComputerSystem -Filter "Name='$($vmObject.Id)'"
+$kvpExchangeComponentSettingData = $computerSystem.GetRelated('Msvm_VirtualSystemSettingData') | ?{ $_.VirtualSystemType -eq 'Microsoft:Hyper-V:System:Realized' } | %{ $_.GetRelated('Msvm_KvpExchangeComponentSettingData') }
+
+$vmKvpItemsObject = @($kvpExchangeComponentSettingData.HostExchangeItems | ?{ $_ } | %{
+	$kvpExchangeDataItem = [xml]$_
+	@{
+		Name=$kvpExchangeDataItem.SelectSingleNode("/INSTANCE/PROPERTY[@NAME='Name']/VALUE").InnerText;
+		Data=$kvpExchangeDataItem.SelectSingleNode("/INSTANCE/PROPERTY[@NAME='Data']/VALUE").InnerText;
+	}
+})
+
+if ($vmKvpItemsObject) {
+	$vmKvpItems = ConvertTo-Json -InputObject $vmKvpItemsObject
+	$vmKvpItems
+} else {
+	"[]"
+}
+`))
+
+func (c *ClientConfig) GetVmKvpItems(ctx context.Context, vmName string) (result []api.VmKvpItem, err error) {
+	result = make([]api.VmKvpItem, 0)
+
+	err = c.WinRmClient.RunScriptWithResult(ctx, getVmKvpItemsTemplate, getVmKvpItemsArgs{
+		VmName: vmName,
+	}, &result)
+
+	return result, err
+}
+
+type deleteVmKvpItemArgs struct {
+	VmName string
+	Name   string
+}
+
+var deleteVmKvpItemTemplate = template.Must(template.New("DeleteVmKvpItem").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+
+$vmObject = Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName }
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($arguments.VmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $arguments.VmName
+}
+
+$virtualSystemManagementService = Get-WmiObject -Namespace root\virtualization\v2 -Class Msvm_VirtualSystemManagementService
+$computerSystem = Get-WmiObject -Namespace root\virtualization\v2 -Class Msvm_ComputerSystem -Filter "Name='$($vmObject.Id)'"
+$kvpExchangeComponentSettingData = $computerSystem.GetRelated('Msvm_VirtualSystemSettingData') | ?{ $_.VirtualSystemType -eq 'Microsoft:Hyper-V:System:Realized' } | %{ $_.GetRelated('Msvm_KvpExchangeComponentSettingData') }
+
+$exists = @($kvpExchangeComponentSettingData.HostExchangeItems | ?{ $_ } | ?{ ([xml]$_).SelectSingleNode("/INSTANCE/PROPERTY[@NAME='Name']/VALUE").InnerText -eq $arguments.Name }).Count -gt 0
+
+if ($exists) {
+	$kvpExchangeDataItem = ([WmiClass]"\\$($virtualSystemManagementService.__SERVER)\root\virtualization\v2:Msvm_KvpExchangeDataItem").CreateInstance()
+	$kvpExchangeDataItem.Name = $arguments.Name
+	$kvpExchangeDataItem.Data = [String]::Empty
+	#Source 0 is a host to guest item
+	$kvpExchangeDataItem.Source = 0
+
+	$result = $virtualSystemManagementService.RemoveKvpItems($computerSystem, @($kvpExchangeDataItem.PSBase.GetText(1)))
+
+	#4096 means the items are changed by a job
+	if ($result.ReturnValue -eq 4096) {
+		$job = [WMI]$result.Job
+		while ($job.JobState -eq 3 -or $job.JobState -eq 4) {
+			Start-Sleep -Milliseconds 100
+			$job = [WMI]$result.Job
+		}
+
+		if ($job.JobState -ne 7) {
+			throw "Unable to remove kvp item $($arguments.Name) of vm $($arguments.VmName) - $($job.ErrorDescription)"
+		}
+	} elseif ($result.ReturnValue -ne 0) {
+		throw "Unable to remove kvp item $($arguments.Name) of vm $($arguments.VmName) - return value $($result.ReturnValue)"
+	}
+}
+`))
+
+func (c *ClientConfig) DeleteVmKvpItem(ctx context.Context, vmName string, name string) (err error) {
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, deleteVmKvpItemTemplate, deleteVmKvpItemArgs{
+		VmName: vmName,
+		Name:   name,
+	})
+
+	return err
+}
+
+type getVmGuestKvpItemsArgs struct {
+	VmName string
+}
+
+var getVmGuestKvpItemsTemplate = template.Must(template.New("GetVmGuestKvpItems").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+
+$vmObject = Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName }
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($arguments.VmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $arguments.VmName
+}
+
+$computerSystem = Get-WmiObject -Namespace root\virtualization\v2 -Class Msvm_ComputerSystem -Filter "Name='$($vmObject.Id)'"
+#The kvp exchange component only exists while the vm is running
+$kvpExchangeComponent = $computerSystem.GetRelated('Msvm_KvpExchangeComponent') | select -First 1
+
+$intrinsicItems = @{}
+$kvpExchangeComponent.GuestIntrinsicExchangeItems | ?{ $_ } | %{
+	$kvpExchangeDataItem = [xml]$_
+	$intrinsicItems[$kvpExchangeDataItem.SelectSingleNode("/INSTANCE/PROPERTY[@NAME='Name']/VALUE").InnerText] = $kvpExchangeDataItem.SelectSingleNode("/INSTANCE/PROPERTY[@NAME='Data']/VALUE").InnerText
+}
+
+$items = @{}
+$kvpExchangeComponent.GuestExchangeItems | ?{ $_ } | %{
+	$kvpExchangeDataItem = [xml]$_
+	$items[$kvpExchangeDataItem.SelectSingleNode("/INSTANCE/PROPERTY[@NAME='Name']/VALUE").InnerText] = $kvpExchangeDataItem.SelectSingleNode("/INSTANCE/PROPERTY[@NAME='Data']/VALUE").InnerText
+}
+
+$vmGuestKvpItemsObject = @{
+	IntrinsicItems=$intrinsicItems;
+	Items=$items;
+}
+
+$vmGuestKvpItems = ConvertTo-Json -InputObject $vmGuestKvpItemsObject
+$vmGuestKvpItems
+`))
+
+func (c *ClientConfig) GetVmGuestKvpItems(ctx context.Context, vmName string) (result api.VmGuestKvpItems, err error) {
+	err = c.WinRmClient.RunScriptWithResult(ctx, getVmGuestKvpItemsTemplate, getVmGuestKvpItemsArgs{
+		VmName: vmName,
+	}, &result)
+
+	return result, err
+}
@@ -0,0 +1,122 @@
+package hyperv_winrm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/template"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+type createOrUpdateVmMemoryArgs struct {
+	VmMemoryJson string
+}
+
+var createOrUpdateVmMemoryTemplate = template.Must(template.New("CreateOrUpdateVmMemory").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmMemory = $arguments.VmMemoryJson | ConvertFrom-Json
+
+$vmObject = Get-VM -Name "$($vmMemory.VmName)*" | ?{$_.Name -eq $vmMemory.VmName }
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($vmMemory.VmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmMemory.VmName
+}
+
+$SetVMMemoryArgs = @{}
+$SetVMMemoryArgs.VM=$vmObject
+$SetVMMemoryArgs.Priority=$vmMemory.Priority
+#The buffer is only used by dynamic memory
+if ($vmObject.DynamicMemoryEnabled){
+	$SetVMMemoryArgs.Buffer=$vmMemory.Buffer
+}
+if ($vmMemory.MaximumAmountPerNumaNodeBytes -gt 0){
+	$SetVMMemoryArgs.MaximumAmountPerNumaNodeBytes=$vmMemory.MaximumAmountPerNumaNodeBytes
+}
+
+Set-VMMemory @SetVMMemoryArgs
+`))
+
+func (c *ClientConfig) CreateOrUpdateVmMemory(
+	ctx context.Context,
+	vmName string,
+	buffer int32,
+	priority int32,
+	maximumAmountPerNumaNodeBytes int64,
+) (err error) {
+	vmMemoryJson, err := json.Marshal(api.VmMemory{
+		VmName:                        vmName,
+		Buffer:                        buffer,
+		Priority:                      priority,
+		MaximumAmountPerNumaNodeBytes: maximumAmountPerNumaNodeBytes,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, createOrUpdateVmMemoryTemplate, createOrUpdateVmMemoryArgs{
+		VmMemoryJson: string(vmMemoryJson),
+	})
+
+	return err
+}
+
+type getVmMemoryArgs struct {
+	VmName string
+}
+
+var getVmMemoryTemplate = template.Must(template.New("GetVmMemory").Parse(`
+$ErrorActionPreference = 'Stop'
+
+$hostNumaSpanning = if ((Get-VMHost).NumaSpanningEnabled) { 'On' } else { 'Off' }
+
+$vmMemoryObject = Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMMemory | %{ @{
+	Buffer=$_.Buffer
+	Priority=$_.Priority
+	MaximumAmountPerNumaNodeBytes=[int64]$_.MaximumPerNumaNode * 1MB
+	HostNumaSpanning=$hostNumaSpanning
+}}
+
+if ($vmMemoryObject) {
+	$vmMemory = ConvertTo-Json -InputObject $vmMemoryObject
+	$vmMemory
+} else {
+	"{}"
+}
+`))
+
+func (c *ClientConfig) GetVmMemory(ctx context.Context, vmName string) (result api.VmMemory, err error) {
+	err = c.WinRmClient.RunScriptWithResult(ctx, getVmMemoryTemplate, getVmMemoryArgs{
+		VmName: vmName,
+	}, &result)
+
+	return result, err
+}
+
+func (c *ClientConfig) GetVmMemories(ctx context.Context, vmName string) (result []api.VmMemory, err error) {
+	result = make([]api.VmMemory, 0)
+	vmMemory, err := c.GetVmMemory(ctx, vmName)
+	if err != nil {
+		return result, err
+	}
+	result = append(result, vmMemory)
+	return result, err
+}
+
+func (c *ClientConfig) CreateOrUpdateVmMemories(ctx context.Context, vmName string, vmMemories []api.VmMemory) (err error) {
+	if len(vmMemories) == 0 {
+		return nil
+	}
+	if len(vmMemories) > 1 {
+		return fmt.Errorf("only 1 vm memory setting allowed per a vm")
+	}
+
+	vmMemory := vmMemories[0]
+
+	return c.CreateOrUpdateVmMemory(ctx, vmName,
+		vmMemory.Buffer,
+		vmMemory.Priority,
+		vmMemory.MaximumAmountPerNumaNodeBytes)
+}
@@ -0,0 +1,235 @@
+package hyperv_winrm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+func (c *ClientConfig) HostName() string {
+	return c.Host
+}
+
+func (c *ClientConfig) ClientForHost(host string) (api.Client, error) {
+	if host == "" || strings.EqualFold(host, c.Host) {
+		return c, nil
+	}
+
+	if c.NewHostClient == nil {
+		return nil, fmt.Errorf("unable to connect to hyper-v host %s as the client only manages %s", host, c.Host)
+	}
+
+	c.hostClientsMutex.Lock()
+	defer c.hostClientsMutex.Unlock()
+
+	key := strings.ToLower(host)
+	if client, ok := c.hostClients[key]; ok {
+		return client, nil
+	}
+
+	client, err := c.NewHostClient(host)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.hostClients == nil {
+		c.hostClients = make(map[string]api.Client)
+	}
+	c.hostClients[key] = client
+
+	return client, nil
+}
+
+type getVmMigrationIncompatibilitiesArgs struct {
+	VmName            string
+	DestinationHost   string
+	IncludeStorage    bool
+	VmStorageMoveJson string
+}
+
+var getVmMigrationIncompatibilitiesTemplate = template.Must(template.New("GetVmMigrationIncompatibilities").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmName = $arguments.VmName
+$vmStorageMove = $arguments.VmStorageMoveJson | ConvertFrom-Json
+
+$vmObject = Get-VM -Name "$($vmName)*" | ?{$_.Name -eq $vmName}
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($vmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmName
+}
+
+$CompareVmArgs = @{
+	DestinationHost=$arguments.DestinationHost
+}
+
+if ($arguments.IncludeStorage) {
+	#Storage that isn't moved somewhere else keeps the same path on the destination host
+	$CompareVmArgs.VirtualMachinePath=if ($vmStorageMove.VirtualMachinePath) { $vmStorageMove.VirtualMachinePath } else { $vmObject.Path }
+	$CompareVmArgs.SnapshotFilePath=if ($vmStorageMove.SnapshotFilePath) { $vmStorageMove.SnapshotFilePath } else { $vmObject.SnapshotFileLocation }
+	$CompareVmArgs.SmartPagingFilePath=if ($vmStorageMove.SmartPagingFilePath) { $vmStorageMove.SmartPagingFilePath } else { $vmObject.SmartPagingFilePath }
+
+	[hashtable[]]$vhds = @(Get-VMHardDiskDrive -VM $vmObject | ?{$_.Path} | %{
+		$sourceFilePath = $_.Path
+		$vhdMove = $vmStorageMove.Vhds | ?{$_ -and $_.SourceFilePath -eq $sourceFilePath} | Select-Object -First 1
+		@{
+			SourceFilePath=$sourceFilePath;
+			DestinationFilePath=if ($vhdMove) { $vhdMove.DestinationFilePath } else { $sourceFilePath };
+		}
+	})
+
+	if ($vhds.Length -gt 0) {
+		$CompareVmArgs.Vhds=$vhds
+	}
+}
+
+$compatibilityReport = Compare-VM -VM $vmObject @CompareVmArgs
+
+$vmMigrationIncompatibilitiesObject = @($compatibilityReport.Incompatibilities | %{ @{
+	MessageId=[int64]$_.MessageId;
+	Message=$_.Message;
+}})
+
+if ($vmMigrationIncompatibilitiesObject) {
+	$vmMigrationIncompatibilities = ConvertTo-Json -InputObject $vmMigrationIncompatibilitiesObject
+	$vmMigrationIncompatibilities
+} else {
+	"[]"
+}
+`))
+
+func (c *ClientConfig) GetVmMigrationIncompatibilities(
+	ctx context.Context,
+	vmName string,
+	destinationHost string,
+	includeStorage bool,
+	vmStorageMove api.VmStorageMove,
+) (result []api.VmMigrationIncompatibility, err error) {
+	result = make([]api.VmMigrationIncompatibility, 0)
+
+	vmStorageMoveJson, err := json.Marshal(vmStorageMove)
+
+	if err != nil {
+		return result, err
+	}
+
+	err = c.WinRmClient.RunScriptWithResult(ctx, getVmMigrationIncompatibilitiesTemplate, getVmMigrationIncompatibilitiesArgs{
+		VmName:            vmName,
+		DestinationHost:   destinationHost,
+		IncludeStorage:    includeStorage,
+		VmStorageMoveJson: string(vmStorageMoveJson),
+	}, &result)
+
+	return result, err
+}
+
+type moveVmArgs struct {
+	VmName            string
+	DestinationHost   string
+	IncludeStorage    bool
+	Timeout           uint32
+	PollPeriod        uint32
+	VmStorageMoveJson string
+}
+
+var moveVmTemplate = template.Must(template.New("MoveVm").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmName = $arguments.VmName
+$vmStorageMove = $arguments.VmStorageMoveJson | ConvertFrom-Json
+$timeout = $arguments.Timeout
+$pollPeriod = $arguments.PollPeriod
+
+$vmObject = Get-VM -Name "$($vmName)*" | ?{$_.Name -eq $vmName}
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($vmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmName
+}
+
+$MoveVmArgs = @{
+	DestinationHost=$arguments.DestinationHost
+}
+
+if ($arguments.IncludeStorage) {
+	#Storage that isn't moved somewhere else keeps the same path on the destination host
+	$MoveVmArgs.VirtualMachinePath=if ($vmStorageMove.VirtualMachinePath) { $vmStorageMove.VirtualMachinePath } else { $vmObject.Path }
+	$MoveVmArgs.SnapshotFilePath=if ($vmStorageMove.SnapshotFilePath) { $vmStorageMove.SnapshotFilePath } else { $vmObject.SnapshotFileLocation }
+	$MoveVmArgs.SmartPagingFilePath=if ($vmStorageMove.SmartPagingFilePath) { $vmStorageMove.SmartPagingFilePath } else { $vmObject.SmartPagingFilePath }
+
+	[hashtable[]]$vhds = @(Get-VMHardDiskDrive -VM $vmObject | ?{$_.Path} | %{
+		$sourceFilePath = $_.Path
+		$vhdMove = $vmStorageMove.Vhds | ?{$_ -and $_.SourceFilePath -eq $sourceFilePath} | Select-Object -First 1
+		@{
+			SourceFilePath=$sourceFilePath;
+			DestinationFilePath=if ($vhdMove) { $vhdMove.DestinationFilePath } else { $sourceFilePath };
+		}
+	})
+
+	if ($vhds.Length -gt 0) {
+		$MoveVmArgs.Vhds=$vhds
+	}
+
+	if ($vmStorageMove.RetainVhdCopiesOnSource) {
+		$MoveVmArgs.RetainVhdCopiesOnSource=$true
+	}
+}
+
+#The vm keeps running while it is live migrated
+$job = Move-VM -VM $vmObject -AsJob @MoveVmArgs
+
+$timer = [Diagnostics.Stopwatch]::StartNew()
+while (($job.State -eq 'NotStarted' -or $job.State -eq 'Running') -and ($timer.Elapsed.TotalSeconds -lt $timeout)) {
+	Start-Sleep -Seconds $pollPeriod
+}
+$timer.Stop()
+
+if ($job.State -eq 'NotStarted' -or $job.State -eq 'Running') {
+	$progress = $job.ChildJobs | %{ $_.Progress } | Select-Object -Last 1
+	Stop-Job -Job $job
+	Remove-Job -Job $job -Force
+	throw "Timeout while moving vm $($vmName) to $($arguments.DestinationHost), the move was $($progress.PercentComplete)% complete"
+}
+
+try {
+	Receive-Job -Job $job -Wait | Out-Null
+} finally {
+	Remove-Job -Job $job -Force
+}
+`))
+
+func (c *ClientConfig) MoveVm(
+	ctx context.Context,
+	vmName string,
+	destinationHost string,
+	includeStorage bool,
+	timeout uint32,
+	pollPeriod uint32,
+	vmStorageMove api.VmStorageMove,
+) (err error) {
+	release, err := c.acquireHeavyOperation(ctx, "MoveVm")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	vmStorageMoveJson, err := json.Marshal(vmStorageMove)
+
+	if err != nil {
+		return err
+	}
+
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, moveVmTemplate, moveVmArgs{
+		VmName:            vmName,
+		DestinationHost:   destinationHost,
+		IncludeStorage:    includeStorage,
+		Timeout:           timeout,
+		PollPeriod:        pollPeriod,
+		VmStorageMoveJson: string(vmStorageMoveJson),
+	})
+
+	return err
+}
@@ -15,7 +15,7 @@ type createVmNetworkAdapterArgs struct {
 var createVmNetworkAdapterTemplate = template.Must(template.New("CreateVmNetworkAdapter").Parse(`
 $ErrorActionPreference = 'Stop'
 Import-Module Hyper-V
-$vmNetworkAdapter = '{{.VmNetworkAdapterJson}}' | ConvertFrom-Json
+$vmNetworkAdapter = $arguments.VmNetworkAdapterJson | ConvertFrom-Json
 
 $dhcpGuard = [Microsoft.HyperV.PowerShell.OnOffState]$vmNetworkAdapter.DhcpGuard
 $routerGuard = [Microsoft.HyperV.PowerShell.OnOffState]$vmNetworkAdapter.RouterGuard
@@ -207,11 +207,11 @@ type getVmNetworkAdaptersArgs struct {
 var getVmNetworkAdaptersTemplate = template.Must(template.New("GetVmNetworkAdapters").Parse(`
 $ErrorActionPreference = 'Stop'
 #First 3 requests fails to get ip address
-Get-VMNetworkAdapter -VmName '{{.VmName}}' | Out-Null
-Get-VMNetworkAdapter -VmName '{{.VmName}}' | Out-Null
-Get-VMNetworkAdapter -VmName '{{.VmName}}' | Out-Null
+Get-VMNetworkAdapter -VmName $arguments.VmName | Out-Null
+Get-VMNetworkAdapter -VmName $arguments.VmName | Out-Null
+Get-VMNetworkAdapter -VmName $arguments.VmName | Out-Null
 
-$vmNetworkAdaptersObject = @(Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' } | Get-VMNetworkAdapter | %{ @{
+$vmNetworkAdaptersObject = @(Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMNetworkAdapter | %{ @{
      Name=$_.Name;
      SwitchName=$_.SwitchName;
      ManagementOs=$_.IsManagementOs;
@@ -373,14 +373,14 @@ function Wait-ForNetworkAdapterIps($Name, $Timeout, $PollPeriod, $VmNetworkAdapt
 }
 
 Import-Module Hyper-V
-$vmNetworkAdaptersToWaitForIps = '{{.VmNetworkAdaptersWaitForIpsJson}}' | ConvertFrom-Json
-$vmName = '{{.VmName}}'
+$vmNetworkAdaptersToWaitForIps = $arguments.VmNetworkAdaptersWaitForIpsJson | ConvertFrom-Json
+$vmName = $arguments.VmName
 $vmObject = Get-VM -Name "$($vmName)*" | ?{$_.Name -eq $vmName}
-$timeout = {{.Timeout}}
-$pollPeriod = {{.PollPeriod}}
+$timeout = $arguments.Timeout
+$pollPeriod = $arguments.PollPeriod
 
 if (!$vmObject){
-	throw "VM does not exist - $($vmName)"
+	Write-Error -Message "VM does not exist - $($vmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmName
 }
 
 Wait-ForNetworkAdapterIps -Name $vmName -Timeout $timeout -PollPeriod $pollPeriod -VmNetworkAdaptersToWaitForIps $vmNetworkAdaptersToWaitForIps
@@ -419,11 +419,11 @@ type updateVmNetworkAdapterArgs struct {
 var updateVmNetworkAdapterTemplate = template.Must(template.New("UpdateVmNetworkAdapter").Parse(`
 $ErrorActionPreference = 'Stop'
 #First 3 requests fails to get ip address
-Get-VMNetworkAdapter -VmName '{{.VmName}}' | Out-Null
-Get-VMNetworkAdapter -VmName '{{.VmName}}' | Out-Null
-Get-VMNetworkAdapter -VmName '{{.VmName}}' | Out-Null
+Get-VMNetworkAdapter -VmName $arguments.VmName | Out-Null
+Get-VMNetworkAdapter -VmName $arguments.VmName | Out-Null
+Get-VMNetworkAdapter -VmName $arguments.VmName | Out-Null
 
-$vmNetworkAdapter = '{{.VmNetworkAdapterJson}}' | ConvertFrom-Json
+$vmNetworkAdapter = $arguments.VmNetworkAdapterJson | ConvertFrom-Json
 
 $dhcpGuard = [Microsoft.HyperV.PowerShell.OnOffState]$vmNetworkAdapter.DhcpGuard
 $routerGuard = [Microsoft.HyperV.PowerShell.OnOffState]$vmNetworkAdapter.RouterGuard
@@ -435,10 +435,10 @@ $deviceNaming = [Microsoft.HyperV.PowerShell.OnOffState]$vmNetworkAdapter.Device
 $fixSpeed10G = [Microsoft.HyperV.PowerShell.OnOffState]$vmNetworkAdapter.FixSpeed10G
 $macAddressSpoofing = [Microsoft.HyperV.PowerShell.OnOffState]$vmNetworkAdapter.MacAddressSpoofing
 
-$vmNetworkAdaptersObject = @(Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' } | Get-VMNetworkAdapter)[{{.Index}}]
+$vmNetworkAdaptersObject = @(Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMNetworkAdapter)[$arguments.Index]
 
 if (!$vmNetworkAdaptersObject){
-	throw "VM network adapter does not exist - {{.Index}}"
+	Write-Error -Message "VM network adapter does not exist - $($arguments.Index)" -Category ObjectNotFound -ErrorId 'VmNetworkAdapterNotFound' -TargetObject $arguments.VmName
 }
 
 if ($vmNetworkAdapter.SwitchName) {
@@ -633,7 +633,7 @@ type deleteVmNetworkAdapterArgs struct {
 var deleteVmNetworkAdapterTemplate = template.Must(template.New("DeleteVmNetworkAdapter").Parse(`
 $ErrorActionPreference = 'Stop'
 
-@(Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' } | Get-VMNetworkAdapter)[{{.Index}}] | Remove-VMNetworkAdapter
+@(Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMNetworkAdapter)[$arguments.Index] | Remove-VMNetworkAdapter
 `))
 
 func (c *ClientConfig) DeleteVmNetworkAdapter(ctx context.Context, vmName string, index int) (err error) {
@@ -16,7 +16,7 @@ type createOrUpdateVmProcessorArgs struct {
 var createOrUpdateVmProcessorTemplate = template.Must(template.New("CreateOrUpdateVmProcessor").Parse(`
 $ErrorActionPreference = 'Stop'
 Import-Module Hyper-V
-$vmProcessor = '{{.VmProcessorJson}}' | ConvertFrom-Json
+$vmProcessor = $arguments.VmProcessorJson | ConvertFrom-Json
 
 $SetVMProcessorArgs = @{}
 $SetVMProcessorArgs.VMName=$vmProcessor.VmName
@@ -87,7 +87,7 @@ type getVmProcessorArgs struct {
 var getVmProcessorTemplate = template.Must(template.New("GetVmProcessor").Parse(`
 $ErrorActionPreference = 'Stop'
 
-$vmProcessorObject = Get-VM -Name '{{.VmName}}*' | ?{$_.Name -eq '{{.VmName}}' } | Get-VMProcessor | %{ @{
+$vmProcessorObject = Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMProcessor | %{ @{
 	CompatibilityForMigrationEnabled=$_.CompatibilityForMigrationEnabled
 	CompatibilityForOlderOperatingSystemsEnabled=$_.CompatibilityForOlderOperatingSystemsEnabled
 	HwThreadCountPerCore=$_.HwThreadCountPerCore
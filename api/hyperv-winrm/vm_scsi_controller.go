@@ -0,0 +1,127 @@
+package hyperv_winrm
+
+import (
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+type createVmScsiControllerArgs struct {
+	VmName string
+}
+
+var createVmScsiControllerTemplate = template.Must(template.New("CreateVmScsiController").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+
+$vmObject = Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName }
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($arguments.VmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $arguments.VmName
+}
+
+Add-VMScsiController -VM $vmObject
+`))
+
+func (c *ClientConfig) CreateVmScsiController(ctx context.Context, vmName string) (err error) {
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, createVmScsiControllerTemplate, createVmScsiControllerArgs{
+		VmName: vmName,
+	})
+
+	return err
+}
+
+type getVmScsiControllersArgs struct {
+	VmName string
+}
+
+var getVmScsiControllersTemplate = template.Must(template.New("GetVmScsiControllers").Parse(`
+$ErrorActionPreference = 'Stop'
+$vmScsiControllersObject = @(Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMScsiController | %{ @{
+	ControllerNumber=$_.ControllerNumber;
+	DriveCount=@($_.Drives).Count;
+}})
+
+if ($vmScsiControllersObject) {
+	$vmScsiControllers = ConvertTo-Json -InputObject $vmScsiControllersObject
+	$vmScsiControllers
+} else {
+	"[]"
+}
+`))
+
+func (c *ClientConfig) GetVmScsiControllers(ctx context.Context, vmName string) (result []api.VmScsiController, err error) {
+	result = make([]api.VmScsiController, 0)
+
+	err = c.WinRmClient.RunScriptWithResult(ctx, getVmScsiControllersTemplate, getVmScsiControllersArgs{
+		VmName: vmName,
+	}, &result)
+
+	return result, err
+}
+
+type deleteVmScsiControllerArgs struct {
+	VmName           string
+	ControllerNumber int
+}
+
+var deleteVmScsiControllerTemplate = template.Must(template.New("DeleteVmScsiController").Parse(`
+$ErrorActionPreference = 'Stop'
+
+@(Get-VM -Name "$($arguments.VmName)*" | ?{$_.Name -eq $arguments.VmName } | Get-VMScsiController -ControllerNumber $arguments.ControllerNumber) | Remove-VMScsiController
+`))
+
+func (c *ClientConfig) DeleteVmScsiController(ctx context.Context, vmName string, controllerNumber int) (err error) {
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, deleteVmScsiControllerTemplate, deleteVmScsiControllerArgs{
+		VmName:           vmName,
+		ControllerNumber: controllerNumber,
+	})
+
+	return err
+}
+
+// CreateOrUpdateVmScsiControllers adds scsi controllers until every referenced controller number exists and removes
+// the empty controllers after the last referenced one. Hyper-V renumbers the controllers that follow a removed
+// controller, so only trailing controllers are removed and controller 0 is always kept.
+func (c *ClientConfig) CreateOrUpdateVmScsiControllers(ctx context.Context, vmName string, controllerNumbers []int) (err error) {
+	desiredControllersLength := 0
+	for _, controllerNumber := range controllerNumbers {
+		if controllerNumber < 0 || controllerNumber >= api.MaxVmScsiControllers {
+			return fmt.Errorf("scsi controller number %d should be between 0 and %d", controllerNumber, api.MaxVmScsiControllers-1)
+		}
+
+		if controllerNumber+1 > desiredControllersLength {
+			desiredControllersLength = controllerNumber + 1
+		}
+	}
+
+	currentScsiControllers, err := c.GetVmScsiControllers(ctx, vmName)
+	if err != nil {
+		return err
+	}
+
+	currentControllersLength := len(currentScsiControllers)
+
+	for i := currentControllersLength - 1; i > 0 && i > desiredControllersLength-1; i-- {
+		currentScsiController := currentScsiControllers[i]
+		if currentScsiController.DriveCount > 0 {
+			break
+		}
+
+		err = c.DeleteVmScsiController(ctx, vmName, currentScsiController.ControllerNumber)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := currentControllersLength; i <= desiredControllersLength-1; i++ {
+		err = c.CreateVmScsiController(ctx, vmName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
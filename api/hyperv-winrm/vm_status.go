@@ -14,7 +14,7 @@ type getVmStatusArgs struct {
 
 var getVmStatusTemplate = template.Must(template.New("GetVmStatus").Parse(`
 $ErrorActionPreference = 'Stop'
-$vmName = '{{.VmName}}'
+$vmName = $arguments.VmName
 
 $vmStateObject = Get-VM -Name "$($vmName)*" | ?{$_.Name -eq $vmName } | %{ @{
 	State=$_.State;
@@ -105,15 +105,15 @@ function Wait-IsInFinalTransitionState($Name, $Timeout, $PollPeriod){
 }
 
 Import-Module Hyper-V
-$vm = '{{.VmStatusJson}}' | ConvertFrom-Json
-$vmName = '{{.VmName}}'
+$vm = $arguments.VmStatusJson | ConvertFrom-Json
+$vmName = $arguments.VmName
 $state = [Microsoft.HyperV.PowerShell.VMState]$vm.State
 $vmObject = Get-VM -Name "$($vmName)*" | ?{$_.Name -eq $vmName}
-$timeout = {{.Timeout}}
-$pollPeriod = {{.PollPeriod}}
+$timeout = $arguments.Timeout
+$pollPeriod = $arguments.PollPeriod
 
 if (!$vmObject){
-	throw "VM does not exist - $($vmName)"
+	Write-Error -Message "VM does not exist - $($vmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmName
 }
 
 if ($vmObject.State -ne $state) {
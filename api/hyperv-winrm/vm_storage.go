@@ -0,0 +1,113 @@
+package hyperv_winrm
+
+import (
+	"context"
+	"encoding/json"
+	"text/template"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+type moveVmStorageArgs struct {
+	VmName            string
+	Timeout           uint32
+	PollPeriod        uint32
+	VmStorageMoveJson string
+}
+
+var moveVmStorageTemplate = template.Must(template.New("MoveVmStorage").Parse(`
+$ErrorActionPreference = 'Stop'
+Import-Module Hyper-V
+$vmName = $arguments.VmName
+$vmStorageMove = $arguments.VmStorageMoveJson | ConvertFrom-Json
+$timeout = $arguments.Timeout
+$pollPeriod = $arguments.PollPeriod
+
+$vmObject = Get-VM -Name "$($vmName)*" | ?{$_.Name -eq $vmName}
+
+if (!$vmObject){
+	Write-Error -Message "VM does not exist - $($vmName)" -Category ObjectNotFound -ErrorId 'VmNotFound' -TargetObject $vmName
+}
+
+$MoveVmStorageArgs = @{}
+
+if ($vmStorageMove.VirtualMachinePath -and $vmStorageMove.VirtualMachinePath -ne $vmObject.Path) {
+	$MoveVmStorageArgs.VirtualMachinePath=$vmStorageMove.VirtualMachinePath
+}
+
+if ($vmStorageMove.SnapshotFilePath -and $vmStorageMove.SnapshotFilePath -ne $vmObject.SnapshotFileLocation) {
+	$MoveVmStorageArgs.SnapshotFilePath=$vmStorageMove.SnapshotFilePath
+}
+
+if ($vmStorageMove.SmartPagingFilePath -and $vmStorageMove.SmartPagingFilePath -ne $vmObject.SmartPagingFilePath) {
+	$MoveVmStorageArgs.SmartPagingFilePath=$vmStorageMove.SmartPagingFilePath
+}
+
+[hashtable[]]$vhds = @($vmStorageMove.Vhds | ?{$_ -and $_.SourceFilePath -ne $_.DestinationFilePath} | %{ @{
+	SourceFilePath=$_.SourceFilePath;
+	DestinationFilePath=$_.DestinationFilePath;
+}})
+
+if ($vhds.Length -gt 0) {
+	$MoveVmStorageArgs.Vhds=$vhds
+}
+
+if ($MoveVmStorageArgs.Count -eq 0) {
+	return
+}
+
+if ($vmStorageMove.RetainVhdCopiesOnSource) {
+	$MoveVmStorageArgs.RetainVhdCopiesOnSource=$true
+}
+
+#The vm keeps running while its storage is moved
+$job = Move-VMStorage -VM $vmObject -AsJob @MoveVmStorageArgs
+
+$timer = [Diagnostics.Stopwatch]::StartNew()
+while (($job.State -eq 'NotStarted' -or $job.State -eq 'Running') -and ($timer.Elapsed.TotalSeconds -lt $timeout)) {
+	Start-Sleep -Seconds $pollPeriod
+}
+$timer.Stop()
+
+if ($job.State -eq 'NotStarted' -or $job.State -eq 'Running') {
+	$progress = $job.ChildJobs | %{ $_.Progress } | Select-Object -Last 1
+	Stop-Job -Job $job
+	Remove-Job -Job $job -Force
+	throw "Timeout while moving storage of vm $($vmName), the move was $($progress.PercentComplete)% complete"
+}
+
+try {
+	Receive-Job -Job $job -Wait | Out-Null
+} finally {
+	Remove-Job -Job $job -Force
+}
+`))
+
+func (c *ClientConfig) MoveVmStorage(
+	ctx context.Context,
+	vmName string,
+	timeout uint32,
+	pollPeriod uint32,
+	vmStorageMove api.VmStorageMove,
+) (err error) {
+	release, err := c.acquireHeavyOperation(ctx, "MoveVmStorage")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	vmStorageMoveJson, err := json.Marshal(vmStorageMove)
+
+	if err != nil {
+		return err
+	}
+
+	err = c.WinRmClient.RunFireAndForgetScript(ctx, moveVmStorageTemplate, moveVmStorageArgs{
+		VmName:            vmName,
+		Timeout:           timeout,
+		PollPeriod:        pollPeriod,
+		VmStorageMoveJson: string(vmStorageMoveJson),
+	})
+
+	return err
+}
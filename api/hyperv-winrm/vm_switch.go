@@ -14,7 +14,7 @@ type existsVMSwitchArgs struct {
 
 var existsVMSwitchTemplate = template.Must(template.New("ExistsVMSwitch").Parse(`
 $ErrorActionPreference = 'Stop'
-$vmSwitchObject = Get-VMSwitch -Name '{{.Name}}*' | ?{$_.Name -eq '{{.Name}}' }
+$vmSwitchObject = Get-VMSwitch -Name "$($arguments.Name)*" | ?{$_.Name -eq $arguments.Name }
 
 if ($vmSwitchObject){
 	$exists = ConvertTo-Json -InputObject @{Exists=$true}
@@ -40,7 +40,7 @@ type createVMSwitchArgs struct {
 var createVMSwitchTemplate = template.Must(template.New("CreateVMSwitch").Parse(`
 $ErrorActionPreference = 'Stop'
 Import-Module Hyper-V
-$vmSwitch = '{{.VmSwitchJson}}' | ConvertFrom-Json
+$vmSwitch = $arguments.VmSwitchJson | ConvertFrom-Json
 $minimumBandwidthMode = [Microsoft.HyperV.PowerShell.VMSwitchBandwidthMode]$vmSwitch.BandwidthReservationMode
 $switchType = [Microsoft.HyperV.PowerShell.VMSwitchType]$vmSwitch.SwitchType
 $NetAdapterNames = @($vmSwitch.NetAdapterNames)
@@ -72,7 +72,7 @@ New-VMSwitch @NewVmSwitchArgs
 $switchObject = Get-VMSwitch -Name "$($vmSwitch.Name)" | ?{$_.Name -eq $vmSwitch.Name}
 
 if (!$switchObject){
-	throw "Switch does not exist - $($vmSwitch.Name)"
+	Write-Error -Message "Switch does not exist - $($vmSwitch.Name)" -Category ObjectNotFound -ErrorId 'VMSwitchNotFound' -TargetObject $vmSwitch.Name
 }
 
 $SetVmSwitchArgs = @{}
@@ -143,7 +143,7 @@ type getVMSwitchArgs struct {
 
 var getVMSwitchTemplate = template.Must(template.New("GetVMSwitch").Parse(`
 $ErrorActionPreference = 'Stop'
-$vmSwitchObject = Get-VMSwitch -Name '{{.Name}}*' | ?{$_.Name -eq '{{.Name}}' } | %{ @{
+$vmSwitchObject = Get-VMSwitch -Name "$($arguments.Name)*" | ?{$_.Name -eq $arguments.Name } | %{ @{
 	Name=$_.Name;
 	Notes=$_.Notes;
 	AllowManagementOS=$_.AllowManagementOS;
@@ -164,7 +164,7 @@ if ($vmSwitchObject){
 	$vmSwitch = ConvertTo-Json -InputObject $vmSwitchObject
 	$vmSwitch
 } else {
-	"{}"
+	Write-Error -Message "Switch does not exist - $($arguments.Name)" -Category ObjectNotFound -ErrorId 'VMSwitchNotFound' -TargetObject $arguments.Name
 }
 `))
 
@@ -184,8 +184,8 @@ type updateVMSwitchArgs struct {
 var updateVMSwitchTemplate = template.Must(template.New("UpdateVMSwitch").Parse(`
 $ErrorActionPreference = 'Stop'
 Import-Module Hyper-V
-$oldName = '{{.OldName}}'
-$vmSwitch = '{{.VmSwitchJson}}' | ConvertFrom-Json
+$oldName = $arguments.OldName
+$vmSwitch = $arguments.VmSwitchJson | ConvertFrom-Json
 $minimumBandwidthMode = [Microsoft.HyperV.PowerShell.VMSwitchBandwidthMode]$vmSwitch.BandwidthReservationMode
 $switchType = [Microsoft.HyperV.PowerShell.VMSwitchType]$vmSwitch.SwitchType
 $NetAdapterNames = @($vmSwitch.NetAdapterNames)
@@ -195,7 +195,7 @@ $NetAdapterNames = @($vmSwitch.NetAdapterNames)
 $switchObject = Get-VMSwitch -Name "$($oldName)*" | ?{$_.Name -eq $oldName}
 
 if (!$switchObject){
-	throw "Switch does not exist - $($oldName)"
+	Write-Error -Message "Switch does not exist - $($oldName)" -Category ObjectNotFound -ErrorId 'VMSwitchNotFound' -TargetObject $oldName
 }
 
 if ($oldName -ne $vmSwitch.Name) {
@@ -291,7 +291,7 @@ type deleteVMSwitchArgs struct {
 
 var deleteVMSwitchTemplate = template.Must(template.New("DeleteVMSwitch").Parse(`
 $ErrorActionPreference = 'Stop'
-Get-VMSwitch -Name '{{.Name}}*' | ?{$_.Name -eq '{{.Name}}'} | Remove-VMSwitch -Force
+Get-VMSwitch -Name "$($arguments.Name)*" | ?{$_.Name -eq $arguments.Name} | Remove-VMSwitch -Force
 `))
 
 func (c *ClientConfig) DeleteVMSwitch(ctx context.Context, name string) (err error) {
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// idPartEscaper escapes the parts of a Terraform id made of several names, so a name that contains the separator,
+// like the default checkpoint names of Hyper-V, can't be mistaken for two parts
+var idPartEscaper = strings.NewReplacer("%", "%25", "/", "%2F")
+
+// joinId joins names into a Terraform id, escaping % and / in each name
+func joinId(parts ...string) string {
+	escapedParts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		escapedParts = append(escapedParts, idPartEscaper.Replace(part))
+	}
+
+	return strings.Join(escapedParts, "/")
+}
+
+// splitId splits a Terraform id made by joinId into count names, none of which may be empty
+func splitId(id string, count int) ([]string, error) {
+	escapedParts := strings.Split(id, "/")
+	if len(escapedParts) != count {
+		return nil, fmt.Errorf("expected %d parts separated by / but got %d", count, len(escapedParts))
+	}
+
+	parts := make([]string, 0, count)
+	for _, escapedPart := range escapedParts {
+		part, err := url.PathUnescape(escapedPart)
+		if err != nil {
+			return nil, err
+		}
+		if part == "" {
+			return nil, fmt.Errorf("expected %d non empty parts", count)
+		}
+
+		parts = append(parts, part)
+	}
+
+	return parts, nil
+}
@@ -3,7 +3,11 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -196,6 +200,8 @@ type IsoImage struct {
 	SourceZipFilePathHash          string
 	SourceBootFilePath             string
 	SourceBootFilePathHash         string
+	SourceFilesHash                string
+	Files                          map[string]string `json:",omitempty"`
 	DestinationIsoFilePath         string
 	DestinationZipFilePath         string
 	DestinationBootFilePath        string
@@ -207,11 +213,75 @@ type IsoImage struct {
 	ResolveDestinationBootFilePath string
 }
 
+// IsoImageDefaultVolumeName is the volume name of an iso that doesn't hold a cloud-init NoCloud seed
+const IsoImageDefaultVolumeName = "UNTITLED"
+
+// IsoImageCloudInitVolumeName is the volume name cloud-init looks for to find a NoCloud seed iso
+const IsoImageCloudInitVolumeName = "CIDATA"
+
+// IsoImageFiles returns the files to write into the root of an iso. user-data, meta-data and network-config are the
+// files of a cloud-init NoCloud seed, meta-data is always written with them as cloud-init requires it. Paths of files
+// are relative to the root of the iso and use / or \ as separator.
+func IsoImageFiles(userData string, metaData string, networkConfig string, files map[string]string) (map[string]string, error) {
+	result := make(map[string]string)
+
+	for path, content := range files {
+		normalizedPath := strings.ReplaceAll(path, `\`, "/")
+		if normalizedPath == "" || strings.HasPrefix(normalizedPath, "/") || strings.Contains(normalizedPath, ":") {
+			return nil, fmt.Errorf("file path %q should be relative to the root of the iso", path)
+		}
+		for _, segment := range strings.Split(normalizedPath, "/") {
+			if segment == "" || segment == "." || segment == ".." {
+				return nil, fmt.Errorf("file path %q should not contain empty, . or .. segments", path)
+			}
+		}
+
+		result[normalizedPath] = content
+	}
+
+	if userData != "" || metaData != "" || networkConfig != "" {
+		for name, content := range map[string]string{"user-data": userData, "meta-data": metaData, "network-config": networkConfig} {
+			if _, found := result[name]; found {
+				return nil, fmt.Errorf("file %q is generated from user_data, meta_data and network_config and can not be set in files", name)
+			}
+
+			if content != "" || name == "meta-data" {
+				result[name] = content
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// IsoImageFilesHash returns the hex encoded SHA-256 of files, it is empty when there are no files
+func IsoImageFilesHash(files map[string]string) string {
+	if len(files) == 0 {
+		return ""
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	hash := sha256.New()
+	for _, path := range paths {
+		hash.Write([]byte(path))
+		hash.Write([]byte{0})
+		hash.Write([]byte(files[path]))
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
 type HypervIsoImageClient interface {
 	RemoteFileExists(ctx context.Context, path string) (exists bool, err error)
 	RemoteFileDelete(ctx context.Context, path string) (err error)
 	RemoteFileUpload(ctx context.Context, filePath string, remoteFilePath string) (err error)
 
-	CreateOrUpdateIsoImage(ctx context.Context, sourceIsoFilePath string, sourceIsoFilePathHash string, sourceZipFilePath string, sourceZipFilePathHash string, sourceBootFilePath string, sourceBootFilePathHash string, destinationIsoFilePath string, destinationZipFilePath string, destinationBootFilePath string, media IsoMediaType, fileSystem IsoFileSystemType, volumeName string, resolveDestinationIsoFilePath string, resolveDestinationZipFilePath string, resolveDestinationBootFilePath string) (err error)
+	CreateOrUpdateIsoImage(ctx context.Context, sourceIsoFilePath string, sourceIsoFilePathHash string, sourceZipFilePath string, sourceZipFilePathHash string, sourceBootFilePath string, sourceBootFilePathHash string, destinationIsoFilePath string, destinationZipFilePath string, destinationBootFilePath string, media IsoMediaType, fileSystem IsoFileSystemType, volumeName string, resolveDestinationIsoFilePath string, resolveDestinationZipFilePath string, resolveDestinationBootFilePath string, sourceFilesHash string, files map[string]string) (err error)
 	GetIsoImage(ctx context.Context, resolveDestinationIsoFilePath string) (result IsoImage, err error)
 }
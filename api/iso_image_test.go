@@ -63,3 +63,58 @@ func TestDeserializeIsoImage(t *testing.T) {
 		t.Errorf("Unable to deserialize iso image: %s", err.Error())
 	}
 }
+
+func TestIsoImageFiles(t *testing.T) {
+	files, err := IsoImageFiles("#cloud-config\n", "", "version: 2\n", map[string]string{`scripts\setup.sh`: "#!/bin/sh\n"})
+	if err != nil {
+		t.Fatalf("Unable to generate iso image files: %s", err.Error())
+	}
+
+	expected := map[string]string{
+		"user-data":        "#cloud-config\n",
+		"meta-data":        "",
+		"network-config":   "version: 2\n",
+		"scripts/setup.sh": "#!/bin/sh\n",
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("Expected %#v but got %#v", expected, files)
+	}
+	for path, content := range expected {
+		if actualContent, found := files[path]; !found || actualContent != content {
+			t.Errorf("Expected %s to contain %q but got %q", path, content, actualContent)
+		}
+	}
+
+	files, err = IsoImageFiles("", "", "", map[string]string{"autounattend.xml": "<unattend/>"})
+	if err != nil {
+		t.Fatalf("Unable to generate iso image files: %s", err.Error())
+	}
+	if len(files) != 1 || files["autounattend.xml"] != "<unattend/>" {
+		t.Errorf("Expected only autounattend.xml but got %#v", files)
+	}
+
+	for _, path := range []string{"", "/etc/passwd", `C:\autounattend.xml`, "../autounattend.xml", "scripts//setup.sh"} {
+		if _, err := IsoImageFiles("", "", "", map[string]string{path: ""}); err == nil {
+			t.Errorf("Expected file path %q to be rejected", path)
+		}
+	}
+
+	if _, err := IsoImageFiles("#cloud-config\n", "", "", map[string]string{"user-data": ""}); err == nil {
+		t.Errorf("Expected user-data in files to be rejected when user_data is set")
+	}
+}
+
+func TestIsoImageFilesHash(t *testing.T) {
+	if IsoImageFilesHash(nil) != "" {
+		t.Errorf("Expected no hash without files")
+	}
+
+	hash := IsoImageFilesHash(map[string]string{"user-data": "a", "meta-data": "b"})
+	if hash == "" || hash != IsoImageFilesHash(map[string]string{"meta-data": "b", "user-data": "a"}) {
+		t.Errorf("Expected the hash to be stable: %s", hash)
+	}
+
+	if hash == IsoImageFilesHash(map[string]string{"user-data": "ab", "meta-data": ""}) {
+		t.Errorf("Expected the hash to change with the content")
+	}
+}
@@ -4,14 +4,25 @@ type Client interface {
 	HypervVhdClient
 	HypervVmClient
 	HypervVmDvdDriveClient
+	HypervVmBiosClient
+	HypervVmComPortClient
 	HypervVmFirmwareClient
+	HypervVmGuestFileClient
+	HypervVmGuestScriptClient
 	HypervVmHardDiskDriveClient
 	HypervVmIntegrationServiceClient
+	HypervVmKvpClient
+	HypervVmMemoryClient
 	HypervVmNetworkAdapterClient
 	HypervVmProcessorClient
+	HypervVmScsiControllerClient
 	HypervVmStatusClient
 	HypervVmSwitchClient
 	HypervIsoImageClient
+	HypervTemporaryItemClient
+	HypervVmCheckpointClient
+	HypervVmStorageClient
+	HypervVmMigrationClient
 }
 
 type Provider struct {
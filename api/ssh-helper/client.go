@@ -0,0 +1,241 @@
+package ssh_helper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	pool "github.com/jolestar/go-commons-pool/v2"
+	winrm_helper "github.com/taliesins/terraform-provider-hyperv/api/winrm-helper"
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
+	"golang.org/x/crypto/ssh"
+)
+
+func New(clientConfig *ClientConfig) (*winrm_helper.Provider, error) {
+	return &winrm_helper.Provider{
+		Client: clientConfig,
+	}, nil
+}
+
+// ClientConfig implements winrm_helper.Client on top of OpenSSH. Scripts are run through an ssh exec
+// request and files are transferred with the sftp subsystem.
+type ClientConfig struct {
+	SshClientPool *pool.ObjectPool
+	Vars          string
+
+	// BorrowTimeout is how long to wait for a pooled ssh client to become available, 0 waits until one is returned
+	BorrowTimeout time.Duration
+}
+
+func (c *ClientConfig) borrowObject(ctx context.Context) (interface{}, error) {
+	return winrm_helper.BorrowObject(ctx, c.SshClientPool, c.BorrowTimeout)
+}
+
+func (c *ClientConfig) RunFireAndForgetScript(ctx context.Context, script *template.Template, args interface{}) error {
+	command, err := powershell.RenderScript(script, args)
+
+	if err != nil {
+		return err
+	}
+
+	sshClient, err := c.borrowObject(ctx)
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", winrm_helper.ErrNotStarted, err)
+	}
+
+	log.Printf("[DEBUG] Running fire and forget script over ssh:\n%s\n", powershell.RenderRedactedScript(script, args))
+
+	_, _, _, err = powershell.RunPowershellOverSsh(ctx, sshClient.(*ssh.Client), c.Vars, command)
+
+	err2 := c.SshClientPool.ReturnObject(ctx, sshClient)
+
+	if err != nil {
+		return powershell.RedactError(winrm_helper.WrapScriptError(err))
+	}
+
+	if err2 != nil {
+		return err2
+	}
+
+	return nil
+}
+
+func (c *ClientConfig) RunScriptWithResult(ctx context.Context, script *template.Template, args interface{}, result interface{}) (err error) {
+	command, err := powershell.RenderScript(script, args)
+
+	if err != nil {
+		return err
+	}
+
+	sshClient, err := c.borrowObject(ctx)
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", winrm_helper.ErrNotStarted, err)
+	}
+
+	log.Printf("[DEBUG] Running script with result over ssh:\n%s\n", powershell.RenderRedactedScript(script, args))
+
+	exitStatus, stdout, stderr, err := powershell.RunPowershellOverSsh(ctx, sshClient.(*ssh.Client), c.Vars, command)
+
+	err2 := c.SshClientPool.ReturnObject(ctx, sshClient)
+
+	if err != nil {
+		return powershell.RedactError(winrm_helper.WrapScriptError(err))
+	}
+
+	if err2 != nil {
+		return err2
+	}
+
+	stdout = strings.TrimSpace(stdout)
+
+	err = json.Unmarshal([]byte(stdout), &result)
+	if err != nil {
+		return fmt.Errorf("exitStatus:%d\nstdOut:%s\nstdErr:%s\nerr:%s\ncommand:%s", exitStatus, powershell.Redact(stdout), powershell.Redact(stderr), err, powershell.RenderRedactedScript(script, args))
+	}
+
+	return nil
+}
+
+func (c *ClientConfig) UploadFile(ctx context.Context, filePath string, remoteFilePath string) (string, error) {
+	sshClient, err := c.borrowObject(ctx)
+
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", winrm_helper.ErrNotStarted, err)
+	}
+
+	log.Printf("[DEBUG] upload file over sftp %#v", filePath)
+
+	remoteFilePath, err = powershell.UploadFileOverSsh(ctx, sshClient.(*ssh.Client), filePath, remoteFilePath)
+
+	err2 := c.SshClientPool.ReturnObject(ctx, sshClient)
+
+	if err != nil {
+		return "", err
+	}
+
+	if err2 != nil {
+		return "", err2
+	}
+
+	log.Printf("[DEBUG] uploaded file %#v to %#v", filePath, remoteFilePath)
+
+	return remoteFilePath, nil
+}
+
+func (c *ClientConfig) UploadDirectory(ctx context.Context, rootPath string, excludeList []string) (remoteRootPath string, remoteAbsoluteFilePaths []string, err error) {
+	sshClient, err := c.borrowObject(ctx)
+
+	if err != nil {
+		return "", []string{}, fmt.Errorf("%w: %w", winrm_helper.ErrNotStarted, err)
+	}
+
+	log.Printf("[DEBUG] upload directory over sftp %#v", rootPath)
+
+	remoteRootPath, remoteAbsoluteFilePaths, err = powershell.UploadDirectoryOverSsh(ctx, sshClient.(*ssh.Client), rootPath, excludeList)
+
+	err2 := c.SshClientPool.ReturnObject(ctx, sshClient)
+
+	if err != nil {
+		return "", []string{}, err
+	}
+
+	if err2 != nil {
+		return "", []string{}, err2
+	}
+
+	log.Printf("[DEBUG] uploaded directory %#v to %#v. The following files where uploaded %#v", rootPath, remoteRootPath, remoteAbsoluteFilePaths)
+
+	return remoteRootPath, remoteAbsoluteFilePaths, nil
+}
+
+func (c *ClientConfig) FileExists(ctx context.Context, remoteFilePath string) (exists bool, err error) {
+	sshClient, err := c.borrowObject(ctx)
+
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", winrm_helper.ErrNotStarted, err)
+	}
+
+	log.Printf("[DEBUG] check file exists over sftp %#v", remoteFilePath)
+
+	result, err := powershell.FileExistsOverSsh(ctx, sshClient.(*ssh.Client), remoteFilePath)
+
+	err2 := c.SshClientPool.ReturnObject(ctx, sshClient)
+
+	if err != nil {
+		return false, err
+	}
+
+	if err2 != nil {
+		return false, err2
+	}
+
+	if result {
+		log.Printf("[DEBUG] file exists %#v", remoteFilePath)
+	} else {
+		log.Printf("[DEBUG] file does not exists %#v", remoteFilePath)
+	}
+
+	return result, nil
+}
+
+func (c *ClientConfig) DirectoryExists(ctx context.Context, remoteDirectoryPath string) (exists bool, err error) {
+	sshClient, err := c.borrowObject(ctx)
+
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", winrm_helper.ErrNotStarted, err)
+	}
+
+	log.Printf("[DEBUG] check directory exists over sftp %#v", remoteDirectoryPath)
+
+	result, err := powershell.DirectoryExistsOverSsh(ctx, sshClient.(*ssh.Client), remoteDirectoryPath)
+
+	err2 := c.SshClientPool.ReturnObject(ctx, sshClient)
+
+	if err != nil {
+		return false, err
+	}
+
+	if err2 != nil {
+		return false, err2
+	}
+
+	if result {
+		log.Printf("[DEBUG] directory exists %#v", remoteDirectoryPath)
+	} else {
+		log.Printf("[DEBUG] directory does not exists %#v", remoteDirectoryPath)
+	}
+
+	return result, nil
+}
+
+func (c *ClientConfig) DeleteFileOrDirectory(ctx context.Context, remotePath string) (err error) {
+	sshClient, err := c.borrowObject(ctx)
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", winrm_helper.ErrNotStarted, err)
+	}
+
+	log.Printf("[DEBUG] delete file or directory over sftp %#v", remotePath)
+
+	err = powershell.DeleteFileOrDirectoryOverSsh(ctx, sshClient.(*ssh.Client), remotePath)
+
+	err2 := c.SshClientPool.ReturnObject(ctx, sshClient)
+
+	if err != nil {
+		return err
+	}
+
+	if err2 != nil {
+		return err2
+	}
+
+	log.Printf("[DEBUG] file or directory deleted %#v", remotePath)
+
+	return nil
+}
@@ -0,0 +1,334 @@
+package ssh_helper
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+
+	pool "github.com/jolestar/go-commons-pool/v2"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+var bindArgumentsRegex = regexp.MustCompile(`FromBase64String\('([A-Za-z0-9+/=]*)'\)`)
+
+// execHandler stands in for powershell on the remote host. It receives the exec command line and the script streamed over stdin.
+type execHandler func(command string, script string) (stdout string, stderr string, exitStatus uint32)
+
+// startSshServer starts a local ssh server that answers exec requests with handler and serves sftp from the local file system.
+func startSshServer(t *testing.T, handler execHandler) string {
+	_, hostPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate host key: %s", err.Error())
+	}
+
+	hostSigner, err := ssh.NewSignerFromKey(hostPrivateKey)
+	if err != nil {
+		t.Fatalf("Unable to create host signer: %s", err.Error())
+	}
+
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err.Error())
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveSshConnection(conn, serverConfig, handler)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func serveSshConnection(conn net.Conn, serverConfig *ssh.ServerConfig, handler execHandler) {
+	_, channels, requests, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go serveSshSession(channel, channelRequests, handler)
+	}
+}
+
+func serveSshSession(channel ssh.Channel, requests <-chan *ssh.Request, handler execHandler) {
+	defer channel.Close()
+
+	for request := range requests {
+		var payload struct{ Value string }
+		_ = ssh.Unmarshal(request.Payload, &payload)
+
+		switch {
+		case request.Type == "exec":
+			_ = request.Reply(true, nil)
+
+			script, _ := io.ReadAll(channel)
+			stdout, stderr, exitStatus := handler(payload.Value, string(script))
+			_, _ = channel.Write([]byte(stdout))
+			_, _ = channel.Stderr().Write([]byte(stderr))
+
+			status := make([]byte, 4)
+			binary.BigEndian.PutUint32(status, exitStatus)
+			_, _ = channel.SendRequest("exit-status", false, status)
+			return
+		case request.Type == "subsystem" && payload.Value == "sftp":
+			_ = request.Reply(true, nil)
+
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			_ = server.Serve()
+			return
+		default:
+			_ = request.Reply(false, nil)
+		}
+	}
+}
+
+// decodeArguments extracts the $arguments bound into a rendered script
+func decodeArguments(t *testing.T, script string) map[string]interface{} {
+	match := bindArgumentsRegex.FindStringSubmatch(script)
+	if match == nil {
+		t.Fatalf("Script does not bind $arguments: %s", script)
+	}
+
+	argumentsJson, err := base64.StdEncoding.DecodeString(match[1])
+	if err != nil {
+		t.Fatalf("Unable to decode arguments: %s", err.Error())
+	}
+
+	arguments := map[string]interface{}{}
+	err = json.Unmarshal(argumentsJson, &arguments)
+	if err != nil {
+		t.Fatalf("Unable to deserialize arguments: %s", err.Error())
+	}
+
+	return arguments
+}
+
+// resolvePathHandler emulates path resolution by mapping $env:TEMP onto a local directory
+func resolvePathHandler(t *testing.T, tempDirectory string) execHandler {
+	return func(command string, script string) (string, string, uint32) {
+		if !strings.Contains(script, "GetFullPath") {
+			return "", "unexpected script", 1
+		}
+
+		filePath := decodeArguments(t, script)["FilePath"].(string)
+		filePath = strings.ReplaceAll(filePath, `$env:TEMP`, tempDirectory)
+		filePath = strings.ReplaceAll(filePath, `\`, "/")
+
+		return filePath + "\r\n", "", 0
+	}
+}
+
+func newTestClientConfig(t *testing.T, addr string) *ClientConfig {
+	ctx := context.Background()
+	factory := pool.NewPooledObjectFactory(
+		func(context.Context) (interface{}, error) {
+			return ssh.Dial("tcp", addr, &ssh.ClientConfig{
+				User:            "Administrator",
+				Auth:            []ssh.AuthMethod{ssh.Password("password")},
+				HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			})
+		},
+		func(ctx context.Context, object *pool.PooledObject) error {
+			return object.Object.(*ssh.Client).Close()
+		}, nil, nil, nil)
+
+	sshClientPool := pool.NewObjectPoolWithDefaultConfig(ctx, factory)
+	t.Cleanup(func() { sshClientPool.Close(ctx) })
+
+	return &ClientConfig{
+		SshClientPool: sshClientPool,
+	}
+}
+
+type testScriptArgs struct {
+	Name string
+}
+
+var testScriptTemplate = template.Must(template.New("TestScript").Parse(`
+$ErrorActionPreference = 'Stop'
+Get-VM -Name $arguments.Name
+`))
+
+func TestRunScriptWithResultOverSsh(t *testing.T) {
+	var receivedCommand, receivedScript string
+	addr := startSshServer(t, func(command string, script string) (string, string, uint32) {
+		receivedCommand = command
+		receivedScript = script
+		return `{"Exists":true}`, "", 0
+	})
+	c := newTestClientConfig(t, addr)
+
+	var result struct{ Exists bool }
+	err := c.RunScriptWithResult(context.Background(), testScriptTemplate, testScriptArgs{Name: "it's"}, &result)
+	if err != nil {
+		t.Fatalf("Unable to run script: %s", err.Error())
+	}
+
+	if !result.Exists {
+		t.Errorf("Result not deserialized: %#v", result)
+	}
+
+	if !strings.HasPrefix(receivedCommand, "powershell ") || !strings.Contains(receivedCommand, "-EncodedCommand ") {
+		t.Errorf("Unexpected command line: %s", receivedCommand)
+	}
+
+	if !strings.Contains(receivedScript, "Get-VM -Name $arguments.Name") {
+		t.Errorf("Script was not streamed over stdin: %s", receivedScript)
+	}
+
+	if decodeArguments(t, receivedScript)["Name"] != "it's" {
+		t.Errorf("Arguments were not bound: %s", receivedScript)
+	}
+}
+
+func TestRunFireAndForgetScriptOverSshReturnsFailure(t *testing.T) {
+	addr := startSshServer(t, func(command string, script string) (string, string, uint32) {
+		return "", "VM not found", 1
+	})
+	c := newTestClientConfig(t, addr)
+
+	err := c.RunFireAndForgetScript(context.Background(), testScriptTemplate, testScriptArgs{Name: "test"})
+	if err == nil {
+		t.Fatalf("Expected failure to be returned")
+	}
+
+	if !strings.Contains(err.Error(), "code=1") || !strings.Contains(err.Error(), "VM not found") {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+}
+
+func TestFileOperationsOverSftp(t *testing.T) {
+	localDirectory := t.TempDir()
+	remoteTempDirectory := t.TempDir()
+	addr := startSshServer(t, resolvePathHandler(t, remoteTempDirectory))
+	c := newTestClientConfig(t, addr)
+	ctx := context.Background()
+
+	localFilePath := filepath.Join(localDirectory, "test.txt")
+	err := os.WriteFile(localFilePath, []byte("test content"), 0600)
+	if err != nil {
+		t.Fatalf("Unable to write local file: %s", err.Error())
+	}
+
+	remoteFilePath, err := c.UploadFile(ctx, localFilePath, `$env:TEMP\upload\test.txt`)
+	if err != nil {
+		t.Fatalf("Unable to upload file: %s", err.Error())
+	}
+
+	expectedFilePath := filepath.Join(remoteTempDirectory, "upload", "test.txt")
+	if remoteFilePath != expectedFilePath {
+		t.Errorf("Unexpected remote file path: %s", remoteFilePath)
+	}
+
+	content, err := os.ReadFile(expectedFilePath)
+	if err != nil || string(content) != "test content" {
+		t.Errorf("File not uploaded: %s %v", string(content), err)
+	}
+
+	exists, err := c.FileExists(ctx, `$env:TEMP\upload\test.txt`)
+	if err != nil || !exists {
+		t.Errorf("Expected file to exist: %v", err)
+	}
+
+	exists, err = c.DirectoryExists(ctx, `$env:TEMP\upload\test.txt`)
+	if err != nil || exists {
+		t.Errorf("Expected file not to be a directory: %v", err)
+	}
+
+	exists, err = c.DirectoryExists(ctx, `$env:TEMP\upload`)
+	if err != nil || !exists {
+		t.Errorf("Expected directory to exist: %v", err)
+	}
+
+	err = c.DeleteFileOrDirectory(ctx, `$env:TEMP\upload`)
+	if err != nil {
+		t.Errorf("Unable to delete directory: %s", err.Error())
+	}
+
+	exists, err = c.FileExists(ctx, `$env:TEMP\upload\test.txt`)
+	if err != nil || exists {
+		t.Errorf("Expected file to be deleted: %v", err)
+	}
+
+	err = c.DeleteFileOrDirectory(ctx, `$env:TEMP\upload`)
+	if err != nil {
+		t.Errorf("Deleting a missing directory should not fail: %s", err.Error())
+	}
+}
+
+func TestUploadDirectoryOverSftp(t *testing.T) {
+	localDirectory := filepath.Join(t.TempDir(), "bootstrap")
+	remoteTempDirectory := t.TempDir()
+	addr := startSshServer(t, resolvePathHandler(t, remoteTempDirectory))
+	c := newTestClientConfig(t, addr)
+
+	err := os.MkdirAll(filepath.Join(localDirectory, "nested"), 0700)
+	if err != nil {
+		t.Fatalf("Unable to create local directory: %s", err.Error())
+	}
+
+	for _, name := range []string{"a.ps1", filepath.Join("nested", "b.ps1")} {
+		err = os.WriteFile(filepath.Join(localDirectory, name), []byte(name), 0600)
+		if err != nil {
+			t.Fatalf("Unable to write local file: %s", err.Error())
+		}
+	}
+
+	remoteRootPath, remoteFilePaths, err := c.UploadDirectory(context.Background(), localDirectory, []string{})
+	if err != nil {
+		t.Fatalf("Unable to upload directory: %s", err.Error())
+	}
+
+	if remoteRootPath != filepath.Join(remoteTempDirectory, "bootstrap") {
+		t.Errorf("Unexpected remote root path: %s", remoteRootPath)
+	}
+
+	if len(remoteFilePaths) != 2 {
+		t.Errorf("Unexpected remote file paths: %#v", remoteFilePaths)
+	}
+
+	content, err := os.ReadFile(filepath.Join(remoteTempDirectory, "bootstrap", "nested", "b.ps1"))
+	if err != nil || string(content) != filepath.Join("nested", "b.ps1") {
+		t.Errorf("Nested file not uploaded: %s %v", string(content), err)
+	}
+}
@@ -0,0 +1,12 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+type HypervTemporaryItemClient interface {
+	// RemoveTemporaryItems removes the temporary files and scheduled tasks the provider left behind on the host that
+	// are older than olderThan. Items of the provider process identified by sessionId are never removed.
+	RemoveTemporaryItems(ctx context.Context, olderThan time.Duration, sessionId string) (removedItems []string, err error)
+}
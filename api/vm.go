@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"log"
 	"strconv"
 	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
 type CriticalErrorAction int
@@ -282,6 +286,61 @@ func (d *OnOffState) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+type VmImportMode int
+
+const (
+	VmImportMode_Register      VmImportMode = 0
+	VmImportMode_Copy          VmImportMode = 1
+	VmImportMode_GenerateNewId VmImportMode = 2
+)
+
+var VmImportMode_name = map[VmImportMode]string{
+	VmImportMode_Register:      "Register",
+	VmImportMode_Copy:          "Copy",
+	VmImportMode_GenerateNewId: "GenerateNewId",
+}
+
+var VmImportMode_value = map[string]VmImportMode{
+	"register":      VmImportMode_Register,
+	"copy":          VmImportMode_Copy,
+	"generatenewid": VmImportMode_GenerateNewId,
+}
+
+func (x VmImportMode) String() string {
+	return VmImportMode_name[x]
+}
+
+func ToVmImportMode(x string) VmImportMode {
+	if integerValue, err := strconv.Atoi(x); err == nil {
+		return VmImportMode(integerValue)
+	}
+	return VmImportMode_value[strings.ToLower(x)]
+}
+
+func (d *VmImportMode) MarshalJSON() ([]byte, error) {
+	buffer := bytes.NewBufferString(`"`)
+	buffer.WriteString(d.String())
+	buffer.WriteString(`"`)
+	return buffer.Bytes(), nil
+}
+
+func (d *VmImportMode) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		var i int
+		err2 := json.Unmarshal(b, &i)
+		if err2 == nil {
+			*d = VmImportMode(i)
+			return nil
+		}
+
+		return err
+	}
+	*d = ToVmImportMode(s)
+	return nil
+}
+
 type VmExists struct {
 	Exists bool
 }
@@ -312,6 +371,50 @@ type Vm struct {
 	// ParentCheckpointName				string  this will allow us to set the checkpoint to use
 }
 
+type VmImport struct {
+	Name                string
+	Path                string
+	Mode                VmImportMode
+	Generation          int
+	VirtualMachinePath  string
+	VhdDestinationPath  string
+	SnapshotFilePath    string
+	SmartPagingFilePath string
+}
+
+func ExpandVmImportSources(d *schema.ResourceData) ([]VmImport, error) {
+	expandedVmImports := make([]VmImport, 0)
+
+	if v, ok := d.GetOk("import_source"); ok {
+		vmImports := v.([]interface{})
+		for _, vmImport := range vmImports {
+			vmImport, ok := vmImport.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("[ERROR][hyperv] import_source should be a Hash - was '%+v'", vmImport)
+			}
+
+			log.Printf("[DEBUG] vmImport =  [%+v]", vmImport)
+
+			expandedVmImport := VmImport{
+				Path:               vmImport["path"].(string),
+				Mode:               ToVmImportMode(vmImport["mode"].(string)),
+				VhdDestinationPath: vmImport["vhd_destination_path"].(string),
+			}
+
+			expandedVmImports = append(expandedVmImports, expandedVmImport)
+		}
+	}
+
+	return expandedVmImports, nil
+}
+
+type VmExport struct {
+	VmName     string
+	Path       string
+	ExportPath string
+	VmcxPath   string
+}
+
 type HypervVmClient interface {
 	VmExists(ctx context.Context, name string) (result VmExists, err error)
 	CreateVm(
@@ -369,4 +472,20 @@ type HypervVmClient interface {
 	) (err error)
 
 	DeleteVm(ctx context.Context, name string) (err error)
+
+	ImportVm(
+		ctx context.Context,
+		name string,
+		path string,
+		mode VmImportMode,
+		generation int,
+		virtualMachinePath string,
+		vhdDestinationPath string,
+		snapshotFilePath string,
+		smartPagingFilePath string,
+	) (err error)
+
+	ExportVm(ctx context.Context, vmName string, path string, checkpointName string) (err error)
+	GetVmExport(ctx context.Context, vmName string, path string) (result VmExport, err error)
+	DeleteVmExport(ctx context.Context, vmName string, path string) (err error)
 }
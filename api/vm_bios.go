@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+type Gen1BootType int
+
+const (
+	Gen1BootType_Floppy               Gen1BootType = 0
+	Gen1BootType_CD                   Gen1BootType = 1
+	Gen1BootType_IDE                  Gen1BootType = 2
+	Gen1BootType_LegacyNetworkAdapter Gen1BootType = 3
+)
+
+var Gen1BootType_name = map[Gen1BootType]string{
+	Gen1BootType_Floppy:               "Floppy",
+	Gen1BootType_CD:                   "CD",
+	Gen1BootType_IDE:                  "IDE",
+	Gen1BootType_LegacyNetworkAdapter: "LegacyNetworkAdapter",
+}
+
+var Gen1BootType_value = map[string]Gen1BootType{
+	"floppy":               Gen1BootType_Floppy,
+	"cd":                   Gen1BootType_CD,
+	"ide":                  Gen1BootType_IDE,
+	"legacynetworkadapter": Gen1BootType_LegacyNetworkAdapter,
+}
+
+func (x Gen1BootType) String() string {
+	return Gen1BootType_name[x]
+}
+
+func ToGen1BootType(x string) Gen1BootType {
+	if integerValue, err := strconv.Atoi(x); err == nil {
+		return Gen1BootType(integerValue)
+	}
+	return Gen1BootType_value[strings.ToLower(x)]
+}
+
+func (d *Gen1BootType) MarshalJSON() ([]byte, error) {
+	buffer := bytes.NewBufferString(`"`)
+	buffer.WriteString(d.String())
+	buffer.WriteString(`"`)
+	return buffer.Bytes(), nil
+}
+
+func (d *Gen1BootType) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		var i int
+		err2 := json.Unmarshal(b, &i)
+		if err2 == nil {
+			*d = Gen1BootType(i)
+			return nil
+		}
+
+		return err
+	}
+	*d = ToGen1BootType(s)
+	return nil
+}
+
+type VmBios struct {
+	VmName         string
+	StartupOrder   []Gen1BootType
+	NumLockEnabled bool
+}
+
+func ExpandVmBioses(d *schema.ResourceData) ([]VmBios, error) {
+	expandedVmBioses := make([]VmBios, 0)
+
+	if v, ok := d.GetOk("vm_bios"); ok {
+		vmBioses := v.([]interface{})
+		for _, bios := range vmBioses {
+			bios, ok := bios.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("[ERROR][hyperv] vm_bios should be a Hash - was '%+v'", bios)
+			}
+
+			log.Printf("[DEBUG] bios = [%+v]", bios)
+
+			startupOrder := make([]Gen1BootType, 0)
+			for _, bootType := range bios["startup_order"].([]interface{}) {
+				startupOrder = append(startupOrder, ToGen1BootType(bootType.(string)))
+			}
+
+			expandedVmBios := VmBios{
+				StartupOrder:   startupOrder,
+				NumLockEnabled: bios["num_lock_enabled"].(bool),
+			}
+
+			expandedVmBioses = append(expandedVmBioses, expandedVmBios)
+		}
+	}
+
+	return expandedVmBioses, nil
+}
+
+func FlattenVmBioses(vmBioses *[]VmBios) []interface{} {
+	if vmBioses == nil || len(*vmBioses) < 1 {
+		return nil
+	}
+
+	flattenedVmBioses := make([]interface{}, 0)
+
+	for _, vmBios := range *vmBioses {
+		flattenedStartupOrder := make([]interface{}, 0)
+		for _, bootType := range vmBios.StartupOrder {
+			flattenedStartupOrder = append(flattenedStartupOrder, bootType.String())
+		}
+
+		flattenedVmBios := make(map[string]interface{})
+		flattenedVmBios["startup_order"] = flattenedStartupOrder
+		flattenedVmBios["num_lock_enabled"] = vmBios.NumLockEnabled
+		flattenedVmBioses = append(flattenedVmBioses, flattenedVmBios)
+	}
+
+	return flattenedVmBioses
+}
+
+type HypervVmBiosClient interface {
+	CreateOrUpdateVmBios(
+		ctx context.Context,
+		vmName string,
+		startupOrder []Gen1BootType,
+		numLockEnabled bool,
+	) (err error)
+	GetVmBios(ctx context.Context, vmName string) (result VmBios, err error)
+	GetNoVmBioses(ctx context.Context) (result []VmBios)
+	GetVmBioses(ctx context.Context, vmName string) (result []VmBios, err error)
+	CreateOrUpdateVmBioses(ctx context.Context, vmName string, vmBioses []VmBios) (err error)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDeserializeVmBios(t *testing.T) {
+	var vmBiosJson = `
+{
+    "StartupOrder":  [
+        "IDE",
+        "CD",
+        "LegacyNetworkAdapter",
+        "Floppy"
+    ],
+    "NumLockEnabled":  true
+}
+`
+
+	var vmBios VmBios
+	err := json.Unmarshal([]byte(vmBiosJson), &vmBios)
+	if err != nil {
+		t.Errorf("Unable to deserialize vm bios: %s", err.Error())
+	}
+
+	expectedStartupOrder := []Gen1BootType{Gen1BootType_IDE, Gen1BootType_CD, Gen1BootType_LegacyNetworkAdapter, Gen1BootType_Floppy}
+	if len(vmBios.StartupOrder) != len(expectedStartupOrder) {
+		t.Fatalf("Expected startup order %v, got %v", expectedStartupOrder, vmBios.StartupOrder)
+	}
+
+	for i, bootType := range expectedStartupOrder {
+		if vmBios.StartupOrder[i] != bootType {
+			t.Errorf("Expected startup order %v, got %v", expectedStartupOrder, vmBios.StartupOrder)
+		}
+	}
+
+	if !vmBios.NumLockEnabled {
+		t.Errorf("Expected num lock to be enabled")
+	}
+}
+
+func TestSerializeVmBios(t *testing.T) {
+	vmBiosJson, err := json.Marshal(VmBios{
+		VmName:       "test",
+		StartupOrder: []Gen1BootType{Gen1BootType_CD, Gen1BootType_IDE},
+	})
+
+	if err != nil {
+		t.Errorf("Unable to serialize vm bios: %s", err.Error())
+	}
+
+	expected := `{"VmName":"test","StartupOrder":["CD","IDE"],"NumLockEnabled":false}`
+	if string(vmBiosJson) != expected {
+		t.Errorf("Expected %s, got %s", expected, string(vmBiosJson))
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"context"
+	"fmt"
+)
+
+type VmCheckpoint struct {
+	VmName               string
+	Name                 string
+	Id                   string
+	ParentCheckpointName string
+	CreationTime         string
+	CheckpointType       string
+}
+
+// VmCheckpointId is the Terraform id of a checkpoint. Checkpoint names are only unique within a vm. The names are
+// escaped, as the default checkpoint names of Hyper-V contain /.
+func VmCheckpointId(vmName string, name string) string {
+	return joinId(vmName, name)
+}
+
+// ParseVmCheckpointId splits a Terraform id made by VmCheckpointId into the vm name and the checkpoint name
+func ParseVmCheckpointId(id string) (vmName string, name string, err error) {
+	parts, err := splitId(id, 2)
+	if err != nil {
+		return "", "", fmt.Errorf("checkpoint id %q should be in the format <vm_name>/<checkpoint_name>, with %% and / in the names escaped as %%25 and %%2F: %w", id, err)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+type HypervVmCheckpointClient interface {
+	CreateVmCheckpoint(ctx context.Context, vmName string, name string) (err error)
+	GetVmCheckpoint(ctx context.Context, vmName string, name string) (result VmCheckpoint, err error)
+	RenameVmCheckpoint(ctx context.Context, vmName string, oldName string, name string) (err error)
+	RestoreVmCheckpoint(ctx context.Context, vmName string, name string) (err error)
+	DeleteVmCheckpoint(ctx context.Context, vmName string, name string, includeChildCheckpoints bool) (err error)
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestParseVmCheckpointId(t *testing.T) {
+	testCases := []struct {
+		vmName string
+		name   string
+	}{
+		{"web", "before upgrade/2"},
+		{"web", "web - (10/18/2026 - 10:11:08)"},
+		{"web/api", "100% done"},
+		{"web%2F", "%2F"},
+	}
+
+	for _, testCase := range testCases {
+		id := VmCheckpointId(testCase.vmName, testCase.name)
+
+		vmName, name, err := ParseVmCheckpointId(id)
+		if err != nil {
+			t.Fatalf("Unable to parse checkpoint id %q: %s", id, err.Error())
+		}
+
+		if vmName != testCase.vmName || name != testCase.name {
+			t.Errorf("Expected %q to be vm name %q and checkpoint name %q but was %q and %q", id, testCase.vmName, testCase.name, vmName, name)
+		}
+	}
+
+	for _, id := range []string{"web", "/baseline", "web/", "web/before upgrade/2", "web/100%"} {
+		if _, _, err := ParseVmCheckpointId(id); err == nil {
+			t.Errorf("Expected %q to be rejected", id)
+		}
+	}
+}
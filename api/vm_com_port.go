@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+	MinVmComPortNumber = 1
+	MaxVmComPortNumber = 2
+)
+
+type VmComPort struct {
+	VmName       string
+	Number       int
+	Path         string
+	DebuggerMode OnOffState
+}
+
+func ExpandVmComPorts(d *schema.ResourceData) ([]VmComPort, error) {
+	expandedVmComPorts := make([]VmComPort, 0)
+
+	if v, ok := d.GetOk("com_ports"); ok {
+		vmComPorts := v.([]interface{})
+		for _, vmComPort := range vmComPorts {
+			vmComPort, ok := vmComPort.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("[ERROR][hyperv] com_ports should be a Hash - was '%+v'", vmComPort)
+			}
+
+			expandedVmComPort := VmComPort{
+				Number:       vmComPort["number"].(int),
+				Path:         vmComPort["path"].(string),
+				DebuggerMode: ToOnOffState(vmComPort["debugger_mode"].(string)),
+			}
+
+			expandedVmComPorts = append(expandedVmComPorts, expandedVmComPort)
+		}
+	}
+
+	return expandedVmComPorts, nil
+}
+
+// FlattenVmComPorts leaves out com ports that aren't connected to a named pipe, as every vm has COM1 and COM2
+func FlattenVmComPorts(vmComPorts *[]VmComPort) []interface{} {
+	if vmComPorts == nil || len(*vmComPorts) < 1 {
+		return nil
+	}
+
+	flattenedVmComPorts := make([]interface{}, 0)
+
+	for _, vmComPort := range *vmComPorts {
+		if vmComPort.Path == "" {
+			continue
+		}
+
+		flattenedVmComPort := make(map[string]interface{})
+		flattenedVmComPort["number"] = vmComPort.Number
+		flattenedVmComPort["path"] = vmComPort.Path
+		flattenedVmComPort["debugger_mode"] = vmComPort.DebuggerMode.String()
+		flattenedVmComPorts = append(flattenedVmComPorts, flattenedVmComPort)
+	}
+
+	return flattenedVmComPorts
+}
+
+type HypervVmComPortClient interface {
+	UpdateVmComPort(
+		ctx context.Context,
+		vmName string,
+		number int,
+		path string,
+		debuggerMode OnOffState,
+	) (err error)
+	GetVmComPorts(ctx context.Context, vmName string) (result []VmComPort, err error)
+	CreateOrUpdateVmComPorts(ctx context.Context, vmName string, vmComPorts []VmComPort) (err error)
+}
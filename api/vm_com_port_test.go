@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDeserializeVmComPorts(t *testing.T) {
+	var vmComPortsJson = `
+[
+    {
+        "Number":  1,
+        "Path":  "\\\\.\\pipe\\web-com1",
+        "DebuggerMode":  0
+    },
+    {
+        "Number":  2,
+        "Path":  "",
+        "DebuggerMode":  1
+    }
+]
+`
+
+	var vmComPorts []VmComPort
+	err := json.Unmarshal([]byte(vmComPortsJson), &vmComPorts)
+	if err != nil {
+		t.Errorf("Unable to deserialize vm com ports: %s", err.Error())
+	}
+
+	if len(vmComPorts) != 2 {
+		t.Fatalf("Expected 2 com ports, got %d", len(vmComPorts))
+	}
+
+	if vmComPorts[0].Number != 1 || vmComPorts[0].Path != `\\.\pipe\web-com1` || vmComPorts[0].DebuggerMode != OnOffState_On {
+		t.Errorf("Unexpected COM1 %+v", vmComPorts[0])
+	}
+
+	if vmComPorts[1].Number != 2 || vmComPorts[1].Path != "" || vmComPorts[1].DebuggerMode != OnOffState_Off {
+		t.Errorf("Unexpected COM2 %+v", vmComPorts[1])
+	}
+}
+
+func TestFlattenVmComPortsSkipsDisconnectedPorts(t *testing.T) {
+	vmComPorts := []VmComPort{
+		{Number: 1, Path: "", DebuggerMode: OnOffState_Off},
+		{Number: 2, Path: `\\.\pipe\web-com2`, DebuggerMode: OnOffState_On},
+	}
+
+	flattenedVmComPorts := FlattenVmComPorts(&vmComPorts)
+	if len(flattenedVmComPorts) != 1 {
+		t.Fatalf("Expected 1 com port, got %d", len(flattenedVmComPorts))
+	}
+
+	flattenedVmComPort := flattenedVmComPorts[0].(map[string]interface{})
+	if flattenedVmComPort["number"] != 2 {
+		t.Errorf("Expected number 2, got %v", flattenedVmComPort["number"])
+	}
+	if flattenedVmComPort["path"] != `\\.\pipe\web-com2` {
+		t.Errorf("Expected path %s, got %v", `\\.\pipe\web-com2`, flattenedVmComPort["path"])
+	}
+	if flattenedVmComPort["debugger_mode"] != "On" {
+		t.Errorf("Expected debugger_mode On, got %v", flattenedVmComPort["debugger_mode"])
+	}
+}
@@ -177,6 +177,61 @@ func (d *Gen2BootType) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+type VmKeyProtectorType int
+
+const (
+	VmKeyProtectorType_None                 VmKeyProtectorType = 0
+	VmKeyProtectorType_NewLocalKeyProtector VmKeyProtectorType = 1
+	VmKeyProtectorType_HgsGuardian          VmKeyProtectorType = 2
+)
+
+var VmKeyProtectorType_name = map[VmKeyProtectorType]string{
+	VmKeyProtectorType_None:                 "None",
+	VmKeyProtectorType_NewLocalKeyProtector: "NewLocalKeyProtector",
+	VmKeyProtectorType_HgsGuardian:          "HgsGuardian",
+}
+
+var VmKeyProtectorType_value = map[string]VmKeyProtectorType{
+	"none":                 VmKeyProtectorType_None,
+	"newlocalkeyprotector": VmKeyProtectorType_NewLocalKeyProtector,
+	"hgsguardian":          VmKeyProtectorType_HgsGuardian,
+}
+
+func (x VmKeyProtectorType) String() string {
+	return VmKeyProtectorType_name[x]
+}
+
+func ToVmKeyProtectorType(x string) VmKeyProtectorType {
+	if integerValue, err := strconv.Atoi(x); err == nil {
+		return VmKeyProtectorType(integerValue)
+	}
+	return VmKeyProtectorType_value[strings.ToLower(x)]
+}
+
+func (d *VmKeyProtectorType) MarshalJSON() ([]byte, error) {
+	buffer := bytes.NewBufferString(`"`)
+	buffer.WriteString(d.String())
+	buffer.WriteString(`"`)
+	return buffer.Bytes(), nil
+}
+
+func (d *VmKeyProtectorType) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		var i int
+		err2 := json.Unmarshal(b, &i)
+		if err2 == nil {
+			*d = VmKeyProtectorType(i)
+			return nil
+		}
+
+		return err
+	}
+	*d = ToVmKeyProtectorType(s)
+	return nil
+}
+
 type Gen2BootOrder struct {
 	Type Gen2BootType
 
@@ -197,6 +252,13 @@ type VmFirmware struct {
 	PreferredNetworkBootProtocol IPProtocolPreference
 	ConsoleMode                  ConsoleModeType
 	PauseAfterBootFailure        OnOffState
+
+	TpmEnabled                        bool
+	KeyProtector                      VmKeyProtectorType
+	HgsGuardianName                   string
+	EncryptStateAndVmMigrationTraffic bool
+	Shielded                          bool
+	VirtualizationBasedSecurityOptOut bool
 }
 
 func DefaultVmFirmwares() (interface{}, error) {
@@ -267,6 +329,13 @@ func ExpandVmFirmwares(d *schema.ResourceData) ([]VmFirmware, error) {
 				PreferredNetworkBootProtocol: ToIPProtocolPreference(firmware["preferred_network_boot_protocol"].(string)),
 				ConsoleMode:                  ToConsoleModeType(firmware["console_mode"].(string)),
 				PauseAfterBootFailure:        ToOnOffState(firmware["pause_after_boot_failure"].(string)),
+
+				TpmEnabled:                        firmware["tpm_enabled"].(bool),
+				KeyProtector:                      ToVmKeyProtectorType(firmware["key_protector"].(string)),
+				HgsGuardianName:                   firmware["hgs_guardian_name"].(string),
+				EncryptStateAndVmMigrationTraffic: firmware["encrypt_state_and_vm_migration_traffic"].(bool),
+				Shielded:                          firmware["shielded"].(bool),
+				VirtualizationBasedSecurityOptOut: firmware["virtualization_based_security_opt_out"].(bool),
 			}
 
 			expandedVmFirmwares = append(expandedVmFirmwares, expandedVmFirmware)
@@ -328,6 +397,12 @@ func FlattenVmFirmwares(vmFirmwares *[]VmFirmware) []interface{} {
 		flattenedVmFirmware["preferred_network_boot_protocol"] = vmFirmware.PreferredNetworkBootProtocol.String()
 		flattenedVmFirmware["console_mode"] = vmFirmware.ConsoleMode.String()
 		flattenedVmFirmware["pause_after_boot_failure"] = vmFirmware.PauseAfterBootFailure.String()
+		flattenedVmFirmware["tpm_enabled"] = vmFirmware.TpmEnabled
+		flattenedVmFirmware["key_protector"] = vmFirmware.KeyProtector.String()
+		flattenedVmFirmware["hgs_guardian_name"] = vmFirmware.HgsGuardianName
+		flattenedVmFirmware["encrypt_state_and_vm_migration_traffic"] = vmFirmware.EncryptStateAndVmMigrationTraffic
+		flattenedVmFirmware["shielded"] = vmFirmware.Shielded
+		flattenedVmFirmware["virtualization_based_security_opt_out"] = vmFirmware.VirtualizationBasedSecurityOptOut
 		flattenedVmFirmwares = append(flattenedVmFirmwares, flattenedVmFirmware)
 	}
 
@@ -344,6 +419,12 @@ type HypervVmFirmwareClient interface {
 		preferredNetworkBootProtocol IPProtocolPreference,
 		consoleMode ConsoleModeType,
 		pauseAfterBootFailure OnOffState,
+		tpmEnabled bool,
+		keyProtector VmKeyProtectorType,
+		hgsGuardianName string,
+		encryptStateAndVmMigrationTraffic bool,
+		shielded bool,
+		virtualizationBasedSecurityOptOut bool,
 	) (err error)
 	GetVmFirmware(ctx context.Context, vmName string) (result VmFirmware, err error)
 	GetNoVmFirmwares(ctx context.Context) (result []VmFirmware)
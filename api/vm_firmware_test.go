@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDeserializeVmFirmware(t *testing.T) {
+	var vmFirmwareJson = `
+{
+    "EnableSecureBoot":  1,
+    "SecureBootTemplate":  "MicrosoftWindows",
+    "TpmEnabled":  true,
+    "KeyProtector":  "NewLocalKeyProtector",
+    "HgsGuardianName":  "",
+    "EncryptStateAndVmMigrationTraffic":  true,
+    "Shielded":  false
+}
+`
+
+	var vmFirmware VmFirmware
+	err := json.Unmarshal([]byte(vmFirmwareJson), &vmFirmware)
+	if err != nil {
+		t.Errorf("Unable to deserialize vm firmware: %s", err.Error())
+	}
+
+	if !vmFirmware.TpmEnabled || vmFirmware.KeyProtector != VmKeyProtectorType_NewLocalKeyProtector || !vmFirmware.EncryptStateAndVmMigrationTraffic {
+		t.Errorf("Unexpected vm firmware security settings: %+v", vmFirmware)
+	}
+}
+
+func TestDeserializeVmKeyProtectorType(t *testing.T) {
+	for value, expected := range map[string]VmKeyProtectorType{
+		`"HgsGuardian"`: VmKeyProtectorType_HgsGuardian,
+		`"none"`:        VmKeyProtectorType_None,
+		`1`:             VmKeyProtectorType_NewLocalKeyProtector,
+	} {
+		var keyProtector VmKeyProtectorType
+		err := json.Unmarshal([]byte(value), &keyProtector)
+		if err != nil {
+			t.Errorf("Unable to deserialize vm key protector type %s: %s", value, err.Error())
+		}
+
+		if keyProtector != expected {
+			t.Errorf("Expected %s to deserialize to %s, got %s", value, expected, keyProtector)
+		}
+	}
+}
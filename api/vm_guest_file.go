@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// VmGuestFileIntegrationService is the integration service Copy-VMFile uses to copy files into the guest
+const VmGuestFileIntegrationService = "Guest Service Interface"
+
+// VmGuestFileId is the Terraform id of a file copied into the guest of a vm
+func VmGuestFileId(vmName string, destinationFilePath string) string {
+	return joinId(vmName, destinationFilePath)
+}
+
+// ParseVmGuestFileId splits a Terraform id made by VmGuestFileId into the vm name and the destination file path
+func ParseVmGuestFileId(id string) (vmName string, destinationFilePath string, err error) {
+	parts, err := splitId(id, 2)
+	if err != nil {
+		return "", "", fmt.Errorf("guest file id %q should be in the format <vm_name>/<destination_file_path>, with %% and / in the names escaped as %%25 and %%2F: %w", id, err)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// VmGuestFileContentHash returns the hex encoded SHA-256 of the local file at filePath
+func VmGuestFileContentHash(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type VmGuestFile struct {
+	VmName              string
+	SourceFilePath      string
+	DestinationFilePath string
+	CreateFullPath      bool
+}
+
+type HypervVmGuestFileClient interface {
+	// CopyVmGuestFile uploads the local file at sourceFilePath to the host and copies it to destinationFilePath in
+	// the guest. The Guest Service Interface integration service is enabled when it is not.
+	CopyVmGuestFile(ctx context.Context, vmName string, sourceFilePath string, destinationFilePath string, createFullPath bool) (err error)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseVmGuestFileId(t *testing.T) {
+	testCases := []struct {
+		vmName              string
+		destinationFilePath string
+	}{
+		{"web", `C:\config/app.json`},
+		{"web/api", `C:\config\app.json`},
+		{"web", `C:\config\100%.json`},
+	}
+
+	for _, testCase := range testCases {
+		id := VmGuestFileId(testCase.vmName, testCase.destinationFilePath)
+
+		vmName, destinationFilePath, err := ParseVmGuestFileId(id)
+		if err != nil {
+			t.Fatalf("Unable to parse guest file id %q: %s", id, err.Error())
+		}
+		if vmName != testCase.vmName || destinationFilePath != testCase.destinationFilePath {
+			t.Errorf("Expected %q to be %s and %s but got %s and %s", id, testCase.vmName, testCase.destinationFilePath, vmName, destinationFilePath)
+		}
+	}
+
+	for _, id := range []string{"", "web", "web/", `/C:\config\app.json`, `web/C:\config/app.json`} {
+		if _, _, err := ParseVmGuestFileId(id); err == nil {
+			t.Errorf("Expected guest file id %q to be rejected", id)
+		}
+	}
+}
+
+func TestVmGuestFileContentHash(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "app.json")
+	err := os.WriteFile(filePath, []byte("hello"), 0600)
+	if err != nil {
+		t.Fatalf("Unable to write local file: %s", err.Error())
+	}
+
+	contentHash, err := VmGuestFileContentHash(filePath)
+	if err != nil {
+		t.Fatalf("Unable to hash local file: %s", err.Error())
+	}
+
+	if contentHash != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("Unexpected content hash %s", contentHash)
+	}
+
+	if _, err := VmGuestFileContentHash(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("Expected a missing file to be rejected")
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"context"
+	"strings"
+)
+
+// VmGuestScriptResult is the outcome of a script run inside the guest of a vm over PowerShell Direct
+type VmGuestScriptResult struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+// JoinVmGuestScriptInline joins inline commands into a script, one command per line
+func JoinVmGuestScriptInline(inline []string) string {
+	return strings.Join(inline, "\r\n")
+}
+
+type HypervVmGuestScriptClient interface {
+	// RunVmGuestScript runs script inside the guest of the running vm over PowerShell Direct, signing in with username
+	// and password. The script runs in its own powershell process so that its output and exit code are captured.
+	RunVmGuestScript(ctx context.Context, vmName string, username string, password string, script string) (result VmGuestScriptResult, err error)
+}
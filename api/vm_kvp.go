@@ -0,0 +1,41 @@
+package api
+
+import (
+	"context"
+	"strings"
+)
+
+// VmKvpItem is a key value pair exchanged with the guest through the Key-Value Pair Exchange integration service
+type VmKvpItem struct {
+	VmName string
+	Name   string
+	Data   string
+}
+
+// VmGuestKvpItems are the key value pairs published by the guest. Intrinsic items are published by the integration
+// services, the other items are published by software running in the guest.
+type VmGuestKvpItems struct {
+	IntrinsicItems map[string]string
+	Items          map[string]string
+}
+
+// SplitVmKvpNetworkAddresses splits the semicolon separated addresses of the NetworkAddressIPv4 and
+// NetworkAddressIPv6 intrinsic items
+func SplitVmKvpNetworkAddresses(networkAddresses string) []string {
+	result := make([]string, 0)
+	for _, networkAddress := range strings.Split(networkAddresses, ";") {
+		networkAddress = strings.TrimSpace(networkAddress)
+		if networkAddress != "" {
+			result = append(result, networkAddress)
+		}
+	}
+
+	return result
+}
+
+type HypervVmKvpClient interface {
+	CreateOrUpdateVmKvpItem(ctx context.Context, vmName string, name string, data string) (err error)
+	GetVmKvpItems(ctx context.Context, vmName string) (result []VmKvpItem, err error)
+	DeleteVmKvpItem(ctx context.Context, vmName string, name string) (err error)
+	GetVmGuestKvpItems(ctx context.Context, vmName string) (result VmGuestKvpItems, err error)
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDeserializeVmGuestKvpItems(t *testing.T) {
+	var vmGuestKvpItemsJson = `
+{
+    "IntrinsicItems":  {
+        "FullyQualifiedDomainName":  "web.example.com",
+        "NetworkAddressIPv4":  "10.0.0.5;192.168.1.10"
+    },
+    "Items":  {
+    }
+}
+`
+
+	var vmGuestKvpItems VmGuestKvpItems
+	err := json.Unmarshal([]byte(vmGuestKvpItemsJson), &vmGuestKvpItems)
+	if err != nil {
+		t.Errorf("Unable to deserialize vm guest kvp items: %s", err.Error())
+	}
+
+	if vmGuestKvpItems.IntrinsicItems["FullyQualifiedDomainName"] != "web.example.com" {
+		t.Errorf("Expected FullyQualifiedDomainName web.example.com, got %v", vmGuestKvpItems.IntrinsicItems)
+	}
+
+	if len(vmGuestKvpItems.Items) != 0 {
+		t.Errorf("Expected no guest items, got %v", vmGuestKvpItems.Items)
+	}
+}
+
+func TestSplitVmKvpNetworkAddresses(t *testing.T) {
+	testCases := map[string][]string{
+		"":                        {},
+		"10.0.0.5":                {"10.0.0.5"},
+		"10.0.0.5;192.168.1.10":   {"10.0.0.5", "192.168.1.10"},
+		"10.0.0.5; 192.168.1.10;": {"10.0.0.5", "192.168.1.10"},
+	}
+
+	for networkAddresses, expected := range testCases {
+		actual := SplitVmKvpNetworkAddresses(networkAddresses)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Expected %q to split into %v, got %v", networkAddresses, expected, actual)
+		}
+	}
+}
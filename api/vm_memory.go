@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func DefaultVmMemories() (interface{}, error) {
+	result := make([]VmMemory, 0)
+	vmMemory := VmMemory{
+		Buffer:                        20,
+		Priority:                      50,
+		MaximumAmountPerNumaNodeBytes: 0,
+	}
+
+	result = append(result, vmMemory)
+	return result, nil
+}
+
+func DiffSuppressVmMemoryBuffer(key, old, new string, d *schema.ResourceData) bool {
+	log.Printf("[DEBUG] '[%s]' Comparing old value '[%v]' with new value '[%v]' ", key, old, new)
+	if !d.Get("dynamic_memory").(bool) {
+		// The buffer is only used by dynamic memory, so it is not applied to the vm
+		return true
+	}
+
+	return new == old
+}
+
+func DiffSuppressVmMemoryMaximumAmountPerNumaNodeBytes(key, old, new string, d *schema.ResourceData) bool {
+	log.Printf("[DEBUG] '[%s]' Comparing old value '[%v]' with new value '[%v]' ", key, old, new)
+	if new == "0" {
+		// We have not explicitly set a value, so allow any value as we are not tracking it
+		return true
+	}
+
+	return new == old
+}
+
+func ExpandVmMemories(d *schema.ResourceData) ([]VmMemory, error) {
+	expandedVmMemories := make([]VmMemory, 0)
+
+	if v, ok := d.GetOk("vm_memory"); ok {
+		vmMemories := v.([]interface{})
+		for _, memory := range vmMemories {
+			memory, ok := memory.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("[ERROR][hyperv] vm_memory should be a Hash - was '%+v'", memory)
+			}
+
+			log.Printf("[DEBUG] memory =  [%+v]", memory)
+
+			expandedVmMemory := VmMemory{
+				Buffer:                        int32(memory["buffer"].(int)),
+				Priority:                      int32(memory["priority"].(int)),
+				MaximumAmountPerNumaNodeBytes: int64(memory["maximum_amount_per_numa_node_bytes"].(int)),
+			}
+
+			expandedVmMemories = append(expandedVmMemories, expandedVmMemory)
+		}
+	}
+
+	return expandedVmMemories, nil
+}
+
+func FlattenVmMemories(vmMemories *[]VmMemory) []interface{} {
+	if vmMemories == nil || len(*vmMemories) < 1 {
+		return nil
+	}
+
+	flattenedVmMemories := make([]interface{}, 0)
+
+	for _, vmMemory := range *vmMemories {
+		flattenedVmMemory := make(map[string]interface{})
+		flattenedVmMemory["buffer"] = vmMemory.Buffer
+		flattenedVmMemory["priority"] = vmMemory.Priority
+		flattenedVmMemory["maximum_amount_per_numa_node_bytes"] = vmMemory.MaximumAmountPerNumaNodeBytes
+		flattenedVmMemory["host_numa_spanning"] = vmMemory.HostNumaSpanning
+		flattenedVmMemories = append(flattenedVmMemories, flattenedVmMemory)
+	}
+
+	return flattenedVmMemories
+}
+
+type VmMemory struct {
+	VmName                        string
+	Buffer                        int32
+	Priority                      int32
+	MaximumAmountPerNumaNodeBytes int64
+	// HostNumaSpanning is On or Off. It is a setting of the host, so it is only read.
+	HostNumaSpanning string `json:",omitempty"`
+}
+
+type HypervVmMemoryClient interface {
+	CreateOrUpdateVmMemory(
+		ctx context.Context,
+		vmName string,
+		buffer int32,
+		priority int32,
+		maximumAmountPerNumaNodeBytes int64,
+	) (err error)
+	GetVmMemories(ctx context.Context, vmName string) (result []VmMemory, err error)
+	CreateOrUpdateVmMemories(ctx context.Context, vmName string, vmMemories []VmMemory) (err error)
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDeserializeVmMemory(t *testing.T) {
+	var vmMemoryJson = `
+{
+    "Buffer":  25,
+    "Priority":  80,
+    "MaximumAmountPerNumaNodeBytes":  4294967296,
+    "HostNumaSpanning":  "Off"
+}
+`
+
+	var vmMemory VmMemory
+	err := json.Unmarshal([]byte(vmMemoryJson), &vmMemory)
+	if err != nil {
+		t.Errorf("Unable to deserialize vm memory: %s", err.Error())
+	}
+
+	expected := VmMemory{
+		Buffer:                        25,
+		Priority:                      80,
+		MaximumAmountPerNumaNodeBytes: 4294967296,
+		HostNumaSpanning:              "Off",
+	}
+	if vmMemory != expected {
+		t.Errorf("Expected %+v, got %+v", expected, vmMemory)
+	}
+}
+
+func TestFlattenVmMemories(t *testing.T) {
+	vmMemories := []VmMemory{{
+		Buffer:                        20,
+		Priority:                      50,
+		MaximumAmountPerNumaNodeBytes: 2147483648,
+		HostNumaSpanning:              "On",
+	}}
+
+	flattenedVmMemories := FlattenVmMemories(&vmMemories)
+	if len(flattenedVmMemories) != 1 {
+		t.Fatalf("Expected 1 vm memory, got %d", len(flattenedVmMemories))
+	}
+
+	flattenedVmMemory := flattenedVmMemories[0].(map[string]interface{})
+	if flattenedVmMemory["buffer"] != int32(20) {
+		t.Errorf("Expected buffer 20, got %v", flattenedVmMemory["buffer"])
+	}
+	if flattenedVmMemory["priority"] != int32(50) {
+		t.Errorf("Expected priority 50, got %v", flattenedVmMemory["priority"])
+	}
+	if flattenedVmMemory["maximum_amount_per_numa_node_bytes"] != int64(2147483648) {
+		t.Errorf("Expected maximum_amount_per_numa_node_bytes 2147483648, got %v", flattenedVmMemory["maximum_amount_per_numa_node_bytes"])
+	}
+	if flattenedVmMemory["host_numa_spanning"] != "On" {
+		t.Errorf("Expected host_numa_spanning On, got %v", flattenedVmMemory["host_numa_spanning"])
+	}
+}
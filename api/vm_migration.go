@@ -0,0 +1,95 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+type VmMigrationMode int
+
+const (
+	VmMigrationMode_SharedNothing VmMigrationMode = 0
+	VmMigrationMode_SharedStorage VmMigrationMode = 1
+	VmMigrationMode_Recreate      VmMigrationMode = 2
+)
+
+var VmMigrationMode_name = map[VmMigrationMode]string{
+	VmMigrationMode_SharedNothing: "SharedNothing",
+	VmMigrationMode_SharedStorage: "SharedStorage",
+	VmMigrationMode_Recreate:      "Recreate",
+}
+
+var VmMigrationMode_value = map[string]VmMigrationMode{
+	"sharednothing": VmMigrationMode_SharedNothing,
+	"sharedstorage": VmMigrationMode_SharedStorage,
+	"recreate":      VmMigrationMode_Recreate,
+}
+
+func (x VmMigrationMode) String() string {
+	return VmMigrationMode_name[x]
+}
+
+func ToVmMigrationMode(x string) VmMigrationMode {
+	if integerValue, err := strconv.Atoi(x); err == nil {
+		return VmMigrationMode(integerValue)
+	}
+	return VmMigrationMode_value[strings.ToLower(x)]
+}
+
+func (d *VmMigrationMode) MarshalJSON() ([]byte, error) {
+	buffer := bytes.NewBufferString(`"`)
+	buffer.WriteString(d.String())
+	buffer.WriteString(`"`)
+	return buffer.Bytes(), nil
+}
+
+func (d *VmMigrationMode) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		var i int
+		err2 := json.Unmarshal(b, &i)
+		if err2 == nil {
+			*d = VmMigrationMode(i)
+			return nil
+		}
+
+		return err
+	}
+	*d = ToVmMigrationMode(s)
+	return nil
+}
+
+// VmMigrationIncompatibility is a reason Compare-VM found why a vm can't be moved to another host
+type VmMigrationIncompatibility struct {
+	MessageId int64
+	Message   string
+}
+
+type HypervVmMigrationClient interface {
+	// HostName returns the Hyper-V host the client manages
+	HostName() string
+	// ClientForHost returns a client for another Hyper-V host that uses the same settings. An empty host returns the
+	// client itself.
+	ClientForHost(host string) (Client, error)
+
+	GetVmMigrationIncompatibilities(
+		ctx context.Context,
+		vmName string,
+		destinationHost string,
+		includeStorage bool,
+		vmStorageMove VmStorageMove,
+	) (result []VmMigrationIncompatibility, err error)
+	MoveVm(
+		ctx context.Context,
+		vmName string,
+		destinationHost string,
+		includeStorage bool,
+		timeout uint32,
+		pollPeriod uint32,
+		vmStorageMove VmStorageMove,
+	) (err error)
+}
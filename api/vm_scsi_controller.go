@@ -0,0 +1,20 @@
+package api
+
+import (
+	"context"
+)
+
+const MaxVmScsiControllers = 4
+
+type VmScsiController struct {
+	VmName           string
+	ControllerNumber int
+	DriveCount       int
+}
+
+type HypervVmScsiControllerClient interface {
+	CreateVmScsiController(ctx context.Context, vmName string) (err error)
+	GetVmScsiControllers(ctx context.Context, vmName string) (result []VmScsiController, err error)
+	DeleteVmScsiController(ctx context.Context, vmName string, controllerNumber int) (err error)
+	CreateOrUpdateVmScsiControllers(ctx context.Context, vmName string, controllerNumbers []int) (err error)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+type VmStorageMigration struct {
+	RetainVhdCopiesOnSource bool
+	MoveHardDiskDrives      bool
+}
+
+func ExpandVmStorageMigrations(d *schema.ResourceData) ([]VmStorageMigration, error) {
+	expandedVmStorageMigrations := make([]VmStorageMigration, 0)
+
+	if v, ok := d.GetOk("storage_migration"); ok {
+		vmStorageMigrations := v.([]interface{})
+		for _, vmStorageMigration := range vmStorageMigrations {
+			vmStorageMigration, ok := vmStorageMigration.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("[ERROR][hyperv] storage_migration should be a Hash - was '%+v'", vmStorageMigration)
+			}
+
+			log.Printf("[DEBUG] vmStorageMigration =  [%+v]", vmStorageMigration)
+
+			expandedVmStorageMigration := VmStorageMigration{
+				RetainVhdCopiesOnSource: vmStorageMigration["retain_vhd_copies_on_source"].(bool),
+				MoveHardDiskDrives:      vmStorageMigration["move_hard_disk_drives"].(bool),
+			}
+
+			expandedVmStorageMigrations = append(expandedVmStorageMigrations, expandedVmStorageMigration)
+		}
+	}
+
+	return expandedVmStorageMigrations, nil
+}
+
+type VhdMove struct {
+	SourceFilePath      string
+	DestinationFilePath string
+}
+
+// VmStorageMove describes what Move-VMStorage moves. Empty paths are left where they are.
+type VmStorageMove struct {
+	VirtualMachinePath      string
+	SnapshotFilePath        string
+	SmartPagingFilePath     string
+	Vhds                    []VhdMove
+	RetainVhdCopiesOnSource bool
+}
+
+type HypervVmStorageClient interface {
+	MoveVmStorage(
+		ctx context.Context,
+		vmName string,
+		timeout uint32,
+		pollPeriod uint32,
+		vmStorageMove VmStorageMove,
+	) (err error)
+}
@@ -36,3 +36,21 @@ func TestDeserializeVm(t *testing.T) {
 		t.Errorf("Unable to deserialize vm: %s", err.Error())
 	}
 }
+
+func TestDeserializeVmImportMode(t *testing.T) {
+	for value, expected := range map[string]VmImportMode{
+		`"GenerateNewId"`: VmImportMode_GenerateNewId,
+		`"register"`:      VmImportMode_Register,
+		`1`:               VmImportMode_Copy,
+	} {
+		var mode VmImportMode
+		err := json.Unmarshal([]byte(value), &mode)
+		if err != nil {
+			t.Errorf("Unable to deserialize vm import mode %s: %s", value, err.Error())
+		}
+
+		if mode != expected {
+			t.Errorf("Expected %s to deserialize to %s, got %s", value, expected, mode)
+		}
+	}
+}
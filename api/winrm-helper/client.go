@@ -1,13 +1,15 @@
 package winrm_helper
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	pool "github.com/jolestar/go-commons-pool/v2"
 	"github.com/masterzen/winrm"
@@ -25,32 +27,125 @@ type ClientConfig struct {
 	ElevatedUser     string
 	ElevatedPassword string
 	Vars             string
+
+	// UploadOptions configures how files are split up and uploaded in parallel
+	UploadOptions powershell.UploadOptions
+
+	// BorrowTimeout is how long to wait for a pooled winrm client to become available, 0 waits until one is returned
+	BorrowTimeout time.Duration
+
+	// UseSession keeps a long-lived powershell session per pooled winrm client instead of uploading a script per call
+	UseSession bool
+
+	sessionsMutex sync.Mutex
+	sessions      map[*winrm.Client]*powershell.Session
 }
 
-func (c *ClientConfig) RunFireAndForgetScript(ctx context.Context, script *template.Template, args interface{}) error {
-	var scriptRendered bytes.Buffer
-	err := script.Execute(&scriptRendered, args)
+func (c *ClientConfig) borrowObject(ctx context.Context) (interface{}, error) {
+	return BorrowObject(ctx, c.WinRmClientPool, c.BorrowTimeout)
+}
+
+func (c *ClientConfig) borrowWinRmClient(ctx context.Context) (*winrm.Client, func(), error) {
+	winrmClient, err := c.borrowObject(ctx)
 
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("%w: %w", ErrNotStarted, err)
 	}
 
-	command := scriptRendered.String()
+	return winrmClient.(*winrm.Client), func() {
+		err := c.WinRmClientPool.ReturnObject(ctx, winrmClient)
+		if err != nil {
+			log.Printf("[DEBUG] Unable to return winrm client to the pool: %s", err)
+		}
+	}, nil
+}
+
+func (c *ClientConfig) getSession(winrmClient *winrm.Client) (*powershell.Session, error) {
+	c.sessionsMutex.Lock()
+	session, ok := c.sessions[winrmClient]
+	c.sessionsMutex.Unlock()
+
+	if ok && !session.Broken() {
+		return session, nil
+	}
 
-	winrmClient, err := c.WinRmClientPool.BorrowObject(ctx)
+	if ok {
+		c.CloseSession(winrmClient)
+	}
+
+	session, err := powershell.NewSession(winrmClient, c.Vars)
+	if err != nil {
+		return nil, err
+	}
+
+	c.sessionsMutex.Lock()
+	if c.sessions == nil {
+		c.sessions = map[*winrm.Client]*powershell.Session{}
+	}
+	c.sessions[winrmClient] = session
+	c.sessionsMutex.Unlock()
+
+	return session, nil
+}
+
+// CloseSession stops the powershell session kept for winrmClient. It should be called when the pool destroys the client.
+func (c *ClientConfig) CloseSession(winrmClient *winrm.Client) {
+	c.sessionsMutex.Lock()
+	session, ok := c.sessions[winrmClient]
+	delete(c.sessions, winrmClient)
+	c.sessionsMutex.Unlock()
+
+	if ok {
+		err := session.Close()
+		if err != nil {
+			log.Printf("[DEBUG] Unable to close powershell session: %s", err)
+		}
+	}
+}
+
+func (c *ClientConfig) runPowershell(ctx context.Context, winrmClient *winrm.Client, command string) (exitStatus int, stdout string, stderr string, err error) {
+	if c.UseSession {
+		session, err := c.getSession(winrmClient)
+		if err == nil {
+			exitStatus, stdout, stderr, err = session.Run(ctx, command)
+			if errors.Is(err, powershell.ErrSessionBroken) {
+				c.CloseSession(winrmClient)
+			}
+
+			// The script may have run when the session broke after it was sent, so it is only run again when it was
+			// never sent
+			if !errors.Is(err, powershell.ErrSessionNotStarted) {
+				return exitStatus, stdout, stderr, err
+			}
+		}
+
+		log.Printf("[WARN] Powershell session unavailable, falling back to uploading the script: %s", err)
+	}
+
+	return powershell.RunPowershell(ctx, winrmClient, c.ElevatedUser, c.ElevatedPassword, c.Vars, command)
+}
+
+func (c *ClientConfig) RunFireAndForgetScript(ctx context.Context, script *template.Template, args interface{}) error {
+	command, err := powershell.RenderScript(script, args)
 
 	if err != nil {
 		return err
 	}
 
-	log.Printf("[DEBUG] Running fire and forget script:\n%s\n", command)
+	winrmClient, err := c.borrowObject(ctx)
 
-	_, _, _, err = powershell.RunPowershell(winrmClient.(*winrm.Client), c.ElevatedUser, c.ElevatedPassword, c.Vars, command)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNotStarted, err)
+	}
+
+	log.Printf("[DEBUG] Running fire and forget script:\n%s\n", powershell.RenderRedactedScript(script, args))
+
+	_, _, _, err = c.runPowershell(ctx, winrmClient.(*winrm.Client), command)
 
 	err2 := c.WinRmClientPool.ReturnObject(ctx, winrmClient)
 
 	if err != nil {
-		return err
+		return powershell.RedactError(WrapScriptError(err))
 	}
 
 	if err2 != nil {
@@ -61,29 +156,26 @@ func (c *ClientConfig) RunFireAndForgetScript(ctx context.Context, script *templ
 }
 
 func (c *ClientConfig) RunScriptWithResult(ctx context.Context, script *template.Template, args interface{}, result interface{}) (err error) {
-	var scriptRendered bytes.Buffer
-	err = script.Execute(&scriptRendered, args)
+	command, err := powershell.RenderScript(script, args)
 
 	if err != nil {
 		return err
 	}
 
-	command := scriptRendered.String()
-
-	winrmClient, err := c.WinRmClientPool.BorrowObject(ctx)
+	winrmClient, err := c.borrowObject(ctx)
 
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", ErrNotStarted, err)
 	}
 
-	log.Printf("[DEBUG] Running script with result:\n%s\n", command)
+	log.Printf("[DEBUG] Running script with result:\n%s\n", powershell.RenderRedactedScript(script, args))
 
-	exitStatus, stdout, stderr, err := powershell.RunPowershell(winrmClient.(*winrm.Client), c.ElevatedUser, c.ElevatedPassword, c.Vars, command)
+	exitStatus, stdout, stderr, err := c.runPowershell(ctx, winrmClient.(*winrm.Client), command)
 
 	err2 := c.WinRmClientPool.ReturnObject(ctx, winrmClient)
 
 	if err != nil {
-		return err
+		return powershell.RedactError(WrapScriptError(err))
 	}
 
 	if err2 != nil {
@@ -94,74 +186,50 @@ func (c *ClientConfig) RunScriptWithResult(ctx context.Context, script *template
 
 	err = json.Unmarshal([]byte(stdout), &result)
 	if err != nil {
-		return fmt.Errorf("exitStatus:%d\nstdOut:%s\nstdErr:%s\nerr:%s\ncommand:%s", exitStatus, stdout, stderr, err, command)
+		return fmt.Errorf("exitStatus:%d\nstdOut:%s\nstdErr:%s\nerr:%s\ncommand:%s", exitStatus, powershell.Redact(stdout), powershell.Redact(stderr), err, powershell.RenderRedactedScript(script, args))
 	}
 
 	return nil
 }
 
 func (c *ClientConfig) UploadFile(ctx context.Context, filePath string, remoteFilePath string) (string, error) {
-	winrmClient, err := c.WinRmClientPool.BorrowObject(ctx)
-
-	if err != nil {
-		return "", err
-	}
-
 	log.Printf("[DEBUG] upload file %#v", filePath)
 
-	remoteFilePath, err = powershell.UploadFile(winrmClient.(*winrm.Client), filePath, remoteFilePath)
-
-	err2 := c.WinRmClientPool.ReturnObject(ctx, winrmClient)
+	remoteFilePath, err := powershell.UploadFile(ctx, c.borrowWinRmClient, filePath, remoteFilePath, c.UploadOptions)
 
 	if err != nil {
 		return "", err
 	}
 
-	if err2 != nil {
-		return "", err2
-	}
-
 	log.Printf("[DEBUG] uploaded file %#v to %#v", filePath, remoteFilePath)
 
 	return remoteFilePath, nil
 }
 
 func (c *ClientConfig) UploadDirectory(ctx context.Context, rootPath string, excludeList []string) (remoteRootPath string, remoteAbsoluteFilePaths []string, err error) {
-	winrmClient, err := c.WinRmClientPool.BorrowObject(ctx)
-
-	if err != nil {
-		return "", []string{}, err
-	}
-
 	log.Printf("[DEBUG] upload directory %#v", rootPath)
 
-	remoteRootPath, remoteAbsoluteFilePaths, err = powershell.UploadDirectory(winrmClient.(*winrm.Client), rootPath, excludeList)
-
-	err2 := c.WinRmClientPool.ReturnObject(ctx, winrmClient)
+	remoteRootPath, remoteAbsoluteFilePaths, err = powershell.UploadDirectory(ctx, c.borrowWinRmClient, rootPath, excludeList, c.UploadOptions)
 
 	if err != nil {
 		return "", []string{}, err
 	}
 
-	if err2 != nil {
-		return "", []string{}, err2
-	}
-
 	log.Printf("[DEBUG] uploaded directory %#v to %#v. The following files where uploaded %#v", rootPath, remoteRootPath, remoteAbsoluteFilePaths)
 
 	return remoteRootPath, remoteAbsoluteFilePaths, nil
 }
 
 func (c *ClientConfig) FileExists(ctx context.Context, remoteFilePath string) (exists bool, err error) {
-	winrmClient, err := c.WinRmClientPool.BorrowObject(ctx)
+	winrmClient, err := c.borrowObject(ctx)
 
 	if err != nil {
-		return false, err
+		return false, fmt.Errorf("%w: %w", ErrNotStarted, err)
 	}
 
 	log.Printf("[DEBUG] check file exists %#v", remoteFilePath)
 
-	result, err := powershell.FileExists(winrmClient.(*winrm.Client), remoteFilePath)
+	result, err := powershell.FileExists(ctx, winrmClient.(*winrm.Client), remoteFilePath)
 
 	err2 := c.WinRmClientPool.ReturnObject(ctx, winrmClient)
 
@@ -183,15 +251,15 @@ func (c *ClientConfig) FileExists(ctx context.Context, remoteFilePath string) (e
 }
 
 func (c *ClientConfig) DirectoryExists(ctx context.Context, remoteDirectoryPath string) (exists bool, err error) {
-	winrmClient, err := c.WinRmClientPool.BorrowObject(ctx)
+	winrmClient, err := c.borrowObject(ctx)
 
 	if err != nil {
-		return false, err
+		return false, fmt.Errorf("%w: %w", ErrNotStarted, err)
 	}
 
 	log.Printf("[DEBUG] check directory exists %#v", remoteDirectoryPath)
 
-	result, err := powershell.DirectoryExists(winrmClient.(*winrm.Client), remoteDirectoryPath)
+	result, err := powershell.DirectoryExists(ctx, winrmClient.(*winrm.Client), remoteDirectoryPath)
 
 	err2 := c.WinRmClientPool.ReturnObject(ctx, winrmClient)
 
@@ -213,15 +281,15 @@ func (c *ClientConfig) DirectoryExists(ctx context.Context, remoteDirectoryPath
 }
 
 func (c *ClientConfig) DeleteFileOrDirectory(ctx context.Context, remotePath string) (err error) {
-	winrmClient, err := c.WinRmClientPool.BorrowObject(ctx)
+	winrmClient, err := c.borrowObject(ctx)
 
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", ErrNotStarted, err)
 	}
 
 	log.Printf("[DEBUG] delete file or directory %#v", remotePath)
 
-	err = powershell.DeleteFileOrDirectory(winrmClient.(*winrm.Client), remotePath)
+	err = powershell.DeleteFileOrDirectory(ctx, winrmClient.(*winrm.Client), remotePath)
 
 	err2 := c.WinRmClientPool.ReturnObject(ctx, winrmClient)
 
@@ -0,0 +1,31 @@
+package winrm_helper
+
+import (
+	"errors"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
+)
+
+// WrapScriptError turns a failed script that reported an ErrorRecord into an api.Error, so callers can check for
+// api.ErrNotFound, api.ErrAccessDenied and api.ErrInUse. Other errors are returned unchanged.
+func WrapScriptError(err error) error {
+	var scriptError *powershell.ScriptError
+	if !errors.As(err, &scriptError) {
+		return err
+	}
+
+	errorRecord := scriptError.ErrorRecord()
+	if errorRecord == nil {
+		return err
+	}
+
+	return &api.Error{
+		Kind:                  api.ToErrorKind(errorRecord.Category, errorRecord.FullyQualifiedErrorId, errorRecord.ExceptionType, errorRecord.HResult),
+		Category:              errorRecord.Category,
+		FullyQualifiedErrorId: errorRecord.FullyQualifiedErrorId,
+		TargetName:            errorRecord.TargetName,
+		Message:               errorRecord.Message,
+		Err:                   err,
+	}
+}
@@ -0,0 +1,34 @@
+package winrm_helper
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
+)
+
+func TestWrapScriptError(t *testing.T) {
+	scriptError := &powershell.ScriptError{
+		ExitCode: 1,
+		Stdout:   `#terraform-error-record {"Category":"ObjectNotFound","FullyQualifiedErrorId":"VmNotFound","TargetName":"web","Message":"VM does not exist - web","ExceptionType":"Microsoft.PowerShell.Commands.WriteErrorException","HResult":-2146233087}`,
+		Stderr:   "VM does not exist - web",
+	}
+
+	err := WrapScriptError(fmt.Errorf("running GetVm: %w", scriptError))
+
+	var apiError *api.Error
+	if !errors.As(err, &apiError) || !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("Expected a not found api error: %v", err)
+	}
+
+	if apiError.TargetName != "web" || apiError.FullyQualifiedErrorId != "VmNotFound" {
+		t.Errorf("ErrorRecord not copied: %#v", apiError)
+	}
+
+	plainError := &powershell.ScriptError{ExitCode: 1, Stderr: "boom"}
+	if err := WrapScriptError(plainError); err != error(plainError) {
+		t.Errorf("Expected errors without an ErrorRecord to be returned unchanged: %v", err)
+	}
+}
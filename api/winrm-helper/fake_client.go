@@ -0,0 +1,236 @@
+package winrm_helper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
+)
+
+// FakeScript is a script that was run through FakeClient
+type FakeScript struct {
+	Name   string
+	Args   interface{}
+	Script string
+}
+
+// FakeResponse is a canned response returned by FakeClient for a script
+type FakeResponse struct {
+	Result string
+	Err    error
+}
+
+// FakeClient is an in-memory implementation of Client. It records every rendered script and answers
+// with canned responses queued per template name, so api/hyperv-winrm can be tested without a Hyper-V host.
+// Uploaded files are kept in memory and keyed by their remote path.
+type FakeClient struct {
+	mutex     sync.Mutex
+	scripts   []FakeScript
+	responses map[string][]FakeResponse
+	files     map[string][]byte
+}
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		responses: map[string][]FakeResponse{},
+		files:     map[string][]byte{},
+	}
+}
+
+// Respond queues a response for the template called name. Responses are returned in the order they were
+// queued, and the last response keeps being returned once the queue is drained.
+func (c *FakeClient) Respond(name string, result string, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.responses[name] = append(c.responses[name], FakeResponse{
+		Result: result,
+		Err:    err,
+	})
+}
+
+// Scripts returns the scripts run so far, in the order they were run
+func (c *FakeClient) Scripts() []FakeScript {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	scripts := make([]FakeScript, len(c.scripts))
+	copy(scripts, c.scripts)
+	return scripts
+}
+
+// Files returns the remote paths of the files uploaded so far, sorted
+func (c *FakeClient) Files() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	remoteFilePaths := make([]string, 0, len(c.files))
+	for remoteFilePath := range c.files {
+		remoteFilePaths = append(remoteFilePaths, remoteFilePath)
+	}
+	sort.Strings(remoteFilePaths)
+	return remoteFilePaths
+}
+
+// FileContent returns the content uploaded to remoteFilePath
+func (c *FakeClient) FileContent(remoteFilePath string) (content []byte, exists bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	content, exists = c.files[remoteFilePath]
+	return content, exists
+}
+
+func (c *FakeClient) run(script *template.Template, args interface{}) (response FakeResponse, err error) {
+	command, err := powershell.RenderScript(script, args)
+
+	if err != nil {
+		return response, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	name := script.Name()
+	c.scripts = append(c.scripts, FakeScript{
+		Name:   name,
+		Args:   args,
+		Script: command,
+	})
+
+	responses := c.responses[name]
+	if len(responses) == 0 {
+		return response, nil
+	}
+
+	response = responses[0]
+	if len(responses) > 1 {
+		c.responses[name] = responses[1:]
+	}
+
+	return response, nil
+}
+
+func (c *FakeClient) RunFireAndForgetScript(ctx context.Context, script *template.Template, args interface{}) error {
+	response, err := c.run(script, args)
+
+	if err != nil {
+		return err
+	}
+
+	return response.Err
+}
+
+func (c *FakeClient) RunScriptWithResult(ctx context.Context, script *template.Template, args interface{}, result interface{}) (err error) {
+	response, err := c.run(script, args)
+
+	if err != nil {
+		return err
+	}
+
+	if response.Err != nil {
+		return response.Err
+	}
+
+	if response.Result == "" {
+		return fmt.Errorf("no response queued for script %s", script.Name())
+	}
+
+	err = json.Unmarshal([]byte(response.Result), &result)
+	if err != nil {
+		return fmt.Errorf("stdOut:%s\nerr:%s\nscript:%s", response.Result, err, script.Name())
+	}
+
+	return nil
+}
+
+func (c *FakeClient) UploadFile(ctx context.Context, filePath string, remoteFilePath string) (resolvedRemoteFilePath string, err error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("error opening file: %s", err)
+	}
+
+	if remoteFilePath == "" {
+		remoteFilePath = `$env:TEMP\` + filepath.Base(filePath)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.files[remoteFilePath] = content
+
+	return remoteFilePath, nil
+}
+
+func (c *FakeClient) UploadDirectory(ctx context.Context, rootPath string, excludeList []string) (remoteRootPath string, remoteAbsoluteFilePaths []string, err error) {
+	remoteRootPath = `$env:TEMP\` + filepath.Base(rootPath)
+	remoteAbsoluteFilePaths = []string{}
+
+	err = filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		for _, exclude := range excludeList {
+			if strings.Contains(path, exclude) {
+				return nil
+			}
+		}
+
+		relativePath, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			return err
+		}
+
+		remoteFilePath, err := c.UploadFile(ctx, path, remoteRootPath+`\`+strings.ReplaceAll(relativePath, "/", `\`))
+		if err != nil {
+			return err
+		}
+
+		remoteAbsoluteFilePaths = append(remoteAbsoluteFilePaths, remoteFilePath)
+		return nil
+	})
+
+	if err != nil {
+		return "", []string{}, err
+	}
+
+	return remoteRootPath, remoteAbsoluteFilePaths, nil
+}
+
+func (c *FakeClient) FileExists(ctx context.Context, remoteFilePath string) (exists bool, err error) {
+	_, exists = c.FileContent(remoteFilePath)
+	return exists, nil
+}
+
+func (c *FakeClient) DirectoryExists(ctx context.Context, remoteDirectoryPath string) (exists bool, err error) {
+	prefix := strings.TrimSuffix(remoteDirectoryPath, `\`) + `\`
+	for _, remoteFilePath := range c.Files() {
+		if strings.HasPrefix(remoteFilePath, prefix) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (c *FakeClient) DeleteFileOrDirectory(ctx context.Context, remotePath string) (err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	prefix := strings.TrimSuffix(remotePath, `\`) + `\`
+	for remoteFilePath := range c.files {
+		if remoteFilePath == remotePath || strings.HasPrefix(remoteFilePath, prefix) {
+			delete(c.files, remoteFilePath)
+		}
+	}
+
+	return nil
+}
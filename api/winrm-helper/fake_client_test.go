@@ -0,0 +1,87 @@
+package winrm_helper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+)
+
+type testScriptArgs struct {
+	Name string
+}
+
+var testScriptTemplate = template.Must(template.New("TestScript").Parse(`
+$ErrorActionPreference = 'Stop'
+Get-VM -Name $arguments.Name
+`))
+
+func TestFakeClientReturnsQueuedResponses(t *testing.T) {
+	c := NewFakeClient()
+	c.Respond("TestScript", `{"Exists":false}`, nil)
+	c.Respond("TestScript", "", errors.New("access denied"))
+	c.Respond("TestScript", `{"Exists":true}`, nil)
+	ctx := context.Background()
+
+	var result struct{ Exists bool }
+	err := c.RunScriptWithResult(ctx, testScriptTemplate, testScriptArgs{Name: "web"}, &result)
+	if err != nil || result.Exists {
+		t.Errorf("Unexpected first response: %#v %v", result, err)
+	}
+
+	err = c.RunFireAndForgetScript(ctx, testScriptTemplate, testScriptArgs{Name: "web"})
+	if err == nil || err.Error() != "access denied" {
+		t.Errorf("Expected queued error to be returned: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = c.RunScriptWithResult(ctx, testScriptTemplate, testScriptArgs{Name: "web"}, &result)
+		if err != nil || !result.Exists {
+			t.Errorf("Expected last response to be repeated: %#v %v", result, err)
+		}
+	}
+
+	scripts := c.Scripts()
+	if len(scripts) != 4 || scripts[0].Name != "TestScript" || scripts[0].Args.(testScriptArgs).Name != "web" {
+		t.Errorf("Scripts not recorded: %#v", scripts)
+	}
+}
+
+func TestFakeClientFiles(t *testing.T) {
+	c := NewFakeClient()
+	ctx := context.Background()
+
+	localDirectory := filepath.Join(t.TempDir(), "bootstrap")
+	err := os.MkdirAll(filepath.Join(localDirectory, "nested"), 0700)
+	if err != nil {
+		t.Fatalf("Unable to create local directory: %s", err.Error())
+	}
+
+	err = os.WriteFile(filepath.Join(localDirectory, "nested", "a.ps1"), []byte("a"), 0600)
+	if err != nil {
+		t.Fatalf("Unable to write local file: %s", err.Error())
+	}
+
+	remoteRootPath, remoteFilePaths, err := c.UploadDirectory(ctx, localDirectory, []string{})
+	if err != nil {
+		t.Fatalf("Unable to upload directory: %s", err.Error())
+	}
+
+	if remoteRootPath != `$env:TEMP\bootstrap` || len(remoteFilePaths) != 1 || remoteFilePaths[0] != `$env:TEMP\bootstrap\nested\a.ps1` {
+		t.Errorf("Unexpected remote paths: %s %#v", remoteRootPath, remoteFilePaths)
+	}
+
+	exists, _ := c.DirectoryExists(ctx, `$env:TEMP\bootstrap`)
+	if !exists {
+		t.Errorf("Expected directory to exist")
+	}
+
+	_ = c.DeleteFileOrDirectory(ctx, `$env:TEMP\bootstrap`)
+
+	exists, _ = c.FileExists(ctx, `$env:TEMP\bootstrap\nested\a.ps1`)
+	if exists {
+		t.Errorf("Expected file to be deleted with its directory")
+	}
+}
@@ -0,0 +1,28 @@
+package winrm_helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pool "github.com/jolestar/go-commons-pool/v2"
+)
+
+// BorrowObject borrows a client from clientPool, giving up after borrowTimeout. A borrowTimeout of 0 waits until a
+// client is returned to the pool or ctx is done.
+func BorrowObject(ctx context.Context, clientPool *pool.ObjectPool, borrowTimeout time.Duration) (interface{}, error) {
+	if borrowTimeout <= 0 {
+		return clientPool.BorrowObject(ctx)
+	}
+
+	borrowCtx, cancel := context.WithTimeout(ctx, borrowTimeout)
+	defer cancel()
+
+	client, err := clientPool.BorrowObject(borrowCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(borrowCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("timed out after %s waiting for a pooled connection, %d of %d connections are in use: %v", borrowTimeout, clientPool.GetNumActive(), clientPool.Config.MaxTotal, err)
+	}
+
+	return client, err
+}
@@ -0,0 +1,37 @@
+package winrm_helper
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	pool "github.com/jolestar/go-commons-pool/v2"
+)
+
+func TestBorrowObjectTimesOut(t *testing.T) {
+	ctx := context.Background()
+	poolConfig := pool.NewDefaultPoolConfig()
+	poolConfig.MaxTotal = 1
+	clientPool := pool.NewObjectPool(ctx, pool.NewPooledObjectFactorySimple(func(context.Context) (interface{}, error) {
+		return &struct{ Name string }{Name: "client"}, nil
+	}), poolConfig)
+	defer clientPool.Close(ctx)
+
+	client, err := BorrowObject(ctx, clientPool, time.Second)
+	if err != nil {
+		t.Fatalf("Unable to borrow client: %s", err.Error())
+	}
+
+	_, err = BorrowObject(ctx, clientPool, 10*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "1 of 1 connections are in use") {
+		t.Errorf("Expected borrowing from an exhausted pool to time out: %v", err)
+	}
+
+	_ = clientPool.ReturnObject(ctx, client)
+
+	_, err = BorrowObject(ctx, clientPool, 10*time.Millisecond)
+	if err != nil {
+		t.Errorf("Expected returned client to be borrowed: %v", err)
+	}
+}
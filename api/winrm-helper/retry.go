@@ -0,0 +1,217 @@
+package winrm_helper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
+)
+
+// ErrNotStarted is returned when an operation failed before anything was sent to the host, so it is always safe to retry
+var ErrNotStarted = errors.New("operation was not started")
+
+type ErrorClass string
+
+const (
+	// ErrorClassConnection is a failure to reach the host, like a reboot or a WinRM listener restart
+	ErrorClassConnection ErrorClass = "connection"
+
+	// ErrorClassBusy is Hyper-V refusing an operation because the object is in use or another operation is in progress
+	ErrorClassBusy ErrorClass = "busy"
+)
+
+const maxRetryDelay = 1 * time.Minute
+
+var connectionErrorMessages = []string{
+	"connection refused",
+	"connection reset",
+	"broken pipe",
+	"i/o timeout",
+	"http response error: 502",
+	"http response error: 503",
+	"the rpc server is unavailable",
+}
+
+var busyErrorMessages = []string{
+	"operation is in progress",
+	"operation is already in progress",
+	"object is in use",
+	"system is busy",
+	"wmi is busy",
+}
+
+// ClassifyError works out which ErrorClass err belongs to. It returns an empty ErrorClass for errors that are not
+// transient.
+func ClassifyError(err error) ErrorClass {
+	if err == nil || errors.Is(err, powershell.ErrCancelled) {
+		return ""
+	}
+
+	if errors.Is(err, api.ErrInUse) {
+		return ErrorClassBusy
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, busyErrorMessage := range busyErrorMessages {
+		if strings.Contains(message, busyErrorMessage) {
+			return ErrorClassBusy
+		}
+	}
+
+	var netError net.Error
+	if errors.Is(err, ErrNotStarted) ||
+		errors.As(err, &netError) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorClassConnection
+	}
+
+	for _, connectionErrorMessage := range connectionErrorMessages {
+		if strings.Contains(message, connectionErrorMessage) {
+			return ErrorClassConnection
+		}
+	}
+
+	return ""
+}
+
+type RetryPolicy struct {
+	// MaxAttempts is the number of times an operation is tried before giving up, 1 disables retries
+	MaxAttempts int
+
+	// BaseDelay is the wait before the first retry, it is doubled for every retry after that
+	BaseDelay time.Duration
+
+	// ErrorClasses are the classes of error that are retried
+	ErrorClasses []ErrorClass
+}
+
+// RetryClient retries operations of Client that fail with a transient error. Reads are retried for any of the
+// configured error classes. Mutations are only retried when the host refused them because it was busy, or when they
+// never reached the host, as otherwise a mutation that did run could be applied twice.
+type RetryClient struct {
+	Client Client
+	Policy RetryPolicy
+}
+
+func NewRetryClient(client Client, policy RetryPolicy) *RetryClient {
+	return &RetryClient{
+		Client: client,
+		Policy: policy,
+	}
+}
+
+func isReadScript(script *template.Template) bool {
+	name := script.Name()
+	return strings.HasPrefix(name, "Get") || strings.HasPrefix(name, "Exists") || strings.HasPrefix(name, "WaitFor")
+}
+
+func (c *RetryClient) shouldRetry(read bool, err error) bool {
+	errorClass := ClassifyError(err)
+	if errorClass == "" {
+		return false
+	}
+
+	retryable := false
+	for _, policyErrorClass := range c.Policy.ErrorClasses {
+		if policyErrorClass == errorClass {
+			retryable = true
+			break
+		}
+	}
+
+	if !retryable {
+		return false
+	}
+
+	return read || errorClass == ErrorClassBusy || errors.Is(err, ErrNotStarted)
+}
+
+func (c *RetryClient) retry(ctx context.Context, operation string, read bool, f func() error) error {
+	delay := c.Policy.BaseDelay
+
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= c.Policy.MaxAttempts || !c.shouldRetry(read, err) {
+			return err
+		}
+
+		log.Printf("[WARN][hyperv][retry] %s failed with a %s error on attempt %d of %d, retrying in %s: %s", operation, ClassifyError(err), attempt, c.Policy.MaxAttempts, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		delay = delay * 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+func (c *RetryClient) RunFireAndForgetScript(ctx context.Context, script *template.Template, args interface{}) error {
+	return c.retry(ctx, script.Name(), isReadScript(script), func() error {
+		return c.Client.RunFireAndForgetScript(ctx, script, args)
+	})
+}
+
+func (c *RetryClient) RunScriptWithResult(ctx context.Context, script *template.Template, args interface{}, result interface{}) (err error) {
+	return c.retry(ctx, script.Name(), isReadScript(script), func() error {
+		return c.Client.RunScriptWithResult(ctx, script, args, result)
+	})
+}
+
+// UploadFile is retried like a read, as an upload either resumes from the parts that made it or starts over
+func (c *RetryClient) UploadFile(ctx context.Context, filePath string, remoteFilePath string) (resolvedRemoteFilePath string, err error) {
+	err = c.retry(ctx, "UploadFile", true, func() error {
+		resolvedRemoteFilePath, err = c.Client.UploadFile(ctx, filePath, remoteFilePath)
+		return err
+	})
+
+	return resolvedRemoteFilePath, err
+}
+
+func (c *RetryClient) UploadDirectory(ctx context.Context, rootPath string, excludeList []string) (remoteRootPath string, remoteAbsoluteFilePaths []string, err error) {
+	err = c.retry(ctx, "UploadDirectory", true, func() error {
+		remoteRootPath, remoteAbsoluteFilePaths, err = c.Client.UploadDirectory(ctx, rootPath, excludeList)
+		return err
+	})
+
+	return remoteRootPath, remoteAbsoluteFilePaths, err
+}
+
+func (c *RetryClient) FileExists(ctx context.Context, remoteFilePath string) (exists bool, err error) {
+	err = c.retry(ctx, "FileExists", true, func() error {
+		exists, err = c.Client.FileExists(ctx, remoteFilePath)
+		return err
+	})
+
+	return exists, err
+}
+
+func (c *RetryClient) DirectoryExists(ctx context.Context, remoteDirectoryPath string) (exists bool, err error) {
+	err = c.retry(ctx, "DirectoryExists", true, func() error {
+		exists, err = c.Client.DirectoryExists(ctx, remoteDirectoryPath)
+		return err
+	})
+
+	return exists, err
+}
+
+func (c *RetryClient) DeleteFileOrDirectory(ctx context.Context, remotePath string) (err error) {
+	return c.retry(ctx, "DeleteFileOrDirectory", false, func() error {
+		return c.Client.DeleteFileOrDirectory(ctx, remotePath)
+	})
+}
@@ -0,0 +1,124 @@
+package winrm_helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/taliesins/terraform-provider-hyperv/api"
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
+)
+
+var testReadScriptTemplate = template.Must(template.New("GetTestScript").Parse(`
+$ErrorActionPreference = 'Stop'
+Get-VM -Name $arguments.Name
+`))
+
+var testMutationScriptTemplate = template.Must(template.New("UpdateTestScript").Parse(`
+$ErrorActionPreference = 'Stop'
+Set-VM -Name $arguments.Name
+`))
+
+var errConnectionReset = errors.New("read tcp 10.0.0.1:5986: connection reset by peer")
+
+func newTestRetryClient(fakeClient *FakeClient, errorClasses ...ErrorClass) *RetryClient {
+	return NewRetryClient(fakeClient, RetryPolicy{
+		MaxAttempts:  3,
+		BaseDelay:    time.Millisecond,
+		ErrorClasses: errorClasses,
+	})
+}
+
+func TestClassifyError(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected ErrorClass
+	}{
+		{errConnectionReset, ErrorClassConnection},
+		{fmt.Errorf("%w: %w", ErrNotStarted, errors.New("dial tcp: lookup hyperv")), ErrorClassConnection},
+		{errors.New("http response error: 503 - invalid content type"), ErrorClassConnection},
+		{&api.Error{Kind: api.ErrInUse, Err: errors.New("The process cannot access the file")}, ErrorClassBusy},
+		{errors.New("The operation cannot be performed while the virtual machine operation is in progress."), ErrorClassBusy},
+		{&api.Error{Kind: api.ErrNotFound, Err: errors.New("VM does not exist - web")}, ""},
+		{fmt.Errorf("%w: %w", powershell.ErrCancelled, context.DeadlineExceeded), ""},
+	}
+
+	for _, testCase := range testCases {
+		if errorClass := ClassifyError(testCase.err); errorClass != testCase.expected {
+			t.Errorf("Expected %q to be classed as %q but was %q", testCase.err, testCase.expected, errorClass)
+		}
+	}
+}
+
+func TestRetryClientRetriesReads(t *testing.T) {
+	fakeClient := NewFakeClient()
+	fakeClient.Respond("GetTestScript", "", errConnectionReset)
+	fakeClient.Respond("GetTestScript", `{"Exists":true}`, nil)
+	c := newTestRetryClient(fakeClient, ErrorClassConnection, ErrorClassBusy)
+
+	var result struct{ Exists bool }
+	err := c.RunScriptWithResult(context.Background(), testReadScriptTemplate, testScriptArgs{Name: "web"}, &result)
+	if err != nil || !result.Exists {
+		t.Errorf("Expected read to be retried: %#v %v", result, err)
+	}
+
+	if scripts := fakeClient.Scripts(); len(scripts) != 2 {
+		t.Errorf("Expected 2 attempts: %#v", scripts)
+	}
+}
+
+func TestRetryClientGivesUpAfterMaxAttempts(t *testing.T) {
+	fakeClient := NewFakeClient()
+	fakeClient.Respond("GetTestScript", "", errConnectionReset)
+	c := newTestRetryClient(fakeClient, ErrorClassConnection)
+
+	err := c.RunFireAndForgetScript(context.Background(), testReadScriptTemplate, testScriptArgs{Name: "web"})
+	if !errors.Is(err, errConnectionReset) {
+		t.Errorf("Expected last error to be returned: %v", err)
+	}
+
+	if scripts := fakeClient.Scripts(); len(scripts) != 3 {
+		t.Errorf("Expected 3 attempts: %#v", scripts)
+	}
+}
+
+func TestRetryClientOnlyRetriesSafeMutations(t *testing.T) {
+	testCases := []struct {
+		err      error
+		attempts int
+	}{
+		{errConnectionReset, 1},
+		{fmt.Errorf("%w: %w", ErrNotStarted, errConnectionReset), 2},
+		{&api.Error{Kind: api.ErrInUse, Err: errors.New("The process cannot access the file")}, 2},
+		{&api.Error{Kind: api.ErrAccessDenied, Err: errors.New("access denied")}, 1},
+	}
+
+	for _, testCase := range testCases {
+		fakeClient := NewFakeClient()
+		fakeClient.Respond("UpdateTestScript", "", testCase.err)
+		fakeClient.Respond("UpdateTestScript", "", nil)
+		c := newTestRetryClient(fakeClient, ErrorClassConnection, ErrorClassBusy)
+
+		_ = c.RunFireAndForgetScript(context.Background(), testMutationScriptTemplate, testScriptArgs{Name: "web"})
+
+		if scripts := fakeClient.Scripts(); len(scripts) != testCase.attempts {
+			t.Errorf("Expected %d attempts for %q but was %d", testCase.attempts, testCase.err, len(scripts))
+		}
+	}
+}
+
+func TestRetryClientOnlyRetriesConfiguredErrorClasses(t *testing.T) {
+	fakeClient := NewFakeClient()
+	fakeClient.Respond("GetTestScript", "", errConnectionReset)
+	fakeClient.Respond("GetTestScript", `{"Exists":true}`, nil)
+	c := newTestRetryClient(fakeClient, ErrorClassBusy)
+
+	var result struct{ Exists bool }
+	err := c.RunScriptWithResult(context.Background(), testReadScriptTemplate, testScriptArgs{Name: "web"}, &result)
+	if !errors.Is(err, errConnectionReset) {
+		t.Errorf("Expected connection error not to be retried: %v", err)
+	}
+}
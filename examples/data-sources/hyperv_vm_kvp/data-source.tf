@@ -0,0 +1,23 @@
+terraform {
+  required_providers {
+    hyperv = {
+      source  = "taliesins/hyperv"
+      version = ">= 1.0.3"
+    }
+  }
+}
+
+provider "hyperv" {
+}
+
+data "hyperv_vm_kvp" "web_server" {
+  vm_name = "web_server"
+}
+
+output "web_server_fqdn" {
+  value = data.hyperv_vm_kvp.web_server.fully_qualified_domain_name
+}
+
+output "web_server_ipv4" {
+  value = data.hyperv_vm_kvp.web_server.network_address_ipv4
+}
@@ -23,4 +23,24 @@ resource "hyperv_iso_image" "bootstrap" {
   destination_iso_file_path = "$env:TEMP\\bootstrap.iso"
   iso_media_type            = "dvdplusrw_duallayer"
   iso_file_system_type      = "unknown"
+}
+
+resource "hyperv_iso_image" "cloud_init" {
+  destination_iso_file_path = "$env:TEMP\\cloud-init.iso"
+  user_data                 = <<-EOT
+    #cloud-config
+    hostname: web
+  EOT
+  meta_data                 = <<-EOT
+    instance-id: web
+    local-hostname: web
+  EOT
+}
+
+resource "hyperv_iso_image" "unattend" {
+  volume_name               = "UNATTEND"
+  destination_iso_file_path = "$env:TEMP\\unattend.iso"
+  files = {
+    "autounattend.xml" = file("autounattend.xml")
+  }
 }
\ No newline at end of file
@@ -103,6 +103,13 @@ resource "hyperv_machine_instance" "default" {
     expose_virtualization_extensions                  = false
   }
 
+  # Configure memory
+  vm_memory {
+    buffer                             = 20
+    priority                           = 50
+    maximum_amount_per_numa_node_bytes = 0
+  }
+
   # Configure integration services
   integration_services = {
     "Guest Service Interface" = false
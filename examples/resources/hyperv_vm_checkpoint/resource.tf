@@ -0,0 +1,18 @@
+terraform {
+  required_providers {
+    hyperv = {
+      source  = "taliesins/hyperv"
+      version = ">= 1.0.3"
+    }
+  }
+}
+
+provider "hyperv" {
+}
+
+resource "hyperv_vm_checkpoint" "default" {
+  vm_name                  = "web_server"
+  name                     = "baseline"
+  restore_on_apply         = true
+  delete_child_checkpoints = false
+}
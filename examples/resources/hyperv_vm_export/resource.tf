@@ -0,0 +1,30 @@
+terraform {
+  required_providers {
+    hyperv = {
+      source  = "taliesins/hyperv"
+      version = ">= 1.0.3"
+    }
+  }
+}
+
+provider "hyperv" {
+}
+
+resource "hyperv_vm_export" "golden" {
+  vm_name         = "golden_image"
+  path            = "D:\\exports"
+  checkpoint_name = "sysprepped"
+}
+
+resource "hyperv_machine_instance" "web_server" {
+  name                 = "web_server"
+  generation           = 2
+  memory_startup_bytes = 2147483648
+  static_memory        = true
+
+  import_source {
+    path                 = hyperv_vm_export.golden.export_path
+    mode                 = "GenerateNewId"
+    vhd_destination_path = "D:\\vhd\\web_server"
+  }
+}
@@ -0,0 +1,18 @@
+terraform {
+  required_providers {
+    hyperv = {
+      source  = "taliesins/hyperv"
+      version = ">= 1.0.3"
+    }
+  }
+}
+
+provider "hyperv" {
+}
+
+resource "hyperv_vm_guest_file" "app_config" {
+  vm_name          = "web_server"
+  source           = "${path.module}/files/app.json"
+  destination_path = "C:\\app\\config\\app.json"
+  create_full_path = true
+}
@@ -0,0 +1,34 @@
+terraform {
+  required_providers {
+    hyperv = {
+      source  = "taliesins/hyperv"
+      version = ">= 1.0.3"
+    }
+  }
+}
+
+provider "hyperv" {
+}
+
+variable "guest_password" {
+  type      = string
+  sensitive = true
+}
+
+resource "hyperv_vm_guest_script" "rename_computer" {
+  vm_name  = "web_server"
+  username = "Administrator"
+  password = var.guest_password
+
+  inline = [
+    "Rename-Computer -NewName web01 -Force",
+    "hostname",
+  ]
+}
+
+resource "hyperv_vm_guest_script" "configure" {
+  vm_name  = "web_server"
+  username = "Administrator"
+  password = var.guest_password
+  script   = "${path.module}/scripts/configure.ps1"
+}
@@ -0,0 +1,19 @@
+terraform {
+  required_providers {
+    hyperv = {
+      source  = "taliesins/hyperv"
+      version = ">= 1.0.3"
+    }
+  }
+}
+
+provider "hyperv" {
+}
+
+resource "hyperv_vm_kvp" "default" {
+  vm_name = "web_server"
+  items = {
+    "Role"        = "frontend"
+    "Environment" = "production"
+  }
+}
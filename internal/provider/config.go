@@ -5,23 +5,31 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/taliesins/terraform-provider-hyperv/api"
 	hyperv_winrm "github.com/taliesins/terraform-provider-hyperv/api/hyperv-winrm"
+	ssh_helper "github.com/taliesins/terraform-provider-hyperv/api/ssh-helper"
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
 
 	"github.com/dylanmei/iso8601"
 	pool "github.com/jolestar/go-commons-pool/v2"
 	winrm "github.com/masterzen/winrm"
 	winrm_helper "github.com/taliesins/terraform-provider-hyperv/api/winrm-helper"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 type Config struct {
 	Version          string
 	Commit           string
 	TerraformVersion string
+	Transport        string
 	User             string
 	Password         string
 	Host             string
@@ -41,13 +49,42 @@ type Config struct {
 	Cert          []byte
 	Key           []byte
 
-	ScriptPath string
-	Timeout    string
+	ScriptPath        string
+	Timeout           string
+	PersistentSession bool
+
+	ConnectionPoolMaxTotal         int
+	ConnectionPoolMaxIdle          int
+	ConnectionPoolMinIdle          int
+	ConnectionPoolIdleTimeout      string
+	ConnectionPoolBorrowTimeout    string
+	ConnectionPoolEvictionInterval string
+	MaxHeavyOperations             int
+
+	UploadPartSize    int
+	UploadParallelism int
+
+	RetryMaxAttempts  int
+	RetryBaseDelay    string
+	RetryErrorClasses []string
+
+	SweepTemporaryItems          bool
+	SweepTemporaryItemsOlderThan string
+
+	SshPort       int
+	SshPrivateKey []byte
+	SshUseAgent   bool
+	SshKnownHosts string
 }
 
 // HypervWinRmClient() returns a new client for configuring hyperv.
 func (c *Config) Client() (comm api.Client, err error) {
-	log.Printf("[INFO][hyperv] HyperV HypervWinRmClient configured for HyperV API operations using:\n"+
+	// The password ends up in scripts, for example in the elevated runner, so keep it out of logs and errors
+	powershell.RegisterSecret(c.Password)
+	powershell.RegisterSecret(c.KrbCCache)
+
+	log.Print(powershell.Redact(fmt.Sprintf("[INFO][hyperv] HyperV HypervWinRmClient configured for HyperV API operations using:\n"+
+		"  Transport: %s\n"+
 		"  Host: %s\n"+
 		"  Port: %d\n"+
 		"  User: %s\n"+
@@ -64,7 +101,27 @@ func (c *Config) Client() (comm api.Client, err error) {
 		"  Cert: %t\n"+
 		"  Key: %t\n"+
 		"  ScriptPath: %s\n"+
-		"  Timeout: %s",
+		"  Timeout: %s\n"+
+		"  PersistentSession: %t\n"+
+		"  ConnectionPoolMaxTotal: %d\n"+
+		"  ConnectionPoolMaxIdle: %d\n"+
+		"  ConnectionPoolMinIdle: %d\n"+
+		"  ConnectionPoolIdleTimeout: %s\n"+
+		"  ConnectionPoolBorrowTimeout: %s\n"+
+		"  ConnectionPoolEvictionInterval: %s\n"+
+		"  MaxHeavyOperations: %d\n"+
+		"  UploadPartSize: %d\n"+
+		"  UploadParallelism: %d\n"+
+		"  RetryMaxAttempts: %d\n"+
+		"  RetryBaseDelay: %s\n"+
+		"  RetryErrorClasses: %s\n"+
+		"  SweepTemporaryItems: %t\n"+
+		"  SweepTemporaryItemsOlderThan: %s\n"+
+		"  SshPort: %d\n"+
+		"  SshPrivateKey: %t\n"+
+		"  SshUseAgent: %t\n"+
+		"  SshKnownHosts: %s",
+		c.Transport,
 		c.Host,
 		c.Port,
 		c.User,
@@ -82,7 +139,26 @@ func (c *Config) Client() (comm api.Client, err error) {
 		c.Key != nil,
 		c.ScriptPath,
 		c.Timeout,
-	)
+		c.PersistentSession,
+		c.ConnectionPoolMaxTotal,
+		c.ConnectionPoolMaxIdle,
+		c.ConnectionPoolMinIdle,
+		c.ConnectionPoolIdleTimeout,
+		c.ConnectionPoolBorrowTimeout,
+		c.ConnectionPoolEvictionInterval,
+		c.MaxHeavyOperations,
+		c.UploadPartSize,
+		c.UploadParallelism,
+		c.RetryMaxAttempts,
+		c.RetryBaseDelay,
+		strings.Join(c.RetryErrorClasses, ","),
+		c.SweepTemporaryItems,
+		c.SweepTemporaryItemsOlderThan,
+		c.SshPort,
+		c.SshPrivateKey != nil,
+		c.SshUseAgent,
+		c.SshKnownHosts,
+	)))
 
 	hyperVProvider, err := getHypervProvider(c)
 
@@ -193,9 +269,164 @@ func ipFormat(ip string) string {
 	return fmt.Sprintf("[%s]", ip)
 }
 
+// GetSshClient creates a new communicator implementation over OpenSSH.
+func GetSshClient(config *Config) (sshClient *ssh.Client, err error) {
+	var authMethods []ssh.AuthMethod
+
+	if config.SshPrivateKey != nil {
+		signer, err := ssh.ParsePrivateKey(config.SshPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse ssh private key: %v", err)
+		}
+
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	}
+
+	if config.SshUseAgent {
+		if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+			agentConnection, err := net.Dial("unix", socket)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't connect to ssh agent: %v", err)
+			}
+			defer agentConnection.Close()
+
+			authMethods = append(authMethods, ssh.PublicKeysCallback(agent.NewClient(agentConnection).Signers))
+		}
+	}
+
+	if config.Password != "" {
+		authMethods = append(authMethods, ssh.Password(config.Password))
+	}
+
+	if len(authMethods) == 0 {
+		return nil, fmt.Errorf("no ssh authentication method available, specify ssh_private_key_path, ssh_use_agent or password")
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if !config.Insecure {
+		knownHostsPath := config.SshKnownHosts
+		if strings.HasPrefix(knownHostsPath, "~") {
+			homeDirectory, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			knownHostsPath = filepath.Join(homeDirectory, strings.TrimPrefix(knownHostsPath, "~"))
+		}
+
+		hostKeyCallback, err = knownhosts.New(knownHostsPath)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't load ssh known hosts %s: %v", knownHostsPath, err)
+		}
+	}
+
+	timeout, err := time.ParseDuration(config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't convert \"%s\" to a duration", config.Timeout)
+	}
+
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.SshPort))
+
+	sshClient, err = ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            config.User,
+		Auth:            authMethods,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sshClient, nil
+}
+
+func getConnectionPool(ctx context.Context, config *Config, factory pool.PooledObjectFactory) (connectionPool *pool.ObjectPool, borrowTimeout time.Duration, err error) {
+	idleTimeout, err := time.ParseDuration(config.ConnectionPoolIdleTimeout)
+	if err != nil {
+		return nil, 0, fmt.Errorf("couldn't convert \"%s\" to a duration", config.ConnectionPoolIdleTimeout)
+	}
+
+	borrowTimeout, err = time.ParseDuration(config.ConnectionPoolBorrowTimeout)
+	if err != nil {
+		return nil, 0, fmt.Errorf("couldn't convert \"%s\" to a duration", config.ConnectionPoolBorrowTimeout)
+	}
+
+	evictionInterval, err := time.ParseDuration(config.ConnectionPoolEvictionInterval)
+	if err != nil {
+		return nil, 0, fmt.Errorf("couldn't convert \"%s\" to a duration", config.ConnectionPoolEvictionInterval)
+	}
+
+	poolConfig := pool.NewDefaultPoolConfig()
+	poolConfig.BlockWhenExhausted = true
+	poolConfig.MinIdle = config.ConnectionPoolMinIdle
+	poolConfig.MaxIdle = config.ConnectionPoolMaxIdle
+	poolConfig.MaxTotal = config.ConnectionPoolMaxTotal
+	poolConfig.MinEvictableIdleTime = idleTimeout
+	poolConfig.TimeBetweenEvictionRuns = evictionInterval
+
+	// The evictor is started when the pool is created, so the config has to be complete by then
+	return pool.NewObjectPool(ctx, factory, poolConfig), borrowTimeout, nil
+}
+
+func getRetryPolicy(config *Config) (retryPolicy winrm_helper.RetryPolicy, err error) {
+	retryBaseDelay, err := time.ParseDuration(config.RetryBaseDelay)
+	if err != nil {
+		return retryPolicy, fmt.Errorf("couldn't convert \"%s\" to a duration", config.RetryBaseDelay)
+	}
+
+	retryPolicy = winrm_helper.RetryPolicy{
+		MaxAttempts: config.RetryMaxAttempts,
+		BaseDelay:   retryBaseDelay,
+	}
+
+	for _, retryErrorClass := range config.RetryErrorClasses {
+		retryPolicy.ErrorClasses = append(retryPolicy.ErrorClasses, winrm_helper.ErrorClass(retryErrorClass))
+	}
+
+	return retryPolicy, nil
+}
+
 func getHypervProvider(config *Config) (hypervProvider *api.Provider, err error) {
+	if config.Transport == TransportSsh {
+		return getHypervSshProvider(config)
+	}
+
+	return getHypervWinRmProvider(config)
+}
+
+// newHypervHostClient returns a func that connects to another Hyper-V host with the same settings as the provider
+func newHypervHostClient(config *Config) func(host string) (api.Client, error) {
+	return func(host string) (api.Client, error) {
+		hostConfig := *config
+		hostConfig.Host = host
+		// These are derived from the host when they are not set, a value set for the provider host won't match another host
+		hostConfig.KrbSpn = ""
+		hostConfig.TLSServerName = ""
+
+		hypervProvider, err := getHypervProvider(&hostConfig)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return hypervProvider.Client, nil
+	}
+}
+
+func getHypervWinRmProvider(config *Config) (hypervProvider *api.Provider, err error) {
 	ctx := context.Background()
-	factory := pool.NewPooledObjectFactorySimple(
+	winrmHelperClientConfig := &winrm_helper.ClientConfig{
+		Vars:             "",
+		ElevatedUser:     config.User,
+		ElevatedPassword: config.Password,
+		UseSession:       config.PersistentSession,
+		UploadOptions: powershell.UploadOptions{
+			PartSize:    int64(config.UploadPartSize),
+			Parallelism: config.UploadParallelism,
+		},
+	}
+
+	factory := pool.NewPooledObjectFactory(
 		func(context.Context) (interface{}, error) {
 			winrmClient, err := GetWinrmClient(config)
 
@@ -204,27 +435,83 @@ func getHypervProvider(config *Config) (hypervProvider *api.Provider, err error)
 			}
 
 			return winrmClient, nil
-		})
+		},
+		func(ctx context.Context, object *pool.PooledObject) error {
+			winrmHelperClientConfig.CloseSession(object.Object.(*winrm.Client))
+			return nil
+		}, nil, nil, nil)
 
-	winRmClientPool := pool.NewObjectPoolWithDefaultConfig(ctx, factory)
-	winRmClientPool.Config.BlockWhenExhausted = true
-	winRmClientPool.Config.MinIdle = 0
-	winRmClientPool.Config.MaxIdle = 2
-	winRmClientPool.Config.MaxTotal = 5
-	winRmClientPool.Config.TimeBetweenEvictionRuns = 10 * time.Second
+	winRmClientPool, borrowTimeout, err := getConnectionPool(ctx, config, factory)
 
-	winrmHelperProvider, err := winrm_helper.New(&winrm_helper.ClientConfig{
-		WinRmClientPool:  winRmClientPool,
-		Vars:             "",
-		ElevatedUser:     config.User,
-		ElevatedPassword: config.Password,
+	if err != nil {
+		return nil, err
+	}
+
+	winrmHelperClientConfig.WinRmClientPool = winRmClientPool
+	winrmHelperClientConfig.BorrowTimeout = borrowTimeout
+
+	winrmHelperProvider, err := winrm_helper.New(winrmHelperClientConfig)
+
+	if err != nil {
+		return nil, err
+	}
+
+	retryPolicy, err := getRetryPolicy(config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return hyperv_winrm.New(&hyperv_winrm.ClientConfig{
+		WinRmClient:        winrm_helper.NewRetryClient(winrmHelperProvider.Client, retryPolicy),
+		MaxHeavyOperations: config.MaxHeavyOperations,
+		Host:               config.Host,
+		NewHostClient:      newHypervHostClient(config),
+	})
+}
+
+func getHypervSshProvider(config *Config) (hypervProvider *api.Provider, err error) {
+	ctx := context.Background()
+	factory := pool.NewPooledObjectFactory(
+		func(context.Context) (interface{}, error) {
+			sshClient, err := GetSshClient(config)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return sshClient, nil
+		},
+		func(ctx context.Context, object *pool.PooledObject) error {
+			return object.Object.(*ssh.Client).Close()
+		}, nil, nil, nil)
+
+	sshClientPool, borrowTimeout, err := getConnectionPool(ctx, config, factory)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sshHelperProvider, err := ssh_helper.New(&ssh_helper.ClientConfig{
+		SshClientPool: sshClientPool,
+		Vars:          "",
+		BorrowTimeout: borrowTimeout,
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	retryPolicy, err := getRetryPolicy(config)
+
+	if err != nil {
+		return nil, err
+	}
+
 	return hyperv_winrm.New(&hyperv_winrm.ClientConfig{
-		WinRmClient: winrmHelperProvider.Client,
+		WinRmClient:        winrm_helper.NewRetryClient(sshHelperProvider.Client, retryPolicy),
+		MaxHeavyOperations: config.MaxHeavyOperations,
+		Host:               config.Host,
+		NewHostClient:      newHypervHostClient(config),
 	})
 }
@@ -247,6 +247,49 @@ func dataSourceHyperVMachineInstance() *schema.Resource {
 				Description: "The amount of time in seconds to wait between trying to get ip addresses for network cards on the virtual machine.",
 			},
 
+			"vm_memory": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				DefaultFunc: api.DefaultVmMemories,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"buffer": {
+							Type:             schema.TypeInt,
+							Optional:         true,
+							Default:          20,
+							ValidateDiagFunc: IntBetween(5, 2000),
+							DiffSuppressFunc: api.DiffSuppressVmMemoryBuffer,
+							Description:      "Specifies the percentage of memory to reserve as a buffer in the virtual machine. Only applies to virtual machines using dynamic memory. Allowed values range from 5 to 2000.",
+						},
+
+						"priority": {
+							Type:             schema.TypeInt,
+							Optional:         true,
+							Default:          50,
+							ValidateDiagFunc: IntBetween(0, 100),
+							Description:      "Specifies the priority for allocating the physical computer's memory to this virtual machine relative to others, also known as memory weight. Allowed values range from 0 to 100.",
+						},
+
+						"maximum_amount_per_numa_node_bytes": {
+							Type:             schema.TypeInt,
+							Optional:         true,
+							Default:          0, // Dynamic value
+							ValidateDiagFunc: IsDivisibleBy(MemoryAlignmentBytes),
+							DiffSuppressFunc: api.DiffSuppressVmMemoryMaximumAmountPerNumaNodeBytes,
+							Description:      "Specifies the maximum amount of memory per NUMA node to be configured for the virtual machine. If value is 0 then the host default is used.",
+						},
+
+						"host_numa_spanning": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Whether virtual machines on the Hyper-V host may span physical NUMA nodes, either `On` or `Off`. This is a setting of the host that applies to every virtual machine on it, so it is only read. It is changed on the host with `Set-VMHost -NumaSpanningEnabled` and only takes effect after the Hyper-V Virtual Machine Management service is restarted.",
+						},
+					},
+				},
+				Description: "The memory settings of the virtual machine that are not covered by `memory_startup_bytes`, `memory_minimum_bytes`, `memory_maximum_bytes`, `dynamic_memory` and `static_memory`.",
+			},
+
 			"vm_processor": {
 				Type:        schema.TypeList,
 				Optional:    true,
@@ -606,7 +649,7 @@ func dataSourceHyperVMachineInstance() *schema.Resource {
 						"controller_number": {
 							Type:        schema.TypeInt,
 							Required:    true,
-							Description: "Specifies the number of the controller to which the DVD drive is to be added.",
+							Description: "Specifies the number of the controller to which the DVD drive is to be added. Missing Scsi controllers are added, and empty Scsi controllers after the last referenced one are removed. Scsi controller 0 is always kept.",
 						},
 						"controller_location": {
 							Type:        schema.TypeInt,
@@ -644,7 +687,7 @@ func dataSourceHyperVMachineInstance() *schema.Resource {
 						"controller_number": {
 							Type:        schema.TypeInt,
 							Required:    true,
-							Description: "Specifies the number of the controller to which the hard disk drive is to be added.",
+							Description: "Specifies the number of the controller to which the hard disk drive is to be added. Missing Scsi controllers are added, and empty Scsi controllers after the last referenced one are removed. Scsi controller 0 is always kept.",
 						},
 						"controller_location": {
 							Type:        schema.TypeInt,
@@ -705,6 +748,64 @@ func dataSourceHyperVMachineInstance() *schema.Resource {
 				},
 			},
 
+			"com_ports": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: api.MaxVmComPortNumber,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"number": {
+							Type:             schema.TypeInt,
+							Required:         true,
+							ValidateDiagFunc: IntBetween(api.MinVmComPortNumber, api.MaxVmComPortNumber),
+							Description:      "Specifies the number of the COM port. Valid values to use are `1` for COM1 and `2` for COM2.",
+						},
+						"path": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Specifies the named pipe that the COM port is connected to e.g. `\\\\.\\pipe\\web-com1`.",
+						},
+						"debugger_mode": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          api.OnOffState_name[api.OnOffState_Off],
+							ValidateDiagFunc: StringKeyInMap(api.OnOffState_value, true),
+							Description:      "Specifies whether the COM port is used by a kernel debugger. Valid values to use are `On`, `Off`.",
+						},
+					},
+				},
+				Description: "The COM ports that are connected to a named pipe e.g. for a serial console. COM ports that are not declared are disconnected.",
+			},
+
+			"vm_bios": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"startup_order": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Computed: true,
+							MaxItems: 4,
+							Elem: &schema.Schema{
+								Type:             schema.TypeString,
+								ValidateDiagFunc: StringKeyInMap(api.Gen1BootType_value, true),
+							},
+							Description: "The order of the devices that the generation 1 virtual machine should try to use for boot up. Every device has to be listed once. Valid values to use are `CD`, `IDE`, `LegacyNetworkAdapter`, `Floppy`.",
+						},
+
+						"num_lock_enabled": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Specifies whether NumLock is enabled when the generation 1 virtual machine starts.",
+						},
+					},
+				},
+				Description: "The BIOS settings of a generation 1 virtual machine. Use `vm_firmware` for generation 2 virtual machines.",
+			},
+
 			"vm_firmware": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -886,6 +987,55 @@ func dataSourceHyperVMachineInstance() *schema.Resource {
 							ValidateDiagFunc: StringKeyInMap(api.OnOffState_value, true),
 							Description:      "Specifies the behavior of the virtual machine after a start failure. For a value of On, if the virtual machine fails to start correctly from a device, the virtual machine is paused. Valid values to use are `On`, `Off`.",
 						},
+
+						"tpm_enabled": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Specifies whether the virtual machine has a virtual TPM. A key protector is required to enable it.",
+						},
+
+						"key_protector": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          api.VmKeyProtectorType_name[api.VmKeyProtectorType_None],
+							ValidateDiagFunc: StringKeyInMap(api.VmKeyProtectorType_value, true),
+							Description:      "Specifies the key protector of the virtual machine, which protects the virtual TPM and the encrypted state of the virtual machine. `NewLocalKeyProtector` creates a key protector owned by the `UntrustedGuardian` of the host. `HgsGuardian` creates a key protector owned by the local HGS guardian `hgs_guardian_name`, which is created when it doesn't exist. A key protector can't be removed once it is set. Hosts without the HgsClient module read any key protector as `NewLocalKeyProtector`, as they can't read its owner. Valid values to use are `None`, `NewLocalKeyProtector`, `HgsGuardian`.",
+						},
+
+						"hgs_guardian_name": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "",
+							DiffSuppressFunc: func(k, oldValue, newValue string, d *schema.ResourceData) bool {
+								keyProtectorKey := strings.Replace(k, "hgs_guardian_name", "key_protector", 1)
+								keyProtector := d.Get(keyProtectorKey).(string)
+
+								return !strings.EqualFold(keyProtector, api.VmKeyProtectorType_HgsGuardian.String())
+							},
+							Description: "Specifies the name of the HGS guardian that owns the key protector when `key_protector` is `HgsGuardian`.",
+						},
+
+						"encrypt_state_and_vm_migration_traffic": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Specifies whether the saved state, checkpoints and live migration traffic of the virtual machine are encrypted. A key protector is required to enable it.",
+						},
+
+						"shielded": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Specifies whether the virtual machine is shielded, which blocks console access and requires the virtual TPM to be enabled.",
+						},
+
+						"virtualization_based_security_opt_out": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Specifies whether the virtual machine opts out of virtualization based security.",
+						},
 					},
 				},
 				Description: "",
@@ -920,11 +1070,26 @@ func datasourceHyperVMachineInstanceRead(ctx context.Context, d *schema.Resource
 		vmFirmwares = client.GetNoVmFirmwares(ctx)
 	}
 
+	var vmBioses []api.VmBios
+	if vm.Generation < 2 {
+		vmBioses, err = client.GetVmBioses(ctx, name)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	} else {
+		vmBioses = client.GetNoVmBioses(ctx)
+	}
+
 	vmProcessors, err := client.GetVmProcessors(ctx, name)
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
+	vmMemories, err := client.GetVmMemories(ctx, name)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	integrationServices, err := client.GetVmIntegrationServices(ctx, name)
 	if err != nil {
 		return diag.FromErr(err)
@@ -940,6 +1105,11 @@ func datasourceHyperVMachineInstanceRead(ctx context.Context, d *schema.Resource
 		return diag.FromErr(err)
 	}
 
+	comPorts, err := client.GetVmComPorts(ctx, name)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	vmState, err := client.GetVmStatus(ctx, name)
 	if err != nil {
 		return diag.FromErr(err)
@@ -987,6 +1157,18 @@ func datasourceHyperVMachineInstanceRead(ctx context.Context, d *schema.Resource
 		log.Printf("[INFO][hyperv][read] skip flattenedVmFirmwares as vm generation is %v", vm.Generation)
 	}
 
+	flattenedVmBioses := api.FlattenVmBioses(&vmBioses)
+	if err := d.Set("vm_bios", flattenedVmBioses); err != nil {
+		return diag.Errorf("[DEBUG] Error setting vm_bios error: %v", err)
+	}
+	if vm.Generation < 2 {
+		log.Printf("[INFO][hyperv][read] vmBioses: %v", vmBioses)
+		log.Printf("[INFO][hyperv][read] flattenedVmBioses: %v", flattenedVmBioses)
+	} else {
+		log.Printf("[INFO][hyperv][read] skip vmBioses as vm generation is %v", vm.Generation)
+		log.Printf("[INFO][hyperv][read] skip flattenedVmBioses as vm generation is %v", vm.Generation)
+	}
+
 	flattenedVmProcessors := api.FlattenVmProcessors(&vmProcessors)
 	if err := d.Set("vm_processor", flattenedVmProcessors); err != nil {
 		return diag.Errorf("[DEBUG] Error setting vm_processor error: %v", err)
@@ -994,6 +1176,13 @@ func datasourceHyperVMachineInstanceRead(ctx context.Context, d *schema.Resource
 	log.Printf("[INFO][hyperv][read] vmProcessors: %v", vmProcessors)
 	log.Printf("[INFO][hyperv][read] flattenedVmProcessors: %v", flattenedVmProcessors)
 
+	flattenedVmMemories := api.FlattenVmMemories(&vmMemories)
+	if err := d.Set("vm_memory", flattenedVmMemories); err != nil {
+		return diag.Errorf("[DEBUG] Error setting vm_memory error: %v", err)
+	}
+	log.Printf("[INFO][hyperv][read] vmMemories: %v", vmMemories)
+	log.Printf("[INFO][hyperv][read] flattenedVmMemories: %v", flattenedVmMemories)
+
 	flattenedIntegrationServices := api.FlattenIntegrationServices(&integrationServices)
 	if err := d.Set("integration_services", flattenedIntegrationServices); err != nil {
 		return diag.Errorf("[DEBUG] Error setting integration_services error: %v", err)
@@ -1013,6 +1202,13 @@ func datasourceHyperVMachineInstanceRead(ctx context.Context, d *schema.Resource
 	log.Printf("[INFO][hyperv][read] hardDiskDrives: %v", hardDiskDrives)
 	log.Printf("[INFO][hyperv][read] flattenedHardDiskDrives: %v", flattenedHardDiskDrives)
 
+	flattenedComPorts := api.FlattenVmComPorts(&comPorts)
+	if err := d.Set("com_ports", flattenedComPorts); err != nil {
+		return diag.Errorf("[DEBUG] Error setting com_ports error: %v", err)
+	}
+	log.Printf("[INFO][hyperv][read] comPorts: %v", comPorts)
+	log.Printf("[INFO][hyperv][read] flattenedComPorts: %v", flattenedComPorts)
+
 	flattenedNetworkAdapters := api.FlattenNetworkAdapters(&networkAdapters)
 	if err := d.Set("network_adaptors", flattenedNetworkAdapters); err != nil {
 		return diag.Errorf("[DEBUG] Error setting network_adaptors error: %v", err)
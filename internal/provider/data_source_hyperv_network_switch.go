@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"log"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
@@ -137,18 +138,16 @@ func datasourceHyperVNetworkSwitchRead(ctx context.Context, d *schema.ResourceDa
 	}
 
 	s, err := c.GetVMSwitch(ctx, switchName)
-
+	if errors.Is(err, api.ErrNotFound) {
+		log.Printf("[INFO][hyperv][read] unable to read hyperv switch as it does not exist: %#v", switchName)
+		return nil
+	}
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
 	log.Printf("[INFO][hyperv][read] retrieved network switch: %+v", s)
 
-	if s.Name != switchName {
-		log.Printf("[INFO][hyperv][read] unable to read hyperv switch as it does not exist: %#v", switchName)
-		return nil
-	}
-
 	switch s.SwitchType {
 	case api.VMSwitchType_Private:
 		if s.AllowManagementOS {
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"log"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
@@ -140,7 +141,7 @@ func datasourceHyperVVhdRead(ctx context.Context, d *schema.ResourceData, meta i
 	}
 
 	vhd, err := c.GetVhd(ctx, path)
-	if err != nil {
+	if err != nil && !errors.Is(err, api.ErrNotFound) {
 		return diag.FromErr(err)
 	}
 
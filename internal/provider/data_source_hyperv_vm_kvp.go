@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"context"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+func dataSourceHyperVVmKvp() *schema.Resource {
+	return &schema.Resource{
+		Description: "Get the key value pairs exchanged with the guest of a virtual machine by the Key-Value Pair Exchange integration service. The guest only publishes its items while the virtual machine is running and the integration service is enabled.",
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(ReadVmKvpTimeout),
+		},
+		ReadContext: datasourceHyperVVmKvpRead,
+		Schema: map[string]*schema.Schema{
+			"vm_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Specifies the name of the virtual machine.",
+			},
+
+			"fully_qualified_domain_name": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The fully qualified domain name of the guest, from the `FullyQualifiedDomainName` intrinsic item.",
+			},
+
+			"os_name": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The name of the guest operating system, from the `OSName` intrinsic item.",
+			},
+
+			"os_version": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The version of the guest operating system, from the `OSVersion` intrinsic item.",
+			},
+
+			"network_address_ipv4": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Description: "The IPv4 addresses of the guest, from the `NetworkAddressIPv4` intrinsic item.",
+			},
+
+			"network_address_ipv6": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Description: "The IPv6 addresses of the guest, from the `NetworkAddressIPv6` intrinsic item.",
+			},
+
+			"guest_intrinsic_items": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Description: "All the key value pairs published by the integration services of the guest.",
+			},
+
+			"guest_items": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Description: "The key value pairs published by software running in the guest.",
+			},
+
+			"items": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Description: "The key value pairs pushed from the host to the guest, for example by `hyperv_vm_kvp`.",
+			},
+		},
+	}
+}
+
+func datasourceHyperVVmKvpRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][read] reading hyperv vm kvp: %#v", d)
+	c := meta.(api.Client)
+
+	vmName := (d.Get("vm_name")).(string)
+
+	vmGuestKvpItems, err := c.GetVmGuestKvpItems(ctx, vmName)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	vmKvpItems, err := c.GetVmKvpItems(ctx, vmName)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][read] retrieved vm guest kvp items: %+v", vmGuestKvpItems)
+
+	items := make(map[string]interface{})
+	for _, vmKvpItem := range vmKvpItems {
+		items[vmKvpItem.Name] = vmKvpItem.Data
+	}
+
+	if err := d.Set("fully_qualified_domain_name", vmGuestKvpItems.IntrinsicItems["FullyQualifiedDomainName"]); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("os_name", vmGuestKvpItems.IntrinsicItems["OSName"]); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("os_version", vmGuestKvpItems.IntrinsicItems["OSVersion"]); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("network_address_ipv4", api.SplitVmKvpNetworkAddresses(vmGuestKvpItems.IntrinsicItems["NetworkAddressIPv4"])); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("network_address_ipv6", api.SplitVmKvpNetworkAddresses(vmGuestKvpItems.IntrinsicItems["NetworkAddressIPv6"])); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("guest_intrinsic_items", vmGuestKvpItems.IntrinsicItems); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("guest_items", vmGuestKvpItems.Items); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("items", items); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(vmName)
+
+	log.Printf("[INFO][hyperv][read] read hyperv vm kvp: %#v", d)
+
+	return nil
+}
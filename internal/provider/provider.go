@@ -3,15 +3,27 @@ package provider
 import (
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
+	"strings"
+	"time"
 
 	"context"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/taliesins/terraform-provider-hyperv/api"
+	winrm_helper "github.com/taliesins/terraform-provider-hyperv/api/winrm-helper"
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
 )
 
 const (
+	TransportWinRm = "winrm"
+
+	TransportSsh = "ssh"
+
+	DefaultTransport = TransportWinRm
+
 	DefaultHost = "127.0.0.1"
 
 	DefaultUseHTTPS = true
@@ -46,8 +58,59 @@ const (
 	// for remote execution if not provided otherwise.
 	DefaultScriptPath = "C:/Temp/terraform_%RAND%.cmd"
 
+	// DefaultPersistentSession is used if there is no persistent session setting given
+	DefaultPersistentSession = false
+
 	// DefaultTimeout is used if there is no timeout given
 	DefaultTimeoutString = "30s"
+
+	// DefaultConnectionPoolMaxTotal is used if there is no connection pool max total given
+	DefaultConnectionPoolMaxTotal = 5
+
+	// DefaultConnectionPoolMaxIdle is used if there is no connection pool max idle given
+	DefaultConnectionPoolMaxIdle = 2
+
+	// DefaultConnectionPoolMinIdle is used if there is no connection pool min idle given
+	DefaultConnectionPoolMinIdle = 0
+
+	// DefaultConnectionPoolIdleTimeout is used if there is no connection pool idle timeout given
+	DefaultConnectionPoolIdleTimeoutString = "30m"
+
+	// DefaultConnectionPoolBorrowTimeout is used if there is no connection pool borrow timeout given
+	DefaultConnectionPoolBorrowTimeoutString = "0s"
+
+	// DefaultConnectionPoolEvictionInterval is used if there is no connection pool eviction interval given
+	DefaultConnectionPoolEvictionIntervalString = "10s"
+
+	// DefaultMaxHeavyOperations is used if there is no max heavy operations given
+	DefaultMaxHeavyOperations = 2
+
+	// DefaultUploadPartSize is used if there is no upload part size given
+	DefaultUploadPartSize = powershell.DefaultUploadPartSize
+
+	// DefaultUploadParallelism is used if there is no upload parallelism given
+	DefaultUploadParallelism = powershell.DefaultUploadParallelism
+
+	// DefaultRetryMaxAttempts is used if there is no retry max attempts given
+	DefaultRetryMaxAttempts = 3
+
+	// DefaultRetryBaseDelay is used if there is no retry base delay given
+	DefaultRetryBaseDelayString = "2s"
+
+	// DefaultSweepTemporaryItems is used if there is no sweep temporary items setting given
+	DefaultSweepTemporaryItems = false
+
+	// DefaultSweepTemporaryItemsOlderThan is used if there is no sweep temporary items older than given
+	DefaultSweepTemporaryItemsOlderThanString = "24h"
+
+	// DefaultSshPort is used if there is no ssh port given
+	DefaultSshPort = 22
+
+	DefaultSshPrivateKeyFile = ""
+
+	DefaultSshUseAgent = true
+
+	DefaultSshKnownHostsFile = "~/.ssh/known_hosts"
 )
 
 func init() {
@@ -71,6 +134,14 @@ func New(version string, commit string) func() *schema.Provider {
 	return func() *schema.Provider {
 		provider := &schema.Provider{
 			Schema: map[string]*schema.Schema{
+				"transport": {
+					Type:             schema.TypeString,
+					Optional:         true,
+					DefaultFunc:      schema.EnvDefaultFunc("HYPERV_TRANSPORT", DefaultTransport),
+					ValidateDiagFunc: StringInSlice([]string{TransportWinRm, TransportSsh}, true),
+					Description:      "The transport used for HyperV api calls. Valid values are `winrm` and `ssh`. When set to `ssh` the host must run OpenSSH with PowerShell available. It can also be sourced from the `HYPERV_TRANSPORT` environment variable otherwise defaults to `winrm`.",
+				},
+
 				"user": {
 					Type:        schema.TypeString,
 					Optional:    true,
@@ -183,6 +254,142 @@ func New(version string, commit string) func() *schema.Provider {
 					Description: "The path used to copy scripts meant for remote execution for HyperV api calls. Can also be sourced from the `HYPERV_SCRIPT_PATH` environment variable otherwise defaults to `C:/Temp/terraform_%RAND%.cmd`.",
 				},
 
+				"persistent_session": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_PERSISTENT_SESSION", DefaultPersistentSession),
+					Description: "Keep a long-lived PowerShell session open for each pooled WinRM connection instead of uploading a script for every HyperV api call. Scripts run in the session use the WinRM logon rather than the elevated scheduled task. When a session dies the script is uploaded and run as usual. Only applies when `transport` is `winrm`. Can also be sourced from the `HYPERV_PERSISTENT_SESSION` environment variable otherwise defaults to `false`.",
+				},
+
+				"connection_pool_max_total": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_CONNECTION_POOL_MAX_TOTAL", DefaultConnectionPoolMaxTotal),
+					Description: "The maximum number of connections opened to the host for HyperV api calls. Keep it below the `MaxShellsPerUser` WinRM setting of the host. Can also be sourced from the `HYPERV_CONNECTION_POOL_MAX_TOTAL` environment variable otherwise defaults to `5`.",
+				},
+
+				"connection_pool_max_idle": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_CONNECTION_POOL_MAX_IDLE", DefaultConnectionPoolMaxIdle),
+					Description: "The maximum number of idle connections kept open to the host. Can also be sourced from the `HYPERV_CONNECTION_POOL_MAX_IDLE` environment variable otherwise defaults to `2`.",
+				},
+
+				"connection_pool_min_idle": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_CONNECTION_POOL_MIN_IDLE", DefaultConnectionPoolMinIdle),
+					Description: "The minimum number of idle connections kept open to the host when connections are evicted. Can also be sourced from the `HYPERV_CONNECTION_POOL_MIN_IDLE` environment variable otherwise defaults to `0`.",
+				},
+
+				"connection_pool_idle_timeout": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_CONNECTION_POOL_IDLE_TIMEOUT", DefaultConnectionPoolIdleTimeoutString),
+					Description: "How long a connection can be idle before it is evicted. Should be provided as a string like 30s or 5m. Can also be sourced from the `HYPERV_CONNECTION_POOL_IDLE_TIMEOUT` environment variable otherwise defaults to `30m`.",
+				},
+
+				"connection_pool_borrow_timeout": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_CONNECTION_POOL_BORROW_TIMEOUT", DefaultConnectionPoolBorrowTimeoutString),
+					Description: "How long a HyperV api call waits for a connection when all of them are in use. `0s` waits until a connection is available. Should be provided as a string like 30s or 5m. Can also be sourced from the `HYPERV_CONNECTION_POOL_BORROW_TIMEOUT` environment variable otherwise defaults to `0s`.",
+				},
+
+				"connection_pool_eviction_interval": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_CONNECTION_POOL_EVICTION_INTERVAL", DefaultConnectionPoolEvictionIntervalString),
+					Description: "How often idle connections are checked for eviction. `0s` disables eviction. Should be provided as a string like 30s or 5m. Can also be sourced from the `HYPERV_CONNECTION_POOL_EVICTION_INTERVAL` environment variable otherwise defaults to `10s`.",
+				},
+
+				"max_heavy_operations": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_MAX_HEAVY_OPERATIONS", DefaultMaxHeavyOperations),
+					Description: "The maximum number of heavy operations, like downloading or converting a vhd and building an iso, that run against the host at once. Other HyperV api calls are not limited by it. `0` disables the limit. Can also be sourced from the `HYPERV_MAX_HEAVY_OPERATIONS` environment variable otherwise defaults to `2`.",
+				},
+
+				"upload_part_size": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_UPLOAD_PART_SIZE", DefaultUploadPartSize),
+					Description: "The size in bytes of the parts a file is split into when it is uploaded over WinRM. An interrupted upload resumes from the last part that made it to the host. Only applies when `transport` is `winrm`. Can also be sourced from the `HYPERV_UPLOAD_PART_SIZE` environment variable otherwise defaults to `4194304`.",
+				},
+
+				"upload_parallelism": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_UPLOAD_PARALLELISM", DefaultUploadParallelism),
+					Description: "The number of parts of a file uploaded at once over WinRM, each over its own pooled connection. Only applies when `transport` is `winrm`. Can also be sourced from the `HYPERV_UPLOAD_PARALLELISM` environment variable otherwise defaults to `4`.",
+				},
+
+				"retry_max_attempts": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_RETRY_MAX_ATTEMPTS", DefaultRetryMaxAttempts),
+					Description: "The number of times a HyperV api call is attempted when it fails with a transient error. Reads are retried for any of the `retry_error_classes`. Changes are only retried when the host reported it was busy or the call never reached the host. Set to `1` to disable retries. Can also be sourced from the `HYPERV_RETRY_MAX_ATTEMPTS` environment variable otherwise defaults to `3`.",
+				},
+
+				"retry_base_delay": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_RETRY_BASE_DELAY", DefaultRetryBaseDelayString),
+					Description: "The delay before the first retry of a HyperV api call, it doubles for every retry after that. Should be provided as a string like 2s or 1m. Can also be sourced from the `HYPERV_RETRY_BASE_DELAY` environment variable otherwise defaults to `2s`.",
+				},
+
+				"retry_error_classes": {
+					Type:     schema.TypeList,
+					Optional: true,
+					Elem: &schema.Schema{
+						Type:             schema.TypeString,
+						ValidateDiagFunc: StringInSlice([]string{string(winrm_helper.ErrorClassConnection), string(winrm_helper.ErrorClassBusy)}, false),
+					},
+					Description: "The classes of transient error that HyperV api calls are retried for. Valid values are `connection` for host reboots and WinRM listener restarts, and `busy` for Hyper-V operations that are in progress or objects that are in use. Defaults to `[\"connection\", \"busy\"]`.",
+				},
+
+				"sweep_temporary_items": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_SWEEP_TEMPORARY_ITEMS", DefaultSweepTemporaryItems),
+					Description: "Remove the temporary scripts, upload staging directories and elevated scheduled tasks that earlier runs of the provider left behind on the host when the provider is configured. Only items named with the `terraform-hyperv-` prefix and older than `sweep_temporary_items_older_than` are removed. Can also be sourced from the `HYPERV_SWEEP_TEMPORARY_ITEMS` environment variable otherwise defaults to `false`.",
+				},
+
+				"sweep_temporary_items_older_than": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_SWEEP_TEMPORARY_ITEMS_OLDER_THAN", DefaultSweepTemporaryItemsOlderThanString),
+					Description: "How old a temporary item left behind on the host has to be before `sweep_temporary_items` removes it. It should be longer than the longest running apply against the host. Should be provided as a string like 12h or 30m. Can also be sourced from the `HYPERV_SWEEP_TEMPORARY_ITEMS_OLDER_THAN` environment variable otherwise defaults to `24h`.",
+				},
+
+				"ssh_port": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_SSH_PORT", DefaultSshPort),
+					Description: "The port to run HyperV api calls against when `transport` is `ssh`. It can also be sourced from the `HYPERV_SSH_PORT` environment variable otherwise defaults to `22`.",
+				},
+
+				"ssh_private_key_path": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_SSH_PRIVATE_KEY_PATH", DefaultSshPrivateKeyFile),
+					Description: "The path to the private key to use for authentication when `transport` is `ssh`. Can also be sourced from the `HYPERV_SSH_PRIVATE_KEY_PATH` environment variable otherwise defaults to empty string.",
+				},
+
+				"ssh_use_agent": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_SSH_USE_AGENT", DefaultSshUseAgent),
+					Description: "Use the ssh agent listening on `SSH_AUTH_SOCK` for authentication when `transport` is `ssh`. Can also be set via setting the `HYPERV_SSH_USE_AGENT` environment variable otherwise defaults to `true`.",
+				},
+
+				"ssh_known_hosts_path": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("HYPERV_SSH_KNOWN_HOSTS_PATH", DefaultSshKnownHostsFile),
+					Description: "The path to the known hosts file used to verify the host key when `transport` is `ssh`. Verification is skipped when `insecure` is `true`. Can also be sourced from the `HYPERV_SSH_KNOWN_HOSTS_PATH` environment variable otherwise defaults to `~/.ssh/known_hosts`.",
+				},
+
 				"timeout": {
 					Type:        schema.TypeString,
 					Optional:    true,
@@ -196,11 +403,17 @@ func New(version string, commit string) func() *schema.Provider {
 				"hyperv_machine_instance": resourceHyperVMachineInstance(),
 				"hyperv_vhd":              resourceHyperVVhd(),
 				"hyperv_iso_image":        resourceHyperVIsoImage(),
+				"hyperv_vm_checkpoint":    resourceHyperVVmCheckpoint(),
+				"hyperv_vm_export":        resourceHyperVVmExport(),
+				"hyperv_vm_kvp":           resourceHyperVVmKvp(),
+				"hyperv_vm_guest_file":    resourceHyperVVmGuestFile(),
+				"hyperv_vm_guest_script":  resourceHyperVVmGuestScript(),
 			},
 			DataSourcesMap: map[string]*schema.Resource{
 				"hyperv_network_switch":   dataSourceHyperVNetworkSwitch(),
 				"hyperv_machine_instance": dataSourceHyperVMachineInstance(),
 				"hyperv_vhd":              dataSourceHyperVVhd(),
+				"hyperv_vm_kvp":           dataSourceHyperVVmKvp(),
 			},
 		}
 
@@ -253,6 +466,19 @@ func configure(version string, commit string, provider *schema.Provider) func(co
 			}
 		}
 
+		var sshPrivateKey []byte = nil
+		sshPrivateKeyPath := resourceData.Get("ssh_private_key_path").(string)
+		if sshPrivateKeyPath != "" {
+			if _, err := os.Stat(sshPrivateKeyPath); os.IsNotExist(err) {
+				return nil, diag.FromErr(fmt.Errorf("sshPrivateKeyPath does not exist - %s", sshPrivateKeyPath))
+			}
+
+			sshPrivateKey, err = ioutil.ReadFile(sshPrivateKeyPath)
+			if err != nil {
+				return nil, diag.FromErr(err)
+			}
+		}
+
 		terraformVersion := provider.TerraformVersion
 		if terraformVersion == "" {
 			// Terraform 0.12 introduced this field to the protocol
@@ -260,27 +486,55 @@ func configure(version string, commit string, provider *schema.Provider) func(co
 			terraformVersion = "0.11+compatible"
 		}
 
+		retryErrorClasses := []string{string(winrm_helper.ErrorClassConnection), string(winrm_helper.ErrorClassBusy)}
+		if v, ok := resourceData.GetOk("retry_error_classes"); ok {
+			retryErrorClasses = []string{}
+			for _, retryErrorClass := range v.([]interface{}) {
+				retryErrorClasses = append(retryErrorClasses, retryErrorClass.(string))
+			}
+		}
+
 		config := Config{
-			Version:          version,
-			Commit:           commit,
-			TerraformVersion: terraformVersion,
-			User:             resourceData.Get("user").(string),
-			Password:         resourceData.Get("password").(string),
-			Host:             resourceData.Get("host").(string),
-			Port:             resourceData.Get("port").(int),
-			HTTPS:            resourceData.Get("https").(bool),
-			CACert:           cacert,
-			Cert:             cert,
-			Key:              key,
-			Insecure:         resourceData.Get("insecure").(bool),
-			NTLM:             resourceData.Get("use_ntlm").(bool),
-			KrbRealm:         resourceData.Get("kerberos_realm").(string),
-			KrbSpn:           resourceData.Get("kerberos_service_principal_name").(string),
-			KrbConfig:        resourceData.Get("kerberos_config").(string),
-			KrbCCache:        resourceData.Get("kerberos_credential_cache").(string),
-			TLSServerName:    resourceData.Get("tls_server_name").(string),
-			ScriptPath:       resourceData.Get("script_path").(string),
-			Timeout:          resourceData.Get("timeout").(string),
+			Version:                        version,
+			Commit:                         commit,
+			TerraformVersion:               terraformVersion,
+			Transport:                      strings.ToLower(resourceData.Get("transport").(string)),
+			User:                           resourceData.Get("user").(string),
+			Password:                       resourceData.Get("password").(string),
+			Host:                           resourceData.Get("host").(string),
+			Port:                           resourceData.Get("port").(int),
+			HTTPS:                          resourceData.Get("https").(bool),
+			CACert:                         cacert,
+			Cert:                           cert,
+			Key:                            key,
+			Insecure:                       resourceData.Get("insecure").(bool),
+			NTLM:                           resourceData.Get("use_ntlm").(bool),
+			KrbRealm:                       resourceData.Get("kerberos_realm").(string),
+			KrbSpn:                         resourceData.Get("kerberos_service_principal_name").(string),
+			KrbConfig:                      resourceData.Get("kerberos_config").(string),
+			KrbCCache:                      resourceData.Get("kerberos_credential_cache").(string),
+			TLSServerName:                  resourceData.Get("tls_server_name").(string),
+			ScriptPath:                     resourceData.Get("script_path").(string),
+			PersistentSession:              resourceData.Get("persistent_session").(bool),
+			Timeout:                        resourceData.Get("timeout").(string),
+			ConnectionPoolMaxTotal:         resourceData.Get("connection_pool_max_total").(int),
+			ConnectionPoolMaxIdle:          resourceData.Get("connection_pool_max_idle").(int),
+			ConnectionPoolMinIdle:          resourceData.Get("connection_pool_min_idle").(int),
+			ConnectionPoolIdleTimeout:      resourceData.Get("connection_pool_idle_timeout").(string),
+			ConnectionPoolBorrowTimeout:    resourceData.Get("connection_pool_borrow_timeout").(string),
+			ConnectionPoolEvictionInterval: resourceData.Get("connection_pool_eviction_interval").(string),
+			MaxHeavyOperations:             resourceData.Get("max_heavy_operations").(int),
+			UploadPartSize:                 resourceData.Get("upload_part_size").(int),
+			UploadParallelism:              resourceData.Get("upload_parallelism").(int),
+			RetryMaxAttempts:               resourceData.Get("retry_max_attempts").(int),
+			RetryBaseDelay:                 resourceData.Get("retry_base_delay").(string),
+			RetryErrorClasses:              retryErrorClasses,
+			SweepTemporaryItems:            resourceData.Get("sweep_temporary_items").(bool),
+			SweepTemporaryItemsOlderThan:   resourceData.Get("sweep_temporary_items_older_than").(string),
+			SshPort:                        resourceData.Get("ssh_port").(int),
+			SshPrivateKey:                  sshPrivateKey,
+			SshUseAgent:                    resourceData.Get("ssh_use_agent").(bool),
+			SshKnownHosts:                  resourceData.Get("ssh_known_hosts_path").(string),
 		}
 
 		client, err := config.Client()
@@ -288,6 +542,34 @@ func configure(version string, commit string, provider *schema.Provider) func(co
 			return nil, diag.FromErr(err)
 		}
 
+		if config.SweepTemporaryItems {
+			diags = append(diags, sweepTemporaryItems(context, client, config.SweepTemporaryItemsOlderThan)...)
+		}
+
 		return client, diags
 	}
 }
+
+// sweepTemporaryItems removes the temporary items earlier runs left behind on the host. A failed sweep doesn't stop
+// the provider from being used, so it is reported as a warning.
+func sweepTemporaryItems(ctx context.Context, client api.Client, olderThan string) diag.Diagnostics {
+	olderThanDuration, err := time.ParseDuration(olderThan)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("couldn't convert \"%s\" to a duration", olderThan))
+	}
+
+	removedItems, err := client.RemoveTemporaryItems(ctx, olderThanDuration, powershell.SessionId)
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Warning,
+				Summary:  "Unable to sweep temporary items from the host",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	log.Printf("[INFO][hyperv] Swept %d temporary items older than %s from the host: %#v", len(removedItems), olderThan, removedItems)
+
+	return nil
+}
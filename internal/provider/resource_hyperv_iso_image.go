@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	log "log"
 	"path/filepath"
@@ -11,6 +12,7 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/taliesins/terraform-provider-hyperv/api"
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
 )
 
 const (
@@ -33,6 +35,7 @@ func resourceHyperVIsoImage() *schema.Resource {
 		ReadContext:   resourceHyperVIsoImageRead,
 		UpdateContext: resourceHyperVIsoImageUpdate,
 		DeleteContext: resourceHyperVIsoImageDelete,
+		CustomizeDiff: resourceHyperVIsoImageCustomizeDiff,
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
 		},
@@ -136,9 +139,57 @@ func resourceHyperVIsoImage() *schema.Resource {
 			"volume_name": {
 				Type:             schema.TypeString,
 				Optional:         true,
-				Default:          "UNTITLED",
+				Computed:         true,
 				ValidateDiagFunc: AllowedIsoVolumeName(),
-				Description:      "Volume name for iso. Must be 15 characters or less. Characters must be `A` through `Z`, `0` through `9` or `_` (underscore)",
+				Description:      "Volume name for iso. Must be 15 characters or less. Characters must be `A` through `Z`, `0` through `9` or `_` (underscore). This defaults to `CIDATA` when `user_data`, `meta_data` or `network_config` are set, so that cloud-init finds the iso, and to `UNTITLED` otherwise. It can't be set to anything but `CIDATA` when they are set.",
+			},
+			"user_data": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Default:     "",
+				Description: "Content of the cloud-init NoCloud `user-data` file written into the root of the iso. It is masked in logs and error messages, as it often holds passwords or keys.",
+				ConflictsWith: []string{
+					"source_iso_file_path",
+					"source_iso_file_path_hash",
+				},
+			},
+			"meta_data": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "Content of the cloud-init NoCloud `meta-data` file written into the root of the iso. An empty `meta-data` file is written when only `user_data` or `network_config` are set, as cloud-init requires it.",
+				ConflictsWith: []string{
+					"source_iso_file_path",
+					"source_iso_file_path_hash",
+				},
+			},
+			"network_config": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "Content of the cloud-init NoCloud `network-config` file written into the root of the iso.",
+				ConflictsWith: []string{
+					"source_iso_file_path",
+					"source_iso_file_path_hash",
+				},
+			},
+			"files": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Description: "Files to write into the iso, keyed by their path relative to the root of the iso. For example `autounattend.xml` for an unattended Windows setup. They are added to the content of `source_zip_file_path` when it is set.",
+				ConflictsWith: []string{
+					"source_iso_file_path",
+					"source_iso_file_path_hash",
+				},
+			},
+			"source_files_hash": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Hash of the files generated from `user_data`, `meta_data`, `network_config` and `files`. A change of their content rebuilds the iso.",
 			},
 			"resolve_destination_iso_file_path": {
 				Type:        schema.TypeString,
@@ -159,6 +210,79 @@ func resourceHyperVIsoImage() *schema.Resource {
 	}
 }
 
+// isoImageFiles returns the files generated from user_data, meta_data, network_config and files
+func isoImageFiles(userData string, metaData string, networkConfig string, files map[string]interface{}) (map[string]string, error) {
+	expandedFiles := make(map[string]string)
+	for path, content := range files {
+		expandedFiles[path] = content.(string)
+	}
+
+	return api.IsoImageFiles(userData, metaData, networkConfig, expandedFiles)
+}
+
+// resourceHyperVIsoImageCustomizeDiff hashes the generated files so that a change of their content rebuilds the iso
+func resourceHyperVIsoImageCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	volumeNameConfigured := isoImageVolumeNameConfigured(d)
+
+	for _, key := range []string{"user_data", "meta_data", "network_config", "files"} {
+		if !d.NewValueKnown(key) {
+			if !volumeNameConfigured && key != "files" {
+				if err := d.SetNewComputed("volume_name"); err != nil {
+					return err
+				}
+			}
+
+			return d.SetNewComputed("source_files_hash")
+		}
+	}
+
+	userData := (d.Get("user_data")).(string)
+	metaData := (d.Get("meta_data")).(string)
+	networkConfig := (d.Get("network_config")).(string)
+	volumeName := (d.Get("volume_name")).(string)
+
+	files, err := isoImageFiles(userData, metaData, networkConfig, (d.Get("files")).(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+
+	// cloud-init only looks for a NoCloud seed on a volume named CIDATA, so that is the default volume name of a seed
+	cloudInit := userData != "" || metaData != "" || networkConfig != ""
+	if volumeNameConfigured {
+		if cloudInit && d.NewValueKnown("volume_name") && volumeName != api.IsoImageCloudInitVolumeName {
+			return fmt.Errorf("volume_name must be %s when user_data, meta_data or network_config are set, cloud-init only looks for a NoCloud seed on a volume named %s - was %s", api.IsoImageCloudInitVolumeName, api.IsoImageCloudInitVolumeName, volumeName)
+		}
+	} else {
+		defaultVolumeName := api.IsoImageDefaultVolumeName
+		if cloudInit {
+			defaultVolumeName = api.IsoImageCloudInitVolumeName
+		}
+
+		if volumeName != defaultVolumeName {
+			if err := d.SetNew("volume_name", defaultVolumeName); err != nil {
+				return err
+			}
+		}
+	}
+
+	sourceFilesHash := api.IsoImageFilesHash(files)
+	if sourceFilesHash != (d.Get("source_files_hash")).(string) {
+		return d.SetNew("source_files_hash", sourceFilesHash)
+	}
+
+	return nil
+}
+
+// isoImageVolumeNameConfigured returns whether volume_name is set in the configuration, rather than defaulted
+func isoImageVolumeNameConfigured(d *schema.ResourceDiff) bool {
+	config := d.GetRawConfig()
+	if config.IsNull() || !config.IsKnown() {
+		return false
+	}
+
+	return !config.GetAttr("volume_name").IsNull()
+}
+
 func winPath(path string) string {
 	if len(path) == 0 {
 		return path
@@ -224,6 +348,14 @@ func resourceHyperVIsoImageCreate(ctx context.Context, d *schema.ResourceData, m
 	fileSystem := api.ToIsoFileSystemType((d.Get("iso_file_system_type")).(string))
 	volumeName := (d.Get("volume_name")).(string)
 
+	powershell.RegisterSecret((d.Get("user_data")).(string))
+
+	files, err := isoImageFiles((d.Get("user_data")).(string), (d.Get("meta_data")).(string), (d.Get("network_config")).(string), (d.Get("files")).(map[string]interface{}))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	sourceFilesHash := api.IsoImageFilesHash(files)
+
 	if destinationIsoFilePath == "" {
 		return diag.Errorf("[ERROR][iso-image][create] path argument is required")
 	}
@@ -243,7 +375,7 @@ func resourceHyperVIsoImageCreate(ctx context.Context, d *schema.ResourceData, m
 		return diag.FromErr(err)
 	}
 
-	err = c.CreateOrUpdateIsoImage(ctx, sourceIsoFilePath, sourceIsoFilePathHash, sourceZipFilePath, sourceZipFilePathHash, sourceBootFilePath, sourceBootFilePathHash, destinationIsoFilePath, destinationZipFilePath, destinationBootFilePath, media, fileSystem, volumeName, resolveDestinationIsoFilePath, resolveDestinationZipFilePath, resolveDestinationBootFilePath)
+	err = c.CreateOrUpdateIsoImage(ctx, sourceIsoFilePath, sourceIsoFilePathHash, sourceZipFilePath, sourceZipFilePathHash, sourceBootFilePath, sourceBootFilePathHash, destinationIsoFilePath, destinationZipFilePath, destinationBootFilePath, media, fileSystem, volumeName, resolveDestinationIsoFilePath, resolveDestinationZipFilePath, resolveDestinationBootFilePath, sourceFilesHash, files)
 
 	if err != nil {
 		return diag.FromErr(err)
@@ -262,6 +394,11 @@ func resourceHyperVIsoImageRead(ctx context.Context, d *schema.ResourceData, met
 	destinationIsoFilePath := d.Id()
 
 	isoImage, err := c.GetIsoImage(ctx, destinationIsoFilePath)
+	if !d.IsNewResource() && errors.Is(err, api.ErrNotFound) {
+		log.Printf("[INFO][iso-image][read] unable to read remote iso as it does not exist: %#v", destinationIsoFilePath)
+		d.SetId("")
+		return nil
+	}
 	if err != nil {
 		return diag.FromErr(err)
 	}
@@ -286,6 +423,9 @@ func resourceHyperVIsoImageRead(ctx context.Context, d *schema.ResourceData, met
 	if err := d.Set("source_boot_file_path_hash", isoImage.SourceBootFilePathHash); err != nil {
 		return diag.FromErr(err)
 	}
+	if err := d.Set("source_files_hash", isoImage.SourceFilesHash); err != nil {
+		return diag.FromErr(err)
+	}
 	if err := d.Set("destination_iso_file_path", destinationIsoFilePath); err != nil {
 		return diag.FromErr(err)
 	}
@@ -456,6 +596,14 @@ func resourceHyperVIsoImageUpdate(ctx context.Context, d *schema.ResourceData, m
 	fileSystem := api.ToIsoFileSystemType((d.Get("iso_file_system_type")).(string))
 	volumeName := (d.Get("volume_name")).(string)
 
+	powershell.RegisterSecret((d.Get("user_data")).(string))
+
+	files, err := isoImageFiles((d.Get("user_data")).(string), (d.Get("meta_data")).(string), (d.Get("network_config")).(string), (d.Get("files")).(map[string]interface{}))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	sourceFilesHash := api.IsoImageFilesHash(files)
+
 	resolveDestinationIsoFilePath, resolveDestinationIsoFilePathChanged, err := ensureFileStateUpdate(ctx, d, c, "iso")
 	if err != nil {
 		return diag.FromErr(err)
@@ -472,7 +620,7 @@ func resourceHyperVIsoImageUpdate(ctx context.Context, d *schema.ResourceData, m
 	}
 
 	recreateDestinationIsoFilePath := false
-	if !resolveDestinationIsoFilePathChanged && (resolveDestinationZipFilePathChanged || resolveDestinationBootFilePathChanged) || ((sourceZipFilePath != "" || sourceBootFilePath != "") && (d.HasChange("iso_media_type") || d.HasChange("iso_file_system_type") || d.HasChange("volume_name"))) {
+	if !resolveDestinationIsoFilePathChanged && (resolveDestinationZipFilePathChanged || resolveDestinationBootFilePathChanged || d.HasChange("source_files_hash")) || ((sourceZipFilePath != "" || sourceBootFilePath != "" || sourceFilesHash != "") && (d.HasChange("iso_media_type") || d.HasChange("iso_file_system_type") || d.HasChange("volume_name"))) {
 		// must delete the iso file as we need to recreate it as the way it is created has changed
 		err = c.RemoteFileDelete(ctx, resolveDestinationIsoFilePath)
 		if err != nil {
@@ -481,8 +629,8 @@ func resourceHyperVIsoImageUpdate(ctx context.Context, d *schema.ResourceData, m
 		recreateDestinationIsoFilePath = true
 	}
 
-	if resolveDestinationIsoFilePathChanged || resolveDestinationZipFilePathChanged || resolveDestinationBootFilePathChanged || recreateDestinationIsoFilePath || d.HasChange("source_iso_file_path") || d.HasChange("source_iso_file_path_hash") || d.HasChange("source_zip_file_path") || d.HasChange("source_zip_file_path_hash") || d.HasChange("source_boot_file_path") || d.HasChange("source_boot_file_path_hash") || d.HasChange("destination_zip_file_path") || d.HasChange("destination_boot_file_path") {
-		err = c.CreateOrUpdateIsoImage(ctx, sourceIsoFilePath, sourceIsoFilePathHash, sourceZipFilePath, sourceZipFilePathHash, sourceBootFilePath, sourceBootFilePathHash, destinationIsoFilePath, destinationZipFilePath, destinationBootFilePath, media, fileSystem, volumeName, resolveDestinationIsoFilePath, resolveDestinationZipFilePath, resolveDestinationBootFilePath)
+	if resolveDestinationIsoFilePathChanged || resolveDestinationZipFilePathChanged || resolveDestinationBootFilePathChanged || recreateDestinationIsoFilePath || d.HasChange("source_iso_file_path") || d.HasChange("source_iso_file_path_hash") || d.HasChange("source_zip_file_path") || d.HasChange("source_zip_file_path_hash") || d.HasChange("source_boot_file_path") || d.HasChange("source_boot_file_path_hash") || d.HasChange("destination_zip_file_path") || d.HasChange("destination_boot_file_path") || d.HasChange("source_files_hash") {
+		err = c.CreateOrUpdateIsoImage(ctx, sourceIsoFilePath, sourceIsoFilePathHash, sourceZipFilePath, sourceZipFilePathHash, sourceBootFilePath, sourceBootFilePathHash, destinationIsoFilePath, destinationZipFilePath, destinationBootFilePath, media, fileSystem, volumeName, resolveDestinationIsoFilePath, resolveDestinationZipFilePath, resolveDestinationBootFilePath, sourceFilesHash, files)
 		if err != nil {
 			return diag.FromErr(err)
 		}
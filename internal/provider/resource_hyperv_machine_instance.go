@@ -2,13 +2,17 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 
@@ -17,6 +21,13 @@ import (
 
 const (
 	MaxUint32                    = 4294967295
+	MaxIdeControllers            = 2
+	MaxIdeControllerLocations    = 2
+	MaxScsiControllers           = api.MaxVmScsiControllers
+	MaxScsiControllerLocations   = 64
+	MemoryAlignmentBytes         = 2097152
+	DefaultMemoryMinimumBytes    = 536870912
+	DefaultMemoryMaximumBytes    = 1099511627776
 	ReadMachineInstanceTimeout   = 2 * time.Minute
 	CreateMachineInstanceTimeout = 30 * time.Minute
 	UpdateMachineInstanceTimeout = 30 * time.Minute
@@ -36,6 +47,13 @@ func resourceHyperVMachineInstance() *schema.Resource {
 		ReadContext:   resourceHyperVMachineInstanceRead,
 		UpdateContext: resourceHyperVMachineInstanceUpdate,
 		DeleteContext: resourceHyperVMachineInstanceDelete,
+		// Sequence returns the first error as is, so that attribute paths are kept in the diagnostic
+		CustomizeDiff: customdiff.Sequence(
+			customizeDiffHyperVMachineInstanceGeneration,
+			customizeDiffHyperVMachineInstanceTopology,
+			customizeDiffHyperVMachineInstanceFirmware,
+			customizeDiffHyperVMachineInstanceHost,
+		),
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
 		},
@@ -46,11 +64,25 @@ func resourceHyperVMachineInstance() *schema.Resource {
 				Description: "Specifies the name of the new virtual machine.",
 			},
 
+			"host": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "Specifies the Hyper-V host of the virtual machine. The host is connected to with the same settings as the provider. Changing it moves the virtual machine as specified by `migration_mode`. The provider host is used when it is empty.",
+			},
+
+			"migration_mode": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          api.VmMigrationMode_name[api.VmMigrationMode_SharedNothing],
+				ValidateDiagFunc: StringKeyInMap(api.VmMigrationMode_value, true),
+				Description:      "Specifies how the virtual machine is moved when `host` changes. `SharedNothing` live migrates the virtual machine together with its storage, `SharedStorage` live migrates the virtual machine and leaves its storage where it is, which has to be reachable from both hosts, and `Recreate` destroys the virtual machine and creates it on the new host. Valid values to use are `SharedNothing`, `SharedStorage`, `Recreate`.",
+			},
+
 			"path": {
 				Type:     schema.TypeString,
 				Optional: true,
 				Computed: true,
-				ForceNew: true,
 				DiffSuppressFunc: func(k, oldValue, newValue string, d *schema.ResourceData) bool {
 					if newValue == "" {
 						return true
@@ -74,7 +106,7 @@ func resourceHyperVMachineInstance() *schema.Resource {
 
 					return false
 				},
-				Description: "The path of the virtual machine.",
+				Description: "The path of the virtual machine. Changing it moves the virtual machine configuration with Move-VMStorage while the virtual machine keeps running.",
 			},
 
 			"generation": {
@@ -86,6 +118,36 @@ func resourceHyperVMachineInstance() *schema.Resource {
 				Description:      "Specifies the generation, as an integer, for the virtual machine. Valid values to use are `1`, `2`.",
 			},
 
+			"import_source": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				ForceNew:    true,
+				MaxItems:    1,
+				Description: "Create the virtual machine by importing an exported virtual machine, for example one exported by `hyperv_vm_export`, instead of creating an empty one. The imported virtual machine is renamed to `name` and the rest of the declared configuration is applied to it. The exported `generation` must match, which is checked before anything is imported or copied. Network adapters connected to switches that don't exist on the host are disconnected before the import.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"path": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Path to the folder of the exported virtual machine or to its vmcx file.",
+						},
+						"mode": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          api.VmImportMode_name[api.VmImportMode_Copy],
+							ValidateDiagFunc: StringKeyInMap(api.VmImportMode_value, true),
+							Description:      "Specifies how the virtual machine is imported. If `Register` is specified, the exported files are used in place and the virtual machine keeps its id. If `Copy` is specified, the files are copied and the virtual machine keeps its id. If `GenerateNewId` is specified, the files are copied and the virtual machine gets a new id, so the same export can be imported more than once. When copying, `path`, `snapshot_file_location` and `smart_paging_file_path` are used as destinations if they are set. Valid values to use are `Register`, `Copy`, `GenerateNewId`.",
+						},
+						"vhd_destination_path": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+							Description: "Specifies the folder to copy the virtual hard disks to when `mode` is `Copy` or `GenerateNewId`. Defaults to the virtual hard disk folder of the Hyper-V host.",
+						},
+					},
+				},
+			},
+
 			"automatic_critical_error_action": {
 				Type:             schema.TypeString,
 				Optional:         true,
@@ -172,14 +234,14 @@ func resourceHyperVMachineInstance() *schema.Resource {
 			"memory_maximum_bytes": {
 				Type:        schema.TypeInt,
 				Optional:    true,
-				Default:     1099511627776,
+				Default:     DefaultMemoryMaximumBytes,
 				Description: "Specifies the maximum amount of memory that the virtual machine is to be allocated. (Applies only to virtual machines using dynamic memory.)",
 			},
 
 			"memory_minimum_bytes": {
 				Type:        schema.TypeInt,
 				Optional:    true,
-				Default:     536870912,
+				Default:     DefaultMemoryMinimumBytes,
 				Description: "Specifies the minimum amount of memory that the virtual machine is to be allocated. (Applies only to virtual machines using dynamic memory.)",
 			},
 
@@ -208,14 +270,37 @@ func resourceHyperVMachineInstance() *schema.Resource {
 				Type:        schema.TypeString,
 				Optional:    true,
 				Default:     `C:\ProgramData\Microsoft\Windows\Hyper-V`,
-				Description: "Specifies the folder in which the Smart Paging file is to be stored.",
+				Description: "Specifies the folder in which the Smart Paging file is to be stored. Changing it moves the Smart Paging file with Move-VMStorage while the virtual machine keeps running.",
 			},
 
 			"snapshot_file_location": {
 				Type:        schema.TypeString,
 				Optional:    true,
 				Default:     `C:\ProgramData\Microsoft\Windows\Hyper-V`,
-				Description: "Specifies the folder in which the virtual machine is to store its snapshot files.",
+				Description: "Specifies the folder in which the virtual machine is to store its snapshot files. Changing it moves the snapshot files with Move-VMStorage while the virtual machine keeps running.",
+			},
+
+			"storage_migration": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Configures how storage is moved with Move-VMStorage when `path`, `snapshot_file_location`, `smart_paging_file_path` or the path of a hard disk drive changes. The move has to finish within the update timeout of the resource.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"retain_vhd_copies_on_source": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Keep the source virtual hard disks after they have been moved.",
+						},
+						"move_hard_disk_drives": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Move the virtual hard disk of a hard disk drive to its new `path` when the path changes. Otherwise the hard disk drive is attached to the virtual hard disk found at the new path, which requires the virtual machine to be turned off.",
+						},
+					},
+				},
 			},
 
 			"static_memory": {
@@ -271,6 +356,50 @@ func resourceHyperVMachineInstance() *schema.Resource {
 				Description: "The amount of time in seconds to wait between trying to get ip addresses for network cards on the virtual machine.",
 			},
 
+			"vm_memory": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Computed: true,
+				MaxItems: 1,
+				//DefaultFunc: api.DefaultVmMemories,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"buffer": {
+							Type:             schema.TypeInt,
+							Optional:         true,
+							Default:          20,
+							ValidateDiagFunc: IntBetween(5, 2000),
+							DiffSuppressFunc: api.DiffSuppressVmMemoryBuffer,
+							Description:      "Specifies the percentage of memory to reserve as a buffer in the virtual machine. Only applies to virtual machines using dynamic memory. Allowed values range from 5 to 2000.",
+						},
+
+						"priority": {
+							Type:             schema.TypeInt,
+							Optional:         true,
+							Default:          50,
+							ValidateDiagFunc: IntBetween(0, 100),
+							Description:      "Specifies the priority for allocating the physical computer's memory to this virtual machine relative to others, also known as memory weight. Allowed values range from 0 to 100.",
+						},
+
+						"maximum_amount_per_numa_node_bytes": {
+							Type:             schema.TypeInt,
+							Optional:         true,
+							Default:          0, // Dynamic value
+							ValidateDiagFunc: IsDivisibleBy(MemoryAlignmentBytes),
+							DiffSuppressFunc: api.DiffSuppressVmMemoryMaximumAmountPerNumaNodeBytes,
+							Description:      "Specifies the maximum amount of memory per NUMA node to be configured for the virtual machine. If value is 0 then the host default is used.",
+						},
+
+						"host_numa_spanning": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Whether virtual machines on the Hyper-V host may span physical NUMA nodes, either `On` or `Off`. This is a setting of the host that applies to every virtual machine on it, so it is only read. It is changed on the host with `Set-VMHost -NumaSpanningEnabled` and only takes effect after the Hyper-V Virtual Machine Management service is restarted.",
+						},
+					},
+				},
+				Description: "The memory settings of the virtual machine that are not covered by `memory_startup_bytes`, `memory_minimum_bytes`, `memory_maximum_bytes`, `dynamic_memory` and `static_memory`. The current settings are kept when it is not declared.",
+			},
+
 			"vm_processor": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -632,7 +761,7 @@ func resourceHyperVMachineInstance() *schema.Resource {
 						"controller_number": {
 							Type:        schema.TypeInt,
 							Required:    true,
-							Description: "Specifies the number of the controller to which the DVD drive is to be added.",
+							Description: "Specifies the number of the controller to which the DVD drive is to be added. Missing Scsi controllers are added, and empty Scsi controllers after the last referenced one are removed. Scsi controller 0 is always kept.",
 						},
 						"controller_location": {
 							Type:        schema.TypeInt,
@@ -671,7 +800,7 @@ func resourceHyperVMachineInstance() *schema.Resource {
 						"controller_number": {
 							Type:        schema.TypeInt,
 							Required:    true,
-							Description: "Specifies the number of the controller to which the hard disk drive is to be added.",
+							Description: "Specifies the number of the controller to which the hard disk drive is to be added. Missing Scsi controllers are added, and empty Scsi controllers after the last referenced one are removed. Scsi controller 0 is always kept.",
 						},
 						"controller_location": {
 							Type:        schema.TypeInt,
@@ -733,6 +862,65 @@ func resourceHyperVMachineInstance() *schema.Resource {
 				Description: "",
 			},
 
+			"com_ports": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: api.MaxVmComPortNumber,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"number": {
+							Type:             schema.TypeInt,
+							Required:         true,
+							ValidateDiagFunc: IntBetween(api.MinVmComPortNumber, api.MaxVmComPortNumber),
+							Description:      "Specifies the number of the COM port. Valid values to use are `1` for COM1 and `2` for COM2.",
+						},
+						"path": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Specifies the named pipe that the COM port is connected to e.g. `\\\\.\\pipe\\web-com1`.",
+						},
+						"debugger_mode": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          api.OnOffState_name[api.OnOffState_Off],
+							ValidateDiagFunc: StringKeyInMap(api.OnOffState_value, true),
+							Description:      "Specifies whether the COM port is used by a kernel debugger. Valid values to use are `On`, `Off`.",
+						},
+					},
+				},
+				Description: "The COM ports that are connected to a named pipe e.g. for a serial console, declared in order of number. COM ports that are not declared are disconnected.",
+			},
+
+			"vm_bios": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Computed: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"startup_order": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Computed: true,
+							MaxItems: 4,
+							Elem: &schema.Schema{
+								Type:             schema.TypeString,
+								ValidateDiagFunc: StringKeyInMap(api.Gen1BootType_value, true),
+							},
+							Description: "The order of the devices that the generation 1 virtual machine should try to use for boot up. Every device has to be listed once. Valid values to use are `CD`, `IDE`, `LegacyNetworkAdapter`, `Floppy`.",
+						},
+
+						"num_lock_enabled": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Specifies whether NumLock is enabled when the generation 1 virtual machine starts.",
+						},
+					},
+				},
+				Description: "The BIOS settings of a generation 1 virtual machine. The current settings are kept when it is not declared. Use `vm_firmware` for generation 2 virtual machines.",
+			},
+
 			"vm_firmware": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -921,6 +1109,55 @@ func resourceHyperVMachineInstance() *schema.Resource {
 							ValidateDiagFunc: StringKeyInMap(api.OnOffState_value, true),
 							Description:      "Specifies the behavior of the virtual machine after a start failure. For a value of On, if the virtual machine fails to start correctly from a device, the virtual machine is paused. Valid values to use are `On`, `Off`.",
 						},
+
+						"tpm_enabled": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Specifies whether the virtual machine has a virtual TPM. A key protector is required to enable it.",
+						},
+
+						"key_protector": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          api.VmKeyProtectorType_name[api.VmKeyProtectorType_None],
+							ValidateDiagFunc: StringKeyInMap(api.VmKeyProtectorType_value, true),
+							Description:      "Specifies the key protector of the virtual machine, which protects the virtual TPM and the encrypted state of the virtual machine. `NewLocalKeyProtector` creates a key protector owned by the `UntrustedGuardian` of the host. `HgsGuardian` creates a key protector owned by the local HGS guardian `hgs_guardian_name`, which is created when it doesn't exist. A key protector can't be removed once it is set. Hosts without the HgsClient module read any key protector as `NewLocalKeyProtector`, as they can't read its owner. Valid values to use are `None`, `NewLocalKeyProtector`, `HgsGuardian`.",
+						},
+
+						"hgs_guardian_name": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "",
+							DiffSuppressFunc: func(k, oldValue, newValue string, d *schema.ResourceData) bool {
+								keyProtectorKey := strings.Replace(k, "hgs_guardian_name", "key_protector", 1)
+								keyProtector := d.Get(keyProtectorKey).(string)
+
+								return !strings.EqualFold(keyProtector, api.VmKeyProtectorType_HgsGuardian.String())
+							},
+							Description: "Specifies the name of the HGS guardian that owns the key protector when `key_protector` is `HgsGuardian`.",
+						},
+
+						"encrypt_state_and_vm_migration_traffic": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Specifies whether the saved state, checkpoints and live migration traffic of the virtual machine are encrypted. A key protector is required to enable it.",
+						},
+
+						"shielded": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Specifies whether the virtual machine is shielded, which blocks console access and requires the virtual TPM to be enabled.",
+						},
+
+						"virtualization_based_security_opt_out": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Specifies whether the virtual machine opts out of virtualization based security.",
+						},
 					},
 				},
 				Description: "",
@@ -931,7 +1168,10 @@ func resourceHyperVMachineInstance() *schema.Resource {
 
 func resourceHyperVMachineInstanceCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	log.Printf("[INFO][hyperv][create] creating hyperv machine: %#v", d)
-	client := meta.(api.Client)
+	client, err := getMachineInstanceClient(d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
 	name := ""
 
@@ -988,6 +1228,11 @@ func resourceHyperVMachineInstanceCreate(ctx context.Context, d *schema.Resource
 		return diag.FromErr(err)
 	}
 
+	vmMemories, err := api.ExpandVmMemories(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	integrationServices, err := api.ExpandIntegrationServices(d)
 	if err != nil {
 		return diag.FromErr(err)
@@ -1008,12 +1253,23 @@ func resourceHyperVMachineInstanceCreate(ctx context.Context, d *schema.Resource
 		return diag.FromErr(err)
 	}
 
+	comPorts, err := api.ExpandVmComPorts(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	var vmFirmwares []api.VmFirmware
+	var vmBioses []api.VmBios
 	if generation > 1 {
 		vmFirmwares, err = api.ExpandVmFirmwares(d)
 		if err != nil {
 			return diag.FromErr(err)
 		}
+	} else {
+		vmBioses, err = api.ExpandVmBioses(d)
+		if err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
 	waitForStateTimeout, waitForStatePollPeriod, err := api.ExpandVmStateWaitForState(d)
@@ -1021,7 +1277,24 @@ func resourceHyperVMachineInstanceCreate(ctx context.Context, d *schema.Resource
 		return diag.FromErr(err)
 	}
 
-	err = client.CreateVm(ctx, name, path, generation, automaticCriticalErrorAction, automaticCriticalErrorActionTimeout, automaticStartAction, automaticStartDelay, automaticStopAction, checkpointType, dynamicMemory, guestControlledCacheTypes, highMemoryMappedIoSpace, lockOnDisconnect, lowMemoryMappedIoSpace, memoryMaximumBytes, memoryMinimumBytes, memoryStartupBytes, notes, processorCount, smartPagingFilePath, snapshotFileLocation, staticMemory)
+	vmImportSources, err := api.ExpandVmImportSources(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if len(vmImportSources) > 0 {
+		// The import fails before anything is imported when the exported vm doesn't have the declared generation, as
+		// generation can't be changed afterwards
+		vmImport := vmImportSources[0]
+		err = client.ImportVm(ctx, name, vmImport.Path, vmImport.Mode, generation, path, vmImport.VhdDestinationPath, snapshotFileLocation, smartPagingFilePath)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		err = client.UpdateVm(ctx, name, automaticCriticalErrorAction, automaticCriticalErrorActionTimeout, automaticStartAction, automaticStartDelay, automaticStopAction, checkpointType, dynamicMemory, guestControlledCacheTypes, highMemoryMappedIoSpace, lockOnDisconnect, lowMemoryMappedIoSpace, memoryMaximumBytes, memoryMinimumBytes, memoryStartupBytes, notes, processorCount, smartPagingFilePath, snapshotFileLocation, staticMemory)
+	} else {
+		err = client.CreateVm(ctx, name, path, generation, automaticCriticalErrorAction, automaticCriticalErrorActionTimeout, automaticStartAction, automaticStartDelay, automaticStopAction, checkpointType, dynamicMemory, guestControlledCacheTypes, highMemoryMappedIoSpace, lockOnDisconnect, lowMemoryMappedIoSpace, memoryMaximumBytes, memoryMinimumBytes, memoryStartupBytes, notes, processorCount, smartPagingFilePath, snapshotFileLocation, staticMemory)
+	}
 	if err != nil {
 		return diag.FromErr(err)
 	}
@@ -1031,6 +1304,11 @@ func resourceHyperVMachineInstanceCreate(ctx context.Context, d *schema.Resource
 		return diag.FromErr(err)
 	}
 
+	err = client.CreateOrUpdateVmMemories(ctx, name, vmMemories)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	err = client.CreateOrUpdateVmNetworkAdapters(ctx, name, networkAdapters)
 	if err != nil {
 		return diag.FromErr(err)
@@ -1051,11 +1329,21 @@ func resourceHyperVMachineInstanceCreate(ctx context.Context, d *schema.Resource
 		return diag.FromErr(err)
 	}
 
+	err = client.CreateOrUpdateVmComPorts(ctx, name, comPorts)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	if generation > 1 {
 		err = client.CreateOrUpdateVmFirmwares(ctx, name, vmFirmwares)
 		if err != nil {
 			return diag.FromErr(err)
 		}
+	} else {
+		err = client.CreateOrUpdateVmBioses(ctx, name, vmBioses)
+		if err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
 	err = client.UpdateVmStatus(ctx, name, waitForStateTimeout, waitForStatePollPeriod, state)
@@ -1071,19 +1359,22 @@ func resourceHyperVMachineInstanceCreate(ctx context.Context, d *schema.Resource
 
 func resourceHyperVMachineInstanceRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	log.Printf("[INFO][hyperv][read] reading hyperv machine: %#v", d)
-	client := meta.(api.Client)
-
-	name := d.Id()
-
-	vm, err := client.GetVm(ctx, name)
+	client, err := getMachineInstanceClient(d, meta)
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	if !d.IsNewResource() && vm.Name == "" {
+	name := d.Id()
+
+	vm, err := client.GetVm(ctx, name)
+	if !d.IsNewResource() && errors.Is(err, api.ErrNotFound) {
+		log.Printf("[INFO][hyperv][read] unable to read hyperv machine as it does not exist: %#v", name)
 		d.SetId("")
 		return nil
 	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
 	if err := d.Set("name", vm.Name); err != nil {
 		return diag.FromErr(err)
@@ -1094,6 +1385,11 @@ func resourceHyperVMachineInstanceRead(ctx context.Context, d *schema.ResourceDa
 		return diag.FromErr(err)
 	}
 
+	vmMemories, err := client.GetVmMemories(ctx, name)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	integrationServices, err := client.GetVmIntegrationServices(ctx, name)
 	if err != nil {
 		return diag.FromErr(err)
@@ -1109,12 +1405,23 @@ func resourceHyperVMachineInstanceRead(ctx context.Context, d *schema.ResourceDa
 		return diag.FromErr(err)
 	}
 
+	comPorts, err := client.GetVmComPorts(ctx, name)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	vmFirmwares := client.GetNoVmFirmwares(ctx)
+	vmBioses := client.GetNoVmBioses(ctx)
 	if vm.Generation > 1 {
 		vmFirmwares, err = client.GetVmFirmwares(ctx, name)
 		if err != nil {
 			return diag.FromErr(err)
 		}
+	} else {
+		vmBioses, err = client.GetVmBioses(ctx, name)
+		if err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
 	vmState, err := client.GetVmStatus(ctx, name)
@@ -1159,6 +1466,13 @@ func resourceHyperVMachineInstanceRead(ctx context.Context, d *schema.ResourceDa
 	log.Printf("[INFO][hyperv][read] vmProcessors: %v", vmProcessors)
 	log.Printf("[INFO][hyperv][read] flattenedVmProcessors: %v", flattenedVmProcessors)
 
+	flattenedVmMemories := api.FlattenVmMemories(&vmMemories)
+	if err := d.Set("vm_memory", flattenedVmMemories); err != nil {
+		return diag.Errorf("[DEBUG] Error setting vm_memory error: %v", err)
+	}
+	log.Printf("[INFO][hyperv][read] vmMemories: %v", vmMemories)
+	log.Printf("[INFO][hyperv][read] flattenedVmMemories: %v", flattenedVmMemories)
+
 	flattenedIntegrationServices := api.FlattenIntegrationServices(&integrationServices)
 	if err := d.Set("integration_services", flattenedIntegrationServices); err != nil {
 		return diag.Errorf("[DEBUG] Error setting integration_services error: %v", err)
@@ -1178,6 +1492,13 @@ func resourceHyperVMachineInstanceRead(ctx context.Context, d *schema.ResourceDa
 	log.Printf("[INFO][hyperv][read] hardDiskDrives: %v", hardDiskDrives)
 	log.Printf("[INFO][hyperv][read] flattenedHardDiskDrives: %v", flattenedHardDiskDrives)
 
+	flattenedComPorts := api.FlattenVmComPorts(&comPorts)
+	if err := d.Set("com_ports", flattenedComPorts); err != nil {
+		return diag.Errorf("[DEBUG] Error setting com_ports error: %v", err)
+	}
+	log.Printf("[INFO][hyperv][read] comPorts: %v", comPorts)
+	log.Printf("[INFO][hyperv][read] flattenedComPorts: %v", flattenedComPorts)
+
 	flattenedNetworkAdapters := api.FlattenNetworkAdapters(&networkAdapters)
 	if err := d.Set("network_adaptors", flattenedNetworkAdapters); err != nil {
 		return diag.Errorf("[DEBUG] Error setting network_adaptors error: %v", err)
@@ -1197,6 +1518,18 @@ func resourceHyperVMachineInstanceRead(ctx context.Context, d *schema.ResourceDa
 		log.Printf("[INFO][hyperv][read] skip flattenedVmFirmwares as vm generation is %v", vm.Generation)
 	}
 
+	flattenedVmBioses := api.FlattenVmBioses(&vmBioses)
+	if err := d.Set("vm_bios", flattenedVmBioses); err != nil {
+		return diag.Errorf("[DEBUG] Error setting vm_bios error: %v", err)
+	}
+	if vm.Generation < 2 {
+		log.Printf("[INFO][hyperv][read] vmBioses: %v", vmBioses)
+		log.Printf("[INFO][hyperv][read] flattenedVmBioses: %v", flattenedVmBioses)
+	} else {
+		log.Printf("[INFO][hyperv][read] skip vmBioses as vm generation is %v", vm.Generation)
+		log.Printf("[INFO][hyperv][read] skip flattenedVmBioses as vm generation is %v", vm.Generation)
+	}
+
 	if err := d.Set("name", vm.Name); err != nil {
 		return diag.FromErr(err)
 	}
@@ -1274,12 +1607,40 @@ func resourceHyperVMachineInstanceRead(ctx context.Context, d *schema.ResourceDa
 
 func resourceHyperVMachineInstanceUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	log.Printf("[INFO][hyperv][update] updating hyperv machine: %#v", d)
-	client := meta.(api.Client)
 
 	name := d.Id()
 
 	generation := (d.Get("generation")).(int)
 
+	var client api.Client
+	var hardDiskDrivesChanged bool
+	var err error
+
+	if d.HasChange("host") {
+		var diags diag.Diagnostics
+		client, hardDiskDrivesChanged, diags = moveVm(ctx, d, meta, name)
+		if diags.HasError() {
+			return diags
+		}
+	} else {
+		client, err = getMachineInstanceClient(d, meta)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		hardDiskDrivesChanged, err = moveVmStorage(ctx, d, client, name)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	// An empty vm_memory leaves the memory settings as they are, so there is nothing to turn the vm off for
+	vmMemories, err := api.ExpandVmMemories(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	vmMemoriesChanged := d.HasChange("vm_memory") && len(vmMemories) > 0
+
 	hasChangesThatRequireVmToBeOff := d.HasChange("automatic_critical_error_action") ||
 		d.HasChange("automatic_critical_error_action_timeout") ||
 		d.HasChange("automatic_start_action") ||
@@ -1296,15 +1657,16 @@ func resourceHyperVMachineInstanceUpdate(ctx context.Context, d *schema.Resource
 		d.HasChange("memory_startup_bytes") ||
 		d.HasChange("notes") ||
 		d.HasChange("processor_count") ||
-		d.HasChange("smart_paging_file_path") ||
-		d.HasChange("snapshot_file_location") ||
 		d.HasChange("static_memory") ||
 		(generation > 1 && d.HasChange("vm_firmware")) ||
+		(generation < 2 && d.HasChange("vm_bios")) ||
 		d.HasChange("vm_processor") ||
+		vmMemoriesChanged ||
 		d.HasChange("integration_services") ||
 		d.HasChange("network_adaptors") ||
 		d.HasChange("dvd_drives") ||
-		d.HasChange("hard_disk_drives")
+		d.HasChange("com_ports") ||
+		hardDiskDrivesChanged
 
 	if hasChangesThatRequireVmToBeOff {
 		err := turnOffVmIfOn(ctx, d, client, name)
@@ -1329,8 +1691,6 @@ func resourceHyperVMachineInstanceUpdate(ctx context.Context, d *schema.Resource
 		d.HasChange("memory_startup_bytes") ||
 		d.HasChange("notes") ||
 		d.HasChange("processor_count") ||
-		d.HasChange("smart_paging_file_path") ||
-		d.HasChange("snapshot_file_location") ||
 		d.HasChange("static_memory") {
 		automaticCriticalErrorAction := api.ToCriticalErrorAction((d.Get("automatic_critical_error_action")).(string))
 		automaticCriticalErrorActionTimeout := int32((d.Get("automatic_critical_error_action_timeout")).(int))
@@ -1378,6 +1738,13 @@ func resourceHyperVMachineInstanceUpdate(ctx context.Context, d *schema.Resource
 		}
 	}
 
+	if vmMemoriesChanged {
+		err = client.CreateOrUpdateVmMemories(ctx, name, vmMemories)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
 	if d.HasChange("integration_services") {
 		integrationServices, err := api.ExpandIntegrationServices(d)
 		if err != nil {
@@ -1416,7 +1783,7 @@ func resourceHyperVMachineInstanceUpdate(ctx context.Context, d *schema.Resource
 		}
 	}
 
-	if d.HasChange("hard_disk_drives") {
+	if hardDiskDrivesChanged {
 		hardDiskDrives, err := api.ExpandHardDiskDrives(d)
 		if err != nil {
 			return diag.FromErr(err)
@@ -1428,6 +1795,18 @@ func resourceHyperVMachineInstanceUpdate(ctx context.Context, d *schema.Resource
 		}
 	}
 
+	if d.HasChange("com_ports") {
+		comPorts, err := api.ExpandVmComPorts(d)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		err = client.CreateOrUpdateVmComPorts(ctx, name, comPorts)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
 	if generation > 1 && d.HasChange("vm_firmware") {
 		vmFirmwares, err := api.ExpandVmFirmwares(d)
 		if err != nil {
@@ -1440,6 +1819,18 @@ func resourceHyperVMachineInstanceUpdate(ctx context.Context, d *schema.Resource
 		}
 	}
 
+	if generation < 2 && d.HasChange("vm_bios") {
+		vmBioses, err := api.ExpandVmBioses(d)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		err = client.CreateOrUpdateVmBioses(ctx, name, vmBioses)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
 	if hasChangesThatRequireVmToBeOff || d.HasChange("state") {
 		waitForStateTimeout, waitForStatePollPeriod, err := api.ExpandVmStateWaitForState(d)
 		if err != nil {
@@ -1461,7 +1852,10 @@ func resourceHyperVMachineInstanceUpdate(ctx context.Context, d *schema.Resource
 func resourceHyperVMachineInstanceDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	log.Printf("[INFO][hyperv][delete] deleting hyperv machine: %#v", d)
 
-	client := meta.(api.Client)
+	client, err := getMachineInstanceClient(d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
 	name := d.Id()
 
@@ -1485,6 +1879,511 @@ func resourceHyperVMachineInstanceDelete(ctx context.Context, d *schema.Resource
 	return nil
 }
 
+// getMachineInstanceClient returns the client for the host of the vm
+func getMachineInstanceClient(d resourceGetter, meta interface{}) (api.Client, error) {
+	return meta.(api.Client).ClientForHost((d.Get("host")).(string))
+}
+
+// getMachineInstanceHostName returns the name of the host of the vm, which is the provider host when host is empty
+func getMachineInstanceHostName(meta interface{}, host string) string {
+	if host == "" {
+		return meta.(api.Client).HostName()
+	}
+
+	return host
+}
+
+// resourceGetter reads a resource during plan, with *schema.ResourceDiff, and during apply, with *schema.ResourceData
+type resourceGetter interface {
+	Get(key string) interface{}
+	GetChange(key string) (interface{}, interface{})
+	HasChange(key string) bool
+}
+
+// resourceDiffGetter reads the planned values of a resource, with *schema.ResourceDiff
+type resourceDiffGetter interface {
+	Get(key string) interface{}
+	GetChange(key string) (interface{}, interface{})
+	NewValueKnown(key string) bool
+}
+
+// customizeDiffHyperVMachineInstanceGeneration rejects settings that the generation of the vm doesn't have
+func customizeDiffHyperVMachineInstanceGeneration(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+	generation := (diff.Get("generation")).(int)
+
+	// vm_bios is computed, so it is only rejected when it is declared rather than kept from the state of a generation 1 vm
+	if generation > 1 && diff.HasChange("vm_bios") && len((diff.Get("vm_bios")).([]interface{})) > 0 {
+		return cty.GetAttrPath("vm_bios").NewErrorf("vm_bios is only supported by generation 1 virtual machines, use vm_firmware for generation %d virtual machines", generation)
+	}
+
+	if generation < 2 && len((diff.Get("vm_firmware")).([]interface{})) > 0 {
+		return cty.GetAttrPath("vm_firmware").NewErrorf("vm_firmware is only supported by generation 2 virtual machines, use vm_bios for generation %d virtual machines", generation)
+	}
+
+	if generation > 1 && diff.NewValueKnown("network_adaptors") {
+		for index, networkAdapter := range (diff.Get("network_adaptors")).([]interface{}) {
+			if networkAdapter.(map[string]interface{})["is_legacy"].(bool) {
+				return cty.GetAttrPath("network_adaptors").IndexInt(index).GetAttr("is_legacy").NewErrorf("legacy network adapters are only supported by generation 1 virtual machines")
+			}
+		}
+	}
+
+	return nil
+}
+
+// customizeDiffHyperVMachineInstanceTopology checks that drives have unique addresses on controllers the vm has and
+// that the memory settings are consistent, which otherwise only fail during apply
+func customizeDiffHyperVMachineInstanceTopology(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+	return validateTopology(diff)
+}
+
+// validateTopology checks the drives, com ports and memory of a planned vm
+func validateTopology(diff resourceDiffGetter) error {
+	generation := (diff.Get("generation")).(int)
+
+	if diff.NewValueKnown("hard_disk_drives") && diff.NewValueKnown("dvd_drives") {
+		driveAddresses := make(map[driveAddress]string)
+
+		for index, hardDiskDrive := range (diff.Get("hard_disk_drives")).([]interface{}) {
+			hardDiskDrive := hardDiskDrive.(map[string]interface{})
+			path := cty.GetAttrPath("hard_disk_drives").IndexInt(index)
+
+			address := driveAddress{
+				ControllerType:     api.ToControllerType(hardDiskDrive["controller_type"].(string)),
+				ControllerNumber:   hardDiskDrive["controller_number"].(int),
+				ControllerLocation: hardDiskDrive["controller_location"].(int),
+			}
+
+			if generation > 1 && address.ControllerType == api.ControllerType_Ide {
+				return path.GetAttr("controller_type").NewErrorf("generation %d virtual machines don't have Ide controllers, use a Scsi controller", generation)
+			}
+
+			err := validateDriveAddress(driveAddresses, path, fmt.Sprintf("hard_disk_drives.%d", index), address)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Dvd drives are attached to the Ide controllers of generation 1 vms and to the Scsi controllers of generation 2 vms
+		dvdDriveControllerType := api.ControllerType_Scsi
+		if generation < 2 {
+			dvdDriveControllerType = api.ControllerType_Ide
+		}
+
+		for index, dvdDrive := range (diff.Get("dvd_drives")).([]interface{}) {
+			dvdDrive := dvdDrive.(map[string]interface{})
+
+			address := driveAddress{
+				ControllerType:     dvdDriveControllerType,
+				ControllerNumber:   dvdDrive["controller_number"].(int),
+				ControllerLocation: dvdDrive["controller_location"].(int),
+			}
+
+			err := validateDriveAddress(driveAddresses, cty.GetAttrPath("dvd_drives").IndexInt(index), fmt.Sprintf("dvd_drives.%d", index), address)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if diff.NewValueKnown("com_ports") {
+		comPortNumbers := make(map[int]int)
+		previousNumber := 0
+
+		for index, comPort := range (diff.Get("com_ports")).([]interface{}) {
+			number := comPort.(map[string]interface{})["number"].(int)
+
+			if existingIndex, ok := comPortNumbers[number]; ok {
+				return cty.GetAttrPath("com_ports").IndexInt(index).GetAttr("number").NewErrorf("COM%d is already used by com_ports.%d", number, existingIndex)
+			}
+
+			// The com ports are read in order of number, so any other order would never match
+			if number < previousNumber {
+				return cty.GetAttrPath("com_ports").IndexInt(index).GetAttr("number").NewErrorf("COM%d should be declared before COM%d, com_ports are declared in order of number", number, previousNumber)
+			}
+
+			comPortNumbers[number] = index
+			previousNumber = number
+		}
+	}
+
+	return validateMemory(diff)
+}
+
+type driveAddress struct {
+	ControllerType     api.ControllerType
+	ControllerNumber   int
+	ControllerLocation int
+}
+
+// validateDriveAddress checks that a drive is attached to a location that exists and that isn't used by another drive
+func validateDriveAddress(driveAddresses map[driveAddress]string, path cty.Path, name string, address driveAddress) error {
+	maxControllers, maxControllerLocations := MaxScsiControllers, MaxScsiControllerLocations
+	if address.ControllerType == api.ControllerType_Ide {
+		maxControllers, maxControllerLocations = MaxIdeControllers, MaxIdeControllerLocations
+	}
+
+	if address.ControllerNumber < 0 || address.ControllerNumber >= maxControllers {
+		return path.GetAttr("controller_number").NewErrorf("%s controller number %d should be between 0 and %d", address.ControllerType, address.ControllerNumber, maxControllers-1)
+	}
+
+	if address.ControllerLocation < 0 || address.ControllerLocation >= maxControllerLocations {
+		return path.GetAttr("controller_location").NewErrorf("%s controller location %d should be between 0 and %d", address.ControllerType, address.ControllerLocation, maxControllerLocations-1)
+	}
+
+	if existingName, ok := driveAddresses[address]; ok {
+		return path.GetAttr("controller_location").NewErrorf("%s controller %d location %d is already used by %s", address.ControllerType, address.ControllerNumber, address.ControllerLocation, existingName)
+	}
+
+	driveAddresses[address] = name
+
+	return nil
+}
+
+// validateMemory checks that memory is allocated in 2 MB blocks, that static memory doesn't change the dynamic memory
+// bounds and that dynamic memory starts between its bounds
+func validateMemory(diff resourceDiffGetter) error {
+	memoryKeys := []string{"memory_minimum_bytes", "memory_startup_bytes", "memory_maximum_bytes"}
+
+	for _, key := range append(memoryKeys, "static_memory") {
+		if !diff.NewValueKnown(key) {
+			return nil
+		}
+	}
+
+	for _, key := range memoryKeys {
+		if value := (diff.Get(key)).(int); value%MemoryAlignmentBytes != 0 {
+			return cty.GetAttrPath(key).NewErrorf("%d bytes should be a multiple of 2 MB (%d bytes)", value, MemoryAlignmentBytes)
+		}
+	}
+
+	memoryMinimumBytes := (diff.Get("memory_minimum_bytes")).(int)
+	memoryStartupBytes := (diff.Get("memory_startup_bytes")).(int)
+	memoryMaximumBytes := (diff.Get("memory_maximum_bytes")).(int)
+
+	if (diff.Get("static_memory")).(bool) {
+		if memoryMinimumBytes != DefaultMemoryMinimumBytes {
+			return cty.GetAttrPath("memory_minimum_bytes").NewErrorf("memory_minimum_bytes only applies to dynamic memory, remove it or enable dynamic_memory")
+		}
+
+		if memoryMaximumBytes != DefaultMemoryMaximumBytes {
+			return cty.GetAttrPath("memory_maximum_bytes").NewErrorf("memory_maximum_bytes only applies to dynamic memory, remove it or enable dynamic_memory")
+		}
+
+		return nil
+	}
+
+	if memoryMinimumBytes > memoryStartupBytes {
+		return cty.GetAttrPath("memory_minimum_bytes").NewErrorf("memory_minimum_bytes %d should not be more than memory_startup_bytes %d", memoryMinimumBytes, memoryStartupBytes)
+	}
+
+	if memoryStartupBytes > memoryMaximumBytes {
+		return cty.GetAttrPath("memory_maximum_bytes").NewErrorf("memory_maximum_bytes %d should not be less than memory_startup_bytes %d", memoryMaximumBytes, memoryStartupBytes)
+	}
+
+	return nil
+}
+
+// customizeDiffHyperVMachineInstanceFirmware checks that the security settings of a generation 2 vm have the key
+// protector they need, which otherwise only fail during apply
+func customizeDiffHyperVMachineInstanceFirmware(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+	return validateFirmwareSecurity(diff)
+}
+
+// validateFirmwareSecurity checks that a key protector is kept once it is set, that the settings it protects have one
+// and that an HGS guardian is named when the key protector is owned by one
+func validateFirmwareSecurity(diff resourceDiffGetter) error {
+	if (diff.Get("generation")).(int) < 2 || !diff.NewValueKnown("vm_firmware") || len((diff.Get("vm_firmware")).([]interface{})) == 0 {
+		return nil
+	}
+
+	path := cty.GetAttrPath("vm_firmware").IndexInt(0)
+
+	oldKeyProtector, newKeyProtector := diff.GetChange("vm_firmware.0.key_protector")
+	keyProtector := api.ToVmKeyProtectorType(newKeyProtector.(string))
+
+	if previousKeyProtector, ok := oldKeyProtector.(string); ok && api.ToVmKeyProtectorType(previousKeyProtector) != api.VmKeyProtectorType_None && keyProtector == api.VmKeyProtectorType_None {
+		return path.GetAttr("key_protector").NewErrorf("the key protector %s of the virtual machine can't be removed, recreate the virtual machine to remove it", previousKeyProtector)
+	}
+
+	if keyProtector == api.VmKeyProtectorType_None {
+		for _, key := range []string{"tpm_enabled", "encrypt_state_and_vm_migration_traffic", "shielded"} {
+			if (diff.Get("vm_firmware.0." + key)).(bool) {
+				return path.GetAttr(key).NewErrorf("%s requires a key protector, set key_protector to %s or %s", key, api.VmKeyProtectorType_NewLocalKeyProtector, api.VmKeyProtectorType_HgsGuardian)
+			}
+		}
+	}
+
+	if (diff.Get("vm_firmware.0.shielded")).(bool) && !(diff.Get("vm_firmware.0.tpm_enabled")).(bool) {
+		return path.GetAttr("shielded").NewErrorf("shielded requires the virtual TPM, set tpm_enabled to true")
+	}
+
+	if keyProtector == api.VmKeyProtectorType_HgsGuardian && (diff.Get("vm_firmware.0.hgs_guardian_name")).(string) == "" {
+		return path.GetAttr("hgs_guardian_name").NewErrorf("hgs_guardian_name is required when key_protector is %s", api.VmKeyProtectorType_HgsGuardian)
+	}
+
+	return nil
+}
+
+// customizeDiffHyperVMachineInstanceHost checks that a vm can be moved to its new host with Compare-VM before apply.
+// The vm is recreated on the new host instead when migration_mode is Recreate.
+func customizeDiffHyperVMachineInstanceHost(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+	if diff.Id() == "" || !diff.HasChange("host") || !diff.NewValueKnown("host") {
+		return nil
+	}
+
+	migrationMode := api.ToVmMigrationMode((diff.Get("migration_mode")).(string))
+	if migrationMode == api.VmMigrationMode_Recreate {
+		return diff.ForceNew("host")
+	}
+
+	oldHost, newHost := diff.GetChange("host")
+	sourceHost := getMachineInstanceHostName(meta, oldHost.(string))
+	destinationHost := getMachineInstanceHostName(meta, newHost.(string))
+	if strings.EqualFold(sourceHost, destinationHost) {
+		return nil
+	}
+
+	sourceClient, err := meta.(api.Client).ClientForHost(oldHost.(string))
+	if err != nil {
+		return err
+	}
+
+	includeStorage := migrationMode == api.VmMigrationMode_SharedNothing
+	vmStorageMove := api.VmStorageMove{}
+	if includeStorage {
+		vmStorageMove, _ = getVmStorageMove(diff, diff.Id(), api.VmStorageMigration{
+			RetainVhdCopiesOnSource: (diff.Get("storage_migration.0.retain_vhd_copies_on_source")).(bool),
+			MoveHardDiskDrives:      (diff.Get("storage_migration.0.move_hard_disk_drives")).(bool),
+		})
+	}
+
+	incompatibilities, err := sourceClient.GetVmMigrationIncompatibilities(ctx, diff.Id(), destinationHost, includeStorage, vmStorageMove)
+	if err != nil {
+		return err
+	}
+
+	if len(incompatibilities) > 0 {
+		messages := make([]string, 0, len(incompatibilities))
+		for _, incompatibility := range incompatibilities {
+			messages = append(messages, fmt.Sprintf("  - %s (%d)", incompatibility.Message, incompatibility.MessageId))
+		}
+
+		return fmt.Errorf("[ERROR][hyperv] vm %s can't be moved from %s to %s:\n%s", diff.Id(), sourceHost, destinationHost, strings.Join(messages, "\n"))
+	}
+
+	return nil
+}
+
+// moveVm live migrates a vm to its new host with Move-VM. With SharedNothing the storage is moved as part of the
+// migration, otherwise it is moved on the new host afterwards. It returns the client for the new host and whether the
+// hard disk drives still have changes that have to be applied.
+func moveVm(ctx context.Context, d *schema.ResourceData, meta interface{}, name string) (client api.Client, hardDiskDrivesChanged bool, diags diag.Diagnostics) {
+	oldHost, newHost := d.GetChange("host")
+
+	sourceClient, err := meta.(api.Client).ClientForHost(oldHost.(string))
+	if err != nil {
+		return nil, false, diag.FromErr(err)
+	}
+
+	client, err = meta.(api.Client).ClientForHost(newHost.(string))
+	if err != nil {
+		return nil, false, diag.FromErr(err)
+	}
+
+	sourceHost := getMachineInstanceHostName(meta, oldHost.(string))
+	destinationHost := getMachineInstanceHostName(meta, newHost.(string))
+	if strings.EqualFold(sourceHost, destinationHost) {
+		hardDiskDrivesChanged, err = moveVmStorage(ctx, d, client, name)
+		return client, hardDiskDrivesChanged, diag.FromErr(err)
+	}
+
+	includeStorage := api.ToVmMigrationMode((d.Get("migration_mode")).(string)) == api.VmMigrationMode_SharedNothing
+	vmStorageMove := api.VmStorageMove{}
+	hardDiskDrivesChanged = d.HasChange("hard_disk_drives")
+	if includeStorage {
+		vmStorageMigrations, err := api.ExpandVmStorageMigrations(d)
+		if err != nil {
+			return nil, false, diag.FromErr(err)
+		}
+
+		vmStorageMigration := api.VmStorageMigration{}
+		if len(vmStorageMigrations) > 0 {
+			vmStorageMigration = vmStorageMigrations[0]
+		}
+
+		vmStorageMove, hardDiskDrivesChanged = getVmStorageMove(d, name, vmStorageMigration)
+	}
+
+	incompatibilities, err := sourceClient.GetVmMigrationIncompatibilities(ctx, name, destinationHost, includeStorage, vmStorageMove)
+	if err != nil {
+		return nil, false, diag.FromErr(err)
+	}
+
+	for _, incompatibility := range incompatibilities {
+		diags = append(diags, diag.Diagnostic{
+			Severity:      diag.Error,
+			Summary:       fmt.Sprintf("vm %s can't be moved from %s to %s", name, sourceHost, destinationHost),
+			Detail:        fmt.Sprintf("%s (%d)", incompatibility.Message, incompatibility.MessageId),
+			AttributePath: cty.GetAttrPath("host"),
+		})
+	}
+	if diags.HasError() {
+		return nil, false, diags
+	}
+
+	timeout, pollPeriod, err := getMoveTimeout(ctx, d)
+	if err != nil {
+		return nil, false, diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][update] moving hyperv machine %s from %s to %s: %+v", name, sourceHost, destinationHost, vmStorageMove)
+
+	// The vm stays on the old host when the move fails, so keep the old host in state
+	d.Partial(true)
+	err = sourceClient.MoveVm(ctx, name, destinationHost, includeStorage, timeout, pollPeriod, vmStorageMove)
+	if err != nil {
+		return nil, false, diag.FromErr(err)
+	}
+	d.Partial(false)
+
+	log.Printf("[INFO][hyperv][update] moved hyperv machine %s from %s to %s", name, sourceHost, destinationHost)
+
+	if !includeStorage {
+		hardDiskDrivesChanged, err = moveVmStorage(ctx, d, client, name)
+		if err != nil {
+			return nil, false, diag.FromErr(err)
+		}
+	}
+
+	return client, hardDiskDrivesChanged, nil
+}
+
+// moveVmStorage moves the storage of a vm with Move-VMStorage when path, snapshot_file_location,
+// smart_paging_file_path or, with move_hard_disk_drives, the path of a hard disk drive changes. It returns whether the
+// hard disk drives still have changes that have to be applied once the moved paths are taken into account.
+func moveVmStorage(ctx context.Context, d *schema.ResourceData, client api.Client, name string) (hardDiskDrivesChanged bool, err error) {
+	vmStorageMigrations, err := api.ExpandVmStorageMigrations(d)
+	if err != nil {
+		return d.HasChange("hard_disk_drives"), err
+	}
+
+	vmStorageMigration := api.VmStorageMigration{}
+	if len(vmStorageMigrations) > 0 {
+		vmStorageMigration = vmStorageMigrations[0]
+	}
+
+	vmStorageMove, hardDiskDrivesChanged := getVmStorageMove(d, name, vmStorageMigration)
+
+	if vmStorageMove.VirtualMachinePath == "" && vmStorageMove.SnapshotFilePath == "" && vmStorageMove.SmartPagingFilePath == "" && len(vmStorageMove.Vhds) == 0 {
+		return hardDiskDrivesChanged, nil
+	}
+
+	timeout, pollPeriod, err := getMoveTimeout(ctx, d)
+	if err != nil {
+		return hardDiskDrivesChanged, err
+	}
+
+	log.Printf("[INFO][hyperv][update] moving storage of hyperv machine %s: %+v", name, vmStorageMove)
+
+	err = client.MoveVmStorage(ctx, name, timeout, pollPeriod, vmStorageMove)
+	if err != nil {
+		return hardDiskDrivesChanged, err
+	}
+
+	log.Printf("[INFO][hyperv][update] moved storage of hyperv machine %s", name)
+
+	return hardDiskDrivesChanged, nil
+}
+
+// getVmStorageMove returns the storage of a vm that moves because its path changed
+func getVmStorageMove(d resourceGetter, name string, vmStorageMigration api.VmStorageMigration) (vmStorageMove api.VmStorageMove, hardDiskDrivesChanged bool) {
+	hardDiskDrivesChanged = d.HasChange("hard_disk_drives")
+
+	vmStorageMove = api.VmStorageMove{
+		RetainVhdCopiesOnSource: vmStorageMigration.RetainVhdCopiesOnSource,
+	}
+
+	if d.HasChange("path") {
+		// New-VM appends the machine name to path, so the configuration is moved to the same folder it would be created in
+		vmStorageMove.VirtualMachinePath = strings.TrimRight((d.Get("path")).(string), "\\") + "\\" + name
+	}
+
+	if d.HasChange("snapshot_file_location") {
+		vmStorageMove.SnapshotFilePath = (d.Get("snapshot_file_location")).(string)
+	}
+
+	if d.HasChange("smart_paging_file_path") {
+		vmStorageMove.SmartPagingFilePath = (d.Get("smart_paging_file_path")).(string)
+	}
+
+	if vmStorageMigration.MoveHardDiskDrives && hardDiskDrivesChanged {
+		oldHardDiskDrives, newHardDiskDrives := d.GetChange("hard_disk_drives")
+		vmStorageMove.Vhds, hardDiskDrivesChanged = getVhdMoves(oldHardDiskDrives.([]interface{}), newHardDiskDrives.([]interface{}))
+	}
+
+	return vmStorageMove, hardDiskDrivesChanged
+}
+
+// getMoveTimeout returns how long a move may take, as it has to finish before the update timeout of the resource. It
+// fails when that timeout has already passed.
+func getMoveTimeout(ctx context.Context, d *schema.ResourceData) (timeout uint32, pollPeriod uint32, err error) {
+	timeout = uint32(d.Timeout(schema.TimeoutUpdate).Seconds())
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return 0, 0, context.DeadlineExceeded
+		}
+
+		timeout = uint32(remaining.Seconds())
+	}
+
+	_, pollPeriod, err = api.ExpandVmStateWaitForState(d)
+
+	return timeout, pollPeriod, err
+}
+
+// getVhdMoves matches hard disk drives by controller and returns the virtual hard disks whose path changed. The hard
+// disk drives are still changed when anything besides those paths differs.
+func getVhdMoves(oldHardDiskDrives []interface{}, newHardDiskDrives []interface{}) (vhdMoves []api.VhdMove, hardDiskDrivesChanged bool) {
+	movedHardDiskDrives := make([]interface{}, 0, len(oldHardDiskDrives))
+
+	for index, oldHardDiskDrive := range oldHardDiskDrives {
+		oldHardDiskDrive := oldHardDiskDrive.(map[string]interface{})
+
+		if index >= len(newHardDiskDrives) {
+			movedHardDiskDrives = append(movedHardDiskDrives, oldHardDiskDrive)
+			continue
+		}
+
+		newHardDiskDrive := newHardDiskDrives[index].(map[string]interface{})
+		oldPath := oldHardDiskDrive["path"].(string)
+		newPath := newHardDiskDrive["path"].(string)
+
+		if oldPath == "" || newPath == "" || strings.EqualFold(oldPath, newPath) ||
+			oldHardDiskDrive["controller_type"] != newHardDiskDrive["controller_type"] ||
+			oldHardDiskDrive["controller_number"] != newHardDiskDrive["controller_number"] ||
+			oldHardDiskDrive["controller_location"] != newHardDiskDrive["controller_location"] {
+			movedHardDiskDrives = append(movedHardDiskDrives, oldHardDiskDrive)
+			continue
+		}
+
+		vhdMoves = append(vhdMoves, api.VhdMove{
+			SourceFilePath:      oldPath,
+			DestinationFilePath: newPath,
+		})
+
+		movedHardDiskDrive := map[string]interface{}{}
+		for key, value := range oldHardDiskDrive {
+			movedHardDiskDrive[key] = value
+		}
+		movedHardDiskDrive["path"] = newPath
+		movedHardDiskDrives = append(movedHardDiskDrives, movedHardDiskDrive)
+	}
+
+	return vhdMoves, !reflect.DeepEqual(movedHardDiskDrives, newHardDiskDrives)
+}
+
 func turnOffVmIfOn(ctx context.Context, data *schema.ResourceData, client api.Client, name string) (err error) {
 	vmState, err := client.GetVmStatus(ctx, name)
 	if err != nil {
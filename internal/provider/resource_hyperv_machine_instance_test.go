@@ -0,0 +1,579 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+type testResourceGetter struct {
+	old map[string]interface{}
+	new map[string]interface{}
+}
+
+func (g testResourceGetter) Get(key string) interface{} {
+	return g.new[key]
+}
+
+func (g testResourceGetter) GetChange(key string) (interface{}, interface{}) {
+	return g.old[key], g.new[key]
+}
+
+func (g testResourceGetter) HasChange(key string) bool {
+	return !reflect.DeepEqual(g.old[key], g.new[key])
+}
+
+func testHardDiskDrive(path string, controllerType string, controllerNumber int, controllerLocation int) map[string]interface{} {
+	return map[string]interface{}{
+		"path":                path,
+		"controller_type":     controllerType,
+		"controller_number":   controllerNumber,
+		"controller_location": controllerLocation,
+	}
+}
+
+func TestGetVhdMoves(t *testing.T) {
+	testCases := []struct {
+		name                          string
+		oldHardDiskDrives             []interface{}
+		newHardDiskDrives             []interface{}
+		expectedVhdMoves              []api.VhdMove
+		expectedHardDiskDrivesChanged bool
+	}{
+		{
+			name:              "path only",
+			oldHardDiskDrives: []interface{}{testHardDiskDrive(`C:\vms\web.vhdx`, "Scsi", 0, 0)},
+			newHardDiskDrives: []interface{}{testHardDiskDrive(`D:\vms\web.vhdx`, "Scsi", 0, 0)},
+			expectedVhdMoves: []api.VhdMove{
+				{SourceFilePath: `C:\vms\web.vhdx`, DestinationFilePath: `D:\vms\web.vhdx`},
+			},
+			expectedHardDiskDrivesChanged: false,
+		},
+		{
+			name:                          "path and controller",
+			oldHardDiskDrives:             []interface{}{testHardDiskDrive(`C:\vms\web.vhdx`, "Scsi", 0, 0)},
+			newHardDiskDrives:             []interface{}{testHardDiskDrive(`D:\vms\web.vhdx`, "Scsi", 0, 1)},
+			expectedVhdMoves:              nil,
+			expectedHardDiskDrivesChanged: true,
+		},
+		{
+			name: "path and added drive",
+			oldHardDiskDrives: []interface{}{
+				testHardDiskDrive(`C:\vms\web.vhdx`, "Scsi", 0, 0),
+			},
+			newHardDiskDrives: []interface{}{
+				testHardDiskDrive(`D:\vms\web.vhdx`, "Scsi", 0, 0),
+				testHardDiskDrive(`D:\vms\data.vhdx`, "Scsi", 0, 1),
+			},
+			expectedVhdMoves: []api.VhdMove{
+				{SourceFilePath: `C:\vms\web.vhdx`, DestinationFilePath: `D:\vms\web.vhdx`},
+			},
+			expectedHardDiskDrivesChanged: true,
+		},
+		{
+			name: "path and removed drive",
+			oldHardDiskDrives: []interface{}{
+				testHardDiskDrive(`C:\vms\web.vhdx`, "Scsi", 0, 0),
+				testHardDiskDrive(`C:\vms\data.vhdx`, "Scsi", 0, 1),
+			},
+			newHardDiskDrives: []interface{}{
+				testHardDiskDrive(`D:\vms\web.vhdx`, "Scsi", 0, 0),
+			},
+			expectedVhdMoves: []api.VhdMove{
+				{SourceFilePath: `C:\vms\web.vhdx`, DestinationFilePath: `D:\vms\web.vhdx`},
+			},
+			expectedHardDiskDrivesChanged: true,
+		},
+		{
+			name:                          "path case only",
+			oldHardDiskDrives:             []interface{}{testHardDiskDrive(`C:\vms\web.vhdx`, "Scsi", 0, 0)},
+			newHardDiskDrives:             []interface{}{testHardDiskDrive(`C:\VMs\Web.vhdx`, "Scsi", 0, 0)},
+			expectedVhdMoves:              nil,
+			expectedHardDiskDrivesChanged: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		vhdMoves, hardDiskDrivesChanged := getVhdMoves(testCase.oldHardDiskDrives, testCase.newHardDiskDrives)
+
+		if !reflect.DeepEqual(vhdMoves, testCase.expectedVhdMoves) {
+			t.Errorf("Expected %s to move %#v but moved %#v", testCase.name, testCase.expectedVhdMoves, vhdMoves)
+		}
+
+		if hardDiskDrivesChanged != testCase.expectedHardDiskDrivesChanged {
+			t.Errorf("Expected %s to change hard disk drives %t but was %t", testCase.name, testCase.expectedHardDiskDrivesChanged, hardDiskDrivesChanged)
+		}
+	}
+}
+
+func TestGetVmStorageMove(t *testing.T) {
+	oldHardDiskDrives := []interface{}{testHardDiskDrive(`C:\vms\web.vhdx`, "Scsi", 0, 0)}
+	movedHardDiskDrives := []interface{}{testHardDiskDrive(`D:\vms\web.vhdx`, "Scsi", 0, 0)}
+
+	testCases := []struct {
+		name                          string
+		d                             testResourceGetter
+		vmStorageMigration            api.VmStorageMigration
+		expectedVmStorageMove         api.VmStorageMove
+		expectedHardDiskDrivesChanged bool
+	}{
+		{
+			name: "path only",
+			d: testResourceGetter{
+				old: map[string]interface{}{"path": `C:\vms`, "hard_disk_drives": oldHardDiskDrives},
+				new: map[string]interface{}{"path": `D:\vms\`, "hard_disk_drives": oldHardDiskDrives},
+			},
+			vmStorageMigration: api.VmStorageMigration{MoveHardDiskDrives: true},
+			expectedVmStorageMove: api.VmStorageMove{
+				VirtualMachinePath: `D:\vms\web`,
+			},
+			expectedHardDiskDrivesChanged: false,
+		},
+		{
+			name: "hard disk drive path",
+			d: testResourceGetter{
+				old: map[string]interface{}{"hard_disk_drives": oldHardDiskDrives},
+				new: map[string]interface{}{"hard_disk_drives": movedHardDiskDrives},
+			},
+			vmStorageMigration: api.VmStorageMigration{MoveHardDiskDrives: true, RetainVhdCopiesOnSource: true},
+			expectedVmStorageMove: api.VmStorageMove{
+				Vhds: []api.VhdMove{
+					{SourceFilePath: `C:\vms\web.vhdx`, DestinationFilePath: `D:\vms\web.vhdx`},
+				},
+				RetainVhdCopiesOnSource: true,
+			},
+			expectedHardDiskDrivesChanged: false,
+		},
+		{
+			name: "hard disk drive path without moving hard disk drives",
+			d: testResourceGetter{
+				old: map[string]interface{}{"hard_disk_drives": oldHardDiskDrives},
+				new: map[string]interface{}{"hard_disk_drives": movedHardDiskDrives},
+			},
+			vmStorageMigration:            api.VmStorageMigration{},
+			expectedVmStorageMove:         api.VmStorageMove{},
+			expectedHardDiskDrivesChanged: true,
+		},
+		{
+			name: "snapshot file location and smart paging file path",
+			d: testResourceGetter{
+				old: map[string]interface{}{"snapshot_file_location": `C:\snapshots`, "smart_paging_file_path": `C:\paging`},
+				new: map[string]interface{}{"snapshot_file_location": `D:\snapshots`, "smart_paging_file_path": `D:\paging`},
+			},
+			expectedVmStorageMove: api.VmStorageMove{
+				SnapshotFilePath:    `D:\snapshots`,
+				SmartPagingFilePath: `D:\paging`,
+			},
+			expectedHardDiskDrivesChanged: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		vmStorageMove, hardDiskDrivesChanged := getVmStorageMove(testCase.d, "web", testCase.vmStorageMigration)
+
+		if !reflect.DeepEqual(vmStorageMove, testCase.expectedVmStorageMove) {
+			t.Errorf("Expected %s to move %#v but moved %#v", testCase.name, testCase.expectedVmStorageMove, vmStorageMove)
+		}
+
+		if hardDiskDrivesChanged != testCase.expectedHardDiskDrivesChanged {
+			t.Errorf("Expected %s to change hard disk drives %t but was %t", testCase.name, testCase.expectedHardDiskDrivesChanged, hardDiskDrivesChanged)
+		}
+	}
+}
+
+func TestGetMoveTimeout(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceHyperVMachineInstance().Schema, map[string]interface{}{
+		"name": "web",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	timeout, _, err := getMoveTimeout(ctx, d)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if timeout == 0 || timeout > uint32(time.Hour.Seconds()) {
+		t.Errorf("Expected timeout to be at most an hour but was %d", timeout)
+	}
+
+	expiredCtx, expiredCancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Minute))
+	defer expiredCancel()
+
+	timeout, _, err = getMoveTimeout(expiredCtx, d)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected an expired deadline to fail with %v but got %v", context.DeadlineExceeded, err)
+	}
+	if timeout != 0 {
+		t.Errorf("Expected an expired deadline to have no timeout but was %d", timeout)
+	}
+}
+
+type testResourceDiffGetter struct {
+	old     map[string]interface{}
+	values  map[string]interface{}
+	unknown map[string]bool
+}
+
+func (g testResourceDiffGetter) Get(key string) interface{} {
+	return g.values[key]
+}
+
+func (g testResourceDiffGetter) GetChange(key string) (interface{}, interface{}) {
+	return g.old[key], g.values[key]
+}
+
+func (g testResourceDiffGetter) NewValueKnown(key string) bool {
+	return !g.unknown[key]
+}
+
+func testMachineInstanceDiff(values map[string]interface{}) testResourceDiffGetter {
+	diff := testResourceDiffGetter{
+		values: map[string]interface{}{
+			"generation":           2,
+			"hard_disk_drives":     []interface{}{},
+			"dvd_drives":           []interface{}{},
+			"com_ports":            []interface{}{},
+			"static_memory":        false,
+			"memory_minimum_bytes": DefaultMemoryMinimumBytes,
+			"memory_startup_bytes": DefaultMemoryMinimumBytes,
+			"memory_maximum_bytes": DefaultMemoryMaximumBytes,
+		},
+		old:     map[string]interface{}{},
+		unknown: map[string]bool{},
+	}
+
+	for key, value := range values {
+		diff.values[key] = value
+	}
+
+	return diff
+}
+
+func checkPathError(t *testing.T, name string, err error, expectedPath cty.Path) {
+	t.Helper()
+
+	if expectedPath == nil {
+		if err != nil {
+			t.Errorf("Expected %s to be valid but got %v", name, err)
+		}
+		return
+	}
+
+	var pathErr cty.PathError
+	if !errors.As(err, &pathErr) {
+		t.Errorf("Expected %s to fail with a path error but got %v", name, err)
+		return
+	}
+
+	if !pathErr.Path.Equals(expectedPath) {
+		t.Errorf("Expected %s to fail at %#v but failed at %#v: %v", name, expectedPath, pathErr.Path, err)
+	}
+}
+
+func TestValidateDriveAddress(t *testing.T) {
+	path := cty.GetAttrPath("hard_disk_drives").IndexInt(1)
+
+	testCases := []struct {
+		name         string
+		address      driveAddress
+		expectedPath cty.Path
+	}{
+		{"free Scsi location", driveAddress{api.ControllerType_Scsi, 0, 1}, nil},
+		{"last Scsi location", driveAddress{api.ControllerType_Scsi, MaxScsiControllers - 1, MaxScsiControllerLocations - 1}, nil},
+		{"used Scsi location", driveAddress{api.ControllerType_Scsi, 0, 0}, path.GetAttr("controller_location")},
+		{"out of range Scsi location", driveAddress{api.ControllerType_Scsi, 0, 64}, path.GetAttr("controller_location")},
+		{"negative Scsi location", driveAddress{api.ControllerType_Scsi, 0, -1}, path.GetAttr("controller_location")},
+		{"out of range Scsi controller", driveAddress{api.ControllerType_Scsi, MaxScsiControllers, 0}, path.GetAttr("controller_number")},
+		{"free Ide location", driveAddress{api.ControllerType_Ide, 1, 1}, nil},
+		{"used Ide location", driveAddress{api.ControllerType_Ide, 0, 0}, path.GetAttr("controller_location")},
+		{"out of range Ide location", driveAddress{api.ControllerType_Ide, 0, 2}, path.GetAttr("controller_location")},
+		{"out of range Ide controller", driveAddress{api.ControllerType_Ide, 2, 0}, path.GetAttr("controller_number")},
+	}
+
+	for _, testCase := range testCases {
+		driveAddresses := map[driveAddress]string{
+			{api.ControllerType_Scsi, 0, 0}: "hard_disk_drives.0",
+			{api.ControllerType_Ide, 0, 0}:  "dvd_drives.0",
+		}
+
+		err := validateDriveAddress(driveAddresses, path, "hard_disk_drives.1", testCase.address)
+		checkPathError(t, testCase.name, err, testCase.expectedPath)
+
+		if testCase.expectedPath == nil && driveAddresses[testCase.address] != "hard_disk_drives.1" {
+			t.Errorf("Expected %s to be recorded as used", testCase.name)
+		}
+	}
+}
+
+func TestValidateMemory(t *testing.T) {
+	testCases := []struct {
+		name         string
+		values       map[string]interface{}
+		unknown      string
+		expectedPath cty.Path
+	}{
+		{
+			name:   "dynamic memory",
+			values: map[string]interface{}{"memory_startup_bytes": 1073741824},
+		},
+		{
+			name:   "static memory with default bounds",
+			values: map[string]interface{}{"static_memory": true, "memory_startup_bytes": 4294967296},
+		},
+		{
+			name:         "static memory with minimum",
+			values:       map[string]interface{}{"static_memory": true, "memory_minimum_bytes": 1073741824, "memory_startup_bytes": 1073741824},
+			expectedPath: cty.GetAttrPath("memory_minimum_bytes"),
+		},
+		{
+			name:         "static memory with maximum",
+			values:       map[string]interface{}{"static_memory": true, "memory_maximum_bytes": 4294967296},
+			expectedPath: cty.GetAttrPath("memory_maximum_bytes"),
+		},
+		{
+			name:         "minimum more than startup",
+			values:       map[string]interface{}{"memory_minimum_bytes": 1073741824, "memory_startup_bytes": 536870912},
+			expectedPath: cty.GetAttrPath("memory_minimum_bytes"),
+		},
+		{
+			name:         "startup more than maximum",
+			values:       map[string]interface{}{"memory_startup_bytes": 4294967296, "memory_maximum_bytes": 2147483648},
+			expectedPath: cty.GetAttrPath("memory_maximum_bytes"),
+		},
+		{
+			name:         "minimum more than startup more than maximum",
+			values:       map[string]interface{}{"memory_minimum_bytes": 4294967296, "memory_startup_bytes": 2147483648, "memory_maximum_bytes": 1073741824},
+			expectedPath: cty.GetAttrPath("memory_minimum_bytes"),
+		},
+		{
+			name:         "startup not aligned to 2 MB",
+			values:       map[string]interface{}{"memory_startup_bytes": 536870912 + 1048576},
+			expectedPath: cty.GetAttrPath("memory_startup_bytes"),
+		},
+		{
+			name:         "maximum not aligned to 2 MB",
+			values:       map[string]interface{}{"memory_maximum_bytes": 1073741823},
+			expectedPath: cty.GetAttrPath("memory_maximum_bytes"),
+		},
+		{
+			name:    "unknown startup",
+			values:  map[string]interface{}{"memory_minimum_bytes": 1073741824},
+			unknown: "memory_startup_bytes",
+		},
+	}
+
+	for _, testCase := range testCases {
+		diff := testMachineInstanceDiff(testCase.values)
+		if testCase.unknown != "" {
+			diff.unknown[testCase.unknown] = true
+		}
+
+		checkPathError(t, testCase.name, validateMemory(diff), testCase.expectedPath)
+	}
+}
+
+func TestValidateTopology(t *testing.T) {
+	testCases := []struct {
+		name         string
+		values       map[string]interface{}
+		expectedPath cty.Path
+	}{
+		{
+			name: "generation 2 drives",
+			values: map[string]interface{}{
+				"hard_disk_drives": []interface{}{testHardDiskDrive(`C:\vms\web.vhdx`, "Scsi", 0, 0)},
+				"dvd_drives":       []interface{}{map[string]interface{}{"controller_number": 0, "controller_location": 1}},
+			},
+		},
+		{
+			name: "generation 1 drives",
+			values: map[string]interface{}{
+				"generation":       1,
+				"hard_disk_drives": []interface{}{testHardDiskDrive(`C:\vms\web.vhdx`, "Ide", 0, 0)},
+				"dvd_drives":       []interface{}{map[string]interface{}{"controller_number": 1, "controller_location": 0}},
+			},
+		},
+		{
+			name: "Ide controller on generation 2",
+			values: map[string]interface{}{
+				"hard_disk_drives": []interface{}{testHardDiskDrive(`C:\vms\web.vhdx`, "Ide", 0, 0)},
+			},
+			expectedPath: cty.GetAttrPath("hard_disk_drives").IndexInt(0).GetAttr("controller_type"),
+		},
+		{
+			name: "duplicate hard disk drive location",
+			values: map[string]interface{}{
+				"hard_disk_drives": []interface{}{
+					testHardDiskDrive(`C:\vms\web.vhdx`, "Scsi", 0, 0),
+					testHardDiskDrive(`C:\vms\data.vhdx`, "Scsi", 0, 0),
+				},
+			},
+			expectedPath: cty.GetAttrPath("hard_disk_drives").IndexInt(1).GetAttr("controller_location"),
+		},
+		{
+			name: "dvd drive on a hard disk drive location",
+			values: map[string]interface{}{
+				"hard_disk_drives": []interface{}{testHardDiskDrive(`C:\vms\web.vhdx`, "Scsi", 0, 0)},
+				"dvd_drives":       []interface{}{map[string]interface{}{"controller_number": 0, "controller_location": 0}},
+			},
+			expectedPath: cty.GetAttrPath("dvd_drives").IndexInt(0).GetAttr("controller_location"),
+		},
+		{
+			name: "out of range Scsi location",
+			values: map[string]interface{}{
+				"hard_disk_drives": []interface{}{testHardDiskDrive(`C:\vms\web.vhdx`, "Scsi", 0, 64)},
+			},
+			expectedPath: cty.GetAttrPath("hard_disk_drives").IndexInt(0).GetAttr("controller_location"),
+		},
+		{
+			name: "out of range Ide dvd drive location",
+			values: map[string]interface{}{
+				"generation": 1,
+				"dvd_drives": []interface{}{map[string]interface{}{"controller_number": 0, "controller_location": 2}},
+			},
+			expectedPath: cty.GetAttrPath("dvd_drives").IndexInt(0).GetAttr("controller_location"),
+		},
+		{
+			name: "duplicate com port",
+			values: map[string]interface{}{
+				"com_ports": []interface{}{
+					map[string]interface{}{"number": 1},
+					map[string]interface{}{"number": 1},
+				},
+			},
+			expectedPath: cty.GetAttrPath("com_ports").IndexInt(1).GetAttr("number"),
+		},
+		{
+			name: "com ports in order of number",
+			values: map[string]interface{}{
+				"com_ports": []interface{}{
+					map[string]interface{}{"number": 1},
+					map[string]interface{}{"number": 2},
+				},
+			},
+		},
+		{
+			name: "com ports out of order",
+			values: map[string]interface{}{
+				"com_ports": []interface{}{
+					map[string]interface{}{"number": 2},
+					map[string]interface{}{"number": 1},
+				},
+			},
+			expectedPath: cty.GetAttrPath("com_ports").IndexInt(1).GetAttr("number"),
+		},
+		{
+			name: "inconsistent memory",
+			values: map[string]interface{}{
+				"static_memory":        true,
+				"memory_maximum_bytes": 4294967296,
+			},
+			expectedPath: cty.GetAttrPath("memory_maximum_bytes"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		checkPathError(t, testCase.name, validateTopology(testMachineInstanceDiff(testCase.values)), testCase.expectedPath)
+	}
+}
+
+func testMachineInstanceFirmwareDiff(oldKeyProtector string, firmware map[string]interface{}) testResourceDiffGetter {
+	vmFirmware := map[string]interface{}{
+		"tpm_enabled":                            false,
+		"key_protector":                          "None",
+		"hgs_guardian_name":                      "",
+		"encrypt_state_and_vm_migration_traffic": false,
+		"shielded":                               false,
+	}
+	for key, value := range firmware {
+		vmFirmware[key] = value
+	}
+
+	values := map[string]interface{}{"vm_firmware": []interface{}{vmFirmware}}
+	for key, value := range vmFirmware {
+		values["vm_firmware.0."+key] = value
+	}
+
+	diff := testMachineInstanceDiff(values)
+	if oldKeyProtector != "" {
+		diff.old["vm_firmware.0.key_protector"] = oldKeyProtector
+	}
+
+	return diff
+}
+
+func TestValidateFirmwareSecurity(t *testing.T) {
+	path := cty.GetAttrPath("vm_firmware").IndexInt(0)
+
+	testCases := []struct {
+		name            string
+		oldKeyProtector string
+		firmware        map[string]interface{}
+		expectedPath    cty.Path
+	}{
+		{
+			name:     "no key protector",
+			firmware: map[string]interface{}{},
+		},
+		{
+			name:     "tpm with local key protector",
+			firmware: map[string]interface{}{"key_protector": "NewLocalKeyProtector", "tpm_enabled": true, "shielded": true, "encrypt_state_and_vm_migration_traffic": true},
+		},
+		{
+			name:     "hgs guardian",
+			firmware: map[string]interface{}{"key_protector": "HgsGuardian", "hgs_guardian_name": "Guardian"},
+		},
+		{
+			name:            "kept key protector",
+			oldKeyProtector: "NewLocalKeyProtector",
+			firmware:        map[string]interface{}{"key_protector": "newlocalkeyprotector"},
+		},
+		{
+			name:            "removed key protector",
+			oldKeyProtector: "NewLocalKeyProtector",
+			firmware:        map[string]interface{}{"key_protector": "None"},
+			expectedPath:    path.GetAttr("key_protector"),
+		},
+		{
+			name:         "tpm without key protector",
+			firmware:     map[string]interface{}{"tpm_enabled": true},
+			expectedPath: path.GetAttr("tpm_enabled"),
+		},
+		{
+			name:         "encrypted state without key protector",
+			firmware:     map[string]interface{}{"encrypt_state_and_vm_migration_traffic": true},
+			expectedPath: path.GetAttr("encrypt_state_and_vm_migration_traffic"),
+		},
+		{
+			name:         "shielded without key protector",
+			firmware:     map[string]interface{}{"shielded": true},
+			expectedPath: path.GetAttr("shielded"),
+		},
+		{
+			name:         "shielded without tpm",
+			firmware:     map[string]interface{}{"key_protector": "NewLocalKeyProtector", "shielded": true},
+			expectedPath: path.GetAttr("shielded"),
+		},
+		{
+			name:         "hgs guardian without name",
+			firmware:     map[string]interface{}{"key_protector": "HgsGuardian"},
+			expectedPath: path.GetAttr("hgs_guardian_name"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		diff := testMachineInstanceFirmwareDiff(testCase.oldKeyProtector, testCase.firmware)
+		checkPathError(t, testCase.name, validateFirmwareSecurity(diff), testCase.expectedPath)
+	}
+
+	diff := testMachineInstanceFirmwareDiff("", map[string]interface{}{"tpm_enabled": true})
+	diff.values["generation"] = 1
+	checkPathError(t, "generation 1", validateFirmwareSecurity(diff), nil)
+}
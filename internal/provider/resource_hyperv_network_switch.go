@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -255,17 +256,17 @@ func resourceHyperVNetworkSwitchRead(ctx context.Context, d *schema.ResourceData
 	name := d.Id()
 
 	s, err := c.GetVMSwitch(ctx, name)
+	if !d.IsNewResource() && errors.Is(err, api.ErrNotFound) {
+		log.Printf("[INFO][hyperv][read] unable to read hyperv switch as it does not exist: %#v", name)
+		d.SetId("")
+		return nil
+	}
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
 	log.Printf("[INFO][hyperv][read] retrieved network switch: %+v", s)
 
-	if s.Name != name {
-		log.Printf("[INFO][hyperv][read] unable to read hyperv switch as it does not exist: %#v", name)
-		return nil
-	}
-
 	if err := d.Set("name", s.Name); err != nil {
 		return diag.FromErr(err)
 	}
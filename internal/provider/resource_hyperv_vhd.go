@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"path"
@@ -254,7 +255,7 @@ func resourceHyperVVhdRead(ctx context.Context, d *schema.ResourceData, meta int
 	path := d.Id()
 
 	vhd, err := c.GetVhd(ctx, path)
-	if err != nil {
+	if err != nil && !errors.Is(err, api.ErrNotFound) {
 		return diag.FromErr(err)
 	}
 
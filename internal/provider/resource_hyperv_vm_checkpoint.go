@@ -0,0 +1,228 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+const (
+	ReadVmCheckpointTimeout   = 1 * time.Minute
+	CreateVmCheckpointTimeout = 10 * time.Minute
+	UpdateVmCheckpointTimeout = 10 * time.Minute
+	DeleteVmCheckpointTimeout = 10 * time.Minute
+)
+
+func resourceHyperVVmCheckpoint() *schema.Resource {
+	return &schema.Resource{
+		Description: "This Hyper-V resource allows you to manage checkpoints of a virtual machine.",
+		Timeouts: &schema.ResourceTimeout{
+			Read:   schema.DefaultTimeout(ReadVmCheckpointTimeout),
+			Create: schema.DefaultTimeout(CreateVmCheckpointTimeout),
+			Update: schema.DefaultTimeout(UpdateVmCheckpointTimeout),
+			Delete: schema.DefaultTimeout(DeleteVmCheckpointTimeout),
+		},
+		CreateContext: resourceHyperVVmCheckpointCreate,
+		ReadContext:   resourceHyperVVmCheckpointRead,
+		UpdateContext: resourceHyperVVmCheckpointUpdate,
+		DeleteContext: resourceHyperVVmCheckpointDelete,
+		CustomizeDiff: resourceHyperVVmCheckpointCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Schema: map[string]*schema.Schema{
+			"vm_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Specifies the name of the virtual machine to checkpoint.",
+			},
+
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Specifies the name of the checkpoint. It must be unique for the virtual machine. Changing it renames the checkpoint.",
+			},
+
+			"restore_on_apply": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Restore the virtual machine to this checkpoint on every apply. Anything that happened in the virtual machine after the checkpoint was created is lost.",
+			},
+
+			"delete_child_checkpoints": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Delete the checkpoints that were created after this checkpoint when this checkpoint is deleted. Otherwise they are merged and remain.",
+			},
+
+			"checkpoint_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The Hyper-V id of the checkpoint.",
+			},
+
+			"parent_checkpoint_name": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The name of the checkpoint this checkpoint was created from. Empty for the first checkpoint of the virtual machine.",
+			},
+
+			"checkpoint_type": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The type of the checkpoint, this is controlled by the `checkpoint_type` of the virtual machine.",
+			},
+
+			"creation_time": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The time the checkpoint was created, in RFC 3339 format.",
+			},
+
+			"last_restored_time": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The time the virtual machine was last restored to the checkpoint by `restore_on_apply`, in RFC 3339 format.",
+			},
+		},
+	}
+}
+
+// resourceHyperVVmCheckpointCustomizeDiff plans a restore on every apply when restore_on_apply is set
+func resourceHyperVVmCheckpointCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || !d.Get("restore_on_apply").(bool) {
+		return nil
+	}
+
+	return d.SetNewComputed("last_restored_time")
+}
+
+func resourceHyperVVmCheckpointCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][create] creating hyperv vm checkpoint: %#v", d)
+	c := meta.(api.Client)
+
+	vmName := (d.Get("vm_name")).(string)
+	name := (d.Get("name")).(string)
+
+	err := c.CreateVmCheckpoint(ctx, vmName, name)
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(api.VmCheckpointId(vmName, name))
+	log.Printf("[INFO][hyperv][create] created hyperv vm checkpoint: %#v", d)
+
+	return resourceHyperVVmCheckpointRead(ctx, d, meta)
+}
+
+func resourceHyperVVmCheckpointRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][read] reading hyperv vm checkpoint: %#v", d)
+	c := meta.(api.Client)
+
+	vmName, name, err := api.ParseVmCheckpointId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	s, err := c.GetVmCheckpoint(ctx, vmName, name)
+	if !d.IsNewResource() && errors.Is(err, api.ErrNotFound) {
+		log.Printf("[INFO][hyperv][read] unable to read hyperv vm checkpoint as it does not exist: %#v", d.Id())
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][read] retrieved vm checkpoint: %+v", s)
+
+	if err := d.Set("vm_name", s.VmName); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("name", s.Name); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("checkpoint_id", s.Id); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("parent_checkpoint_name", s.ParentCheckpointName); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("checkpoint_type", s.CheckpointType); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("creation_time", s.CreationTime); err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][read] read hyperv vm checkpoint: %#v", d)
+
+	return nil
+}
+
+func resourceHyperVVmCheckpointUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][update] updating hyperv vm checkpoint: %#v", d)
+	c := meta.(api.Client)
+
+	vmName, oldName, err := api.ParseVmCheckpointId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	name := (d.Get("name")).(string)
+
+	if name != oldName {
+		err = c.RenameVmCheckpoint(ctx, vmName, oldName, name)
+
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		d.SetId(api.VmCheckpointId(vmName, name))
+	}
+
+	if (d.Get("restore_on_apply")).(bool) {
+		err = c.RestoreVmCheckpoint(ctx, vmName, name)
+
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		if err := d.Set("last_restored_time", time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	log.Printf("[INFO][hyperv][update] updated hyperv vm checkpoint: %#v", d)
+
+	return resourceHyperVVmCheckpointRead(ctx, d, meta)
+}
+
+func resourceHyperVVmCheckpointDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][delete] deleting hyperv vm checkpoint: %#v", d)
+
+	c := meta.(api.Client)
+
+	vmName, name, err := api.ParseVmCheckpointId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	err = c.DeleteVmCheckpoint(ctx, vmName, name, (d.Get("delete_child_checkpoints")).(bool))
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][delete] deleted hyperv vm checkpoint: %#v", d)
+	return nil
+}
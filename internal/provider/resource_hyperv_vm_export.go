@@ -0,0 +1,172 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+const (
+	ReadVmExportTimeout   = 1 * time.Minute
+	CreateVmExportTimeout = 60 * time.Minute
+	DeleteVmExportTimeout = 10 * time.Minute
+)
+
+func resourceHyperVVmExport() *schema.Resource {
+	return &schema.Resource{
+		Description: "This Hyper-V resource allows you to export a virtual machine, or one of its checkpoints, to a folder on the Hyper-V host. The export can be imported with the `import_source` of `hyperv_machine_instance`.",
+		Timeouts: &schema.ResourceTimeout{
+			Read:   schema.DefaultTimeout(ReadVmExportTimeout),
+			Create: schema.DefaultTimeout(CreateVmExportTimeout),
+			Delete: schema.DefaultTimeout(DeleteVmExportTimeout),
+		},
+		CreateContext: resourceHyperVVmExportCreate,
+		ReadContext:   resourceHyperVVmExportRead,
+		DeleteContext: resourceHyperVVmExportDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Schema: map[string]*schema.Schema{
+			"vm_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Specifies the name of the virtual machine to export.",
+			},
+
+			"path": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+				DiffSuppressFunc: func(k, oldValue, newValue string, d *schema.ResourceData) bool {
+					// The id drops the trailing \ of path, so an imported export only has the path without it
+					return strings.TrimRight(oldValue, `\`) == strings.TrimRight(newValue, `\`)
+				},
+				Description: "Specifies the folder to export the virtual machine to. The export is written to a sub folder named after the virtual machine, which must not exist yet.",
+			},
+
+			"checkpoint_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "",
+				Description: "Specifies the name of a checkpoint to export instead of the current state of the virtual machine.",
+			},
+
+			"export_path": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The folder the virtual machine was exported to.",
+			},
+
+			"vmcx_path": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The path of the exported virtual machine configuration file.",
+			},
+		},
+	}
+}
+
+// vmExportId is the folder Export-VM writes to, so an existing export can be imported by its path
+func vmExportId(vmName string, path string) string {
+	return strings.TrimRight(path, `\`) + `\` + vmName
+}
+
+func parseVmExportId(id string) (vmName string, path string, err error) {
+	index := strings.LastIndex(id, `\`)
+	if index <= 0 || index == len(id)-1 {
+		return "", "", fmt.Errorf("export id %q should be in the format <path>\\<vm_name>", id)
+	}
+
+	return id[index+1:], id[:index], nil
+}
+
+func resourceHyperVVmExportCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][create] creating hyperv vm export: %#v", d)
+	c := meta.(api.Client)
+
+	vmName := (d.Get("vm_name")).(string)
+	path := (d.Get("path")).(string)
+	checkpointName := (d.Get("checkpoint_name")).(string)
+
+	err := c.ExportVm(ctx, vmName, path, checkpointName)
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(vmExportId(vmName, path))
+	log.Printf("[INFO][hyperv][create] created hyperv vm export: %#v", d)
+
+	return resourceHyperVVmExportRead(ctx, d, meta)
+}
+
+func resourceHyperVVmExportRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][read] reading hyperv vm export: %#v", d)
+	c := meta.(api.Client)
+
+	vmName, path, err := parseVmExportId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	s, err := c.GetVmExport(ctx, vmName, path)
+	if !d.IsNewResource() && errors.Is(err, api.ErrNotFound) {
+		log.Printf("[INFO][hyperv][read] unable to read hyperv vm export as it does not exist: %#v", d.Id())
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][read] retrieved vm export: %+v", s)
+
+	if err := d.Set("vm_name", s.VmName); err != nil {
+		return diag.FromErr(err)
+	}
+	// The configured path is kept, the path in the id doesn't have its trailing \
+	if _, ok := d.GetOk("path"); !ok {
+		if err := d.Set("path", s.Path); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	if err := d.Set("export_path", s.ExportPath); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("vmcx_path", s.VmcxPath); err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][read] read hyperv vm export: %#v", d)
+
+	return nil
+}
+
+func resourceHyperVVmExportDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][delete] deleting hyperv vm export: %#v", d)
+
+	c := meta.(api.Client)
+
+	vmName, path, err := parseVmExportId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	err = c.DeleteVmExport(ctx, vmName, path)
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][delete] deleted hyperv vm export: %#v", d)
+	return nil
+}
@@ -0,0 +1,191 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+const (
+	ReadVmGuestFileTimeout   = 1 * time.Minute
+	CreateVmGuestFileTimeout = 10 * time.Minute
+	UpdateVmGuestFileTimeout = 10 * time.Minute
+	DeleteVmGuestFileTimeout = 1 * time.Minute
+)
+
+func resourceHyperVVmGuestFile() *schema.Resource {
+	return &schema.Resource{
+		Description: "This Hyper-V resource allows you to copy a local file into the guest of a running virtual machine with Copy-VMFile. The file is uploaded to the Hyper-V host and copied into the guest by the Guest Service Interface integration service, which is enabled when it is not. Destroying the resource leaves the file in the guest.",
+		Timeouts: &schema.ResourceTimeout{
+			Read:   schema.DefaultTimeout(ReadVmGuestFileTimeout),
+			Create: schema.DefaultTimeout(CreateVmGuestFileTimeout),
+			Update: schema.DefaultTimeout(UpdateVmGuestFileTimeout),
+			Delete: schema.DefaultTimeout(DeleteVmGuestFileTimeout),
+		},
+		CreateContext: resourceHyperVVmGuestFileCreate,
+		ReadContext:   resourceHyperVVmGuestFileRead,
+		UpdateContext: resourceHyperVVmGuestFileUpdate,
+		DeleteContext: resourceHyperVVmGuestFileDelete,
+		CustomizeDiff: resourceHyperVVmGuestFileCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Schema: map[string]*schema.Schema{
+			"vm_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Specifies the name of the virtual machine to copy the file into. The virtual machine must be running.",
+			},
+
+			"source": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Local path of the file to copy into the guest.",
+			},
+
+			"destination_path": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Path of the file in the guest. An existing file is overwritten.",
+			},
+
+			"create_full_path": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Create the directories of `destination_path` in the guest when they do not exist.",
+			},
+
+			"content_hash": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The SHA-256 of the content of `source`. A change of the content copies the file into the guest again.",
+			},
+		},
+	}
+}
+
+// resourceHyperVVmGuestFileCustomizeDiff plans a copy when the content of source changed
+func resourceHyperVVmGuestFileCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("source") {
+		return d.SetNewComputed("content_hash")
+	}
+
+	contentHash, err := api.VmGuestFileContentHash((d.Get("source")).(string))
+	if errors.Is(err, os.ErrNotExist) {
+		// The file may be created by another resource during apply
+		return d.SetNewComputed("content_hash")
+	}
+	if err != nil {
+		return err
+	}
+
+	if contentHash != (d.Get("content_hash")).(string) {
+		return d.SetNew("content_hash", contentHash)
+	}
+
+	return nil
+}
+
+func copyVmGuestFile(ctx context.Context, d *schema.ResourceData, c api.Client) error {
+	vmName := (d.Get("vm_name")).(string)
+	source := (d.Get("source")).(string)
+	destinationPath := (d.Get("destination_path")).(string)
+	createFullPath := (d.Get("create_full_path")).(bool)
+
+	contentHash, err := api.VmGuestFileContentHash(source)
+	if err != nil {
+		return err
+	}
+
+	err = c.CopyVmGuestFile(ctx, vmName, source, destinationPath, createFullPath)
+	if err != nil {
+		return err
+	}
+
+	return d.Set("content_hash", contentHash)
+}
+
+func resourceHyperVVmGuestFileCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][create] creating hyperv vm guest file: %#v", d)
+	c := meta.(api.Client)
+
+	vmName := (d.Get("vm_name")).(string)
+	destinationPath := (d.Get("destination_path")).(string)
+
+	err := copyVmGuestFile(ctx, d, c)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(api.VmGuestFileId(vmName, destinationPath))
+	log.Printf("[INFO][hyperv][create] created hyperv vm guest file: %#v", d)
+
+	return resourceHyperVVmGuestFileRead(ctx, d, meta)
+}
+
+func resourceHyperVVmGuestFileRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][read] reading hyperv vm guest file: %#v", d)
+	c := meta.(api.Client)
+
+	vmName, destinationPath, err := api.ParseVmGuestFileId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// Copy-VMFile is only able to copy files into the guest, so the file is tracked for as long as the vm exists
+	_, err = c.GetVm(ctx, vmName)
+	if !d.IsNewResource() && errors.Is(err, api.ErrNotFound) {
+		log.Printf("[INFO][hyperv][read] unable to read hyperv vm guest file as the vm does not exist: %#v", vmName)
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("vm_name", vmName); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("destination_path", destinationPath); err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][read] read hyperv vm guest file: %#v", d)
+
+	return nil
+}
+
+func resourceHyperVVmGuestFileUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][update] updating hyperv vm guest file: %#v", d)
+	c := meta.(api.Client)
+
+	if d.HasChanges("source", "create_full_path", "content_hash") {
+		err := copyVmGuestFile(ctx, d, c)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	log.Printf("[INFO][hyperv][update] updated hyperv vm guest file: %#v", d)
+
+	return resourceHyperVVmGuestFileRead(ctx, d, meta)
+}
+
+func resourceHyperVVmGuestFileDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][delete] deleting hyperv vm guest file: %#v", d)
+
+	// Copy-VMFile is not able to delete files from the guest, so the file is left where it is
+	d.SetId("")
+
+	log.Printf("[INFO][hyperv][delete] deleted hyperv vm guest file: %#v", d)
+	return nil
+}
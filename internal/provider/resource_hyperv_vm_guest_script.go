@@ -0,0 +1,263 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/id"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/taliesins/terraform-provider-hyperv/api"
+	"github.com/taliesins/terraform-provider-hyperv/powershell"
+)
+
+const (
+	ReadVmGuestScriptTimeout   = 1 * time.Minute
+	CreateVmGuestScriptTimeout = 30 * time.Minute
+	UpdateVmGuestScriptTimeout = 1 * time.Minute
+	DeleteVmGuestScriptTimeout = 1 * time.Minute
+)
+
+func resourceHyperVVmGuestScript() *schema.Resource {
+	return &schema.Resource{
+		Description: "This Hyper-V resource allows you to run a script inside the Windows guest of a running virtual machine over PowerShell Direct, so the guest does not need a network connection. The script runs when the resource is created and again when `inline`, `script`, the content of `script` or `triggers` change. Destroying the resource does not run anything in the guest.",
+		Timeouts: &schema.ResourceTimeout{
+			Read:   schema.DefaultTimeout(ReadVmGuestScriptTimeout),
+			Create: schema.DefaultTimeout(CreateVmGuestScriptTimeout),
+			Update: schema.DefaultTimeout(UpdateVmGuestScriptTimeout),
+			Delete: schema.DefaultTimeout(DeleteVmGuestScriptTimeout),
+		},
+		CreateContext: resourceHyperVVmGuestScriptCreate,
+		ReadContext:   resourceHyperVVmGuestScriptRead,
+		UpdateContext: resourceHyperVVmGuestScriptUpdate,
+		DeleteContext: resourceHyperVVmGuestScriptDelete,
+		CustomizeDiff: resourceHyperVVmGuestScriptCustomizeDiff,
+		Schema: map[string]*schema.Schema{
+			"vm_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Specifies the name of the virtual machine to run the script in. The virtual machine must be running.",
+			},
+
+			"username": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The user to sign in to the guest with, for example `Administrator` or `domain\\user`.",
+			},
+
+			"password": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Sensitive:   true,
+				Description: "The password of `username`. It is masked in logs and error messages.",
+			},
+
+			"inline": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				ExactlyOneOf: []string{"inline", "script"},
+				Description:  "PowerShell commands to run in the guest, one command per line.",
+			},
+
+			"script": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ExactlyOneOf: []string{"inline", "script"},
+				Description:  "Local path of a PowerShell script to run in the guest. The script is read locally and passed to the guest, a change of its content runs it again.",
+			},
+
+			"triggers": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Description: "Arbitrary values that run the script again when they change.",
+			},
+
+			"ignore_exit_code": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Do not fail when the script exits with a code other than 0.",
+			},
+
+			"exit_code": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The exit code of the script.",
+			},
+
+			"stdout": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The standard output of the script.",
+			},
+
+			"stderr": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The standard error of the script.",
+			},
+
+			"script_hash": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The SHA-256 of the content of `script`. A change of the content runs the script again.",
+			},
+		},
+	}
+}
+
+// resourceHyperVVmGuestScriptCustomizeDiff runs the script again when the content of script changed
+func resourceHyperVVmGuestScriptCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("script") {
+		return d.SetNewComputed("script_hash")
+	}
+
+	scriptHash := ""
+	if script := (d.Get("script")).(string); script != "" {
+		var err error
+		scriptHash, err = api.VmGuestFileContentHash(script)
+		if errors.Is(err, os.ErrNotExist) {
+			// The script may be created by another resource during apply
+			return d.SetNewComputed("script_hash")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if scriptHash == (d.Get("script_hash")).(string) {
+		return nil
+	}
+
+	if err := d.SetNew("script_hash", scriptHash); err != nil {
+		return err
+	}
+
+	if d.Id() == "" {
+		return nil
+	}
+
+	return d.ForceNew("script_hash")
+}
+
+func resourceHyperVVmGuestScriptCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][create] creating hyperv vm guest script: %#v", d)
+	c := meta.(api.Client)
+
+	vmName := (d.Get("vm_name")).(string)
+	username := (d.Get("username")).(string)
+	password := (d.Get("password")).(string)
+	ignoreExitCode := (d.Get("ignore_exit_code")).(bool)
+
+	powershell.RegisterSecret(password)
+
+	var script string
+	var scriptHash string
+	if v, ok := d.GetOk("script"); ok {
+		content, err := os.ReadFile(v.(string))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		script = string(content)
+		hash := sha256.Sum256(content)
+		scriptHash = hex.EncodeToString(hash[:])
+	} else {
+		inline := make([]string, 0)
+		for _, command := range (d.Get("inline")).([]interface{}) {
+			if command == nil {
+				command = ""
+			}
+			inline = append(inline, command.(string))
+		}
+		script = api.JoinVmGuestScriptInline(inline)
+	}
+
+	result, err := c.RunVmGuestScript(ctx, vmName, username, password, script)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][create] vm guest script exited with code %d", result.ExitCode)
+
+	if result.ExitCode != 0 && !ignoreExitCode {
+		return diag.FromErr(powershell.RedactError(fmt.Errorf("script in vm %s exited with code %d\nstdout:\n%s\nstderr:\n%s", vmName, result.ExitCode, result.Stdout, result.Stderr)))
+	}
+
+	d.SetId(id.UniqueId())
+
+	if err := d.Set("exit_code", result.ExitCode); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("stdout", result.Stdout); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("stderr", result.Stderr); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("script_hash", scriptHash); err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][create] created hyperv vm guest script: %#v", d)
+
+	return resourceHyperVVmGuestScriptRead(ctx, d, meta)
+}
+
+func resourceHyperVVmGuestScriptRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][read] reading hyperv vm guest script: %#v", d)
+	c := meta.(api.Client)
+
+	vmName := (d.Get("vm_name")).(string)
+
+	// The outcome of the script is only known when it runs, so the script is tracked for as long as the vm exists
+	_, err := c.GetVm(ctx, vmName)
+	if !d.IsNewResource() && errors.Is(err, api.ErrNotFound) {
+		log.Printf("[INFO][hyperv][read] unable to read hyperv vm guest script as the vm does not exist: %#v", vmName)
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][read] read hyperv vm guest script: %#v", d)
+
+	return nil
+}
+
+func resourceHyperVVmGuestScriptUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][update] updating hyperv vm guest script: %#v", d)
+
+	// Only the credentials and ignore_exit_code can change without running the script again, they are used the next
+	// time the script runs
+	powershell.RegisterSecret((d.Get("password")).(string))
+
+	log.Printf("[INFO][hyperv][update] updated hyperv vm guest script: %#v", d)
+
+	return resourceHyperVVmGuestScriptRead(ctx, d, meta)
+}
+
+func resourceHyperVVmGuestScriptDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][delete] deleting hyperv vm guest script: %#v", d)
+
+	d.SetId("")
+
+	log.Printf("[INFO][hyperv][delete] deleted hyperv vm guest script: %#v", d)
+	return nil
+}
@@ -0,0 +1,202 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/taliesins/terraform-provider-hyperv/api"
+)
+
+const (
+	ReadVmKvpTimeout   = 1 * time.Minute
+	CreateVmKvpTimeout = 5 * time.Minute
+	UpdateVmKvpTimeout = 5 * time.Minute
+	DeleteVmKvpTimeout = 5 * time.Minute
+)
+
+func resourceHyperVVmKvp() *schema.Resource {
+	return &schema.Resource{
+		Description: "This Hyper-V resource allows you to push key value pairs from the host to the guest of a virtual machine with the Key-Value Pair Exchange integration service. The guest reads them from the `HKEY_LOCAL_MACHINE\\SOFTWARE\\Microsoft\\Virtual Machine\\External` registry key on Windows and from the `/var/lib/hyperv/.kvp_pool_0` pool on Linux.",
+		Timeouts: &schema.ResourceTimeout{
+			Read:   schema.DefaultTimeout(ReadVmKvpTimeout),
+			Create: schema.DefaultTimeout(CreateVmKvpTimeout),
+			Update: schema.DefaultTimeout(UpdateVmKvpTimeout),
+			Delete: schema.DefaultTimeout(DeleteVmKvpTimeout),
+		},
+		CreateContext: resourceHyperVVmKvpCreate,
+		ReadContext:   resourceHyperVVmKvpRead,
+		UpdateContext: resourceHyperVVmKvpUpdate,
+		DeleteContext: resourceHyperVVmKvpDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceHyperVVmKvpImport,
+		},
+		Schema: map[string]*schema.Schema{
+			"vm_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Specifies the name of the virtual machine to push the key value pairs to.",
+			},
+
+			"items": {
+				Type:     schema.TypeMap,
+				Required: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Description: "The key value pairs to push to the guest. Only the keys declared here are managed, key value pairs pushed to the guest by something else are left as they are.",
+			},
+		},
+	}
+}
+
+func resourceHyperVVmKvpCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][create] creating hyperv vm kvp: %#v", d)
+	c := meta.(api.Client)
+
+	vmName := (d.Get("vm_name")).(string)
+	items := (d.Get("items")).(map[string]interface{})
+
+	for name, data := range items {
+		err := c.CreateOrUpdateVmKvpItem(ctx, vmName, name, data.(string))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	d.SetId(vmName)
+	log.Printf("[INFO][hyperv][create] created hyperv vm kvp: %#v", d)
+
+	return resourceHyperVVmKvpRead(ctx, d, meta)
+}
+
+// resourceHyperVVmKvpImport tracks every key value pair of the vm, as there is no configuration yet to tell which of
+// them are managed
+func resourceHyperVVmKvpImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	log.Printf("[INFO][hyperv][import] importing hyperv vm kvp: %#v", d)
+	c := meta.(api.Client)
+
+	vmKvpItems, err := c.GetVmKvpItems(ctx, d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[string]interface{})
+	for _, vmKvpItem := range vmKvpItems {
+		items[vmKvpItem.Name] = vmKvpItem.Data
+	}
+
+	if err := d.Set("items", items); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[INFO][hyperv][import] imported hyperv vm kvp: %#v", d)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+func resourceHyperVVmKvpRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][read] reading hyperv vm kvp: %#v", d)
+	c := meta.(api.Client)
+
+	vmName := d.Id()
+
+	vmKvpItems, err := c.GetVmKvpItems(ctx, vmName)
+	if !d.IsNewResource() && errors.Is(err, api.ErrNotFound) {
+		log.Printf("[INFO][hyperv][read] unable to read hyperv vm kvp as the vm does not exist: %#v", vmName)
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][read] retrieved vm kvp items: %+v", vmKvpItems)
+
+	// Only keep track of the items that are managed by this resource, resourceHyperVVmKvpImport tracks every item
+	managedItems := (d.Get("items")).(map[string]interface{})
+
+	items := make(map[string]interface{})
+	for _, vmKvpItem := range vmKvpItems {
+		if _, ok := managedItems[vmKvpItem.Name]; ok {
+			items[vmKvpItem.Name] = vmKvpItem.Data
+		}
+	}
+
+	if err := d.Set("vm_name", vmName); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("items", items); err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO][hyperv][read] read hyperv vm kvp: %#v", d)
+
+	return nil
+}
+
+func resourceHyperVVmKvpUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][update] updating hyperv vm kvp: %#v", d)
+	c := meta.(api.Client)
+
+	vmName := d.Id()
+
+	if d.HasChange("items") {
+		oldItemsValue, newItemsValue := d.GetChange("items")
+		oldItems := oldItemsValue.(map[string]interface{})
+		newItems := newItemsValue.(map[string]interface{})
+
+		for name := range oldItems {
+			if _, ok := newItems[name]; ok {
+				continue
+			}
+
+			err := c.DeleteVmKvpItem(ctx, vmName, name)
+			if err != nil {
+				return diag.FromErr(err)
+			}
+		}
+
+		for name, data := range newItems {
+			if oldData, ok := oldItems[name]; ok && oldData == data {
+				continue
+			}
+
+			err := c.CreateOrUpdateVmKvpItem(ctx, vmName, name, data.(string))
+			if err != nil {
+				return diag.FromErr(err)
+			}
+		}
+	}
+
+	log.Printf("[INFO][hyperv][update] updated hyperv vm kvp: %#v", d)
+
+	return resourceHyperVVmKvpRead(ctx, d, meta)
+}
+
+func resourceHyperVVmKvpDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO][hyperv][delete] deleting hyperv vm kvp: %#v", d)
+
+	c := meta.(api.Client)
+
+	vmName := d.Id()
+	items := (d.Get("items")).(map[string]interface{})
+
+	for name := range items {
+		err := c.DeleteVmKvpItem(ctx, vmName, name)
+		if errors.Is(err, api.ErrNotFound) {
+			log.Printf("[INFO][hyperv][delete] vm of hyperv vm kvp does not exist anymore: %#v", vmName)
+			return nil
+		}
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	log.Printf("[INFO][hyperv][delete] deleted hyperv vm kvp: %#v", d)
+	return nil
+}
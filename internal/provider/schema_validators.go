@@ -135,6 +135,35 @@ func IntInSlice(valid []int) schema.SchemaValidateDiagFunc {
 	}
 }
 
+func StringInSlice(valid []string, ignoreCase bool) schema.SchemaValidateDiagFunc {
+	return func(i interface{}, path cty.Path) diag.Diagnostics {
+		var diags diag.Diagnostics
+
+		value, ok := i.(string)
+		if !ok {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("expected type of %s to be string", i),
+			})
+
+			return diags
+		}
+
+		for _, validValue := range valid {
+			if value == validValue || (ignoreCase && strings.EqualFold(value, validValue)) {
+				return diags
+			}
+		}
+
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("expected %s to be one of %v, got %v", i, valid, value),
+		})
+
+		return diags
+	}
+}
+
 func IntBetween(min, max int) schema.SchemaValidateDiagFunc {
 	return func(i interface{}, path cty.Path) diag.Diagnostics {
 		var diags diag.Diagnostics
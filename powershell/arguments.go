@@ -0,0 +1,92 @@
+package powershell
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"text/template"
+	"text/template/parse"
+)
+
+// ArgumentsVariable is the PowerShell variable that script templates read their arguments from.
+// $args is deliberately not used as PowerShell resets it inside every script block, so it would
+// not be visible inside Where-Object or ForEach-Object filters.
+const ArgumentsVariable = "$arguments"
+
+type bindArgumentsTemplateOptions struct {
+	ArgumentsVariable string
+	Base64Arguments   string
+}
+
+// Base64 only uses characters that are safe inside a single quoted PowerShell string
+var bindArgumentsTemplate = template.Must(template.New("BindArguments").Parse(`{{.ArgumentsVariable}} = [System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String('{{.Base64Arguments}}')) | ConvertFrom-Json
+`))
+
+// ValidateScriptTemplate ensures that a script template only contains literal PowerShell. Values
+// must never be spliced into the script text, they have to be read from $arguments instead.
+func ValidateScriptTemplate(script *template.Template) error {
+	if script == nil || script.Tree == nil || script.Tree.Root == nil {
+		return fmt.Errorf("script template has not been parsed")
+	}
+
+	for _, node := range script.Tree.Root.Nodes {
+		if node.Type() != parse.NodeText {
+			return fmt.Errorf("script template %s contains raw interpolation %s, use %s instead", script.Name(), node.String(), ArgumentsVariable)
+		}
+	}
+
+	return nil
+}
+
+// BindArguments renders the PowerShell statement that decodes args into $arguments. The
+// arguments are serialized as json and base64 encoded so that no value can escape its quoting.
+func BindArguments(args interface{}) (string, error) {
+	argsJson, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("error serializing script arguments: %v", err)
+	}
+
+	var bindArgumentsTemplateRendered bytes.Buffer
+	err = bindArgumentsTemplate.Execute(&bindArgumentsTemplateRendered, bindArgumentsTemplateOptions{
+		ArgumentsVariable: ArgumentsVariable,
+		Base64Arguments:   base64.StdEncoding.EncodeToString(argsJson),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return bindArgumentsTemplateRendered.String(), nil
+}
+
+// RenderScript renders a script template with args bound to $arguments and failures reported
+// through ErrorRecordTrap. Templates that interpolate values directly are rejected. The values of
+// fields tagged `sensitive:"true"` are registered as secrets, so they are masked if they turn up
+// in output or errors.
+func RenderScript(script *template.Template, args interface{}) (string, error) {
+	err := ValidateScriptTemplate(script)
+	if err != nil {
+		return "", err
+	}
+
+	for _, secret := range sensitiveArguments(args) {
+		RegisterSecret(secret)
+	}
+
+	bindArguments, err := BindArguments(args)
+	if err != nil {
+		return "", err
+	}
+
+	var scriptRendered bytes.Buffer
+	scriptRendered.WriteString(bindArguments)
+	scriptRendered.WriteString(ErrorRecordTrap)
+
+	err = script.Execute(&scriptRendered, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return scriptRendered.String(), nil
+}
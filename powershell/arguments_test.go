@@ -0,0 +1,38 @@
+package powershell
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestRenderScriptRejectsInterpolation(t *testing.T) {
+	script := template.Must(template.New("Interpolated").Parse(`Get-VM -Name '{{.Name}}'`))
+
+	_, err := RenderScript(script, struct{ Name string }{Name: "test"})
+
+	if err == nil {
+		t.Errorf("Expected interpolated template to be rejected")
+	}
+}
+
+func TestRenderScriptBindsArguments(t *testing.T) {
+	script := template.Must(template.New("Bound").Parse(`Get-VM -Name $arguments.Name`))
+
+	rendered, err := RenderScript(script, struct{ Name string }{Name: "te'st"})
+
+	if err != nil {
+		t.Fatalf("Unable to render script: %s", err.Error())
+	}
+
+	expected := `$arguments = [System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String('eyJOYW1lIjoidGUnc3QifQ==')) | ConvertFrom-Json
+` + ErrorRecordTrap + `Get-VM -Name $arguments.Name`
+
+	if rendered != expected {
+		t.Errorf("Rendered script not as expected: %s", rendered)
+	}
+
+	if strings.Contains(rendered, "te'st") {
+		t.Errorf("Argument value was rendered into the script: %s", rendered)
+	}
+}
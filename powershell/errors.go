@@ -0,0 +1,66 @@
+package powershell
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCancelled is returned when the context of an operation is cancelled, or its deadline passes, while the operation
+// is running on the host. The remote command has been terminated by the time it is returned.
+var ErrCancelled = errors.New("cancelled")
+
+func cancelledError(ctx context.Context) error {
+	return fmt.Errorf("%w: %w", ErrCancelled, context.Cause(ctx))
+}
+
+const errorRecordMarker = "#terraform-error-record "
+
+// ErrorRecordTrap is rendered in front of every script. It writes the ErrorRecord that terminates a script to stdout as
+// json, and then rethrows it so that the script still fails the way it would have without the trap.
+const ErrorRecordTrap = `trap { $errorRecord = @{Category=[string]$_.CategoryInfo.Category;FullyQualifiedErrorId=[string]$_.FullyQualifiedErrorId;TargetName=[string]$_.CategoryInfo.TargetName;Message=[string]$_.Exception.Message;ExceptionType=[string]$_.Exception.GetType().FullName;HResult=[int]$_.Exception.HResult}; [Console]::Out.WriteLine('` + errorRecordMarker + `' + (ConvertTo-Json -Compress -InputObject $errorRecord)); break }
+`
+
+// ErrorRecord is the part of a PowerShell ErrorRecord that is needed to work out why a script failed
+type ErrorRecord struct {
+	Category              string
+	FullyQualifiedErrorId string
+	TargetName            string
+	Message               string
+	ExceptionType         string
+	HResult               int
+}
+
+// ScriptError is returned when a script exits with a non zero exit code or writes to stderr
+type ScriptError struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+func (e *ScriptError) Error() string {
+	if e.ExitCode != 0 {
+		return Redact(fmt.Sprintf("run command operation returned code=%d\nstderr:\n%s\nstdOut:\n%s", e.ExitCode, e.Stderr, e.Stdout))
+	}
+
+	return Redact(fmt.Sprintf("run command operation returned \nstderr:\n%s\nstdOut:\n%s", e.Stderr, e.Stdout))
+}
+
+// ErrorRecord returns the first ErrorRecord written by ErrorRecordTrap, or nil if the script did not fail with one
+func (e *ScriptError) ErrorRecord() *ErrorRecord {
+	for _, line := range strings.Split(e.Stdout+"\n"+e.Stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, errorRecordMarker) {
+			continue
+		}
+
+		errorRecord := &ErrorRecord{}
+		if json.Unmarshal([]byte(strings.TrimPrefix(line, errorRecordMarker)), errorRecord) == nil {
+			return errorRecord
+		}
+	}
+
+	return nil
+}
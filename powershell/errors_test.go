@@ -0,0 +1,30 @@
+package powershell
+
+import (
+	"testing"
+)
+
+func TestScriptErrorParsesErrorRecord(t *testing.T) {
+	scriptError := &ScriptError{
+		ExitCode: 1,
+		Stdout:   "partial output\n" + errorRecordMarker + `{"Category":"ObjectNotFound","FullyQualifiedErrorId":"VmNotFound","TargetName":"web","Message":"VM does not exist - web","ExceptionType":"Microsoft.PowerShell.Commands.WriteErrorException","HResult":-2146233087}`,
+		Stderr:   "VM does not exist - web",
+	}
+
+	errorRecord := scriptError.ErrorRecord()
+	if errorRecord == nil {
+		t.Fatalf("Expected error record to be parsed")
+	}
+
+	if errorRecord.Category != "ObjectNotFound" || errorRecord.FullyQualifiedErrorId != "VmNotFound" || errorRecord.TargetName != "web" {
+		t.Errorf("Error record not parsed: %#v", errorRecord)
+	}
+}
+
+func TestScriptErrorWithoutErrorRecord(t *testing.T) {
+	scriptError := &ScriptError{ExitCode: 1, Stderr: "The term 'Get-VM' is not recognized"}
+
+	if scriptError.ErrorRecord() != nil {
+		t.Errorf("Expected no error record")
+	}
+}
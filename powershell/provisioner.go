@@ -3,6 +3,7 @@ package powershell
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -12,16 +13,33 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/masterzen/winrm"
 	"github.com/segmentio/ksuid"
 )
 
+// commandCleanUpTimeout is how long cleaning up after a command may take. Clean up runs even when the context of the
+// command is already done.
+const commandCleanUpTimeout = 1 * time.Minute
+
+// TemporaryItemPrefix starts the name of every temporary file and scheduled task created on the host. It names the
+// provider, so that sweeping leftovers never touches the items of Terraform itself or of other providers.
+const TemporaryItemPrefix = "terraform-hyperv-"
+
+// SessionId identifies this provider process. It is part of the name of every temporary file and scheduled task
+// created on the host, so that leftovers of other runs can be told apart from the items this process is still using.
+var SessionId = TimeOrderedUUID()
+
 func TimeOrderedUUID() string {
 	id := ksuid.New()
 	return id.String()
 }
 
+func temporaryName() string {
+	return fmt.Sprintf("%s%s-%s", TemporaryItemPrefix, SessionId, TimeOrderedUUID())
+}
+
 func winPath(path string) string {
 	if len(path) == 0 {
 		return path
@@ -34,24 +52,25 @@ func winPath(path string) string {
 	return strings.ReplaceAll(path, "/", "\\")
 }
 
-func doCopy(client *winrm.Client, maxChunks int, in io.Reader, toPath string) (remoteAbsolutePath string, err error) {
-	tempFile := fmt.Sprintf("terraform-%s", TimeOrderedUUID())
+func doCopy(ctx context.Context, client *winrm.Client, maxChunks int, in io.Reader, toPath string) (remoteAbsolutePath string, err error) {
+	tempFile := temporaryName()
 	tempPath := fmt.Sprintf(`%s\%s`, `$env:TEMP`, tempFile)
 	if os.Getenv("WINRMCP_DEBUG") != "" {
 		log.Printf("[DEBUG] Resolving remote temp path of [%s]", tempPath)
 	}
-	tempPath, err = ResolvePath(client, tempPath)
+	tempPath, err = ResolvePath(ctx, client, tempPath)
 	if err != nil {
 		return "", err
 	}
 	if os.Getenv("WINRMCP_DEBUG") != "" {
 		log.Printf("[DEBUG] Remote temp path resolved to [%s]", tempPath)
 	}
+	defer cleanUpCommand(ctx, client, "", []string{tempPath})
 
 	if os.Getenv("WINRMCP_DEBUG") != "" {
 		log.Printf("[DEBUG] Resolving remote to path of [%s]", toPath)
 	}
-	toPath, err = ResolvePath(client, toPath)
+	toPath, err = ResolvePath(ctx, client, toPath)
 	if err != nil {
 		return "", err
 	}
@@ -62,7 +81,7 @@ func doCopy(client *winrm.Client, maxChunks int, in io.Reader, toPath string) (r
 	if os.Getenv("WINRMCP_DEBUG") != "" {
 		log.Printf("[DEBUG] Uploading file to %s", tempPath)
 	}
-	err = uploadContent(client, maxChunks, in, tempPath)
+	err = uploadContent(ctx, client, maxChunks, in, tempPath)
 	if err != nil {
 		return "", fmt.Errorf("error uploading file to %s: %v", tempPath, err)
 	}
@@ -70,27 +89,19 @@ func doCopy(client *winrm.Client, maxChunks int, in io.Reader, toPath string) (r
 	if os.Getenv("WINRMCP_DEBUG") != "" {
 		log.Printf("[DEBUG] Moving file from %s to %s", tempPath, toPath)
 	}
-	remoteAbsolutePath, err = restoreContent(client, tempPath, toPath)
+	remoteAbsolutePath, err = restoreContent(ctx, client, tempPath, toPath)
 	if err != nil {
 		return "", fmt.Errorf("error restoring file from %s to %s: %v", tempPath, toPath, err)
 	}
 
-	if os.Getenv("WINRMCP_DEBUG") != "" {
-		log.Printf("[DEBUG] Removing temporary file %s", tempPath)
-	}
-	err = DeleteFileOrDirectory(client, tempPath)
-	if err != nil {
-		return "", fmt.Errorf("error removing temporary file %s: %v", tempPath, err)
-	}
-
 	return remoteAbsolutePath, nil
 }
 
-func uploadContent(client *winrm.Client, maxChunks int, in io.Reader, toPath string) error {
+func uploadContent(ctx context.Context, client *winrm.Client, maxChunks int, in io.Reader, toPath string) error {
 	var err error
 	done := false
 	for !done {
-		done, err = uploadChunks(client, maxChunks, in, toPath)
+		done, err = uploadChunks(ctx, client, maxChunks, in, toPath)
 		if err != nil {
 			return err
 		}
@@ -99,7 +110,7 @@ func uploadContent(client *winrm.Client, maxChunks int, in io.Reader, toPath str
 	return nil
 }
 
-func uploadChunks(client *winrm.Client, maxChunks int, in io.Reader, toPath string) (bool, error) {
+func uploadChunks(ctx context.Context, client *winrm.Client, maxChunks int, in io.Reader, toPath string) (bool, error) {
 	shell, err := client.CreateShell()
 	if err != nil {
 		return false, fmt.Errorf("couldn't create shell: %v", err)
@@ -135,7 +146,7 @@ func uploadChunks(client *winrm.Client, maxChunks int, in io.Reader, toPath stri
 		}
 
 		content := base64.StdEncoding.EncodeToString(chunk[:n])
-		if err = appendContent(shell, toPath, content); err != nil {
+		if err = appendContent(ctx, shell, toPath, content); err != nil {
 			return false, err
 		}
 	}
@@ -143,7 +154,7 @@ func uploadChunks(client *winrm.Client, maxChunks int, in io.Reader, toPath stri
 	return false, nil
 }
 
-func restoreContent(client *winrm.Client, fromPath, toPath string) (string, error) {
+func restoreContent(ctx context.Context, client *winrm.Client, fromPath, toPath string) (string, error) {
 	shell, err := client.CreateShell()
 	if err != nil {
 		return "", err
@@ -173,7 +184,7 @@ func restoreContent(client *winrm.Client, fromPath, toPath string) (string, erro
 
 	script = executePowershellFromCommandLineTemplateRendered.String()
 
-	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(shell, script)
+	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(ctx, shell, script)
 
 	if err != nil {
 		return "", err
@@ -190,7 +201,7 @@ func restoreContent(client *winrm.Client, fromPath, toPath string) (string, erro
 	return stdOutPut, nil
 }
 
-func ResolvePath(client *winrm.Client, filePath string) (string, error) {
+func ResolvePath(ctx context.Context, client *winrm.Client, filePath string) (string, error) {
 	shell, err := client.CreateShell()
 	if err != nil {
 		return "", err
@@ -219,7 +230,7 @@ func ResolvePath(client *winrm.Client, filePath string) (string, error) {
 
 	script = executePowershellFromCommandLineTemplateRendered.String()
 
-	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(shell, script)
+	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(ctx, shell, script)
 
 	if err != nil {
 		return "", err
@@ -236,7 +247,7 @@ func ResolvePath(client *winrm.Client, filePath string) (string, error) {
 	return stdOutPut, nil
 }
 
-func FileExists(client *winrm.Client, filePath string) (bool, error) {
+func FileExists(ctx context.Context, client *winrm.Client, filePath string) (bool, error) {
 	shell, err := client.CreateShell()
 	if err != nil {
 		return false, err
@@ -265,7 +276,7 @@ func FileExists(client *winrm.Client, filePath string) (bool, error) {
 
 	script = executePowershellFromCommandLineTemplateRendered.String()
 
-	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(shell, script)
+	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(ctx, shell, script)
 
 	if err != nil {
 		return false, err
@@ -287,7 +298,7 @@ func FileExists(client *winrm.Client, filePath string) (bool, error) {
 	return result, nil
 }
 
-func DirectoryExists(client *winrm.Client, directoryPath string) (bool, error) {
+func DirectoryExists(ctx context.Context, client *winrm.Client, directoryPath string) (bool, error) {
 	shell, err := client.CreateShell()
 	if err != nil {
 		return false, err
@@ -316,7 +327,7 @@ func DirectoryExists(client *winrm.Client, directoryPath string) (bool, error) {
 
 	script = executePowershellFromCommandLineTemplateRendered.String()
 
-	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(shell, script)
+	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(ctx, shell, script)
 
 	if err != nil {
 		return false, err
@@ -338,7 +349,7 @@ func DirectoryExists(client *winrm.Client, directoryPath string) (bool, error) {
 	return result, nil
 }
 
-func DeleteFileOrDirectory(client *winrm.Client, filePath string) error {
+func DeleteFileOrDirectory(ctx context.Context, client *winrm.Client, filePath string) error {
 	shell, err := client.CreateShell()
 	if err != nil {
 		return err
@@ -367,7 +378,7 @@ func DeleteFileOrDirectory(client *winrm.Client, filePath string) error {
 
 	script = executePowershellFromCommandLineTemplateRendered.String()
 
-	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(shell, script)
+	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(ctx, shell, script)
 
 	if err != nil {
 		return err
@@ -384,7 +395,7 @@ func DeleteFileOrDirectory(client *winrm.Client, filePath string) error {
 	return nil
 }
 
-func appendContent(shell *winrm.Shell, filePath, content string) error {
+func appendContent(ctx context.Context, shell *winrm.Shell, filePath, content string) error {
 	var appendFileTemplateRendered bytes.Buffer
 	err := appendFileTemplate.Execute(&appendFileTemplateRendered, appendFileTemplateOptions{
 		FilePath: filePath,
@@ -397,7 +408,8 @@ func appendContent(shell *winrm.Shell, filePath, content string) error {
 
 	script := appendFileTemplateRendered.String()
 
-	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(shell, script)
+	// The content is base64 encoded, so a secret in it, like the password in an elevated runner, can't be redacted
+	commandExitCode, stdOutPut, errorOutPut, err := shellExecuteWithLogCommand(ctx, shell, script, fmt.Sprintf("append %d base64 characters to %s", len(content), filePath))
 
 	if err != nil {
 		return err
@@ -414,7 +426,12 @@ func appendContent(shell *winrm.Shell, filePath, content string) error {
 	return nil
 }
 
-func shellExecute(shell *winrm.Shell, command string, arguments ...string) (int, string, string, error) {
+func shellExecute(ctx context.Context, shell *winrm.Shell, command string, arguments ...string) (int, string, string, error) {
+	return shellExecuteWithLogCommand(ctx, shell, command, command, arguments...)
+}
+
+// shellExecuteWithLogCommand executes command, but logs logCommand in its place
+func shellExecuteWithLogCommand(ctx context.Context, shell *winrm.Shell, command string, logCommand string, arguments ...string) (int, string, string, error) {
 	stdOutBytes := new(bytes.Buffer)
 	stdErrBytes := new(bytes.Buffer)
 
@@ -429,10 +446,15 @@ func shellExecute(shell *winrm.Shell, command string, arguments ...string) (int,
 	}
 
 	if os.Getenv("WINRMCP_DEBUG") != "" {
-		log.Printf("[DEBUG] Shell execute: %s %s", command, arguments)
+		log.Printf("[DEBUG] Shell execute: %s %s", Redact(logCommand), Redact(strings.Join(arguments, " ")))
+	}
+
+	if ctx.Err() != nil {
+		return 0, "", "", cancelledError(ctx)
 	}
 
-	cmd, err := shell.Execute(command, arguments...)
+	// The command is signalled to terminate on the host as soon as ctx is done
+	cmd, err := shell.ExecuteWithContext(ctx, command, arguments...)
 
 	if err != nil {
 		return 0, "", "", err
@@ -450,6 +472,12 @@ func shellExecute(shell *winrm.Shell, command string, arguments ...string) (int,
 	go stdErrFunc(stdErrBytes, os.Stderr, cmd.Stderr)
 
 	cmd.Wait()
+
+	if ctx.Err() != nil {
+		closed = true
+		return 0, "", "", cancelledError(ctx)
+	}
+
 	exitCode := cmd.ExitCode()
 
 	err = cmd.Close()
@@ -471,13 +499,13 @@ func shellExecute(shell *winrm.Shell, command string, arguments ...string) (int,
 	stdErrString := strings.TrimSpace(stdErrBytes.String())
 
 	if os.Getenv("WINRMCP_DEBUG") != "" {
-		log.Printf("[DEBUG] Shell execute result: exitCode=%d stdOut=%s stdErr=%s", exitCode, stdOutString, stdErrString)
+		log.Printf("[DEBUG] Shell execute result: exitCode=%d stdOut=%s stdErr=%s", exitCode, Redact(stdOutString), Redact(stdErrString))
 	}
 
 	return exitCode, stdOutString, stdErrString, nil
 }
 
-func uploadScript(client *winrm.Client, fileName string, command string) (remoteAbsolutePath string, err error) {
+func uploadScript(ctx context.Context, client *winrm.Client, fileName string, command string) (remoteAbsolutePath string, err error) {
 	tmpFile, err := os.CreateTemp(os.TempDir(), fileName)
 	if err != nil {
 		return "", fmt.Errorf("error creating temp file: %s", err)
@@ -502,7 +530,7 @@ func uploadScript(client *winrm.Client, fileName string, command string) (remote
 
 	log.Printf("[DEBUG] Uploading shell wrapper for command from [%s] to [%s] ", tmpFile.Name(), remotePath)
 
-	remoteAbsolutePath, err = doCopy(client, 15, f, winPath(remotePath))
+	remoteAbsolutePath, err = doCopy(ctx, client, 15, f, winPath(remotePath))
 	if err != nil {
 		return "", fmt.Errorf("error uploading shell script: %s", err)
 	}
@@ -528,21 +556,22 @@ func createCommand(vars string, remotePath string) (commandText string, err erro
 	return commandText, err
 }
 
-func createElevatedCommand(client *winrm.Client, elevatedUser string, elevatedPassword string, vars string, remotePath string) (commandText string, elevatedRemotePath string, err error) {
-	elevatedRemotePath, err = generateElevatedRunner(client, elevatedUser, elevatedPassword, remotePath)
+func createElevatedCommand(ctx context.Context, client *winrm.Client, elevatedUser string, elevatedPassword string, vars string, remotePath string) (commandText string, taskName string, elevatedRemotePath string, err error) {
+	taskName, elevatedRemotePath, err = generateElevatedRunner(ctx, client, elevatedUser, elevatedPassword, remotePath)
 	if err != nil {
-		return "", "", fmt.Errorf("error generating elevated runner: %s", err)
+		return "", "", "", fmt.Errorf("error generating elevated runner: %s", err)
 	}
 
 	commandText, err = createCommand(vars, elevatedRemotePath)
 
-	return commandText, elevatedRemotePath, err
+	return commandText, taskName, elevatedRemotePath, err
 }
 
-func generateElevatedRunner(client *winrm.Client, elevatedUser string, elevatedPassword string, remotePath string) (elevatedRemotePath string, err error) {
+func generateElevatedRunner(ctx context.Context, client *winrm.Client, elevatedUser string, elevatedPassword string, remotePath string) (taskName string, elevatedRemotePath string, err error) {
 	log.Printf("[DEBUG] Building elevated command wrapper for: %s", remotePath)
+	RegisterSecret(elevatedPassword)
 
-	name := fmt.Sprintf("terraform-%s", TimeOrderedUUID())
+	name := temporaryName()
 	fileName := fmt.Sprintf(`elevated-shell-%s.ps1`, name)
 
 	var elevatedCommandTemplateRendered bytes.Buffer
@@ -557,35 +586,110 @@ func generateElevatedRunner(client *winrm.Client, elevatedUser string, elevatedP
 
 	if err != nil {
 		fmt.Printf("Error creating elevated command template: %s", err)
-		return "", err
+		return "", "", err
 	}
 
 	elevatedCommand := elevatedCommandTemplateRendered.String()
 
-	elevatedRemotePath, err = uploadScript(client, fileName, elevatedCommand)
+	elevatedRemotePath, err = uploadScript(ctx, client, fileName, elevatedCommand)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return elevatedRemotePath, nil
+	return name, elevatedRemotePath, nil
+}
+
+func removeCommandLeftovers(ctx context.Context, client *winrm.Client, taskName string, remotePaths []string) error {
+	shell, err := client.CreateShell()
+	if err != nil {
+		return err
+	}
+	defer shell.Close()
+
+	var removeCommandLeftoversTemplateRendered bytes.Buffer
+	err = removeCommandLeftoversTemplate.Execute(&removeCommandLeftoversTemplateRendered, removeCommandLeftoversTemplateOptions{
+		TaskName: taskName,
+		Paths:    remotePaths,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	script := removeCommandLeftoversTemplateRendered.String()
+
+	var executePowershellFromCommandLineTemplateRendered bytes.Buffer
+	err = executePowershellFromCommandLineTemplate.Execute(&executePowershellFromCommandLineTemplateRendered, executePowershellFromCommandLineTemplateOptions{
+		Powershell: script,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	script = executePowershellFromCommandLineTemplateRendered.String()
+
+	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(ctx, shell, script)
+
+	if err != nil {
+		return err
+	}
+
+	if commandExitCode != 0 {
+		return fmt.Errorf("cleanup operation returned code=%d\nstderr:\n%s\nstdOut:\n%s", commandExitCode, errorOutPut, stdOutPut)
+	}
+
+	if len(errorOutPut) > 0 {
+		return fmt.Errorf("cleanup operation returned \nstderr:\n%s\nstdOut:\n%s", errorOutPut, stdOutPut)
+	}
+
+	return nil
+}
+
+// cleanUpCommand removes the temporary files of a command, whether it succeeded, failed or was cancelled. The
+// scheduled task of an elevated command normally unregisters itself, but it is left running when the command is
+// cancelled or the runner fails, so it is stopped and unregistered as well. Failures are only logged, anything left
+// behind is removed by a sweep of the host.
+func cleanUpCommand(ctx context.Context, client *winrm.Client, taskName string, remotePaths []string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commandCleanUpTimeout)
+	defer cancel()
+
+	if os.Getenv("WINRMCP_DEBUG") != "" {
+		log.Printf("[DEBUG] Removing elevated task [%s] and temporary files %s", taskName, remotePaths)
+	}
+
+	err := removeCommandLeftovers(ctx, client, taskName, remotePaths)
+	if err != nil {
+		log.Printf("[WARN] Unable to remove elevated task [%s] and temporary files %s: %s", taskName, remotePaths, err)
+	}
 }
 
 // Run powershell
-func RunPowershell(client *winrm.Client, elevatedUser string, elevatedPassword string, vars string, commandText string) (exitStatus int, stdout string, stderr string, err error) {
-	name := fmt.Sprintf("terraform-%s", TimeOrderedUUID())
+func RunPowershell(ctx context.Context, client *winrm.Client, elevatedUser string, elevatedPassword string, vars string, commandText string) (exitStatus int, stdout string, stderr string, err error) {
+	name := temporaryName()
 	fileName := fmt.Sprintf(`shell-%s.ps1`, name)
 
-	path, err := uploadScript(client, fileName, commandText)
+	path, err := uploadScript(ctx, client, fileName, commandText)
 	if err != nil {
 		return 0, "", "", err
 	}
 
 	var command string
+	var taskName string
+	remotePaths := []string{path}
+
+	defer func() {
+		cleanUpCommand(ctx, client, taskName, remotePaths)
+	}()
 
 	if elevatedUser == "" {
 		command, err = createCommand(vars, path)
 	} else {
-		command, path, err = createElevatedCommand(client, elevatedUser, elevatedPassword, vars, path)
+		var elevatedRemotePath string
+		command, taskName, elevatedRemotePath, err = createElevatedCommand(ctx, client, elevatedUser, elevatedPassword, vars, path)
+		if err == nil {
+			remotePaths = append(remotePaths, elevatedRemotePath)
+		}
 	}
 
 	if err != nil {
@@ -609,53 +713,23 @@ func RunPowershell(client *winrm.Client, elevatedUser string, elevatedPassword s
 	}
 	defer shell.Close()
 
-	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(shell, command)
+	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(ctx, shell, command)
 
 	if err != nil {
 		return 0, "", "", err
 	}
 
 	if commandExitCode != 0 {
-		return 0, "", "", fmt.Errorf("run command operation returned code=%d\nstderr:\n%s\nstdOut:\n%s", commandExitCode, errorOutPut, stdOutPut)
+		return 0, "", "", &ScriptError{ExitCode: commandExitCode, Stdout: stdOutPut, Stderr: errorOutPut}
 	}
 
 	if len(errorOutPut) > 0 {
-		return 0, "", "", fmt.Errorf("run command operation returned \nstderr:\n%s\nstdOut:\n%s", errorOutPut, stdOutPut)
-	}
-
-	err = DeleteFileOrDirectory(client, path)
-	if err != nil {
-		return 0, "", "", fmt.Errorf("error removing temporary file %s: %v", path, err)
+		return 0, "", "", &ScriptError{Stdout: stdOutPut, Stderr: errorOutPut}
 	}
 
 	return commandExitCode, stdOutPut, errorOutPut, nil
 }
 
-func UploadFile(client *winrm.Client, filePath string, remoteFilePath string) (string, error) {
-	if remoteFilePath == "" {
-		remoteFilePath = winPath(filepath.Join(`$env:TEMP`, filepath.Base(filePath)))
-	}
-
-	f, err := os.Open(filePath)
-	if err != nil {
-		return "", fmt.Errorf("error opening file: %s", err)
-	}
-
-	remoteFilePath, err = doCopy(client, 15, f, remoteFilePath)
-
-	err2 := f.Close()
-
-	if err != nil {
-		return "", err
-	}
-
-	if err2 != nil {
-		return "", err2
-	}
-
-	return remoteFilePath, nil
-}
-
 func getFilesInDirectory(rootPath string, excludeList []string) (fileList []string, err error) {
 	excludeListRegex := regexp.MustCompile(strings.Join(excludeList, "|"))
 	validateRegex := len(fileList) > 0
@@ -677,43 +751,3 @@ func getFilesInDirectory(rootPath string, excludeList []string) (fileList []stri
 	}
 	return fileList, nil
 }
-
-func UploadDirectory(client *winrm.Client, rootPath string, excludeList []string) (remoteRootPath string, remoteAbsolutePaths []string, err error) {
-	sourceFilePaths, err := getFilesInDirectory(rootPath, excludeList)
-	if err != nil {
-		return "", []string{}, err
-	}
-
-	remoteRootPath = filepath.Join(`$env:TEMP`, filepath.Base(rootPath))
-	remoteFilePaths := []string{}
-
-	for _, sourceFilePath := range sourceFilePaths {
-		filePath, err := filepath.Rel(rootPath, sourceFilePath)
-		if err != nil {
-			return "", []string{}, err
-		}
-
-		remoteFilePath := filepath.Join(remoteRootPath, filePath)
-
-		f, err := os.Open(sourceFilePath)
-		if err != nil {
-			return "", []string{}, fmt.Errorf("error opening file: %s", err)
-		}
-
-		remoteFilePath, err = doCopy(client, 15, f, winPath(remoteFilePath))
-
-		err2 := f.Close()
-
-		if err != nil {
-			return "", []string{}, err
-		}
-
-		if err2 != nil {
-			return "", []string{}, err2
-		}
-
-		remoteFilePaths = append(remoteFilePaths, remoteFilePath)
-	}
-
-	return remoteRootPath, remoteFilePaths, nil
-}
@@ -0,0 +1,140 @@
+package powershell
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// RedactedValue replaces secrets in log lines and error messages
+const RedactedValue = "********"
+
+// sensitiveTag marks a string field of script arguments as a secret, for example `sensitive:"true"`
+const sensitiveTag = "sensitive"
+
+var (
+	secretsMutex   sync.RWMutex
+	secrets        = map[string]struct{}{}
+	secretReplacer = strings.NewReplacer()
+)
+
+// RegisterSecret makes Redact mask secret in every log line and error message. The forms secret takes once it is
+// escaped for json or for a single quoted PowerShell string are masked as well.
+func RegisterSecret(secret string) {
+	if secret == "" {
+		return
+	}
+
+	jsonSecret, _ := json.Marshal(secret)
+
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+
+	for _, form := range []string{secret, strings.Trim(string(jsonSecret), `"`), strings.ReplaceAll(secret, `'`, `''`)} {
+		secrets[form] = struct{}{}
+	}
+
+	// Longer secrets are replaced first so a secret that contains another one is masked completely
+	forms := make([]string, 0, len(secrets))
+	for form := range secrets {
+		forms = append(forms, form)
+	}
+	sort.Slice(forms, func(i, j int) bool {
+		return len(forms[i]) > len(forms[j])
+	})
+
+	oldNew := make([]string, 0, len(forms)*2)
+	for _, form := range forms {
+		oldNew = append(oldNew, form, RedactedValue)
+	}
+	secretReplacer = strings.NewReplacer(oldNew...)
+}
+
+// Redact masks every registered secret in text
+func Redact(text string) string {
+	secretsMutex.RLock()
+	defer secretsMutex.RUnlock()
+
+	return secretReplacer.Replace(text)
+}
+
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return Redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// RedactError masks every registered secret in the message of err. errors.Is and errors.As still see the errors that
+// err wraps.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &redactedError{err: err}
+}
+
+// sensitiveArguments returns the values of the fields of args that are tagged as sensitive
+func sensitiveArguments(args interface{}) (values []string) {
+	value := reflect.Indirect(reflect.ValueOf(args))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		if value.Type().Field(i).Tag.Get(sensitiveTag) == "true" && value.Field(i).Kind() == reflect.String {
+			values = append(values, value.Field(i).String())
+		}
+	}
+
+	return values
+}
+
+// redactArguments returns a copy of args with the fields that are tagged as sensitive masked
+func redactArguments(args interface{}) interface{} {
+	value := reflect.Indirect(reflect.ValueOf(args))
+	if value.Kind() != reflect.Struct {
+		return args
+	}
+
+	redacted := reflect.New(value.Type()).Elem()
+	redacted.Set(value)
+
+	for i := 0; i < redacted.NumField(); i++ {
+		field := redacted.Field(i)
+		if value.Type().Field(i).Tag.Get(sensitiveTag) == "true" && field.Kind() == reflect.String && field.String() != "" {
+			field.SetString(RedactedValue)
+		}
+	}
+
+	return redacted.Interface()
+}
+
+// RenderRedactedScript renders script for logging. Unlike RenderScript the arguments are shown as readable json, with
+// the fields tagged as sensitive and every registered secret masked.
+func RenderRedactedScript(script *template.Template, args interface{}) string {
+	argsJson, err := json.Marshal(redactArguments(args))
+	if err != nil {
+		return Redact(script.Name())
+	}
+
+	var scriptRendered bytes.Buffer
+	scriptRendered.WriteString(ArgumentsVariable + " = '" + strings.ReplaceAll(string(argsJson), `'`, `''`) + "' | ConvertFrom-Json\n")
+
+	err = script.Execute(&scriptRendered, nil)
+	if err != nil {
+		return Redact(script.Name())
+	}
+
+	return Redact(scriptRendered.String())
+}
@@ -0,0 +1,80 @@
+package powershell
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestRedactMasksEveryFormOfASecret(t *testing.T) {
+	RegisterSecret(`p@ss'w"ord`)
+
+	for _, text := range []string{
+		`password p@ss'w"ord`,
+		`{"Password":"p@ss'w\"ord"}`,
+		`$password = 'p@ss''w"ord'`,
+	} {
+		redacted := Redact(text)
+		if strings.Contains(redacted, "p@ss") || !strings.Contains(redacted, RedactedValue) {
+			t.Errorf("Expected secret to be masked: %s", redacted)
+		}
+	}
+
+	if Redact("nothing to hide") != "nothing to hide" {
+		t.Errorf("Expected text without secrets to be unchanged")
+	}
+}
+
+func TestRedactErrorKeepsWrappedErrors(t *testing.T) {
+	RegisterSecret("hunter2")
+
+	err := RedactError(fmt.Errorf("logon with hunter2 failed: %w", ErrCancelled))
+
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("Expected secret to be masked: %s", err.Error())
+	}
+
+	if !errors.Is(err, ErrCancelled) {
+		t.Errorf("Expected redacted error to wrap ErrCancelled")
+	}
+
+	if RedactError(nil) != nil {
+		t.Errorf("Expected nil error to stay nil")
+	}
+}
+
+type sensitiveTestArgs struct {
+	User     string
+	Password string `sensitive:"true"`
+}
+
+var sensitiveTestTemplate = template.Must(template.New("SensitiveTest").Parse(`New-Credential -User $arguments.User -Password $arguments.Password`))
+
+func TestRenderScriptRegistersSensitiveArguments(t *testing.T) {
+	args := sensitiveTestArgs{User: "administrator", Password: "correct-horse-battery-staple"}
+
+	_, err := RenderScript(sensitiveTestTemplate, args)
+	if err != nil {
+		t.Fatalf("Unable to render script: %s", err.Error())
+	}
+
+	if Redact("output correct-horse-battery-staple") != "output "+RedactedValue {
+		t.Errorf("Expected sensitive argument to be registered as a secret")
+	}
+}
+
+func TestRenderRedactedScriptMasksSensitiveArguments(t *testing.T) {
+	args := sensitiveTestArgs{User: "administrator", Password: "tagged-but-not-registered"}
+
+	rendered := RenderRedactedScript(sensitiveTestTemplate, args)
+
+	if strings.Contains(rendered, "tagged-but-not-registered") {
+		t.Errorf("Expected sensitive argument to be masked: %s", rendered)
+	}
+
+	if !strings.Contains(rendered, `"User":"administrator"`) || !strings.Contains(rendered, "New-Credential") {
+		t.Errorf("Expected other arguments and the script body to be shown: %s", rendered)
+	}
+}
@@ -0,0 +1,264 @@
+package powershell
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/masterzen/winrm"
+)
+
+// ErrSessionBroken is returned when the remote powershell process of a session can no longer be used. Callers are
+// expected to throw the session away.
+var ErrSessionBroken = errors.New("powershell session is broken")
+
+// ErrSessionNotStarted is returned when a script could not be sent to a broken session, so it is safe to fall back to
+// running the script another way. It wraps ErrSessionBroken. A session that breaks after the script was sent returns
+// ErrSessionBroken alone, as the script may have run.
+var ErrSessionNotStarted = fmt.Errorf("%w: script was not sent", ErrSessionBroken)
+
+type sessionFrame struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+// Session is a long-lived powershell process on the remote host. Scripts are sent to it over stdin one line at a time
+// and results are read back as framed json, so modules like Hyper-V are only imported once per session.
+type Session struct {
+	mutex  sync.Mutex
+	marker string
+	stdin  io.Writer
+	stdout *bufio.Reader
+	close  func() error
+	broken bool
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewSession starts a powershell session in a new shell of client
+func NewSession(client *winrm.Client, vars string) (*Session, error) {
+	shell, err := client.CreateShell()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create shell: %v", err)
+	}
+
+	var executeEncodedCommandTemplateRendered bytes.Buffer
+	err = executeEncodedCommandTemplate.Execute(&executeEncodedCommandTemplateRendered, executeEncodedCommandTemplateOptions{
+		EncodedCommand: encodeCommand(readSessionFromStdinCommand),
+	})
+
+	if err != nil {
+		_ = shell.Close()
+		return nil, err
+	}
+
+	cmd, err := shell.Execute(executeEncodedCommandTemplateRendered.String())
+	if err != nil {
+		_ = shell.Close()
+		return nil, fmt.Errorf("couldn't start powershell session: %v", err)
+	}
+
+	stderr := new(bytes.Buffer)
+	stderrMutex := sync.Mutex{}
+	go func() {
+		// Stderr has to be drained, otherwise the shell stops delivering stdout
+		buffer := make([]byte, 4096)
+		for {
+			n, err := cmd.Stderr.Read(buffer)
+			stderrMutex.Lock()
+			stderr.Write(buffer[:n])
+			stderrMutex.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	session, err := newSession(cmd.Stdin, cmd.Stdout, func() error {
+		_ = cmd.Stdin.Close()
+		err := cmd.Close()
+		err2 := shell.Close()
+		if err != nil {
+			return err
+		}
+		return err2
+	}, vars)
+
+	if err != nil {
+		stderrMutex.Lock()
+		defer stderrMutex.Unlock()
+		return nil, fmt.Errorf("%w\nstderr:\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return session, nil
+}
+
+func newSession(stdin io.Writer, stdout io.Reader, close func() error, vars string) (*Session, error) {
+	session := &Session{
+		marker: fmt.Sprintf("#terraform-session-%s", TimeOrderedUUID()),
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+		close:  close,
+	}
+
+	var sessionTemplateRendered bytes.Buffer
+	err := sessionTemplate.Execute(&sessionTemplateRendered, sessionTemplateOptions{
+		Vars:   vars,
+		Marker: session.marker,
+	})
+
+	if err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+
+	err = session.writeLine(sessionTemplateRendered.String())
+	if err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+
+	// A no-op round trip makes sure the session loop is up before it is handed out
+	_, _, _, err = session.Run(context.Background(), "")
+	if err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *Session) writeLine(text string) error {
+	_, err := s.stdin.Write([]byte(base64.StdEncoding.EncodeToString([]byte(text)) + "\r\n"))
+	if err != nil {
+		s.broken = true
+		return fmt.Errorf("%w: %v", ErrSessionNotStarted, err)
+	}
+
+	return nil
+}
+
+func (s *Session) readFrame() (frame sessionFrame, plainStdout string, err error) {
+	var plainStdoutBuilder strings.Builder
+
+	for {
+		line, err := s.stdout.ReadString('\n')
+		if err != nil {
+			s.broken = true
+			return frame, "", fmt.Errorf("%w: %v", ErrSessionBroken, err)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if !strings.HasPrefix(line, s.marker+" ") {
+			plainStdoutBuilder.WriteString(line)
+			plainStdoutBuilder.WriteString("\n")
+			continue
+		}
+
+		frameJson, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, s.marker+" "))
+		if err == nil {
+			err = json.Unmarshal(frameJson, &frame)
+		}
+
+		if err != nil {
+			s.broken = true
+			return frame, "", fmt.Errorf("%w: invalid frame: %v", ErrSessionBroken, err)
+		}
+
+		return frame, plainStdoutBuilder.String(), nil
+	}
+}
+
+func (s *Session) roundTrip(commandText string) (frame sessionFrame, plainStdout string, err error) {
+	err = s.writeLine(commandText)
+	if err != nil {
+		return frame, "", err
+	}
+
+	return s.readFrame()
+}
+
+// Run executes commandText in the session. Errors wrapping ErrSessionNotStarted mean the script did not run, other
+// errors wrapping ErrSessionBroken mean the script may have run. If ctx is done before the script finishes the remote
+// powershell process is stopped, which breaks the session.
+func (s *Session) Run(ctx context.Context, commandText string) (exitStatus int, stdout string, stderr string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.broken {
+		return 0, "", "", ErrSessionNotStarted
+	}
+
+	if ctx.Err() != nil {
+		return 0, "", "", cancelledError(ctx)
+	}
+
+	if os.Getenv("WINRMCP_DEBUG") != "" {
+		log.Printf("[DEBUG] Session execute: %s", Redact(commandText))
+	}
+
+	// A running script can only be interrupted by stopping the remote powershell process
+	stop := context.AfterFunc(ctx, func() {
+		_ = s.Close()
+	})
+
+	frame, plainStdout, err := s.roundTrip(commandText)
+
+	if !stop() {
+		s.broken = true
+		return 0, "", "", cancelledError(ctx)
+	}
+
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	stdOutPut := strings.TrimSpace(plainStdout + frame.Stdout)
+	errorOutPut := strings.TrimSpace(frame.Stderr)
+
+	if os.Getenv("WINRMCP_DEBUG") != "" {
+		log.Printf("[DEBUG] Session execute result: exitCode=%d stdOut=%s stdErr=%s", frame.ExitCode, Redact(stdOutPut), Redact(errorOutPut))
+	}
+
+	if frame.ExitCode != 0 {
+		return 0, "", "", &ScriptError{ExitCode: frame.ExitCode, Stdout: stdOutPut, Stderr: errorOutPut}
+	}
+
+	if len(errorOutPut) > 0 {
+		return 0, "", "", &ScriptError{Stdout: stdOutPut, Stderr: errorOutPut}
+	}
+
+	return frame.ExitCode, stdOutPut, errorOutPut, nil
+}
+
+// Broken reports whether the session has failed and should be thrown away
+func (s *Session) Broken() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.broken
+}
+
+// Close stops the remote powershell process and closes its shell
+func (s *Session) Close() error {
+	if s.close == nil {
+		return nil
+	}
+
+	s.closeOnce.Do(func() {
+		s.closeErr = s.close()
+	})
+
+	return s.closeErr
+}
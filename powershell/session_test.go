@@ -0,0 +1,177 @@
+package powershell
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var sessionMarkerRegex = regexp.MustCompile(`'(#terraform-session-[^ ']+) '`)
+
+// startFakeSessionHost stands in for the remote session loop. It answers every script with the frame returned by
+// handler, and stops answering once handler returns nil.
+func startFakeSessionHost(t *testing.T, handler func(script string) *sessionFrame) (*Session, error) {
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+
+	go func() {
+		defer stdoutWriter.Close()
+
+		lines := bufio.NewScanner(stdinReader)
+		marker := ""
+		for lines.Scan() {
+			script, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines.Text()))
+			if err != nil {
+				return
+			}
+
+			if marker == "" {
+				marker = sessionMarkerRegex.FindStringSubmatch(string(script))[1]
+				continue
+			}
+
+			frame := handler(string(script))
+			if frame == nil {
+				return
+			}
+
+			frameJson, _ := json.Marshal(frame)
+			_, _ = stdoutWriter.Write([]byte("WARNING: console noise\r\n" + marker + " " + base64.StdEncoding.EncodeToString(frameJson) + "\r\n"))
+		}
+	}()
+
+	return newSession(stdinWriter, stdoutReader, func() error {
+		_ = stdoutReader.Close()
+		return stdinWriter.Close()
+	}, "")
+}
+
+func TestSessionRunsScripts(t *testing.T) {
+	session, err := startFakeSessionHost(t, func(script string) *sessionFrame {
+		return &sessionFrame{Stdout: strings.ToUpper(script)}
+	})
+	if err != nil {
+		t.Fatalf("Unable to start session: %s", err.Error())
+	}
+	defer session.Close()
+
+	for _, script := range []string{"get-vm", "get-vmswitch"} {
+		_, stdout, _, err := session.Run(context.Background(), script)
+		if err != nil {
+			t.Fatalf("Unable to run script: %s", err.Error())
+		}
+
+		if stdout != "WARNING: console noise\n"+strings.ToUpper(script) {
+			t.Errorf("Unexpected stdout: %s", stdout)
+		}
+	}
+}
+
+func TestSessionReturnsScriptFailures(t *testing.T) {
+	session, err := startFakeSessionHost(t, func(script string) *sessionFrame {
+		if script == "" {
+			return &sessionFrame{}
+		}
+		return &sessionFrame{ExitCode: 1, Stderr: "VM not found"}
+	})
+	if err != nil {
+		t.Fatalf("Unable to start session: %s", err.Error())
+	}
+	defer session.Close()
+
+	_, _, _, err = session.Run(context.Background(), "get-vm")
+	if err == nil || errors.Is(err, ErrSessionBroken) || !strings.Contains(err.Error(), "VM not found") {
+		t.Errorf("Expected script failure: %v", err)
+	}
+
+	if session.Broken() {
+		t.Errorf("A script failure should not break the session")
+	}
+}
+
+func TestSessionBreaksWhenHostDies(t *testing.T) {
+	session, err := startFakeSessionHost(t, func(script string) *sessionFrame {
+		if script == "" {
+			return &sessionFrame{}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unable to start session: %s", err.Error())
+	}
+	defer session.Close()
+
+	_, _, _, err = session.Run(context.Background(), "get-vm")
+	if !errors.Is(err, ErrSessionBroken) {
+		t.Errorf("Expected session to be broken: %v", err)
+	}
+
+	_, _, _, err = session.Run(context.Background(), "get-vm")
+	if !errors.Is(err, ErrSessionNotStarted) || !session.Broken() {
+		t.Errorf("Expected broken session to stay broken: %v", err)
+	}
+}
+
+func TestSessionDoesNotReportSentScriptsAsNotStarted(t *testing.T) {
+	scripts := make(chan string, 1)
+	session, err := startFakeSessionHost(t, func(script string) *sessionFrame {
+		if script == "" {
+			return &sessionFrame{}
+		}
+		scripts <- script
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unable to start session: %s", err.Error())
+	}
+	defer session.Close()
+
+	_, _, _, err = session.Run(context.Background(), "New-VM -Name web")
+	if !errors.Is(err, ErrSessionBroken) {
+		t.Errorf("Expected session to be broken: %v", err)
+	}
+
+	// The script reached the host before stdout hit EOF, so it must not be run again another way
+	if errors.Is(err, ErrSessionNotStarted) {
+		t.Errorf("Expected a script that was sent not to be reported as not started: %v", err)
+	}
+
+	if script := <-scripts; script != "New-VM -Name web" {
+		t.Errorf("Expected the script to have been sent: %s", script)
+	}
+}
+
+func TestSessionStopsWhenCancelled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	session, err := startFakeSessionHost(t, func(script string) *sessionFrame {
+		if script != "" {
+			<-release
+		}
+		return &sessionFrame{}
+	})
+	if err != nil {
+		t.Fatalf("Unable to start session: %s", err.Error())
+	}
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, _, _, err = session.Run(ctx, "Start-Sleep -Seconds 3600")
+	if !errors.Is(err, ErrCancelled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected script to be cancelled: %v", err)
+	}
+
+	if !session.Broken() {
+		t.Errorf("Expected cancelled session to be broken")
+	}
+}
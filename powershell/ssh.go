@@ -0,0 +1,332 @@
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+var windowsDrivePathRegex = regexp.MustCompile(`^[A-Za-z]:`)
+
+// encodeCommand encodes a command for powershell -EncodedCommand, which expects base64 encoded UTF-16LE
+func encodeCommand(command string) string {
+	utf16Command := utf16.Encode([]rune(command))
+	commandBytes := make([]byte, len(utf16Command)*2)
+	for i, r := range utf16Command {
+		commandBytes[i*2] = byte(r)
+		commandBytes[i*2+1] = byte(r >> 8)
+	}
+
+	return base64.StdEncoding.EncodeToString(commandBytes)
+}
+
+// sftpPath converts a resolved windows path (C:\Temp\file) into the form expected by the OpenSSH sftp subsystem (/C:/Temp/file)
+func sftpPath(windowsPath string) string {
+	remotePath := strings.ReplaceAll(windowsPath, `\`, "/")
+	if windowsDrivePathRegex.MatchString(remotePath) {
+		remotePath = "/" + remotePath
+	}
+
+	return remotePath
+}
+
+func sshExecute(ctx context.Context, client *ssh.Client, command string, stdin string) (int, string, string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return 0, "", "", fmt.Errorf("couldn't create session: %v", err)
+	}
+	defer session.Close()
+
+	stdOutBytes := new(bytes.Buffer)
+	stdErrBytes := new(bytes.Buffer)
+	session.Stdout = stdOutBytes
+	session.Stderr = stdErrBytes
+	session.Stdin = strings.NewReader(stdin)
+
+	if os.Getenv("WINRMCP_DEBUG") != "" {
+		log.Printf("[DEBUG] Ssh execute: %s", Redact(command))
+	}
+
+	if ctx.Err() != nil {
+		return 0, "", "", cancelledError(ctx)
+	}
+
+	// Closing the session closes the channel, which stops the powershell process on the host
+	stop := context.AfterFunc(ctx, func() {
+		_ = session.Signal(ssh.SIGKILL)
+		_ = session.Close()
+	})
+	defer stop()
+
+	exitCode := 0
+	err = session.Run(command)
+	if ctx.Err() != nil {
+		return 0, "", "", cancelledError(ctx)
+	}
+
+	if err != nil {
+		var exitError *ssh.ExitError
+		if !errors.As(err, &exitError) {
+			return 0, "", "", err
+		}
+		exitCode = exitError.ExitStatus()
+	}
+
+	stdOutString := strings.TrimSpace(stdOutBytes.String())
+	stdErrString := strings.TrimSpace(stdErrBytes.String())
+
+	if os.Getenv("WINRMCP_DEBUG") != "" {
+		log.Printf("[DEBUG] Ssh execute result: exitCode=%d stdOut=%s stdErr=%s", exitCode, Redact(stdOutString), Redact(stdErrString))
+	}
+
+	return exitCode, stdOutString, stdErrString, nil
+}
+
+// RunPowershellOverSsh streams the script to a powershell process started through an ssh exec request
+func RunPowershellOverSsh(ctx context.Context, client *ssh.Client, vars string, commandText string) (exitStatus int, stdout string, stderr string, err error) {
+	var executeScriptFromStdinTemplateRendered bytes.Buffer
+	err = executeScriptFromStdinTemplate.Execute(&executeScriptFromStdinTemplateRendered, executeScriptFromStdinTemplateOptions{
+		Vars: vars,
+	})
+
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	script := executeScriptFromStdinTemplateRendered.String() + commandText
+
+	var executeEncodedCommandTemplateRendered bytes.Buffer
+	err = executeEncodedCommandTemplate.Execute(&executeEncodedCommandTemplateRendered, executeEncodedCommandTemplateOptions{
+		EncodedCommand: encodeCommand(readScriptFromStdinCommand),
+	})
+
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	command := executeEncodedCommandTemplateRendered.String()
+
+	commandExitCode, stdOutPut, errorOutPut, err := sshExecute(ctx, client, command, script)
+
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	if commandExitCode != 0 {
+		return 0, "", "", &ScriptError{ExitCode: commandExitCode, Stdout: stdOutPut, Stderr: errorOutPut}
+	}
+
+	if len(errorOutPut) > 0 {
+		return 0, "", "", &ScriptError{Stdout: stdOutPut, Stderr: errorOutPut}
+	}
+
+	return commandExitCode, stdOutPut, errorOutPut, nil
+}
+
+// ResolvePathOverSsh expands powershell variables like $env:TEMP in filePath and returns the absolute windows path
+func ResolvePathOverSsh(ctx context.Context, client *ssh.Client, filePath string) (string, error) {
+	script, err := RenderScript(resolvePathOverSshTemplate, resolvePathTemplateOptions{
+		FilePath: filePath,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	_, stdOutPut, _, err := RunPowershellOverSsh(ctx, client, "", script)
+	if err != nil {
+		return "", fmt.Errorf("resolve path operation failed: %v", err)
+	}
+
+	return stdOutPut, nil
+}
+
+func uploadOverSftp(sftpClient *sftp.Client, in io.Reader, toPath string) error {
+	remotePath := sftpPath(toPath)
+
+	err := sftpClient.MkdirAll(path.Dir(remotePath))
+	if err != nil {
+		return fmt.Errorf("error creating directory for %s: %v", toPath, err)
+	}
+
+	out, err := sftpClient.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", toPath, err)
+	}
+
+	_, err = io.Copy(out, in)
+
+	err2 := out.Close()
+
+	if err != nil {
+		return fmt.Errorf("error uploading file to %s: %v", toPath, err)
+	}
+
+	return err2
+}
+
+func UploadFileOverSsh(ctx context.Context, client *ssh.Client, filePath string, remoteFilePath string) (string, error) {
+	if remoteFilePath == "" {
+		remoteFilePath = winPath(filepath.Join(`$env:TEMP`, filepath.Base(filePath)))
+	}
+
+	remoteFilePath, err := ResolvePathOverSsh(ctx, client, strings.Trim(remoteFilePath, `'"`))
+	if err != nil {
+		return "", err
+	}
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return "", fmt.Errorf("couldn't create sftp client: %v", err)
+	}
+	defer sftpClient.Close()
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("error opening file: %s", err)
+	}
+
+	err = uploadOverSftp(sftpClient, f, remoteFilePath)
+
+	err2 := f.Close()
+
+	if err != nil {
+		return "", err
+	}
+
+	if err2 != nil {
+		return "", err2
+	}
+
+	return remoteFilePath, nil
+}
+
+func UploadDirectoryOverSsh(ctx context.Context, client *ssh.Client, rootPath string, excludeList []string) (remoteRootPath string, remoteAbsolutePaths []string, err error) {
+	sourceFilePaths, err := getFilesInDirectory(rootPath, excludeList)
+	if err != nil {
+		return "", []string{}, err
+	}
+
+	remoteRootPath, err = ResolvePathOverSsh(ctx, client, fmt.Sprintf(`$env:TEMP\%s`, filepath.Base(rootPath)))
+	if err != nil {
+		return "", []string{}, err
+	}
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return "", []string{}, fmt.Errorf("couldn't create sftp client: %v", err)
+	}
+	defer sftpClient.Close()
+
+	remoteFilePaths := []string{}
+
+	for _, sourceFilePath := range sourceFilePaths {
+		filePath, err := filepath.Rel(rootPath, sourceFilePath)
+		if err != nil {
+			return "", []string{}, err
+		}
+
+		remoteFilePath := remoteRootPath + `\` + strings.ReplaceAll(filePath, "/", `\`)
+
+		f, err := os.Open(sourceFilePath)
+		if err != nil {
+			return "", []string{}, fmt.Errorf("error opening file: %s", err)
+		}
+
+		err = uploadOverSftp(sftpClient, f, remoteFilePath)
+
+		err2 := f.Close()
+
+		if err != nil {
+			return "", []string{}, err
+		}
+
+		if err2 != nil {
+			return "", []string{}, err2
+		}
+
+		remoteFilePaths = append(remoteFilePaths, remoteFilePath)
+	}
+
+	return remoteRootPath, remoteFilePaths, nil
+}
+
+func statOverSsh(ctx context.Context, client *ssh.Client, remotePath string) (os.FileInfo, error) {
+	remotePath, err := ResolvePathOverSsh(ctx, client, remotePath)
+	if err != nil {
+		return nil, err
+	}
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create sftp client: %v", err)
+	}
+	defer sftpClient.Close()
+
+	fileInfo, err := sftpClient.Stat(sftpPath(remotePath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return fileInfo, err
+}
+
+func FileExistsOverSsh(ctx context.Context, client *ssh.Client, filePath string) (bool, error) {
+	fileInfo, err := statOverSsh(ctx, client, filePath)
+	if err != nil {
+		return false, err
+	}
+
+	return fileInfo != nil && !fileInfo.IsDir(), nil
+}
+
+func DirectoryExistsOverSsh(ctx context.Context, client *ssh.Client, directoryPath string) (bool, error) {
+	fileInfo, err := statOverSsh(ctx, client, directoryPath)
+	if err != nil {
+		return false, err
+	}
+
+	return fileInfo != nil && fileInfo.IsDir(), nil
+}
+
+func DeleteFileOrDirectoryOverSsh(ctx context.Context, client *ssh.Client, filePath string) error {
+	remotePath, err := ResolvePathOverSsh(ctx, client, filePath)
+	if err != nil {
+		return err
+	}
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return fmt.Errorf("couldn't create sftp client: %v", err)
+	}
+	defer sftpClient.Close()
+
+	_, err = sftpClient.Stat(sftpPath(remotePath))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = sftpClient.RemoveAll(sftpPath(remotePath))
+	if err != nil {
+		return fmt.Errorf("cleanup operation failed for %s: %v", remotePath, err)
+	}
+
+	return nil
+}
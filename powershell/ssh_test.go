@@ -0,0 +1,27 @@
+package powershell
+
+import (
+	"testing"
+)
+
+func TestEncodeCommand(t *testing.T) {
+	encodedCommand := encodeCommand("dir")
+
+	if encodedCommand != "ZABpAHIA" {
+		t.Errorf("Encoded command not as expected: %s", encodedCommand)
+	}
+}
+
+func TestSftpPath(t *testing.T) {
+	testCases := map[string]string{
+		`C:\Users\Administrator\AppData\Local\Temp\test.iso`: `/C:/Users/Administrator/AppData/Local/Temp/test.iso`,
+		`d:\vms`:    `/d:/vms`,
+		`/tmp/test`: `/tmp/test`,
+	}
+
+	for windowsPath, expected := range testCases {
+		if actual := sftpPath(windowsPath); actual != expected {
+			t.Errorf("Sftp path for %s not as expected: %s", windowsPath, actual)
+		}
+	}
+}
@@ -28,7 +28,7 @@ var executeCommandTemplate = template.Must(template.New("ExecuteCommand").Parse(
 
 type elevatedCommandTemplateOptions struct {
 	User                   string
-	Password               string
+	Password               string `sensitive:"true"`
 	TaskName               string
 	TaskDescription        string
 	TaskExecutionTimeLimit string
@@ -167,6 +167,45 @@ $exitCode = RunAsScheduledTask -username $username -password $password -taskName
 exit $exitCode;
 `))
 
+type removeCommandLeftoversTemplateOptions struct {
+	TaskName string
+	Paths    []string
+}
+
+var removeCommandLeftoversTemplate = template.Must(template.New("RemoveCommandLeftovers").Funcs(template.FuncMap{
+	"escapeSingleQuotes": func(textToEscape string) string {
+		return strings.ReplaceAll(textToEscape, `'`, `''`)
+	},
+}).Parse(`
+if (Test-Path variable:global:ProgressPreference) {
+	$ProgressPreference='SilentlyContinue';
+};
+$taskName = '{{escapeSingleQuotes .TaskName}}';
+$paths = @({{range $i, $path := .Paths}}{{if $i}}, {{end}}'{{escapeSingleQuotes $path}}'{{end}});
+if ($taskName) {
+	$schedule = New-Object -ComObject 'Schedule.Service';
+	$schedule.Connect();
+	$folder = $schedule.GetFolder('\');
+	$registeredTask = $null;
+	try {
+		$registeredTask = $folder.GetTask('\' + $taskName);
+	} catch {
+	};
+	if ($registeredTask) {
+		$registeredTask.Stop(0) | Out-Null;
+		$folder.DeleteTask($taskName, 0) | Out-Null;
+	};
+	[System.Runtime.Interopservices.Marshal]::ReleaseComObject($schedule) | Out-Null;
+	$paths += Join-Path -Path $env:TEMP -ChildPath ($taskName + '_stdout.log');
+};
+foreach ($path in $paths) {
+	if (Test-Path -LiteralPath $path) {
+		Remove-Item -LiteralPath $path -Force -Recurse -ErrorAction SilentlyContinue | Out-Null;
+	};
+};
+exit 0;
+`))
+
 type convertBase64FileToTextFileTemplateOptions struct {
 	Base64FilePath string
 	FilePath       string
@@ -240,6 +279,53 @@ type deleteFileTemplateOptions struct {
 
 var deleteFileTemplate = template.Must(template.New("DeleteFile").Parse(`if (Test-Path variable:global:ProgressPreference){$ProgressPreference='SilentlyContinue';};if (Test-Path -Path $ExecutionContext.InvokeCommand.ExpandString("{{.FilePath}}")) {Remove-Item -Path $ExecutionContext.InvokeCommand.ExpandString("{{.FilePath}}") -Force -Recurse -ErrorAction SilentlyContinue;};exit $LastExitCode;`))
 
+type createDirectoryTemplateOptions struct {
+	DirectoryPath string
+}
+
+var createDirectoryTemplate = template.Must(template.New("CreateDirectory").Parse(`if (Test-Path variable:global:ProgressPreference){$ProgressPreference='SilentlyContinue';};New-Item -ItemType Directory -Force -Path $ExecutionContext.InvokeCommand.ExpandString("{{.DirectoryPath}}") | Out-Null;exit $LastExitCode;`))
+
+type assembleUploadPartsTemplateOptions struct {
+	StagingPath string
+	FilePath    string
+}
+
+// The parts are named after their offset, so sorting them by name puts them back in order
+var assembleUploadPartsTemplate = template.Must(template.New("AssembleUploadParts").Parse(`
+if (Test-Path variable:global:ProgressPreference) {
+	$ProgressPreference='SilentlyContinue';
+};
+$stagingPath = [System.IO.Path]::GetFullPath("{{.StagingPath}}".Trim("'"));
+$filePath = [System.IO.Path]::GetFullPath("{{.FilePath}}".Trim("'"));
+if (Test-Path -Path $filePath -PathType container) {
+	Exit 1;
+};
+New-Item -ItemType directory -Force -ErrorAction SilentlyContinue -Path ([System.IO.Path]::GetDirectoryName($filePath)) | Out-Null;
+$writer = [System.IO.File]::Create($filePath);
+try {
+	foreach ($part in @(Get-ChildItem -Path $stagingPath -Filter 'part-*.b64' | Sort-Object Name)) {
+		$reader = [System.IO.File]::OpenText($part.FullName);
+		try {
+			for(;;) {
+				$base64_line = $reader.ReadLine();
+				if ($base64_line -eq $null) {
+					break;
+				};
+				$bytes = [System.Convert]::FromBase64String($base64_line);
+				$writer.Write($bytes, 0, $bytes.Length);
+			};
+		} finally {
+			$reader.Close();
+		};
+	};
+} finally {
+	$writer.Close();
+};
+$filePath;
+(Get-FileHash -Path $filePath -Algorithm SHA256).Hash;
+exit $LastExitCode;
+`))
+
 type appendFileTemplateOptions struct {
 	FilePath string
 	Content  string
@@ -247,3 +333,69 @@ type appendFileTemplateOptions struct {
 
 // This is not a Powershell script
 var appendFileTemplate = template.Must(template.New("AppendFile").Parse(`echo {{.Content}} >> "{{.FilePath}}"`))
+
+type executeEncodedCommandTemplateOptions struct {
+	EncodedCommand string
+}
+
+// The script itself is streamed over stdin so that it is not limited by the maximum command line length
+var executeEncodedCommandTemplate = template.Must(template.New("ExecuteEncodedCommand").Parse(`powershell -NoLogo -NoProfile -NonInteractive -ExecutionPolicy Bypass -EncodedCommand {{.EncodedCommand}}`))
+
+const readScriptFromStdinCommand = `$script = [Console]::In.ReadToEnd();& ([ScriptBlock]::Create($script));exit $LastExitCode;`
+
+type executeScriptFromStdinTemplateOptions struct {
+	Vars string
+}
+
+var executeScriptFromStdinTemplate = template.Must(template.New("ExecuteScriptFromStdin").Parse(`if (Test-Path variable:global:ProgressPreference){$ProgressPreference='SilentlyContinue';};{{.Vars}};
+`))
+
+var resolvePathOverSshTemplate = template.Must(template.New("ResolvePathOverSsh").Parse(`[System.IO.Path]::GetFullPath($ExecutionContext.InvokeCommand.ExpandString($arguments.FilePath))`))
+
+// The session host is started with a tiny bootstrap that reads the session loop from the first line of stdin, so the
+// loop itself is not limited by the maximum command line length
+const readSessionFromStdinCommand = `$session = [Console]::In.ReadLine();& ([ScriptBlock]::Create([System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String($session))));exit $LastExitCode;`
+
+type sessionTemplateOptions struct {
+	Vars   string
+	Marker string
+}
+
+// Each line received on stdin is a base64 encoded script. Its output is written back as a single line made up of the
+// marker and a base64 encoded json frame, anything else written to the console is treated as plain stdout.
+var sessionTemplate = template.Must(template.New("Session").Parse(`
+if (Test-Path variable:global:ProgressPreference){$ProgressPreference='SilentlyContinue';};{{.Vars}};
+Import-Module Hyper-V -ErrorAction SilentlyContinue | Out-Null;
+while ($true) {
+	$line = [Console]::In.ReadLine();
+	if ($line -eq $null) {
+		break;
+	}
+
+	$script = [System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String($line));
+	$stdout = New-Object System.Text.StringBuilder;
+	$stderr = New-Object System.Text.StringBuilder;
+	$exitCode = 0;
+	$global:LastExitCode = 0;
+
+	try {
+		& ([ScriptBlock]::Create($script)) 2>&1 6>&1 | %{
+			if ($_ -is [System.Management.Automation.ErrorRecord]) {
+				[void]$stderr.AppendLine(($_ | Out-String).TrimEnd());
+			} else {
+				[void]$stdout.AppendLine(($_ | Out-String).TrimEnd());
+			}
+		};
+		if ($global:LastExitCode) {
+			$exitCode = $global:LastExitCode;
+		}
+	} catch {
+		[void]$stderr.AppendLine(($_ | Out-String).TrimEnd());
+		$exitCode = 1;
+	}
+
+	$frame = ConvertTo-Json -Compress -InputObject @{ExitCode=$exitCode;Stdout=$stdout.ToString();Stderr=$stderr.ToString()};
+	[Console]::Out.WriteLine('{{.Marker}} ' + [System.Convert]::ToBase64String([System.Text.Encoding]::UTF8.GetBytes($frame)));
+	[Console]::Out.Flush();
+}
+`))
@@ -2,6 +2,7 @@ package powershell
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -23,3 +24,29 @@ func TestEscapeQuotesOfCommandLineTemplate(t *testing.T) {
 		t.Errorf("Command line template output not as expected: %s", err.Error())
 	}
 }
+
+func TestRemoveCommandLeftoversTemplate(t *testing.T) {
+	var removeCommandLeftoversTemplateRendered bytes.Buffer
+	err := removeCommandLeftoversTemplate.Execute(&removeCommandLeftoversTemplateRendered, removeCommandLeftoversTemplateOptions{
+		TaskName: "terraform-o'brien",
+		Paths:    []string{`C:\Temp\shell-terraform-1.ps1`, `C:\Temp\elevated-shell-terraform-1.ps1`},
+	})
+
+	if err != nil {
+		t.Fatalf("Unable to render remove command leftovers template: %s", err.Error())
+	}
+
+	script := removeCommandLeftoversTemplateRendered.String()
+
+	if !strings.Contains(script, `$taskName = 'terraform-o''brien';`) {
+		t.Errorf("Task name not escaped: %s", script)
+	}
+
+	if !strings.Contains(script, `$paths = @('C:\Temp\shell-terraform-1.ps1', 'C:\Temp\elevated-shell-terraform-1.ps1');`) {
+		t.Errorf("Paths not rendered: %s", script)
+	}
+
+	if strings.Contains(script, `"`) {
+		t.Errorf("Script is run from the command line so it should not contain double quotes: %s", script)
+	}
+}
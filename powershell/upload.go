@@ -0,0 +1,516 @@
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/masterzen/winrm"
+)
+
+const (
+	// DefaultUploadPartSize is the number of bytes of a file uploaded by one stream before it moves on to another part
+	DefaultUploadPartSize = 4 * 1024 * 1024
+
+	// DefaultUploadParallelism is the number of parts of a file uploaded at once
+	DefaultUploadParallelism = 4
+)
+
+type UploadOptions struct {
+	// PartSize is the number of bytes of a file uploaded by one stream before it moves on to another part
+	PartSize int64
+
+	// Parallelism is the number of parts uploaded at once, each over its own client
+	Parallelism int
+
+	// JournalDirectory is where the offsets of uploaded parts are recorded, so an interrupted upload can resume
+	JournalDirectory string
+}
+
+func (o UploadOptions) withDefaults() UploadOptions {
+	if o.PartSize <= 0 {
+		o.PartSize = DefaultUploadPartSize
+	}
+
+	if o.Parallelism <= 0 {
+		o.Parallelism = DefaultUploadParallelism
+	}
+
+	if o.JournalDirectory == "" {
+		o.JournalDirectory = filepath.Join(os.TempDir(), "terraform-provider-hyperv-uploads")
+	}
+
+	return o
+}
+
+// BorrowClientFunc borrows a winrm client for uploading one part of a file. release must be called once the part has
+// been uploaded.
+type BorrowClientFunc func(ctx context.Context) (client *winrm.Client, release func(), err error)
+
+// uploadTarget is the host side of an upload
+type uploadTarget interface {
+	resolvePath(ctx context.Context, filePath string) (string, error)
+	directoryExists(ctx context.Context, directoryPath string) (bool, error)
+	createDirectory(ctx context.Context, directoryPath string) error
+	uploadPart(ctx context.Context, partPath string, content []byte) error
+	assembleParts(ctx context.Context, stagingPath string, filePath string) (remoteAbsolutePath string, remoteSha256 string, err error)
+	delete(ctx context.Context, remotePath string) error
+}
+
+// uploadJournal records which parts of a file have made it to the staging directory on the host
+type uploadJournal struct {
+	mutex sync.Mutex
+	path  string
+
+	RemoteFilePath string
+	StagingPath    string
+	Sha256         string
+	Size           int64
+	PartSize       int64
+	Offsets        []int64
+}
+
+func (j *uploadJournal) save() error {
+	journalJson, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(j.path), 0700)
+	if err != nil {
+		return err
+	}
+
+	// Write then rename, so an interrupted write never leaves a corrupt journal behind
+	err = os.WriteFile(j.path+".tmp", journalJson, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(j.path+".tmp", j.path)
+}
+
+func (j *uploadJournal) record(offset int64) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.Offsets = append(j.Offsets, offset)
+	return j.save()
+}
+
+func (j *uploadJournal) uploaded(offset int64) bool {
+	for _, uploadedOffset := range j.Offsets {
+		if uploadedOffset == offset {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (j *uploadJournal) remove() {
+	err := os.Remove(j.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("[DEBUG] Unable to remove upload journal %s: %s", j.path, err)
+	}
+}
+
+func fileSha256(f *os.File) (string, int64, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+func partPath(stagingPath string, offset int64) string {
+	return fmt.Sprintf(`%s\part-%020d.b64`, stagingPath, offset)
+}
+
+// loadUploadJournal returns the journal of an earlier attempt at uploading the same file to the same place, or a new
+// journal if there was none.
+func loadUploadJournal(ctx context.Context, target uploadTarget, journalDirectory string, localSha256 string, size int64, partSize int64, remoteFilePath string) (*uploadJournal, error) {
+	key := sha256Hex(localSha256 + "\n" + remoteFilePath)[:32]
+	journal := &uploadJournal{
+		path:           filepath.Join(journalDirectory, key+".json"),
+		RemoteFilePath: remoteFilePath,
+		Sha256:         localSha256,
+		Size:           size,
+		PartSize:       partSize,
+	}
+
+	journalJson, err := os.ReadFile(journal.path)
+	if err == nil {
+		previousJournal := uploadJournal{}
+		if json.Unmarshal(journalJson, &previousJournal) == nil &&
+			previousJournal.Sha256 == localSha256 &&
+			previousJournal.Size == size &&
+			previousJournal.PartSize == partSize &&
+			previousJournal.StagingPath != "" {
+			stagingPathExists, err := target.directoryExists(ctx, previousJournal.StagingPath)
+			if err != nil {
+				return nil, err
+			}
+
+			if stagingPathExists {
+				journal.StagingPath = previousJournal.StagingPath
+				journal.Offsets = previousJournal.Offsets
+				return journal, nil
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	journal.StagingPath, err = target.resolvePath(ctx, fmt.Sprintf(`$env:TEMP\%supload-%s`, TemporaryItemPrefix, key))
+	if err != nil {
+		return nil, err
+	}
+
+	err = target.createDirectory(ctx, journal.StagingPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return journal, journal.save()
+}
+
+func sha256Hex(text string) string {
+	hash := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(hash[:])
+}
+
+func uploadFile(ctx context.Context, target uploadTarget, filePath string, remoteFilePath string, options UploadOptions) (string, error) {
+	options = options.withDefaults()
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("error opening file: %s", err)
+	}
+	defer f.Close()
+
+	localSha256, size, err := fileSha256(f)
+	if err != nil {
+		return "", fmt.Errorf("error hashing file %s: %s", filePath, err)
+	}
+
+	journal, err := loadUploadJournal(ctx, target, options.JournalDirectory, localSha256, size, options.PartSize, remoteFilePath)
+	if err != nil {
+		return "", fmt.Errorf("error preparing upload of %s to %s: %v", filePath, remoteFilePath, err)
+	}
+
+	offsets := []int64{}
+	for offset := int64(0); offset < size; offset += options.PartSize {
+		if !journal.uploaded(offset) {
+			offsets = append(offsets, offset)
+		}
+	}
+
+	partCount := (size + options.PartSize - 1) / options.PartSize
+	if len(offsets) < int(partCount) {
+		log.Printf("[INFO] Resuming upload of %s to %s, %d of %d parts already uploaded", filePath, remoteFilePath, int(partCount)-len(offsets), partCount)
+	}
+
+	uploadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pending := make(chan int64, len(offsets))
+	for _, offset := range offsets {
+		pending <- offset
+	}
+	close(pending)
+
+	errs := make(chan error, options.Parallelism)
+	waitGroup := sync.WaitGroup{}
+	for i := 0; i < options.Parallelism; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+
+			for offset := range pending {
+				if uploadCtx.Err() != nil {
+					return
+				}
+
+				content := make([]byte, options.PartSize)
+				n, err := f.ReadAt(content, offset)
+				if err != nil && err != io.EOF {
+					errs <- err
+					cancel()
+					return
+				}
+
+				err = target.uploadPart(uploadCtx, partPath(journal.StagingPath, offset), content[:n])
+				if err == nil {
+					err = journal.record(offset)
+				}
+
+				if err != nil {
+					errs <- fmt.Errorf("error uploading part at offset %d: %v", offset, err)
+					cancel()
+					return
+				}
+
+				journal.mutex.Lock()
+				uploadedParts := len(journal.Offsets)
+				journal.mutex.Unlock()
+				log.Printf("[INFO] Uploaded %d of %d parts of %s to %s", uploadedParts, partCount, filePath, remoteFilePath)
+			}
+		}()
+	}
+
+	waitGroup.Wait()
+	close(errs)
+
+	if err = <-errs; err != nil {
+		return "", fmt.Errorf("error uploading %s to %s, it will resume from the last uploaded part: %v", filePath, remoteFilePath, err)
+	}
+
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+
+	remoteAbsolutePath, remoteSha256, err := target.assembleParts(ctx, journal.StagingPath, remoteFilePath)
+	if err != nil {
+		return "", fmt.Errorf("error restoring file from %s to %s: %v", journal.StagingPath, remoteFilePath, err)
+	}
+
+	// The parts are only useful until the file has been assembled, so start from scratch if it doesn't match
+	journal.remove()
+	err = target.delete(ctx, journal.StagingPath)
+	if err != nil {
+		return "", fmt.Errorf("error removing temporary directory %s: %v", journal.StagingPath, err)
+	}
+
+	if !strings.EqualFold(remoteSha256, localSha256) {
+		err = target.delete(ctx, remoteAbsolutePath)
+		if err != nil {
+			log.Printf("[DEBUG] Unable to remove corrupt upload %s: %s", remoteAbsolutePath, err)
+		}
+
+		return "", fmt.Errorf("error uploading %s to %s: expected sha256 %s but was %s", filePath, remoteAbsolutePath, localSha256, remoteSha256)
+	}
+
+	return remoteAbsolutePath, nil
+}
+
+func uploadDirectory(ctx context.Context, target uploadTarget, rootPath string, excludeList []string, options UploadOptions) (remoteRootPath string, remoteAbsolutePaths []string, err error) {
+	sourceFilePaths, err := getFilesInDirectory(rootPath, excludeList)
+	if err != nil {
+		return "", []string{}, err
+	}
+
+	sort.Strings(sourceFilePaths)
+
+	remoteRootPath = filepath.Join(`$env:TEMP`, filepath.Base(rootPath))
+	remoteFilePaths := []string{}
+
+	for _, sourceFilePath := range sourceFilePaths {
+		filePath, err := filepath.Rel(rootPath, sourceFilePath)
+		if err != nil {
+			return "", []string{}, err
+		}
+
+		remoteFilePath, err := uploadFile(ctx, target, sourceFilePath, winPath(filepath.Join(remoteRootPath, filePath)), options)
+		if err != nil {
+			return "", []string{}, err
+		}
+
+		remoteFilePaths = append(remoteFilePaths, remoteFilePath)
+	}
+
+	return remoteRootPath, remoteFilePaths, nil
+}
+
+// UploadFile uploads filePath to remoteFilePath in parts, spread over clients borrowed with borrowClient. Uploaded parts
+// are journaled so that an interrupted upload carries on where it stopped, and the SHA-256 of the uploaded file is
+// checked against the local file.
+func UploadFile(ctx context.Context, borrowClient BorrowClientFunc, filePath string, remoteFilePath string, options UploadOptions) (string, error) {
+	if remoteFilePath == "" {
+		remoteFilePath = winPath(filepath.Join(`$env:TEMP`, filepath.Base(filePath)))
+	}
+
+	return uploadFile(ctx, &winrmUploadTarget{borrowClient: borrowClient}, filePath, remoteFilePath, options)
+}
+
+// UploadDirectory uploads every file under rootPath to $env:TEMP the way UploadFile does
+func UploadDirectory(ctx context.Context, borrowClient BorrowClientFunc, rootPath string, excludeList []string, options UploadOptions) (remoteRootPath string, remoteAbsolutePaths []string, err error) {
+	return uploadDirectory(ctx, &winrmUploadTarget{borrowClient: borrowClient}, rootPath, excludeList, options)
+}
+
+type winrmUploadTarget struct {
+	borrowClient BorrowClientFunc
+}
+
+func (t *winrmUploadTarget) withClient(ctx context.Context, f func(client *winrm.Client) error) error {
+	client, release, err := t.borrowClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return f(client)
+}
+
+func (t *winrmUploadTarget) resolvePath(ctx context.Context, filePath string) (resolvedPath string, err error) {
+	err = t.withClient(ctx, func(client *winrm.Client) error {
+		resolvedPath, err = ResolvePath(ctx, client, filePath)
+		return err
+	})
+
+	return resolvedPath, err
+}
+
+func (t *winrmUploadTarget) directoryExists(ctx context.Context, directoryPath string) (exists bool, err error) {
+	err = t.withClient(ctx, func(client *winrm.Client) error {
+		exists, err = DirectoryExists(ctx, client, directoryPath)
+		return err
+	})
+
+	return exists, err
+}
+
+func (t *winrmUploadTarget) createDirectory(ctx context.Context, directoryPath string) error {
+	return t.withClient(ctx, func(client *winrm.Client) error {
+		return createDirectory(ctx, client, directoryPath)
+	})
+}
+
+func (t *winrmUploadTarget) uploadPart(ctx context.Context, partPath string, content []byte) error {
+	return t.withClient(ctx, func(client *winrm.Client) error {
+		// A part that was only partly uploaded before an interruption has to start again
+		err := DeleteFileOrDirectory(ctx, client, partPath)
+		if err != nil {
+			return err
+		}
+
+		return uploadContent(ctx, client, 15, bytes.NewReader(content), partPath)
+	})
+}
+
+func (t *winrmUploadTarget) assembleParts(ctx context.Context, stagingPath string, filePath string) (remoteAbsolutePath string, remoteSha256 string, err error) {
+	err = t.withClient(ctx, func(client *winrm.Client) error {
+		remoteAbsolutePath, remoteSha256, err = assembleUploadParts(ctx, client, stagingPath, filePath)
+		return err
+	})
+
+	return remoteAbsolutePath, remoteSha256, err
+}
+
+func (t *winrmUploadTarget) delete(ctx context.Context, remotePath string) error {
+	return t.withClient(ctx, func(client *winrm.Client) error {
+		return DeleteFileOrDirectory(ctx, client, remotePath)
+	})
+}
+
+func createDirectory(ctx context.Context, client *winrm.Client, directoryPath string) error {
+	shell, err := client.CreateShell()
+	if err != nil {
+		return err
+	}
+	defer shell.Close()
+
+	var createDirectoryTemplateRendered bytes.Buffer
+	err = createDirectoryTemplate.Execute(&createDirectoryTemplateRendered, createDirectoryTemplateOptions{
+		DirectoryPath: directoryPath,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	script := createDirectoryTemplateRendered.String()
+
+	var executePowershellFromCommandLineTemplateRendered bytes.Buffer
+	err = executePowershellFromCommandLineTemplate.Execute(&executePowershellFromCommandLineTemplateRendered, executePowershellFromCommandLineTemplateOptions{
+		Powershell: script,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	script = executePowershellFromCommandLineTemplateRendered.String()
+
+	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(ctx, shell, script)
+
+	if err != nil {
+		return err
+	}
+
+	if commandExitCode != 0 {
+		return fmt.Errorf("create directory operation returned code=%d\nstderr:\n%s\nstdOut:\n%s", commandExitCode, errorOutPut, stdOutPut)
+	}
+
+	if len(errorOutPut) > 0 {
+		return fmt.Errorf("create directory operation returned \nstderr:\n%s\nstdOut:\n%s", errorOutPut, stdOutPut)
+	}
+
+	return nil
+}
+
+func assembleUploadParts(ctx context.Context, client *winrm.Client, stagingPath string, filePath string) (remoteAbsolutePath string, remoteSha256 string, err error) {
+	shell, err := client.CreateShell()
+	if err != nil {
+		return "", "", err
+	}
+	defer shell.Close()
+
+	var assembleUploadPartsTemplateRendered bytes.Buffer
+	err = assembleUploadPartsTemplate.Execute(&assembleUploadPartsTemplateRendered, assembleUploadPartsTemplateOptions{
+		StagingPath: stagingPath,
+		FilePath:    filePath,
+	})
+
+	if err != nil {
+		return "", "", err
+	}
+
+	script := assembleUploadPartsTemplateRendered.String()
+
+	var executePowershellFromCommandLineTemplateRendered bytes.Buffer
+	err = executePowershellFromCommandLineTemplate.Execute(&executePowershellFromCommandLineTemplateRendered, executePowershellFromCommandLineTemplateOptions{
+		Powershell: script,
+	})
+
+	if err != nil {
+		return "", "", err
+	}
+
+	script = executePowershellFromCommandLineTemplateRendered.String()
+
+	commandExitCode, stdOutPut, errorOutPut, err := shellExecute(ctx, shell, script)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	if commandExitCode != 0 {
+		return "", "", fmt.Errorf("assemble operation returned code=%d\nstderr:\n%s\nstdOut:\n%s", commandExitCode, errorOutPut, stdOutPut)
+	}
+
+	if len(errorOutPut) > 0 {
+		return "", "", fmt.Errorf("assemble operation returned \nstderr:\n%s\nstdOut:\n%s", errorOutPut, stdOutPut)
+	}
+
+	// The script writes the path of the file followed by its hash
+	lines := strings.Split(stdOutPut, "\n")
+	if len(lines) < 2 {
+		return "", "", fmt.Errorf("assemble operation returned unexpected output:\n%s", stdOutPut)
+	}
+
+	return strings.TrimSpace(lines[len(lines)-2]), strings.TrimSpace(lines[len(lines)-1]), nil
+}
@@ -0,0 +1,220 @@
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeUploadTarget keeps uploaded parts in memory. It fails uploading parts once failAfterParts parts have been
+// uploaded, and corrupts the assembled file when corrupt is set.
+type fakeUploadTarget struct {
+	mutex          sync.Mutex
+	files          map[string][]byte
+	directories    map[string]bool
+	uploadedParts  int
+	failAfterParts int
+	corrupt        bool
+}
+
+func newFakeUploadTarget() *fakeUploadTarget {
+	return &fakeUploadTarget{
+		files:          map[string][]byte{},
+		directories:    map[string]bool{},
+		failAfterParts: -1,
+	}
+}
+
+func (t *fakeUploadTarget) resolvePath(ctx context.Context, filePath string) (string, error) {
+	return strings.ReplaceAll(filePath, `$env:TEMP`, `C:\Temp`), nil
+}
+
+func (t *fakeUploadTarget) directoryExists(ctx context.Context, directoryPath string) (bool, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.directories[directoryPath], nil
+}
+
+func (t *fakeUploadTarget) createDirectory(ctx context.Context, directoryPath string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.directories[directoryPath] = true
+	return nil
+}
+
+func (t *fakeUploadTarget) uploadPart(ctx context.Context, partPath string, content []byte) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.failAfterParts >= 0 && t.uploadedParts >= t.failAfterParts {
+		return errors.New("connection reset by peer")
+	}
+
+	t.uploadedParts++
+	t.files[partPath] = append([]byte{}, content...)
+	return nil
+}
+
+func (t *fakeUploadTarget) assembleParts(ctx context.Context, stagingPath string, filePath string) (string, string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	partPaths := []string{}
+	for path := range t.files {
+		if strings.HasPrefix(path, stagingPath+`\part-`) {
+			partPaths = append(partPaths, path)
+		}
+	}
+	sort.Strings(partPaths)
+
+	content := []byte{}
+	for _, partPath := range partPaths {
+		content = append(content, t.files[partPath]...)
+	}
+
+	if t.corrupt {
+		content = append(content, 0)
+	}
+
+	filePath, _ = t.resolvePath(ctx, filePath)
+	t.files[filePath] = content
+
+	hash := sha256.Sum256(content)
+	return filePath, strings.ToUpper(hex.EncodeToString(hash[:])), nil
+}
+
+func (t *fakeUploadTarget) delete(ctx context.Context, remotePath string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.directories, remotePath)
+	for path := range t.files {
+		if path == remotePath || strings.HasPrefix(path, remotePath+`\`) {
+			delete(t.files, path)
+		}
+	}
+
+	return nil
+}
+
+func writeTestFile(t *testing.T, size int) (string, []byte) {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "source.iso")
+	err := os.WriteFile(filePath, content, 0600)
+	if err != nil {
+		t.Fatalf("Unable to write test file: %s", err.Error())
+	}
+
+	return filePath, content
+}
+
+func TestUploadFileInParallelParts(t *testing.T) {
+	filePath, content := writeTestFile(t, 1000)
+	target := newFakeUploadTarget()
+	options := UploadOptions{PartSize: 64, Parallelism: 3, JournalDirectory: t.TempDir()}
+
+	remoteFilePath, err := uploadFile(context.Background(), target, filePath, `$env:TEMP\source.iso`, options)
+	if err != nil {
+		t.Fatalf("Unable to upload file: %s", err.Error())
+	}
+
+	if remoteFilePath != `C:\Temp\source.iso` || !bytes.Equal(target.files[remoteFilePath], content) {
+		t.Errorf("Uploaded file does not match: %s", remoteFilePath)
+	}
+
+	if len(target.files) != 1 || len(target.directories) != 0 {
+		t.Errorf("Expected staging directory to be removed: %#v", target.directories)
+	}
+
+	journals, _ := os.ReadDir(options.JournalDirectory)
+	if len(journals) != 0 {
+		t.Errorf("Expected journal to be removed after upload: %#v", journals)
+	}
+}
+
+func TestUploadFileResumesFromJournal(t *testing.T) {
+	filePath, content := writeTestFile(t, 1000)
+	target := newFakeUploadTarget()
+	target.failAfterParts = 5
+	options := UploadOptions{PartSize: 64, Parallelism: 1, JournalDirectory: t.TempDir()}
+
+	_, err := uploadFile(context.Background(), target, filePath, `$env:TEMP\source.iso`, options)
+	if err == nil || !strings.Contains(err.Error(), "connection reset by peer") {
+		t.Fatalf("Expected interrupted upload to fail: %v", err)
+	}
+
+	target.failAfterParts = -1
+	remoteFilePath, err := uploadFile(context.Background(), target, filePath, `$env:TEMP\source.iso`, options)
+	if err != nil {
+		t.Fatalf("Unable to resume upload: %s", err.Error())
+	}
+
+	if !bytes.Equal(target.files[remoteFilePath], content) {
+		t.Errorf("Resumed file does not match")
+	}
+
+	if target.uploadedParts != 16 {
+		t.Errorf("Expected only the missing parts to be uploaded again, uploaded %d parts", target.uploadedParts)
+	}
+}
+
+func TestUploadFileVerifiesChecksum(t *testing.T) {
+	filePath, _ := writeTestFile(t, 100)
+	target := newFakeUploadTarget()
+	target.corrupt = true
+	options := UploadOptions{PartSize: 64, Parallelism: 2, JournalDirectory: t.TempDir()}
+
+	_, err := uploadFile(context.Background(), target, filePath, `$env:TEMP\source.iso`, options)
+	if err == nil || !strings.Contains(err.Error(), "expected sha256") {
+		t.Fatalf("Expected checksum mismatch: %v", err)
+	}
+
+	if len(target.files) != 0 {
+		t.Errorf("Expected corrupt file to be removed: %#v", target.files)
+	}
+}
+
+func TestUploadDirectory(t *testing.T) {
+	rootPath := filepath.Join(t.TempDir(), "bootstrap")
+	err := os.MkdirAll(filepath.Join(rootPath, "nested"), 0700)
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err.Error())
+	}
+
+	for _, name := range []string{"a.ps1", filepath.Join("nested", "b.ps1")} {
+		err = os.WriteFile(filepath.Join(rootPath, name), []byte(name), 0600)
+		if err != nil {
+			t.Fatalf("Unable to write file: %s", err.Error())
+		}
+	}
+
+	target := newFakeUploadTarget()
+	remoteRootPath, remoteFilePaths, err := uploadDirectory(context.Background(), target, rootPath, []string{}, UploadOptions{JournalDirectory: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Unable to upload directory: %s", err.Error())
+	}
+
+	if remoteRootPath != filepath.Join(`$env:TEMP`, "bootstrap") || len(remoteFilePaths) != 2 {
+		t.Errorf("Unexpected remote paths: %s %#v", remoteRootPath, remoteFilePaths)
+	}
+
+	for _, remoteFilePath := range remoteFilePaths {
+		if _, ok := target.files[remoteFilePath]; !ok {
+			t.Errorf("Expected %s to be uploaded", remoteFilePath)
+		}
+	}
+}